@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/accounts"
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const adminUsage = `usage: chirpy admin [flags] <command> [args]
+
+Users are given by ID or email. Passwords are read from CHIRPY_PASSWORD or the
+first line of stdin.
+
+commands:
+  show-user <user>
+  create-user <email>
+  disable-user <user>         stop the user logging in and revoke their sessions
+  enable-user <user>
+  delete-user [-yes] <user>   delete the user and everything they own
+  reset-password <user>       set a new password and revoke the user's sessions
+  grant-red <user>
+  revoke-red <user>
+  sessions <user>             list the user's sessions
+  revoke-sessions <user> [session]
+                              revoke one session, or all of them
+  purge-chirps [-yes] <user>  delete every chirp of the user
+  set-role -reason <why> <user> <role>
+                              make the user a user, moderator or admin
+  role-changes <user>         list the changes of the user's role`
+
+// runAdmin implements the admin subcommand, which manages users directly in
+// the database. Like migrate, it only needs the database URL.
+func runAdmin(args []string) {
+    cfg, rest, err := config.Parse(args, os.Getenv)
+    if err != nil {
+        log.Fatalf("invalid configuration: %s", err)
+    }
+    if len(rest) == 0 {
+        log.Fatal(adminUsage)
+    }
+    if cfg.DBURL == "" {
+        log.Fatal("DB_URL must be set")
+    }
+
+    db, dialect, err := store.Open(cfg.DBURL)
+    if err != nil {
+        log.Fatalf("failed to connect to database: %s", err)
+    }
+    defer db.Close()
+
+    s := store.NewDB(db, dialect, nil)
+    a := admin{
+        accounts: accounts.New(s, s, webhooks.NewDispatcher(s), audit.NewLog(s)),
+        getenv: os.Getenv,
+        stdin: bufio.NewReader(os.Stdin),
+        stdout: os.Stdout,
+    }
+    if err := a.run(context.Background(), rest); err != nil {
+        db.Close()
+        log.Fatal(err)
+    }
+}
+
+var adminCommands = []string{
+    "show-user", "create-user", "disable-user", "enable-user", "delete-user", "reset-password",
+    "grant-red", "revoke-red", "sessions", "revoke-sessions", "purge-chirps", "set-role", "role-changes",
+}
+
+type admin struct {
+    accounts accounts.Service
+    getenv func(string) string
+    stdin *bufio.Reader
+    stdout io.Writer
+}
+
+func (a admin) run(ctx context.Context, args []string) error {
+    name, args := args[0], args[1:]
+    if !slices.Contains(adminCommands, name) {
+        return fmt.Errorf("unknown admin command %q\n%s", name, adminUsage)
+    }
+    fs := flag.NewFlagSet("chirpy admin " + name, flag.ContinueOnError)
+    fs.SetOutput(io.Discard)
+    yes := fs.Bool("yes", false, "don't ask for confirmation")
+    reason := fs.String("reason", "", "why the role is changing")
+    if err := fs.Parse(args); err != nil {
+        return fmt.Errorf("%s\n%s", err, adminUsage)
+    }
+    args = fs.Args()
+
+    want := 1
+    if name == "revoke-sessions" && len(args) == 2 || name == "set-role" {
+        want = 2
+    }
+    if len(args) != want {
+        return fmt.Errorf("chirpy admin %s takes a user\n%s", name, adminUsage)
+    }
+
+    if name == "create-user" {
+        password, err := a.password()
+        if err != nil {
+            return err
+        }
+        user, err := a.accounts.CreateUser(ctx, args[0], password)
+        if err != nil {
+            return err
+        }
+        a.printUser(user)
+        return nil
+    }
+
+    user, err := a.accounts.Lookup(ctx, args[0])
+    if err != nil {
+        return err
+    }
+
+    if name == "set-role" {
+        role, err := auth.ParseRole(args[1])
+        if err != nil {
+            return err
+        }
+        // Changes made here aren't made by any user, so they're logged
+        // without one.
+        change, err := a.accounts.SetRole(ctx, user.ID, role, uuid.Nil, *reason)
+        if err != nil {
+            return err
+        }
+        fmt.Fprintf(a.stdout, "changed the role of %s from %s to %s\n", user.Email, change.OldRole, change.NewRole)
+        return nil
+    }
+
+    switch name {
+    case "show-user":
+        a.printUser(user)
+    case "disable-user":
+        user, err = a.accounts.Disable(ctx, user.ID)
+        if err == nil {
+            a.printUser(user)
+        }
+    case "enable-user":
+        user, err = a.accounts.Enable(ctx, user.ID)
+        if err == nil {
+            a.printUser(user)
+        }
+    case "reset-password":
+        var password string
+        password, err = a.password()
+        if err != nil {
+            return err
+        }
+        user, err = a.accounts.ResetPassword(ctx, user.ID, password)
+        if err == nil {
+            fmt.Fprintf(a.stdout, "reset the password of %s and revoked their sessions\n", user.Email)
+        }
+    case "grant-red", "revoke-red":
+        user, err = a.accounts.SetChirpyRed(ctx, user.ID, name == "grant-red")
+        if err == nil {
+            a.printUser(user)
+        }
+    case "delete-user":
+        if err := a.confirm(*yes, "delete " + user.Email + " and everything they own"); err != nil {
+            return err
+        }
+        var n int
+        n, err = a.accounts.Delete(ctx, user.ID)
+        if err == nil {
+            fmt.Fprintf(a.stdout, "deleted %s and %d chirps\n", user.Email, n)
+        }
+    case "purge-chirps":
+        if err := a.confirm(*yes, "delete every chirp of " + user.Email); err != nil {
+            return err
+        }
+        var n int
+        n, err = a.accounts.PurgeChirps(ctx, user.ID)
+        if err == nil {
+            fmt.Fprintf(a.stdout, "deleted %d chirps of %s\n", n, user.Email)
+        }
+    case "sessions":
+        var sessions []accounts.Session
+        sessions, err = a.accounts.Sessions(ctx, user.ID)
+        if err == nil {
+            a.printSessions(sessions)
+        }
+    case "revoke-sessions":
+        if len(args) == 2 {
+            var session accounts.Session
+            session, err = a.accounts.RevokeSession(ctx, user.ID, args[1])
+            if err == nil {
+                fmt.Fprintf(a.stdout, "revoked session %s of %s\n", session.ID, user.Email)
+            }
+        } else {
+            err = a.accounts.RevokeSessions(ctx, user.ID)
+            if err == nil {
+                fmt.Fprintf(a.stdout, "revoked every session of %s\n", user.Email)
+            }
+        }
+    case "role-changes":
+        var changes []database.RoleChange
+        changes, err = a.accounts.RoleChanges(ctx, user.ID)
+        if err == nil {
+            a.printRoleChanges(changes)
+        }
+    }
+    return err
+}
+
+func (a admin) password() (string, error) {
+    if password := a.getenv("CHIRPY_PASSWORD"); password != "" {
+        return password, nil
+    }
+    line, err := a.stdin.ReadString('\n')
+    if err != nil && !errors.Is(err, io.EOF) {
+        return "", fmt.Errorf("reading password: %w", err)
+    }
+    return strings.TrimRight(line, "\r\n"), nil
+}
+
+// confirm asks before destructive commands, unless -yes was given.
+func (a admin) confirm(yes bool, action string) error {
+    if yes {
+        return nil
+    }
+    fmt.Fprintf(a.stdout, "This will %s. Type yes to continue: ", action)
+    line, err := a.stdin.ReadString('\n')
+    if err != nil && !errors.Is(err, io.EOF) {
+        return err
+    }
+    if strings.TrimSpace(line) != "yes" {
+        return errors.New("aborted")
+    }
+    return nil
+}
+
+func (a admin) printUser(user database.User) {
+    w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintf(w, "id\t%s\n", user.ID)
+    fmt.Fprintf(w, "email\t%s\n", user.Email)
+    fmt.Fprintf(w, "created\t%s\n", user.CreatedAt.Format(time.RFC3339))
+    fmt.Fprintf(w, "role\t%s\n", user.Role)
+    fmt.Fprintf(w, "chirpy red\t%t\n", user.IsChirpyRed)
+    if user.DisabledAt.Valid {
+        fmt.Fprintf(w, "disabled\t%s\n", user.DisabledAt.Time.Format(time.RFC3339))
+    }
+    w.Flush()
+}
+
+func (a admin) printSessions(sessions []accounts.Session) {
+    w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(w, "SESSION\tSTATUS\tCREATED\tEXPIRES")
+    for _, s := range sessions {
+        fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.ID, s.Status, s.CreatedAt.Format(time.RFC3339), s.ExpiresAt.Format(time.RFC3339))
+    }
+    w.Flush()
+}
+
+func (a admin) printRoleChanges(changes []database.RoleChange) {
+    w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(w, "CHANGED\tFROM\tTO\tBY\tREASON")
+    for _, c := range changes {
+        by := "chirpy admin"
+        if c.ChangedBy.Valid {
+            by = c.ChangedBy.UUID.String()
+        }
+        fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.CreatedAt.Format(time.RFC3339), c.OldRole, c.NewRole, by, c.Reason)
+    }
+    w.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/accounts"
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runTestAdmin(t *testing.T, m *store.Memory, stdin string, args ...string) (string, error) {
+    t.Helper()
+    var stdout bytes.Buffer
+    a := admin{
+        accounts: accounts.New(m, m, webhooks.NewDispatcher(m), audit.NewLog(m)),
+        getenv: func(string) string { return "" },
+        stdin: bufio.NewReader(strings.NewReader(stdin)),
+        stdout: &stdout,
+    }
+    err := a.run(context.Background(), args)
+    return stdout.String(), err
+}
+
+func TestAdmin(t *testing.T) {
+    m := store.NewMemory()
+
+    out, err := runTestAdmin(t, m, "correct horse\n", "create-user", "walt@example.com")
+    require.NoError(t, err)
+    assert.Contains(t, out, "walt@example.com")
+
+    t.Run("Disables users by email", func(t *testing.T) {
+        out, err := runTestAdmin(t, m, "", "disable-user", "walt@example.com")
+        require.NoError(t, err)
+        assert.Contains(t, out, "disabled")
+
+        out, err = runTestAdmin(t, m, "", "enable-user", "walt@example.com")
+        require.NoError(t, err)
+        assert.NotContains(t, out, "disabled")
+    })
+
+    t.Run("Sets roles with a reason", func(t *testing.T) {
+        _, err := runTestAdmin(t, m, "", "set-role", "walt@example.com", "admin")
+        assert.ErrorIs(t, err, accounts.ErrReasonRequired)
+
+        out, err := runTestAdmin(t, m, "", "set-role", "-reason", "first admin", "walt@example.com", "admin")
+        require.NoError(t, err)
+        assert.Equal(t, "changed the role of walt@example.com from user to admin\n", out)
+
+        out, err = runTestAdmin(t, m, "", "role-changes", "walt@example.com")
+        require.NoError(t, err)
+        assert.Contains(t, out, "chirpy admin")
+        assert.Contains(t, out, "first admin")
+
+        _, err = runTestAdmin(t, m, "", "set-role", "-reason", "typo", "walt@example.com", "superuser")
+        assert.Error(t, err)
+    })
+
+    t.Run("Asks before deleting", func(t *testing.T) {
+        _, err := runTestAdmin(t, m, "no\n", "delete-user", "walt@example.com")
+        assert.EqualError(t, err, "aborted")
+        _, err = m.GetUserByEmail(context.Background(), "walt@example.com")
+        require.NoError(t, err)
+
+        out, err := runTestAdmin(t, m, "", "delete-user", "-yes", "walt@example.com")
+        require.NoError(t, err)
+        assert.Equal(t, "deleted walt@example.com and 0 chirps\n", out)
+    })
+
+    t.Run("Rejects bad usage", func(t *testing.T) {
+        for _, args := range [][]string{
+            {"show-user"},
+            {"show-user", "a@example.com", "b@example.com"},
+            {"reboot", "walt@example.com"},
+            {"sessions", "-all", "a@example.com"},
+        } {
+            _, err := runTestAdmin(t, m, "", args...)
+            assert.Error(t, err, args)
+        }
+        _, err := runTestAdmin(t, m, "", "show-user", "nobody@example.com")
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+    })
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bamcmanus/Chirpy/pkg/chirpyclient"
+	"github.com/google/uuid"
+)
+
+func userTable(server string, user chirpyclient.User) *table {
+    t := &table{header: []string{"ID", "EMAIL", "CHIRPY RED", "SERVER"}}
+    t.add(user.Id.String(), user.Email, strconv.FormatBool(user.IsChirpyRed), server)
+    return t
+}
+
+func chirpTable(chirps ...chirpyclient.Chirp) *table {
+    t := &table{header: []string{"ID", "CREATED", "AUTHOR", "BODY"}}
+    for _, chirp := range chirps {
+        t.add(chirp.Id.String(), formatTime(chirp.CreatedAt), chirp.UserId.String(), cell(chirp.Body))
+    }
+    return t
+}
+
+func (c *cli) login(ctx context.Context, args []string) error {
+    fs := c.flags("login", "[email]")
+    email := fs.String("email", "", "email to log in with")
+    if err := c.parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() > 1 || (fs.NArg() == 1 && *email != "") {
+        return fmt.Errorf("%w: give the email once", errUsage)
+    }
+    if fs.NArg() == 1 {
+        *email = fs.Arg(0)
+    }
+    if *email == "" {
+        return fmt.Errorf("%w: an email is required", errUsage)
+    }
+
+    password := c.getenv("CHIRPY_PASSWORD")
+    if password == "" {
+        fmt.Fprint(c.stderr, "Password: ")
+        line, err := bufio.NewReader(c.stdin).ReadString('\n')
+        if err != nil && !errors.Is(err, io.EOF) {
+            return fmt.Errorf("reading password: %w", err)
+        }
+        fmt.Fprintln(c.stderr)
+        password = strings.TrimRight(line, "\r\n")
+    }
+    if password == "" {
+        return fmt.Errorf("%w: a password is required, from CHIRPY_PASSWORD or stdin", errUsage)
+    }
+
+    client, err := c.client(nil)
+    if err != nil {
+        return err
+    }
+    // Log in before forgetting any earlier session, so a typo doesn't log
+    // the user out.
+    s, err := client.Login(ctx, *email, password)
+    if err != nil {
+        return err
+    }
+    if old, err := c.sessions.load(); err == nil {
+        if client, err := chirpyclient.New(old.Server, nil); err == nil {
+            client.SetTokens(old.AccessToken, old.RefreshToken)
+            _ = client.Revoke(ctx)
+        }
+    }
+
+    server := c.server
+    if server == "" {
+        server = defaultServer
+    }
+    err = c.sessions.save(&session{
+        Server: server,
+        User: s.User,
+        AccessToken: s.Token,
+        RefreshToken: s.RefreshToken,
+    })
+    if err != nil {
+        return err
+    }
+    return c.print(s.User, userTable(server, s.User))
+}
+
+func (c *cli) post(ctx context.Context, args []string) error {
+    fs := c.flags("post", "[text...]")
+    if err := c.parse(fs, args); err != nil {
+        return err
+    }
+
+    body := strings.Join(fs.Args(), " ")
+    if fs.NArg() == 0 || body == "-" {
+        data, err := io.ReadAll(c.stdin)
+        if err != nil {
+            return fmt.Errorf("reading chirp: %w", err)
+        }
+        body = strings.TrimRight(string(data), "\r\n")
+    }
+    if body == "" {
+        return fmt.Errorf("%w: the chirp is empty", errUsage)
+    }
+
+    return c.withSession(func(client *chirpyclient.Client, _ *session) error {
+        chirp, err := client.CreateChirp(ctx, body)
+        if err != nil {
+            return err
+        }
+        return c.print(chirp, chirpTable(chirp))
+    })
+}
+
+func (c *cli) ls(ctx context.Context, args []string) error {
+    fs := c.flags("ls", "")
+    author := fs.String("author", "", "only list chirps by this user ID, or me")
+    order := fs.String("sort", "asc", "order by creation time, asc or desc")
+    limit := fs.Int("n", 0, "list at most this many chirps; 0 lists all")
+    if err := c.parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() > 0 {
+        return fmt.Errorf("%w: unexpected arguments: %s", errUsage, strings.Join(fs.Args(), " "))
+    }
+    if *order != "asc" && *order != "desc" {
+        return fmt.Errorf("%w: -sort must be asc or desc, not %q", errUsage, *order)
+    }
+    if *limit < 0 {
+        return fmt.Errorf("%w: -n must not be negative", errUsage)
+    }
+
+    params := chirpyclient.ListChirpsParams{Descending: *order == "desc"}
+    if *limit > 0 && *limit < chirpyclient.DefaultPageSize {
+        params.PageSize = *limit
+    }
+
+    // Listing chirps needs no login, but the session says which server to
+    // list them from.
+    s, err := c.sessions.load()
+    if err != nil && !errors.Is(err, errNotLoggedIn) {
+        return err
+    }
+    switch *author {
+    case "":
+    case "me":
+        if s == nil {
+            return errNotLoggedIn
+        }
+        params.AuthorId = s.User.Id
+    default:
+        id, err := uuid.Parse(*author)
+        if err != nil {
+            return fmt.Errorf("%w: -author must be a user ID or me", errUsage)
+        }
+        params.AuthorId = id
+    }
+
+    client, err := c.client(s)
+    if err != nil {
+        return err
+    }
+    chirps := []chirpyclient.Chirp{}
+    for chirp, err := range client.Chirps(ctx, params) {
+        if err != nil {
+            return err
+        }
+        chirps = append(chirps, chirp)
+        if len(chirps) == *limit {
+            break
+        }
+    }
+    return c.print(chirps, chirpTable(chirps...))
+}
+
+func (c *cli) rm(ctx context.Context, args []string) error {
+    fs := c.flags("rm", "<id>...")
+    if err := c.parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() == 0 {
+        return fmt.Errorf("%w: give the IDs of the chirps to delete", errUsage)
+    }
+    ids := make([]uuid.UUID, fs.NArg())
+    for i, arg := range fs.Args() {
+        id, err := uuid.Parse(arg)
+        if err != nil {
+            return fmt.Errorf("%w: %q is not a chirp ID", errUsage, arg)
+        }
+        ids[i] = id
+    }
+
+    return c.withSession(func(client *chirpyclient.Client, _ *session) error {
+        deleted := []uuid.UUID{}
+        var err error
+        for _, id := range ids {
+            if err = client.DeleteChirp(ctx, id); err != nil {
+                err = fmt.Errorf("deleting %s: %w", id, err)
+                break
+            }
+            deleted = append(deleted, id)
+        }
+
+        t := &table{header: []string{"DELETED"}}
+        for _, id := range deleted {
+            t.add(id.String())
+        }
+        if len(deleted) > 0 {
+            if printErr := c.print(deleted, t); printErr != nil {
+                return errors.Join(err, printErr)
+            }
+        }
+        return err
+    })
+}
+
+// whoami shows the user the session was saved for, without calling the API,
+// which has no route to describe the caller.
+func (c *cli) whoami(ctx context.Context, args []string) error {
+    fs := c.flags("whoami", "")
+    if err := c.parse(fs, args); err != nil {
+        return err
+    }
+    s, err := c.sessions.load()
+    if err != nil {
+        return err
+    }
+    return c.print(s.User, userTable(s.Server, s.User))
+}
+
+func (c *cli) refresh(ctx context.Context, args []string) error {
+    fs := c.flags("refresh", "")
+    if err := c.parse(fs, args); err != nil {
+        return err
+    }
+
+    return c.withSession(func(client *chirpyclient.Client, s *session) error {
+        token, err := client.Refresh(ctx)
+        if err != nil {
+            return err
+        }
+        t := &table{header: []string{"EMAIL", "TOKEN"}}
+        t.add(s.User.Email, token)
+        return c.print(struct{
+            Token string `json:"token"`
+        }{Token: token}, t)
+    })
+}
+
+// logout revokes the refresh token and deletes the session. A session the
+// API no longer accepts is deleted all the same.
+func (c *cli) logout(ctx context.Context, args []string) error {
+    fs := c.flags("logout", "")
+    if err := c.parse(fs, args); err != nil {
+        return err
+    }
+
+    s, err := c.sessions.load()
+    if errors.Is(err, errNotLoggedIn) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    client, err := c.client(s)
+    if err != nil {
+        return err
+    }
+    if err := client.Revoke(ctx); err != nil && !errors.Is(err, chirpyclient.ErrUnauthorized) {
+        return err
+    }
+    return c.sessions.remove()
+}
@@ -0,0 +1,193 @@
+// Command chirpy-cli is a command-line client for the Chirpy API, meant for
+// scripts as much as for people.
+//
+// It logs in once and keeps the session in its config directory, refreshing
+// the access token as needed, so later commands need no credentials:
+//
+//	CHIRPY_PASSWORD=... chirpy-cli login ops@example.com
+//	echo "Deploy finished" | chirpy-cli post
+//	chirpy-cli -o json ls -author me -sort desc -n 10
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/bamcmanus/Chirpy/pkg/chirpyclient"
+)
+
+const defaultServer = "http://localhost:8080"
+
+const usage = `usage: chirpy-cli [-server URL] [-o table|json] <command> [flags] [args]
+
+commands:
+  login [email]         log in and save the session; the password is read from
+                        CHIRPY_PASSWORD or the first line of stdin
+  post [text...]        post a chirp; without text, the chirp is read from stdin
+  ls                    list chirps (-author ID|me, -sort asc|desc, -n max)
+  rm <id>...            delete chirps
+  whoami                show the logged in user
+  refresh               get a new access token
+  logout                revoke the session and forget it
+
+The server is taken from -server, CHIRPY_URL or the saved session, in that
+order, and defaults to ` + defaultServer + `. The session is kept in
+CHIRPY_CONFIG_DIR, or the chirpy directory of the user's config directory.
+`
+
+// errUsage is returned for bad command lines, which exit with status 2.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+    os.Exit(run(ctx, os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr))
+}
+
+// cli holds what every command needs.
+type cli struct {
+    getenv func(string) string
+    stdin io.Reader
+    stdout io.Writer
+    stderr io.Writer
+    server string
+    output string
+    sessions sessionStore
+}
+
+// run runs the command line in args and returns the exit status.
+func run(ctx context.Context, args []string, getenv func(string) string, stdin io.Reader, stdout, stderr io.Writer) int {
+    c := &cli{getenv: getenv, stdin: stdin, stdout: stdout, stderr: stderr}
+
+    fs := flag.NewFlagSet("chirpy-cli", flag.ContinueOnError)
+    fs.SetOutput(stderr)
+    fs.Usage = func() { fmt.Fprint(stderr, usage) }
+    fs.StringVar(&c.server, "server", getenv("CHIRPY_URL"), "API base URL (env CHIRPY_URL)")
+    fs.StringVar(&c.output, "o", outputTable, "output format, table or json")
+    if err := fs.Parse(args); err != nil {
+        if errors.Is(err, flag.ErrHelp) {
+            return 0
+        }
+        return 2
+    }
+    if fs.NArg() == 0 {
+        fmt.Fprint(stderr, usage)
+        return 2
+    }
+
+    dir, err := configDir(getenv)
+    if err != nil {
+        fmt.Fprintf(stderr, "chirpy-cli: %s\n", err)
+        return 1
+    }
+    c.sessions = sessionStore{dir: dir}
+
+    commands := map[string]func(context.Context, []string) error{
+        "login": c.login,
+        "post": c.post,
+        "ls": c.ls,
+        "rm": c.rm,
+        "whoami": c.whoami,
+        "refresh": c.refresh,
+        "logout": c.logout,
+    }
+    name, rest := fs.Arg(0), fs.Args()[1:]
+    command, ok := commands[name]
+    if !ok {
+        fmt.Fprintf(stderr, "chirpy-cli: unknown command %q\n%s", name, usage)
+        return 2
+    }
+
+    err = command(ctx, rest)
+    switch {
+    case err == nil:
+        return 0
+    case errors.Is(err, flag.ErrHelp):
+        return 0
+    case errors.Is(err, errUsage):
+        fmt.Fprintf(stderr, "chirpy-cli %s: %s\n", name, err)
+        return 2
+    case errors.Is(err, chirpyclient.ErrUnauthorized) && name != "login":
+        fmt.Fprintf(stderr, "chirpy-cli %s: %s\nthe session has expired or was revoked; run chirpy-cli login\n", name, err)
+        return 1
+    default:
+        fmt.Fprintf(stderr, "chirpy-cli %s: %s\n", name, err)
+        return 1
+    }
+}
+
+// flags returns a flag set for a command that also accepts -o, so the output
+// format can follow the command.
+func (c *cli) flags(name, args string) *flag.FlagSet {
+    fs := flag.NewFlagSet(name, flag.ContinueOnError)
+    fs.SetOutput(c.stderr)
+    fs.Usage = func() {
+        fmt.Fprintf(c.stderr, "usage: chirpy-cli %s [flags] %s\n", name, args)
+        fs.PrintDefaults()
+    }
+    fs.StringVar(&c.output, "o", c.output, "output format, table or json")
+    return fs
+}
+
+// parse parses a command's flags, turning flag errors, which the flag set has
+// already printed, into errUsage.
+func (c *cli) parse(fs *flag.FlagSet, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        if errors.Is(err, flag.ErrHelp) {
+            return err
+        }
+        return fmt.Errorf("%w: %s", errUsage, err)
+    }
+    if c.output != outputTable && c.output != outputJSON {
+        return fmt.Errorf("%w: -o must be table or json, not %q", errUsage, c.output)
+    }
+    return nil
+}
+
+// client returns a client for the server. If the user is logged in, it has
+// the session's tokens.
+func (c *cli) client(s *session) (*chirpyclient.Client, error) {
+    server := c.server
+    if server == "" && s != nil {
+        server = s.Server
+    }
+    if server == "" {
+        server = defaultServer
+    }
+    client, err := chirpyclient.New(server, nil)
+    if err != nil {
+        return nil, err
+    }
+    if s != nil {
+        client.SetTokens(s.AccessToken, s.RefreshToken)
+    }
+    return client, nil
+}
+
+// withSession runs fn with a client logged in as the saved session's user,
+// then saves the tokens if the client refreshed them, even if fn failed.
+func (c *cli) withSession(fn func(*chirpyclient.Client, *session) error) error {
+    s, err := c.sessions.load()
+    if err != nil {
+        return err
+    }
+    client, err := c.client(s)
+    if err != nil {
+        return err
+    }
+
+    err = fn(client, s)
+
+    if accessToken, refreshToken := client.Tokens(); accessToken != s.AccessToken && refreshToken != "" {
+        s.AccessToken = accessToken
+        if saveErr := c.sessions.save(s); saveErr != nil {
+            return errors.Join(err, saveErr)
+        }
+    }
+    return err
+}
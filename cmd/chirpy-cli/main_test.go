@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/apitest"
+	"github.com/bamcmanus/Chirpy/pkg/chirpyclient"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "correct horse"
+
+// testCLI runs commands against a test server, with its own config
+// directory.
+type testCLI struct {
+    server *apitest.Server
+    configDir string
+}
+
+func newTestCLI(t *testing.T) testCLI {
+    t.Helper()
+    return testCLI{server: apitest.NewServer(t), configDir: t.TempDir()}
+}
+
+type result struct {
+    code int
+    stdout string
+    stderr string
+}
+
+func (c testCLI) run(t *testing.T, stdin string, args ...string) result {
+    t.Helper()
+    env := map[string]string{
+        "CHIRPY_URL": c.server.URL,
+        "CHIRPY_CONFIG_DIR": c.configDir,
+    }
+    var stdout, stderr bytes.Buffer
+    code := run(context.Background(), args, func(key string) string { return env[key] }, strings.NewReader(stdin), &stdout, &stderr)
+    return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// signUp creates a user through the API.
+func (c testCLI) signUp(t *testing.T, email string) chirpyclient.User {
+    t.Helper()
+    client, err := chirpyclient.New(c.server.URL, nil)
+    require.NoError(t, err)
+    user, err := client.CreateUser(context.Background(), email, testPassword)
+    require.NoError(t, err)
+    return user
+}
+
+// login signs up a user and logs the CLI in as them.
+func (c testCLI) login(t *testing.T, email string) chirpyclient.User {
+    t.Helper()
+    user := c.signUp(t, email)
+    res := c.run(t, testPassword + "\n", "login", email)
+    require.Equal(t, 0, res.code, res.stderr)
+    return user
+}
+
+func (c testCLI) session(t *testing.T) session {
+    t.Helper()
+    data, err := os.ReadFile(filepath.Join(c.configDir, sessionFile))
+    require.NoError(t, err)
+    var s session
+    require.NoError(t, json.Unmarshal(data, &s))
+    return s
+}
+
+func decodeOutput[T any](t *testing.T, res result) T {
+    t.Helper()
+    require.Equal(t, 0, res.code, res.stderr)
+    var v T
+    require.NoError(t, json.Unmarshal([]byte(res.stdout), &v), res.stdout)
+    return v
+}
+
+func TestLogin(t *testing.T) {
+    t.Run("Saves the session", func(t *testing.T) {
+        cli := newTestCLI(t)
+        user := cli.signUp(t, "walt@example.com")
+
+        res := cli.run(t, testPassword + "\n", "login", "walt@example.com")
+        require.Equal(t, 0, res.code, res.stderr)
+        assert.Contains(t, res.stdout, user.Id.String())
+
+        s := cli.session(t)
+        assert.Equal(t, cli.server.URL, s.Server)
+        assert.Equal(t, user.Id, s.User.Id)
+        assert.NotEmpty(t, s.AccessToken)
+        assert.NotEmpty(t, s.RefreshToken)
+
+        info, err := os.Stat(filepath.Join(cli.configDir, sessionFile))
+        require.NoError(t, err)
+        assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+    })
+
+    t.Run("Takes the password from the environment", func(t *testing.T) {
+        cli := newTestCLI(t)
+        cli.signUp(t, "jesse@example.com")
+        env := map[string]string{
+            "CHIRPY_URL": cli.server.URL,
+            "CHIRPY_CONFIG_DIR": cli.configDir,
+            "CHIRPY_PASSWORD": testPassword,
+        }
+
+        var stdout, stderr bytes.Buffer
+        code := run(context.Background(), []string{"login", "-email", "jesse@example.com"}, func(key string) string { return env[key] }, strings.NewReader(""), &stdout, &stderr)
+        require.Equal(t, 0, code, stderr.String())
+        assert.NotContains(t, stderr.String(), "Password")
+    })
+
+    t.Run("Keeps the session on a wrong password", func(t *testing.T) {
+        cli := newTestCLI(t)
+        user := cli.login(t, "skyler@example.com")
+
+        res := cli.run(t, "wrong password\n", "login", "skyler@example.com")
+        assert.Equal(t, 1, res.code)
+        assert.Contains(t, res.stderr, "invalid_credentials")
+        assert.Equal(t, user.Id, cli.session(t).User.Id)
+    })
+
+    t.Run("Revokes the session it replaces", func(t *testing.T) {
+        cli := newTestCLI(t)
+        cli.login(t, "hank@example.com")
+        old := cli.session(t)
+        cli.login(t, "marie@example.com")
+
+        client, err := chirpyclient.New(cli.server.URL, nil)
+        require.NoError(t, err)
+        client.SetTokens("", old.RefreshToken)
+        _, err = client.Refresh(context.Background())
+        assert.ErrorIs(t, err, chirpyclient.ErrUnauthorized)
+    })
+}
+
+func TestWhoami(t *testing.T) {
+    cli := newTestCLI(t)
+
+    res := cli.run(t, "", "whoami")
+    assert.Equal(t, 1, res.code)
+    assert.Contains(t, res.stderr, "not logged in")
+
+    user := cli.login(t, "saul@example.com")
+    got := decodeOutput[chirpyclient.User](t, cli.run(t, "", "-o", "json", "whoami"))
+    assert.Equal(t, user.Id, got.Id)
+    assert.Equal(t, "saul@example.com", got.Email)
+
+    res = cli.run(t, "", "whoami")
+    require.Equal(t, 0, res.code)
+    lines := strings.Split(strings.TrimSpace(res.stdout), "\n")
+    require.Len(t, lines, 2)
+    assert.Equal(t, []string{"ID", "EMAIL", "CHIRPY", "RED", "SERVER"}, strings.Fields(lines[0]))
+    assert.Equal(t, []string{user.Id.String(), "saul@example.com", "false", cli.server.URL}, strings.Fields(lines[1]))
+}
+
+func TestChirps(t *testing.T) {
+    cli := newTestCLI(t)
+    user := cli.login(t, "gus@example.com")
+
+    first := decodeOutput[chirpyclient.Chirp](t, cli.run(t, "", "post", "-o", "json", "Deploy", "started"))
+    assert.Equal(t, "Deploy started", first.Body)
+    assert.Equal(t, user.Id, first.UserId)
+
+    second := decodeOutput[chirpyclient.Chirp](t, cli.run(t, "Deploy finished\n", "-o", "json", "post"))
+    assert.Equal(t, "Deploy finished", second.Body)
+
+    other := newTestCLI(t)
+    other.server = cli.server
+    other.login(t, "lydia@example.com")
+    require.Equal(t, 0, other.run(t, "", "post", "Not by Gus").code)
+
+    t.Run("Lists every chirp", func(t *testing.T) {
+        chirps := decodeOutput[[]chirpyclient.Chirp](t, cli.run(t, "", "ls", "-o", "json"))
+        require.Len(t, chirps, 3)
+        assert.Equal(t, first.Id, chirps[0].Id)
+    })
+
+    t.Run("Filters and sorts", func(t *testing.T) {
+        chirps := decodeOutput[[]chirpyclient.Chirp](t, cli.run(t, "", "-o", "json", "ls", "-author", "me", "-sort", "desc", "-n", "1"))
+        require.Len(t, chirps, 1)
+        assert.Equal(t, second.Id, chirps[0].Id)
+
+        chirps = decodeOutput[[]chirpyclient.Chirp](t, cli.run(t, "", "-o", "json", "ls", "-author", user.Id.String()))
+        assert.Len(t, chirps, 2)
+
+        chirps = decodeOutput[[]chirpyclient.Chirp](t, cli.run(t, "", "-o", "json", "ls", "-author", uuid.NewString()))
+        assert.Empty(t, chirps)
+    })
+
+    t.Run("Prints a table", func(t *testing.T) {
+        res := cli.run(t, "", "ls", "-author", "me")
+        require.Equal(t, 0, res.code)
+        lines := strings.Split(strings.TrimSpace(res.stdout), "\n")
+        require.Len(t, lines, 3)
+        assert.Equal(t, []string{"ID", "CREATED", "AUTHOR", "BODY"}, strings.Fields(lines[0]))
+        assert.True(t, strings.HasPrefix(lines[1], first.Id.String()))
+        assert.True(t, strings.HasSuffix(lines[1], "Deploy started"))
+    })
+
+    t.Run("Deletes chirps", func(t *testing.T) {
+        deleted := decodeOutput[[]uuid.UUID](t, cli.run(t, "", "-o", "json", "rm", first.Id.String()))
+        assert.Equal(t, []uuid.UUID{first.Id}, deleted)
+
+        res := other.run(t, "", "rm", second.Id.String())
+        assert.Equal(t, 1, res.code)
+        assert.Contains(t, res.stderr, "403 forbidden")
+    })
+}
+
+func TestRefresh(t *testing.T) {
+    t.Run("Replaces the access token", func(t *testing.T) {
+        cli := newTestCLI(t)
+        cli.login(t, "mike@example.com")
+        before := cli.session(t)
+
+        out := decodeOutput[struct{ Token string }](t, cli.run(t, "", "-o", "json", "refresh"))
+        after := cli.session(t)
+        assert.Equal(t, out.Token, after.AccessToken)
+        assert.Equal(t, before.RefreshToken, after.RefreshToken)
+    })
+
+    t.Run("Saves a token refreshed by another command", func(t *testing.T) {
+        cli := newTestCLI(t)
+        cli.login(t, "tuco@example.com")
+        s := cli.session(t)
+        s.AccessToken = "expired"
+        require.NoError(t, sessionStore{dir: cli.configDir}.save(&s))
+
+        res := cli.run(t, "", "post", "Tight tight tight")
+        require.Equal(t, 0, res.code, res.stderr)
+        assert.NotEqual(t, "expired", cli.session(t).AccessToken)
+    })
+}
+
+func TestLogout(t *testing.T) {
+    cli := newTestCLI(t)
+    cli.login(t, "todd@example.com")
+    s := cli.session(t)
+
+    res := cli.run(t, "", "logout")
+    require.Equal(t, 0, res.code, res.stderr)
+    assert.NoFileExists(t, filepath.Join(cli.configDir, sessionFile))
+
+    client, err := chirpyclient.New(cli.server.URL, nil)
+    require.NoError(t, err)
+    client.SetTokens("", s.RefreshToken)
+    _, err = client.Refresh(context.Background())
+    assert.ErrorIs(t, err, chirpyclient.ErrUnauthorized)
+
+    res = cli.run(t, "", "post", "Still here?")
+    assert.Equal(t, 1, res.code)
+    assert.Contains(t, res.stderr, "not logged in")
+
+    assert.Equal(t, 0, cli.run(t, "", "logout").code)
+}
+
+func TestUsage(t *testing.T) {
+    cli := newTestCLI(t)
+    tests := []struct {
+        name string
+        args []string
+    }{
+        {"No command", nil},
+        {"Unknown command", []string{"tweet"}},
+        {"Unknown flag", []string{"ls", "-since", "yesterday"}},
+        {"Unknown output", []string{"-o", "yaml", "whoami"}},
+        {"Login without email", []string{"login"}},
+        {"Bad sort", []string{"ls", "-sort", "newest"}},
+        {"Bad author", []string{"ls", "-author", "gus"}},
+        {"Negative count", []string{"ls", "-n", "-1"}},
+        {"Rm without IDs", []string{"rm"}},
+        {"Rm with a bad ID", []string{"rm", "not-an-id"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            res := cli.run(t, "", tt.args...)
+            assert.Equal(t, 2, res.code)
+            assert.NotEmpty(t, res.stderr)
+            assert.Empty(t, res.stdout)
+        })
+    }
+}
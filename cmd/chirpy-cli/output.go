@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats. Table is for people; JSON is for scripts and is the same
+// as what the API returns.
+const (
+    outputTable = "table"
+    outputJSON = "json"
+)
+
+// table is a tab-aligned table with a header row.
+type table struct {
+    header []string
+    rows [][]string
+}
+
+func (t *table) add(cells ...string) {
+    t.rows = append(t.rows, cells)
+}
+
+// print writes v as JSON, or t as a table.
+func (c *cli) print(v any, t *table) error {
+    if c.output == outputJSON {
+        encoder := json.NewEncoder(c.stdout)
+        encoder.SetIndent("", "  ")
+        return encoder.Encode(v)
+    }
+
+    w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(w, strings.Join(t.header, "\t"))
+    for _, row := range t.rows {
+        fmt.Fprintln(w, strings.Join(row, "\t"))
+    }
+    return w.Flush()
+}
+
+// cell makes s safe to put in a table cell, which can't hold tabs or
+// newlines.
+func cell(s string) string {
+    return strings.Join(strings.Fields(s), " ")
+}
+
+func formatTime(t time.Time) string {
+    return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bamcmanus/Chirpy/pkg/chirpyclient"
+)
+
+const sessionFile = "session.json"
+
+// errNotLoggedIn is returned by commands that need a session when there is
+// none.
+var errNotLoggedIn = errors.New("not logged in; run chirpy-cli login")
+
+// session is what login saves: the tokens, the server they are for, and the
+// user as the API described them at login.
+type session struct {
+    Server string `json:"server"`
+    User chirpyclient.User `json:"user"`
+    AccessToken string `json:"access_token"`
+    RefreshToken string `json:"refresh_token"`
+}
+
+// configDir returns CHIRPY_CONFIG_DIR, or the chirpy directory of the user's
+// config directory, e.g. ~/.config/chirpy.
+func configDir(getenv func(string) string) (string, error) {
+    if dir := getenv("CHIRPY_CONFIG_DIR"); dir != "" {
+        return dir, nil
+    }
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "", fmt.Errorf("finding the config directory: %w; set CHIRPY_CONFIG_DIR", err)
+    }
+    return filepath.Join(dir, "chirpy"), nil
+}
+
+// sessionStore keeps the session in a file only the user can read, since
+// the refresh token is as good as a password until it expires.
+type sessionStore struct {
+    dir string
+}
+
+func (s sessionStore) path() string {
+    return filepath.Join(s.dir, sessionFile)
+}
+
+func (s sessionStore) load() (*session, error) {
+    data, err := os.ReadFile(s.path())
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, errNotLoggedIn
+    }
+    if err != nil {
+        return nil, fmt.Errorf("reading session: %w", err)
+    }
+
+    var sess session
+    if err := json.Unmarshal(data, &sess); err != nil {
+        return nil, fmt.Errorf("reading session %s: %w", s.path(), err)
+    }
+    if sess.RefreshToken == "" {
+        return nil, errNotLoggedIn
+    }
+    return &sess, nil
+}
+
+// save replaces the session file atomically, so a command killed halfway
+// through can't leave a truncated session behind.
+func (s sessionStore) save(sess *session) error {
+    data, err := json.MarshalIndent(sess, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(s.dir, 0o700); err != nil {
+        return fmt.Errorf("saving session: %w", err)
+    }
+
+    f, err := os.CreateTemp(s.dir, sessionFile + ".*")
+    if err != nil {
+        return fmt.Errorf("saving session: %w", err)
+    }
+    defer os.Remove(f.Name())
+    if _, err := f.Write(data); err != nil {
+        f.Close()
+        return fmt.Errorf("saving session: %w", err)
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("saving session: %w", err)
+    }
+    if err := os.Rename(f.Name(), s.path()); err != nil {
+        return fmt.Errorf("saving session: %w", err)
+    }
+    return nil
+}
+
+func (s sessionStore) remove() error {
+    if err := os.Remove(s.path()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return fmt.Errorf("removing session: %w", err)
+    }
+    return nil
+}
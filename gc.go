@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/tokengc"
+)
+
+// runGC implements the gc subcommand, which deletes stale refresh tokens once
+// instead of waiting for the workers' next sweep. Like migrate, it only needs
+// the database URL and its own settings.
+func runGC(args []string) {
+    cfg, rest, err := config.Parse(args, os.Getenv)
+    if err != nil {
+        log.Fatalf("invalid configuration: %s", err)
+    }
+    if len(rest) > 0 {
+        log.Fatalf("unexpected arguments: %s\nusage: chirpy gc [flags]", strings.Join(rest, " "))
+    }
+    if cfg.DBURL == "" {
+        log.Fatal("DB_URL must be set")
+    }
+    if cfg.TokenGC.BatchSize < 1 || cfg.TokenGC.RevokedRetention < 0 {
+        log.Fatal("token_gc.batch_size must be at least 1 and token_gc.revoked_retention must not be negative")
+    }
+
+    db, dialect, err := store.Open(cfg.DBURL)
+    if err != nil {
+        log.Fatalf("failed to connect to database: %s", err)
+    }
+    defer db.Close()
+
+    sweeper := tokengc.NewSweeper(store.New(dialect, db), cfg.TokenGC, metrics.New(nil))
+    res, err := sweeper.Sweep(context.Background())
+    fmt.Printf("deleted %d expired and %d revoked refresh tokens\n", res.Expired, res.Revoked)
+    if err != nil {
+        db.Close()
+        log.Fatal(err)
+    }
+}
@@ -0,0 +1,459 @@
+// Package accounts implements the support tasks behind chirpy admin: creating,
+// disabling and deleting users, resetting passwords, granting Chirpy Red,
+// changing roles, and managing a user's sessions and chirps. It works on the
+// store directly, so it needs no API credentials, only database access. The
+// admin API uses it to change roles too. Every change is recorded in the audit
+// log in the same transaction.
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/validate"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// source is the metadata that marks events recorded without an acting user,
+// which are made with chirpy admin.
+const source = "chirpy admin"
+
+// sessionIDLength is how much of a refresh token identifies its session. The
+// rest is never shown, so listing sessions doesn't leak usable tokens.
+const sessionIDLength = 12
+
+var (
+    ErrUserNotFound = errors.New("user not found")
+    ErrEmailTaken = errors.New("a user with that email already exists")
+    ErrSessionNotFound = errors.New("session not found")
+    ErrReasonRequired = errors.New("a reason is required")
+    ErrRoleUnchanged = errors.New("the user already has that role")
+    ErrOwnRole = errors.New("users can't change their own role")
+)
+
+// Session statuses.
+const (
+    SessionActive = "active"
+    SessionExpired = "expired"
+    SessionRevoked = "revoked"
+)
+
+// Session is a refresh token, identified by its first characters.
+type Session struct {
+    ID string `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    ExpiresAt time.Time `json:"expires_at"`
+    RevokedAt *time.Time `json:"revoked_at"`
+    Status string `json:"status"`
+}
+
+type Service struct {
+    store store.Store
+    tx store.Transactor
+    events webhooks.Dispatcher
+    audit audit.Log
+}
+
+func New(s store.Store, tx store.Transactor, events webhooks.Dispatcher, log audit.Log) Service {
+    return Service{
+        store: s,
+        tx: tx,
+        events: events,
+        audit: log,
+    }
+}
+
+// Lookup finds a user by ID or by email.
+func (s Service) Lookup(ctx context.Context, ref string) (database.User, error) {
+    var user database.User
+    var err error
+    if id, parseErr := uuid.Parse(ref); parseErr == nil {
+        user, err = s.store.GetUser(ctx, id)
+    } else {
+        user, err = s.store.GetUserByEmail(ctx, ref)
+    }
+    if errors.Is(err, sql.ErrNoRows) {
+        return user, fmt.Errorf("%w: %s", ErrUserNotFound, ref)
+    }
+    return user, err
+}
+
+// newUser and newPassword hold the rules a user's email and password must
+// follow, the same as for sign-ups through the API.
+type newUser struct {
+    Email string `json:"email" validate:"required,email,max=254"`
+    Password string `json:"password" validate:"required,password"`
+}
+
+type newPassword struct {
+    Password string `json:"password" validate:"required,password"`
+}
+
+// check validates v and joins the messages of its invalid fields.
+func check(v any) error {
+    var errs []error
+    for _, fe := range validate.Struct(v) {
+        errs = append(errs, errors.New(fe.Message))
+    }
+    return errors.Join(errs...)
+}
+
+func (s Service) CreateUser(ctx context.Context, email, password string) (database.User, error) {
+    if err := check(&newUser{Email: email, Password: password}); err != nil {
+        return database.User{}, err
+    }
+    hashedPassword, err := auth.HashPassword(password)
+    if err != nil {
+        return database.User{}, fmt.Errorf("hashing password: %w", err)
+    }
+
+    var user database.User
+    err = s.tx.InTx(ctx, func(st store.Store) error {
+        var err error
+        user, err = st.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashedPassword})
+        if err != nil {
+            return err
+        }
+        return s.record(ctx, st, audit.Event{Action: audit.ActionUserCreated, TargetID: user.ID})
+    })
+    if store.IsUniqueViolation(err) {
+        return user, ErrEmailTaken
+    }
+    return user, err
+}
+
+// Disable stops a user from logging in and revokes their sessions. Access
+// tokens already issued keep working until they expire.
+func (s Service) Disable(ctx context.Context, id uuid.UUID) (database.User, error) {
+    var user database.User
+    err := s.tx.InTx(ctx, func(st store.Store) error {
+        var err error
+        user, err = st.SetUserDisabledAt(ctx, database.SetUserDisabledAtParams{
+            DisabledAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+            ID: id,
+        })
+        if err != nil {
+            return err
+        }
+        if err := st.RevokeUserRefreshTokens(ctx, id); err != nil {
+            return err
+        }
+        return s.record(ctx, st, audit.Event{Action: audit.ActionUserDisabled, TargetID: id})
+    })
+    return user, notFound(id, err)
+}
+
+// Enable lets a disabled user log in again.
+func (s Service) Enable(ctx context.Context, id uuid.UUID) (database.User, error) {
+    var user database.User
+    err := s.tx.InTx(ctx, func(st store.Store) error {
+        var err error
+        user, err = st.SetUserDisabledAt(ctx, database.SetUserDisabledAtParams{ID: id})
+        if err != nil {
+            return err
+        }
+        return s.record(ctx, st, audit.Event{Action: audit.ActionUserEnabled, TargetID: id})
+    })
+    return user, notFound(id, err)
+}
+
+// ResetPassword sets a user's password and revokes their sessions, as
+// changing the password through the API does.
+func (s Service) ResetPassword(ctx context.Context, id uuid.UUID, password string) (database.User, error) {
+    if err := check(&newPassword{Password: password}); err != nil {
+        return database.User{}, err
+    }
+    hashedPassword, err := auth.HashPassword(password)
+    if err != nil {
+        return database.User{}, fmt.Errorf("hashing password: %w", err)
+    }
+
+    var user database.User
+    err = s.tx.InTx(ctx, func(st store.Store) error {
+        var err error
+        user, err = st.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{HashedPassword: hashedPassword, ID: id})
+        if err != nil {
+            return err
+        }
+        if err := st.RevokeUserRefreshTokens(ctx, id); err != nil {
+            return err
+        }
+        return s.record(ctx, st, audit.Event{Action: audit.ActionPasswordReset, TargetID: id})
+    })
+    return user, notFound(id, err)
+}
+
+// SetChirpyRed grants or revokes Chirpy Red.
+func (s Service) SetChirpyRed(ctx context.Context, id uuid.UUID, red bool) (database.User, error) {
+    action := audit.ActionUserDowngraded
+    if red {
+        action = audit.ActionUserUpgraded
+    }
+    var user database.User
+    err := s.tx.InTx(ctx, func(st store.Store) error {
+        var err error
+        user, err = st.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{IsChirpyRed: red, ID: id})
+        if err != nil {
+            return err
+        }
+        return s.record(ctx, st, audit.Event{Action: action, TargetID: id})
+    })
+    return user, notFound(id, err)
+}
+
+// SetRole changes a user's role and records the change, who made it and why.
+// changedBy is the admin making the change, or uuid.Nil for changes made with
+// chirpy admin. The user's access tokens keep their old role until they are
+// refreshed.
+func (s Service) SetRole(ctx context.Context, id uuid.UUID, role auth.Role, changedBy uuid.UUID, reason string) (database.RoleChange, error) {
+    if _, err := auth.ParseRole(string(role)); err != nil {
+        return database.RoleChange{}, err
+    }
+    reason = strings.TrimSpace(reason)
+    if reason == "" {
+        return database.RoleChange{}, ErrReasonRequired
+    }
+    if changedBy == id {
+        return database.RoleChange{}, ErrOwnRole
+    }
+
+    var change database.RoleChange
+    err := s.tx.InTx(ctx, func(st store.Store) error {
+        user, err := st.GetUser(ctx, id)
+        if err != nil {
+            return err
+        }
+        if auth.Role(user.Role) == role {
+            return ErrRoleUnchanged
+        }
+        if _, err := st.SetUserRole(ctx, database.SetUserRoleParams{Role: string(role), ID: id}); err != nil {
+            return fmt.Errorf("setting role: %w", err)
+        }
+        change, err = st.CreateRoleChange(ctx, database.CreateRoleChangeParams{
+            UserID: id,
+            OldRole: user.Role,
+            NewRole: string(role),
+            ChangedBy: uuid.NullUUID{UUID: changedBy, Valid: changedBy != uuid.Nil},
+            Reason: reason,
+        })
+        if err != nil {
+            return fmt.Errorf("recording role change: %w", err)
+        }
+        return s.record(ctx, st, audit.Event{
+            Action: audit.ActionRoleChanged,
+            ActorID: changedBy,
+            TargetID: id,
+            Metadata: map[string]any{"old_role": user.Role, "new_role": string(role), "reason": reason},
+        })
+    })
+    return change, notFound(id, err)
+}
+
+// RoleChanges lists the changes of a user's role, newest first. The log is
+// kept after the user is deleted.
+func (s Service) RoleChanges(ctx context.Context, id uuid.UUID) ([]database.RoleChange, error) {
+    return s.store.ListRoleChanges(ctx, id)
+}
+
+// Delete deletes a user and everything they own. Their chirps are deleted
+// first, as by PurgeChirps, so webhooks hear about them.
+func (s Service) Delete(ctx context.Context, id uuid.UUID) (chirps int, err error) {
+    err = s.tx.InTx(ctx, func(st store.Store) error {
+        chirps, err = s.purgeChirps(ctx, st, id)
+        if err != nil {
+            return err
+        }
+        n, err := st.DeleteUser(ctx, id)
+        if err != nil {
+            return fmt.Errorf("deleting user: %w", err)
+        }
+        if n == 0 {
+            return sql.ErrNoRows
+        }
+        return s.record(ctx, st, audit.Event{Action: audit.ActionUserDeleted, TargetID: id, Metadata: map[string]any{"chirps": chirps}})
+    })
+    return chirps, notFound(id, err)
+}
+
+// PurgeChirps deletes every chirp of a user and returns how many there were.
+// A chirp.deleted event is emitted for each, as when they are deleted through
+// the API.
+func (s Service) PurgeChirps(ctx context.Context, id uuid.UUID) (int, error) {
+    if _, err := s.store.GetUser(ctx, id); err != nil {
+        return 0, notFound(id, err)
+    }
+
+    var n int
+    err := s.tx.InTx(ctx, func(st store.Store) error {
+        var err error
+        n, err = s.purgeChirps(ctx, st, id)
+        return err
+    })
+    return n, err
+}
+
+func (s Service) purgeChirps(ctx context.Context, st store.Store, userID uuid.UUID) (int, error) {
+    chirps, err := st.ListChirpsByUser(ctx, userID)
+    if err != nil {
+        return 0, fmt.Errorf("listing chirps: %w", err)
+    }
+
+    events := s.events.With(st)
+    for _, chirp := range chirps {
+        if err := st.DeleteChirp(ctx, chirp.ID); err != nil {
+            return 0, fmt.Errorf("deleting chirp %s: %w", chirp.ID, err)
+        }
+        event := webhooks.Event{
+            Type: webhooks.EventChirpDeleted,
+            UserID: chirp.UserID,
+            Data: struct{
+                Id uuid.UUID `json:"id"`
+                UserId uuid.UUID `json:"user_id"`
+            }{
+                Id: chirp.ID,
+                UserId: chirp.UserID,
+            },
+        }
+        if err := events.Emit(ctx, event); err != nil {
+            return 0, err
+        }
+        err := s.record(ctx, st, audit.Event{
+            Action: audit.ActionChirpDeleted,
+            TargetID: chirp.ID,
+            Metadata: map[string]any{"author_id": chirp.UserID, "moderated": true},
+        })
+        if err != nil {
+            return 0, err
+        }
+    }
+    return len(chirps), nil
+}
+
+// Sessions lists a user's sessions, newest first, including revoked and
+// expired ones until they are garbage collected.
+func (s Service) Sessions(ctx context.Context, id uuid.UUID) ([]Session, error) {
+    if _, err := s.store.GetUser(ctx, id); err != nil {
+        return nil, notFound(id, err)
+    }
+    tokens, err := s.store.ListUserRefreshTokens(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+
+    sessions := make([]Session, len(tokens))
+    now := time.Now()
+    for i, t := range tokens {
+        sessions[i] = newSession(t, now)
+    }
+    return sessions, nil
+}
+
+// RevokeSession revokes the session of a user whose ID starts with prefix.
+func (s Service) RevokeSession(ctx context.Context, userID uuid.UUID, prefix string) (Session, error) {
+    if prefix == "" {
+        return Session{}, fmt.Errorf("%w: empty session ID", ErrSessionNotFound)
+    }
+    tokens, err := s.store.ListUserRefreshTokens(ctx, userID)
+    if err != nil {
+        return Session{}, err
+    }
+
+    var matches []database.RefreshToken
+    for _, t := range tokens {
+        if strings.HasPrefix(t.Token, prefix) {
+            matches = append(matches, t)
+        }
+    }
+    switch {
+    case len(matches) == 0:
+        return Session{}, fmt.Errorf("%w: %s", ErrSessionNotFound, prefix)
+    case len(matches) > 1:
+        return Session{}, fmt.Errorf("session ID %s is ambiguous; give more of it", prefix)
+    }
+
+    var token database.RefreshToken
+    err = s.tx.InTx(ctx, func(st store.Store) error {
+        var err error
+        token, err = st.RevokeRefreshToken(ctx, matches[0].Token)
+        if err != nil {
+            return err
+        }
+        return s.record(ctx, st, audit.Event{
+            Action: audit.ActionTokenRevoked,
+            TargetID: userID,
+            Metadata: map[string]any{"session": SessionID(token.Token)},
+        })
+    })
+    if err != nil {
+        return Session{}, err
+    }
+    return newSession(token, time.Now()), nil
+}
+
+// RevokeSessions revokes every session of a user.
+func (s Service) RevokeSessions(ctx context.Context, id uuid.UUID) error {
+    if _, err := s.store.GetUser(ctx, id); err != nil {
+        return notFound(id, err)
+    }
+    return s.tx.InTx(ctx, func(st store.Store) error {
+        if err := st.RevokeUserRefreshTokens(ctx, id); err != nil {
+            return err
+        }
+        return s.record(ctx, st, audit.Event{Action: audit.ActionSessionsRevoked, TargetID: id})
+    })
+}
+
+// SessionID returns the ID of the session a refresh token belongs to.
+func SessionID(token string) string {
+    return token[:min(len(token), sessionIDLength)]
+}
+
+func newSession(t database.RefreshToken, now time.Time) Session {
+    session := Session{
+        ID: SessionID(t.Token),
+        CreatedAt: t.CreatedAt,
+        ExpiresAt: t.ExpiresAt,
+        Status: SessionActive,
+    }
+    switch {
+    case t.RevokedAt.Valid:
+        session.RevokedAt = &t.RevokedAt.Time
+        session.Status = SessionRevoked
+    case !t.ExpiresAt.After(now):
+        session.Status = SessionExpired
+    }
+    return session
+}
+
+// record writes event through st, the transaction of the change it describes.
+// Events without an acting user are marked as made with chirpy admin.
+func (s Service) record(ctx context.Context, st store.Store, event audit.Event) error {
+    if event.ActorID == uuid.Nil {
+        metadata := map[string]any{"source": source}
+        for k, v := range event.Metadata {
+            metadata[k] = v
+        }
+        event.Metadata = metadata
+    }
+    if err := s.audit.With(st).Record(ctx, event); err != nil {
+        return fmt.Errorf("recording %s: %w", event.Action, err)
+    }
+    return nil
+}
+
+// notFound reports a missing user as ErrUserNotFound.
+func notFound(id uuid.UUID, err error) error {
+    if errors.Is(err, sql.ErrNoRows) {
+        return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+    }
+    return err
+}
@@ -0,0 +1,326 @@
+package accounts_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/accounts"
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const password = "correct horse"
+
+func newService(t *testing.T) (accounts.Service, *store.Memory) {
+    t.Helper()
+    m := store.NewMemory()
+    return accounts.New(m, m, webhooks.NewDispatcher(m), audit.NewLog(m)), m
+}
+
+func newUser(t *testing.T, svc accounts.Service, email string) database.User {
+    t.Helper()
+    user, err := svc.CreateUser(context.Background(), email, password)
+    require.NoError(t, err)
+    return user
+}
+
+func newToken(t *testing.T, m *store.Memory, userID uuid.UUID, token string, expiresAt time.Time) {
+    t.Helper()
+    _, err := m.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+        Token: token,
+        UserID: userID,
+        ExpiresAt: expiresAt,
+    })
+    require.NoError(t, err)
+}
+
+func TestUsers(t *testing.T) {
+    ctx := context.Background()
+
+    t.Run("Users are created and looked up by ID or email", func(t *testing.T) {
+        svc, _ := newService(t)
+        user := newUser(t, svc, "walt@example.com")
+        require.NoError(t, auth.CheckPasswordHash(user.HashedPassword, password))
+
+        got, err := svc.Lookup(ctx, user.ID.String())
+        require.NoError(t, err)
+        assert.Equal(t, user.ID, got.ID)
+
+        got, err = svc.Lookup(ctx, "walt@example.com")
+        require.NoError(t, err)
+        assert.Equal(t, user.ID, got.ID)
+
+        _, err = svc.Lookup(ctx, "jesse@example.com")
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+        _, err = svc.Lookup(ctx, uuid.NewString())
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+    })
+
+    t.Run("New users follow the sign-up rules", func(t *testing.T) {
+        svc, _ := newService(t)
+        newUser(t, svc, "walt@example.com")
+
+        _, err := svc.CreateUser(ctx, "walt@example.com", password)
+        assert.ErrorIs(t, err, accounts.ErrEmailTaken)
+
+        _, err = svc.CreateUser(ctx, "not an email", password)
+        assert.ErrorContains(t, err, "email")
+
+        _, err = svc.CreateUser(ctx, "jesse@example.com", "short")
+        assert.ErrorContains(t, err, "password must be at least")
+    })
+
+    t.Run("Disabling a user revokes their sessions", func(t *testing.T) {
+        svc, m := newService(t)
+        user := newUser(t, svc, "walt@example.com")
+        newToken(t, m, user.ID, "token-walt", time.Now().Add(time.Hour))
+
+        disabled, err := svc.Disable(ctx, user.ID)
+        require.NoError(t, err)
+        assert.True(t, disabled.DisabledAt.Valid)
+
+        token, err := m.GetRefreshToken(ctx, "token-walt")
+        require.NoError(t, err)
+        assert.True(t, token.RevokedAt.Valid)
+
+        enabled, err := svc.Enable(ctx, user.ID)
+        require.NoError(t, err)
+        assert.False(t, enabled.DisabledAt.Valid)
+
+        _, err = svc.Disable(ctx, uuid.New())
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+    })
+
+    t.Run("Resetting a password revokes sessions", func(t *testing.T) {
+        svc, m := newService(t)
+        user := newUser(t, svc, "walt@example.com")
+        newToken(t, m, user.ID, "token-walt", time.Now().Add(time.Hour))
+
+        _, err := svc.ResetPassword(ctx, user.ID, "short")
+        assert.Error(t, err)
+
+        updated, err := svc.ResetPassword(ctx, user.ID, "a new passphrase")
+        require.NoError(t, err)
+        assert.NoError(t, auth.CheckPasswordHash(updated.HashedPassword, "a new passphrase"))
+
+        token, err := m.GetRefreshToken(ctx, "token-walt")
+        require.NoError(t, err)
+        assert.True(t, token.RevokedAt.Valid)
+    })
+
+    t.Run("Chirpy Red is granted and revoked", func(t *testing.T) {
+        svc, _ := newService(t)
+        user := newUser(t, svc, "walt@example.com")
+
+        user, err := svc.SetChirpyRed(ctx, user.ID, true)
+        require.NoError(t, err)
+        assert.True(t, user.IsChirpyRed)
+
+        user, err = svc.SetChirpyRed(ctx, user.ID, false)
+        require.NoError(t, err)
+        assert.False(t, user.IsChirpyRed)
+    })
+}
+
+func TestRoles(t *testing.T) {
+    ctx := context.Background()
+    svc, _ := newService(t)
+    user := newUser(t, svc, "walt@example.com")
+    admin := newUser(t, svc, "gus@example.com")
+
+    t.Run("Changes are recorded", func(t *testing.T) {
+        change, err := svc.SetRole(ctx, user.ID, auth.RoleModerator, uuid.Nil, "joined the moderators")
+        require.NoError(t, err)
+        assert.Equal(t, "user", change.OldRole)
+        assert.Equal(t, "moderator", change.NewRole)
+        assert.False(t, change.ChangedBy.Valid)
+
+        _, err = svc.SetRole(ctx, user.ID, auth.RoleUser, admin.ID, "  left the moderators ")
+        require.NoError(t, err)
+
+        got, err := svc.Lookup(ctx, user.ID.String())
+        require.NoError(t, err)
+        assert.Equal(t, "user", got.Role)
+
+        changes, err := svc.RoleChanges(ctx, user.ID)
+        require.NoError(t, err)
+        require.Len(t, changes, 2)
+        assert.Equal(t, uuid.NullUUID{UUID: admin.ID, Valid: true}, changes[0].ChangedBy)
+        assert.Equal(t, "left the moderators", changes[0].Reason)
+        assert.Equal(t, change, changes[1])
+    })
+
+    t.Run("Bad changes are rejected", func(t *testing.T) {
+        _, err := svc.SetRole(ctx, user.ID, auth.RoleAdmin, uuid.Nil, " ")
+        assert.ErrorIs(t, err, accounts.ErrReasonRequired)
+        _, err = svc.SetRole(ctx, user.ID, auth.RoleUser, uuid.Nil, "no-op")
+        assert.ErrorIs(t, err, accounts.ErrRoleUnchanged)
+        _, err = svc.SetRole(ctx, user.ID, "root", uuid.Nil, "typo")
+        assert.ErrorContains(t, err, "unknown role")
+        _, err = svc.SetRole(ctx, admin.ID, auth.RoleUser, admin.ID, "stepping down")
+        assert.ErrorIs(t, err, accounts.ErrOwnRole)
+        _, err = svc.SetRole(ctx, uuid.New(), auth.RoleAdmin, uuid.Nil, "ghost")
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+    })
+}
+
+func TestChirps(t *testing.T) {
+    ctx := context.Background()
+    svc, m := newService(t)
+    user := newUser(t, svc, "walt@example.com")
+    other := newUser(t, svc, "jesse@example.com")
+
+    _, err := m.CreateWebhook(ctx, database.CreateWebhookParams{
+        UserID: user.ID,
+        Url: "https://example.com/hook",
+        Secret: "secret",
+        Events: []string{webhooks.EventChirpDeleted},
+    })
+    require.NoError(t, err)
+    for _, id := range []uuid.UUID{user.ID, user.ID, other.ID} {
+        _, err := m.CreateChirp(ctx, database.CreateChirpParams{Body: "Say my name", UserID: id})
+        require.NoError(t, err)
+    }
+
+    t.Run("Purging deletes only the user's chirps", func(t *testing.T) {
+        n, err := svc.PurgeChirps(ctx, user.ID)
+        require.NoError(t, err)
+        assert.Equal(t, 2, n)
+
+        chirps, err := m.ListChirpsByUser(ctx, user.ID)
+        require.NoError(t, err)
+        assert.Empty(t, chirps)
+        chirps, err = m.ListChirpsByUser(ctx, other.ID)
+        require.NoError(t, err)
+        assert.Len(t, chirps, 1)
+
+        hooks, err := m.ListWebhooksByUser(ctx, user.ID)
+        require.NoError(t, err)
+        deliveries, err := m.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{WebhookID: hooks[0].ID, Limit: 10})
+        require.NoError(t, err)
+        assert.Len(t, deliveries, 2)
+    })
+
+    t.Run("Deleting a user deletes their chirps", func(t *testing.T) {
+        n, err := svc.Delete(ctx, other.ID)
+        require.NoError(t, err)
+        assert.Equal(t, 1, n)
+
+        _, err = svc.Lookup(ctx, other.ID.String())
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+        _, err = svc.Delete(ctx, other.ID)
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+        _, err = svc.PurgeChirps(ctx, other.ID)
+        assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+    })
+}
+
+func TestSessions(t *testing.T) {
+    ctx := context.Background()
+    svc, m := newService(t)
+    user := newUser(t, svc, "walt@example.com")
+    newToken(t, m, user.ID, "aaaaaaaaaaaa-first", time.Now().Add(-time.Hour))
+    newToken(t, m, user.ID, "aaaaaaaaaaab-second", time.Now().Add(time.Hour))
+    newToken(t, m, user.ID, "bbbbbbbbbbbb-third", time.Now().Add(time.Hour))
+
+    t.Run("Sessions are listed newest first without their tokens", func(t *testing.T) {
+        sessions, err := svc.Sessions(ctx, user.ID)
+        require.NoError(t, err)
+        require.Len(t, sessions, 3)
+        assert.Equal(t, "bbbbbbbbbbbb", sessions[0].ID)
+        assert.Equal(t, accounts.SessionActive, sessions[0].Status)
+        assert.Equal(t, "aaaaaaaaaaaa", sessions[2].ID)
+        assert.Equal(t, accounts.SessionExpired, sessions[2].Status)
+    })
+
+    t.Run("A session is revoked by a unique prefix", func(t *testing.T) {
+        _, err := svc.RevokeSession(ctx, user.ID, "aaaa")
+        assert.ErrorContains(t, err, "ambiguous")
+        _, err = svc.RevokeSession(ctx, user.ID, "cccc")
+        assert.ErrorIs(t, err, accounts.ErrSessionNotFound)
+        _, err = svc.RevokeSession(ctx, user.ID, "")
+        assert.ErrorIs(t, err, accounts.ErrSessionNotFound)
+
+        session, err := svc.RevokeSession(ctx, user.ID, "bbbb")
+        require.NoError(t, err)
+        assert.Equal(t, accounts.SessionRevoked, session.Status)
+        assert.NotNil(t, session.RevokedAt)
+    })
+
+    t.Run("Every session is revoked", func(t *testing.T) {
+        require.NoError(t, svc.RevokeSessions(ctx, user.ID))
+        sessions, err := svc.Sessions(ctx, user.ID)
+        require.NoError(t, err)
+        for _, s := range sessions {
+            assert.Equal(t, accounts.SessionRevoked, s.Status)
+        }
+    })
+}
+
+func TestAudit(t *testing.T) {
+    ctx := context.Background()
+    svc, m := newService(t)
+    user := newUser(t, svc, "walt@example.com")
+    _, err := m.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: user.ID})
+    require.NoError(t, err)
+    newToken(t, m, user.ID, "3f9a1c0b7e2d-token", time.Now().Add(time.Hour))
+
+    steps := []struct {
+        action string
+        run func() error
+    }{
+        {audit.ActionUserDisabled, func() error { _, err := svc.Disable(ctx, user.ID); return err }},
+        {audit.ActionUserEnabled, func() error { _, err := svc.Enable(ctx, user.ID); return err }},
+        {audit.ActionPasswordReset, func() error { _, err := svc.ResetPassword(ctx, user.ID, "a new password"); return err }},
+        {audit.ActionUserUpgraded, func() error { _, err := svc.SetChirpyRed(ctx, user.ID, true); return err }},
+        {audit.ActionUserDowngraded, func() error { _, err := svc.SetChirpyRed(ctx, user.ID, false); return err }},
+        {audit.ActionTokenRevoked, func() error { _, err := svc.RevokeSession(ctx, user.ID, "3f9a"); return err }},
+        {audit.ActionSessionsRevoked, func() error { return svc.RevokeSessions(ctx, user.ID) }},
+        {audit.ActionRoleChanged, func() error { _, err := svc.SetRole(ctx, user.ID, auth.RoleModerator, uuid.Nil, "test"); return err }},
+        {audit.ActionChirpDeleted, func() error { _, err := svc.PurgeChirps(ctx, user.ID); return err }},
+        {audit.ActionUserDeleted, func() error { _, err := svc.Delete(ctx, user.ID); return err }},
+    }
+    for _, step := range steps {
+        t.Run(step.action + " is recorded as made with chirpy admin", func(t *testing.T) {
+            require.NoError(t, step.run())
+
+            events, err := m.ListAuditEvents(ctx, database.ListAuditEventsParams{MaxRows: 1})
+            require.NoError(t, err)
+            require.Len(t, events, 1)
+            assert.Equal(t, step.action, events[0].Action)
+            assert.False(t, events[0].ActorID.Valid)
+            assert.Contains(t, string(events[0].Metadata), `"source":"chirpy admin"`)
+        })
+    }
+
+    t.Run("Creating a user is recorded as made with chirpy admin", func(t *testing.T) {
+        created := newUser(t, svc, "skyler@example.com")
+
+        events, err := m.ListAuditEvents(ctx, database.ListAuditEventsParams{MaxRows: 1})
+        require.NoError(t, err)
+        require.Len(t, events, 1)
+        assert.Equal(t, audit.ActionUserCreated, events[0].Action)
+        assert.Equal(t, uuid.NullUUID{UUID: created.ID, Valid: true}, events[0].TargetID)
+        assert.False(t, events[0].ActorID.Valid)
+        assert.Contains(t, string(events[0].Metadata), `"source":"chirpy admin"`)
+    })
+
+    t.Run("Nothing is recorded when the change fails", func(t *testing.T) {
+        before, err := m.ListAuditEvents(ctx, database.ListAuditEventsParams{MaxRows: 100})
+        require.NoError(t, err)
+
+        _, err = svc.Disable(ctx, user.ID)
+        require.ErrorIs(t, err, accounts.ErrUserNotFound)
+
+        after, err := m.ListAuditEvents(ctx, database.ListAuditEventsParams{MaxRows: 100})
+        require.NoError(t, err)
+        assert.Len(t, after, len(before))
+    })
+}
@@ -0,0 +1,85 @@
+// Package apitest serves the real API handlers for tests of its clients.
+package apitest
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/accounts"
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/handlers"
+	"github.com/bamcmanus/Chirpy/internal/health"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/ratelimit"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+    JWTSecret = "test-jwt-secret"
+    PolkaKey = "test-polka-key"
+)
+
+// Server is the API on the dev platform, backed by an in-memory store.
+type Server struct {
+    *httptest.Server
+    Store *store.Memory
+}
+
+// NewServer starts a server wired the same way main does. It is closed when
+// the test ends.
+func NewServer(t testing.TB) *Server {
+    t.Helper()
+
+    s := store.NewMemory()
+    m := metrics.New(nil)
+    checker := health.New(time.Second)
+    checker.Add("database", func(context.Context) error { return nil })
+
+    mux := http.NewServeMux()
+    handlers.Routes(mux, handlers.Deps{
+        Store: s,
+        Tx: s,
+        Metrics: m,
+        Checker: checker,
+        Platform: "dev",
+        JWTSecret: JWTSecret,
+        PolkaKey: PolkaKey,
+        JWTLifetime: time.Hour,
+        RefreshTokenLifetime: 24 * time.Hour,
+    })
+
+    server := httptest.NewServer(handlers.Middleware(mux, slog.New(slog.DiscardHandler), m, nil, clientIP))
+    t.Cleanup(server.Close)
+    return &Server{Server: server, Store: s}
+}
+
+// clientIP is the peer's address, as main finds it with no trusted proxies.
+func clientIP(req *http.Request) string {
+    return ratelimit.Proxies(nil).ClientIP(req)
+}
+
+// SetRole gives the user with the given email a role, as chirpy admin would.
+// Tokens issued before then keep the old role until they are refreshed.
+func (s *Server) SetRole(t testing.TB, email string, role auth.Role) database.User {
+    t.Helper()
+
+    ctx := context.Background()
+    a := accounts.New(s.Store, s.Store, webhooks.NewDispatcher(s.Store), audit.NewLog(s.Store))
+    user, err := a.Lookup(ctx, email)
+    if err != nil {
+        t.Fatalf("looking up %s: %s", email, err)
+    }
+    if _, err := a.SetRole(ctx, user.ID, role, uuid.Nil, "test"); err != nil {
+        t.Fatalf("setting the role of %s: %s", email, err)
+    }
+    user.Role = string(role)
+    return user
+}
@@ -0,0 +1,135 @@
+// Package audit records privileged and security-relevant actions, such as
+// logins, password changes and admin resets, in the append-only audit_events
+// table, for compliance. Actions that change data record their event through
+// the same transaction as the change, so one is never kept without the other.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+    ActionLoginSucceeded = "login.succeeded"
+    ActionLoginFailed = "login.failed"
+    ActionTokenRefreshed = "token.refreshed"
+    ActionTokenRevoked = "token.revoked"
+    ActionPasswordChanged = "user.password_changed"
+    ActionEmailChanged = "user.email_changed"
+    ActionUserCreated = "user.created"
+    ActionUserUpgraded = "user.upgraded"
+    ActionUserDowngraded = "user.downgraded"
+    ActionUserDisabled = "user.disabled"
+    ActionUserEnabled = "user.enabled"
+    ActionUserDeleted = "user.deleted"
+    ActionPasswordReset = "user.password_reset"
+    ActionRoleChanged = "user.role_changed"
+    ActionSessionsRevoked = "user.sessions_revoked"
+    ActionChirpDeleted = "chirp.deleted"
+    ActionAdminReset = "admin.reset"
+    ActionAdminSeed = "admin.seed"
+)
+
+// Actions lists every action that is recorded.
+var Actions = []string{
+    ActionLoginSucceeded, ActionLoginFailed, ActionTokenRefreshed, ActionTokenRevoked, ActionPasswordChanged,
+    ActionEmailChanged, ActionUserCreated, ActionUserUpgraded, ActionUserDowngraded, ActionUserDisabled,
+    ActionUserEnabled, ActionUserDeleted, ActionPasswordReset, ActionRoleChanged, ActionSessionsRevoked,
+    ActionChirpDeleted, ActionAdminReset, ActionAdminSeed,
+}
+
+func IsValidAction(action string) bool {
+    return slices.Contains(Actions, action)
+}
+
+// maxUserAgentLength bounds the user agent stored with each event, which the
+// client chooses.
+const maxUserAgentLength = 512
+
+// Event is an action to record. ActorID is the user who acted and TargetID the
+// user or chirp acted on; either is uuid.Nil when there is none, as for a
+// failed login, which has no actor, or an admin reset, which has no target.
+type Event struct {
+    Action string
+    ActorID uuid.UUID
+    TargetID uuid.UUID
+    Metadata map[string]any
+}
+
+type Log struct {
+    store store.AuditStore
+}
+
+func NewLog(s store.AuditStore) Log {
+    return Log{
+        store: s,
+    }
+}
+
+// With returns a Log that records through s, so that events are written in the
+// same transaction as the action they describe.
+func (l Log) With(s store.AuditStore) Log {
+    return Log{
+        store: s,
+    }
+}
+
+// Record writes the event with the IP and user agent of the client whose
+// request ctx belongs to; see Middleware. Both are empty outside of a request.
+func (l Log) Record(ctx context.Context, event Event) error {
+    metadata := []byte("{}")
+    if len(event.Metadata) > 0 {
+        var err error
+        metadata, err = json.Marshal(event.Metadata)
+        if err != nil {
+            return fmt.Errorf("marshalling %s metadata: %w", event.Action, err)
+        }
+    }
+
+    c, _ := ctx.Value(clientKey{}).(client)
+    _, err := l.store.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+        Action: event.Action,
+        ActorID: nullID(event.ActorID),
+        TargetID: nullID(event.TargetID),
+        Ip: c.ip,
+        UserAgent: c.userAgent,
+        Metadata: metadata,
+    })
+    if err != nil {
+        return fmt.Errorf("recording %s: %w", event.Action, err)
+    }
+    return nil
+}
+
+func nullID(id uuid.UUID) uuid.NullUUID {
+    return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+type client struct {
+    ip string
+    userAgent string
+}
+
+type clientKey struct{}
+
+// Middleware stores the client's IP, as found by clientIP, and user agent in
+// each request's context for Record.
+func Middleware(clientIP func(*http.Request) string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            userAgent := req.UserAgent()
+            if len(userAgent) > maxUserAgentLength {
+                userAgent = userAgent[:maxUserAgentLength]
+            }
+            c := client{ip: clientIP(req), userAgent: userAgent}
+            next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), clientKey{}, c)))
+        })
+    }
+}
@@ -0,0 +1,62 @@
+package audit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listEvents(t *testing.T, s *store.Memory) []database.AuditEvent {
+    t.Helper()
+    events, err := s.ListAuditEvents(context.Background(), database.ListAuditEventsParams{MaxRows: 10})
+    require.NoError(t, err)
+    return events
+}
+
+func TestRecord(t *testing.T) {
+    t.Run("Requests record the client", func(t *testing.T) {
+        s := store.NewMemory()
+        log := audit.NewLog(s)
+        actor := uuid.New()
+
+        handler := audit.Middleware(func(*http.Request) string { return "192.0.2.1" })(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            err := log.Record(req.Context(), audit.Event{
+                Action: audit.ActionAdminReset,
+                ActorID: actor,
+                Metadata: map[string]any{"platform": "dev"},
+            })
+            require.NoError(t, err)
+        }))
+        req := httptest.NewRequest(http.MethodPost, "/admin/reset", nil)
+        req.Header.Set("User-Agent", "curl/8.0 "+strings.Repeat("x", 1000))
+        handler.ServeHTTP(httptest.NewRecorder(), req)
+
+        events := listEvents(t, s)
+        require.Len(t, events, 1)
+        assert.Equal(t, audit.ActionAdminReset, events[0].Action)
+        assert.Equal(t, uuid.NullUUID{UUID: actor, Valid: true}, events[0].ActorID)
+        assert.False(t, events[0].TargetID.Valid)
+        assert.Equal(t, "192.0.2.1", events[0].Ip)
+        assert.Len(t, events[0].UserAgent, 512)
+        assert.JSONEq(t, `{"platform": "dev"}`, string(events[0].Metadata))
+    })
+
+    t.Run("Events outside a request have no client", func(t *testing.T) {
+        s := store.NewMemory()
+        require.NoError(t, audit.NewLog(s).Record(context.Background(), audit.Event{Action: audit.ActionUserUpgraded}))
+
+        events := listEvents(t, s)
+        require.Len(t, events, 1)
+        assert.Empty(t, events[0].Ip)
+        assert.JSONEq(t, `{}`, string(events[0].Metadata))
+    })
+}
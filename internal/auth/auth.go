@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -13,6 +14,11 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+    ErrMissingAuthorization = errors.New("missing Authorization header")
+    ErrMalformedAuthorization = errors.New("malformed Authorization header")
+)
+
 func HashPassword(password string) (string, error) {
     hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
     if err != nil {
@@ -25,8 +31,21 @@ func CheckPasswordHash(hash, password string) error {
     return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-    claims := newRegisteredClaims(userID, expiresIn)
+// claims are the JWT claims Chirpy issues. Scope is a space-separated list,
+// as in RFC 8693. Role is the user's role when the token was issued, so a
+// change of role applies once the user's access token is refreshed.
+type claims struct {
+    jwt.RegisteredClaims
+    Scope string `json:"scope,omitempty"`
+    Role Role `json:"role,omitempty"`
+}
+
+func MakeJWT(userID uuid.UUID, role Role, tokenSecret string, expiresIn time.Duration, scopes ...string) (string, error) {
+    claims := claims{
+        RegisteredClaims: newRegisteredClaims(userID, expiresIn),
+        Scope: strings.Join(scopes, " "),
+        Role: role,
+    }
     jwt := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
     return jwt.SignedString([]byte(tokenSecret))
 }
@@ -42,38 +61,65 @@ func newRegisteredClaims(userId uuid.UUID, expirationTime time.Duration) jwt.Reg
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-    var id uuid.UUID
-    var claims jwt.RegisteredClaims
+    principal, err := ParseJWT(tokenString, tokenSecret)
+    return principal.UserID, err
+}
+
+// ParseJWT validates an access token and returns the principal it was
+// issued to.
+func ParseJWT(tokenString, tokenSecret string) (Principal, error) {
+    var claims claims
     _, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {
         return []byte(tokenSecret), nil
-    })
+    }, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
     if err != nil {
-        return id, err
+        return Principal{}, err
+    }
+
+    var id uuid.UUID
+    if err := id.UnmarshalText([]byte(claims.Subject)); err != nil {
+        return Principal{}, err
     }
-    
-    userID := claims.Subject
-    if err := id.UnmarshalText([]byte(userID)); err != nil {
-        return id, err
+
+    // Tokens issued before roles existed have none and belong to users.
+    role := RoleUser
+    if claims.Role != "" {
+        role, err = ParseRole(string(claims.Role))
+        if err != nil {
+            return Principal{}, err
+        }
     }
-    return id, nil
+    return Principal{UserID: id, Role: role, Scopes: strings.Fields(claims.Scope), Method: MethodJWT}, nil
 }
 
+// GetBearerToken returns the token from an "Authorization: Bearer <token>"
+// header.
 func GetBearerToken(headers http.Header) (string, error) {
-    authHeader := headers.Get("Authorization")
-    if authHeader == "" {
-        return "", errors.New("missing Authorization header")
-    }
-
-    return strings.TrimPrefix(authHeader, "Bearer "), nil
+    return getCredentials(headers, "Bearer")
 }
 
+// GetAPIKey returns the key from an "Authorization: ApiKey <key>" header.
 func GetAPIKey(headers http.Header) (string, error) {
+    return getCredentials(headers, "ApiKey")
+}
+
+// getCredentials parses an Authorization header of the form
+// "<scheme> <credentials>". The scheme is matched case-insensitively, as
+// RFC 9110 requires, but must be present: a bare token is rejected.
+func getCredentials(headers http.Header, scheme string) (string, error) {
     authHeader := headers.Get("Authorization")
     if authHeader == "" {
-        return "", errors.New("missing Authorization header")
+        return "", ErrMissingAuthorization
     }
 
-    return strings.TrimPrefix(authHeader, "ApiKey "), nil
+    got, credentials, ok := strings.Cut(authHeader, " ")
+    if !ok || !strings.EqualFold(got, scheme) {
+        return "", fmt.Errorf("%w: expected the %s scheme", ErrMalformedAuthorization, scheme)
+    }
+    if credentials == "" || strings.ContainsAny(credentials, " \t") {
+        return "", fmt.Errorf("%w: expected a single %s credential", ErrMalformedAuthorization, scheme)
+    }
+    return credentials, nil
 }
 
 func MakeRefreshToken() (string, error) {
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -15,7 +16,7 @@ func TestJWT(t *testing.T) {
         tokenSecret := "my-secret-key"
         expiresIn := 1 * time.Minute
 
-        jwt, err := MakeJWT(id, tokenSecret, expiresIn)
+        jwt, err := MakeJWT(id, RoleUser, tokenSecret, expiresIn)
 
         assert.NotEmpty(t, jwt)
         assert.NoError(t, err)
@@ -31,7 +32,7 @@ func TestJWT(t *testing.T) {
         tokenSecret := "my-secret-key"
         expiresIn := 1 * time.Minute
 
-        jwt, err := MakeJWT(id, tokenSecret, expiresIn)
+        jwt, err := MakeJWT(id, RoleUser, tokenSecret, expiresIn)
 
         assert.NotEmpty(t, jwt)
         assert.NoError(t, err)
@@ -41,12 +42,67 @@ func TestJWT(t *testing.T) {
         assert.EqualError(t, err, "token signature is invalid: signature is invalid")
     })
 
+    t.Run("Scopes round-trip", func(t *testing.T) {
+        id := uuid.New()
+        jwt, err := MakeJWT(id, RoleUser, "my-secret-key", time.Minute, "chirps:write", "admin")
+        assert.NoError(t, err)
+
+        principal, err := ParseJWT(jwt, "my-secret-key")
+
+        assert.NoError(t, err)
+        assert.Equal(t, Principal{UserID: id, Role: RoleUser, Scopes: []string{"chirps:write", "admin"}, Method: MethodJWT}, principal)
+        assert.True(t, principal.HasScope("admin"))
+        assert.False(t, principal.HasScope("chirps:read"))
+    })
+
+    t.Run("Roles round-trip", func(t *testing.T) {
+        jwt, err := MakeJWT(uuid.New(), RoleModerator, "my-secret-key", time.Minute)
+        assert.NoError(t, err)
+
+        principal, err := ParseJWT(jwt, "my-secret-key")
+
+        assert.NoError(t, err)
+        assert.Equal(t, RoleModerator, principal.Role)
+        assert.True(t, principal.Can(PermModerateChirps))
+        assert.False(t, principal.Can(PermManageRoles))
+    })
+
+    t.Run("Tokens without a role belong to users", func(t *testing.T) {
+        token := jwt.NewWithClaims(jwt.SigningMethodHS256, newRegisteredClaims(uuid.New(), time.Minute))
+        signed, err := token.SignedString([]byte("my-secret-key"))
+        assert.NoError(t, err)
+
+        principal, err := ParseJWT(signed, "my-secret-key")
+
+        assert.NoError(t, err)
+        assert.Equal(t, RoleUser, principal.Role)
+    })
+
+    t.Run("Unknown roles are rejected", func(t *testing.T) {
+        jwt, err := MakeJWT(uuid.New(), "superuser", "my-secret-key", time.Minute)
+        assert.NoError(t, err)
+
+        _, err = ParseJWT(jwt, "my-secret-key")
+
+        assert.ErrorContains(t, err, `unknown role "superuser"`)
+    })
+
+    t.Run("Other signing methods are rejected", func(t *testing.T) {
+        token := jwt.NewWithClaims(jwt.SigningMethodHS512, newRegisteredClaims(uuid.New(), time.Minute))
+        signed, err := token.SignedString([]byte("my-secret-key"))
+        assert.NoError(t, err)
+
+        _, err = ValidateJWT(signed, "my-secret-key")
+
+        assert.ErrorContains(t, err, "signing method HS512 is invalid")
+    })
+
     t.Run("expiration", func(t *testing.T) {
         id := uuid.New()
         tokenSecret := "my-secret-key"
         expiresIn := 1 * time.Millisecond
 
-        jwt, err := MakeJWT(id, tokenSecret, expiresIn)
+        jwt, err := MakeJWT(id, RoleUser, tokenSecret, expiresIn)
 
         assert.NotEmpty(t, jwt)
         assert.NoError(t, err)
@@ -57,6 +113,21 @@ func TestJWT(t *testing.T) {
     })
 }
 
+func TestRoles(t *testing.T) {
+    for _, p := range []Permission{PermModerateChirps, PermViewMetrics, PermManageJobs, PermManageRoles, PermResetData, PermReadAudit} {
+        assert.False(t, RoleUser.Can(p), p)
+        assert.True(t, RoleAdmin.Can(p), p)
+    }
+    assert.True(t, RoleModerator.Can(PermModerateChirps))
+    assert.False(t, RoleModerator.Can(PermViewMetrics))
+
+    role, err := ParseRole("moderator")
+    assert.NoError(t, err)
+    assert.Equal(t, RoleModerator, role)
+    _, err = ParseRole("Admin")
+    assert.Error(t, err)
+}
+
 func TestHeader(t *testing.T) {
     t.Run("no authorization header", func(t *testing.T) {
         var header http.Header
@@ -76,4 +147,43 @@ func TestHeader(t *testing.T) {
         assert.NoError(t, err)
         assert.Equal(t, "token", jwt)
     })
+
+    t.Run("Strict parsing", func(t *testing.T) {
+        tests := []struct{
+            name string
+            header string
+            want string
+            err error
+        }{
+            {"Scheme is case-insensitive", "bearer token", "token", nil},
+            {"Missing scheme", "token", "", ErrMalformedAuthorization},
+            {"Other scheme", "ApiKey token", "", ErrMalformedAuthorization},
+            {"Scheme without a token", "Bearer ", "", ErrMalformedAuthorization},
+            {"Scheme prefix only", "Bearertoken", "", ErrMalformedAuthorization},
+            {"Extra fields", "Bearer token extra", "", ErrMalformedAuthorization},
+        }
+        for _, tt := range tests {
+            t.Run(tt.name, func(t *testing.T) {
+                header := make(http.Header)
+                header.Set("Authorization", tt.header)
+
+                token, err := GetBearerToken(header)
+
+                assert.ErrorIs(t, err, tt.err)
+                assert.Equal(t, tt.want, token)
+            })
+        }
+    })
+
+    t.Run("API keys", func(t *testing.T) {
+        header := make(http.Header)
+        header.Set("Authorization", "ApiKey key")
+        key, err := GetAPIKey(header)
+        assert.NoError(t, err)
+        assert.Equal(t, "key", key)
+
+        header.Set("Authorization", "key")
+        _, err = GetAPIKey(header)
+        assert.ErrorIs(t, err, ErrMalformedAuthorization)
+    })
 }
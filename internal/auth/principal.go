@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Method is how a principal authenticated.
+type Method string
+
+const (
+    MethodJWT Method = "jwt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+    UserID uuid.UUID
+    Role Role
+    Scopes []string
+    Method Method
+}
+
+func (p Principal) HasScope(scope string) bool {
+    return slices.Contains(p.Scopes, scope)
+}
+
+// Can reports whether the principal's role grants p.
+func (p Principal) Can(perm Permission) bool {
+    return p.Role.Can(perm)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+    return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored in ctx, if the request
+// was authenticated.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+    p, ok := ctx.Value(principalKey{}).(Principal)
+    return p, ok
+}
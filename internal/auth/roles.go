@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Role is a user's standing beyond their own account. Every user may manage
+// their own account and chirps; roles grant permissions over everyone else's.
+type Role string
+
+const (
+    RoleUser Role = "user"
+    RoleModerator Role = "moderator"
+    RoleAdmin Role = "admin"
+)
+
+// Roles lists the roles from least to most privileged.
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+// ParseRole returns the role named s.
+func ParseRole(s string) (Role, error) {
+    if !slices.Contains(Roles, Role(s)) {
+        return "", fmt.Errorf("unknown role %q; must be user, moderator or admin", s)
+    }
+    return Role(s), nil
+}
+
+// Permission is something a role may do.
+type Permission string
+
+const (
+    // PermModerateChirps allows deleting any user's chirps.
+    PermModerateChirps Permission = "chirps:moderate"
+    // PermViewMetrics allows viewing /admin/metrics.
+    PermViewMetrics Permission = "metrics:read"
+    // PermManageJobs allows listing and retrying background jobs.
+    PermManageJobs Permission = "jobs:manage"
+    // PermManageRoles allows changing users' roles and reading the log of
+    // changes.
+    PermManageRoles Permission = "roles:manage"
+    // PermResetData allows emptying the database and loading fixtures on the
+    // dev platform.
+    PermResetData Permission = "data:reset"
+    // PermReadAudit allows reading and exporting the audit log.
+    PermReadAudit Permission = "audit:read"
+)
+
+var rolePermissions = map[Role][]Permission{
+    RoleModerator: {PermModerateChirps},
+    RoleAdmin: {PermModerateChirps, PermViewMetrics, PermManageJobs, PermManageRoles, PermResetData, PermReadAudit},
+}
+
+// Can reports whether the role grants p.
+func (r Role) Can(p Permission) bool {
+    return slices.Contains(rolePermissions[r], p)
+}
@@ -0,0 +1,577 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Config holds every runtime setting for Chirpy. Values are resolved with the
+// following precedence, lowest first: built-in defaults, the YAML config file,
+// environment variables, and command-line flags.
+type Config struct {
+    Addr string `yaml:"addr"`
+    // MetricsAddr is where /metrics is served, apart from Addr so that it can
+    // be kept off the public network. Empty turns it off.
+    MetricsAddr string `yaml:"metrics_addr"`
+    Platform string `yaml:"platform"`
+    DBURL string `yaml:"db_url"`
+    // AutoMigrate applies pending migrations at startup.
+    AutoMigrate bool `yaml:"auto_migrate"`
+    // RunWorkers runs the background workers in the API server. Turn it off
+    // when they run separately under `chirpy worker`.
+    RunWorkers bool `yaml:"run_workers"`
+    JWTSecret string `yaml:"jwt_secret"`
+    PolkaKey string `yaml:"polka_key"`
+    JWTLifetime time.Duration `yaml:"jwt_lifetime"`
+    RefreshTokenLifetime time.Duration `yaml:"refresh_token_lifetime"`
+    Log LogConfig `yaml:"log"`
+    Tracing TracingConfig `yaml:"tracing"`
+    Server ServerConfig `yaml:"server"`
+    Webhooks WebhooksConfig `yaml:"webhooks"`
+    Jobs JobsConfig `yaml:"jobs"`
+    TokenGC TokenGCConfig `yaml:"token_gc"`
+    RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+type LogConfig struct {
+    Level string `yaml:"level"`
+    Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+    // Exporter is one of "none", "stdout" or "otlp".
+    Exporter string `yaml:"exporter"`
+    // Endpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318.
+    // When empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+    Endpoint string `yaml:"endpoint"`
+    // File is where the stdout exporter writes spans; empty means stdout.
+    File string `yaml:"file"`
+    ServiceName string `yaml:"service_name"`
+}
+
+type ServerConfig struct {
+    ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+    ReadTimeout time.Duration `yaml:"read_timeout"`
+    WriteTimeout time.Duration `yaml:"write_timeout"`
+    IdleTimeout time.Duration `yaml:"idle_timeout"`
+    ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+    // ShutdownDelay is how long to keep serving, with readiness failing,
+    // before draining, so load balancers stop sending new requests first.
+    ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+    ReadinessTimeout time.Duration `yaml:"readiness_timeout"`
+    MaxHeaderBytes int `yaml:"max_header_bytes"`
+}
+
+type WebhooksConfig struct {
+    PollInterval time.Duration `yaml:"poll_interval"`
+    BatchSize int `yaml:"batch_size"`
+    MaxAttempts int `yaml:"max_attempts"`
+    DisableAfter int `yaml:"disable_after"`
+}
+
+type JobsConfig struct {
+    PollInterval time.Duration `yaml:"poll_interval"`
+    // Concurrency is the number of jobs each worker runs at once.
+    Concurrency int `yaml:"concurrency"`
+    // MaxAttempts is the number of attempts after which a failing job is
+    // moved to the dead-letter queue.
+    MaxAttempts int `yaml:"max_attempts"`
+}
+
+type TokenGCConfig struct {
+    // Interval is how often the workers sweep the refresh_tokens table.
+    Interval time.Duration `yaml:"interval"`
+    // RevokedRetention is how long revoked tokens are kept before deletion.
+    // Expired tokens are deleted straight away.
+    RevokedRetention time.Duration `yaml:"revoked_retention"`
+    // BatchSize is the number of rows each DELETE removes, which bounds how
+    // long it holds its locks.
+    BatchSize int `yaml:"batch_size"`
+}
+
+type RateLimitConfig struct {
+    Enabled bool `yaml:"enabled"`
+    // Store is "memory", which limits each server separately, or "database",
+    // which shares the limits between every server using the database.
+    Store string `yaml:"store"`
+    // TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+    // whose X-Forwarded-For header is believed when finding the client IP.
+    TrustedProxies []string `yaml:"trusted_proxies"`
+    // Policies maps route patterns, as registered on the mux, to their limits.
+    // Routes without a policy are not limited.
+    Policies map[string]RateLimitPolicy `yaml:"policies"`
+}
+
+// RateLimitPolicy allows Requests per Period to each key, in bursts of up to
+// Burst requests. A policy with zero Requests turns limiting off for its
+// route.
+type RateLimitPolicy struct {
+    Requests int `yaml:"requests"`
+    Period time.Duration `yaml:"period"`
+    // Burst defaults to Requests.
+    Burst int `yaml:"burst"`
+    // Key is what requests are counted by: "ip", "user" or "api_key". User
+    // and API key policies fall back to the client IP for requests without
+    // a valid credential.
+    Key string `yaml:"key"`
+}
+
+func Default() Config {
+    return Config{
+        Addr: ":8080",
+        MetricsAddr: "localhost:9090",
+        RunWorkers: true,
+        JWTLifetime: time.Hour,
+        RefreshTokenLifetime: 60 * 24 * time.Hour,
+        Log: LogConfig{
+            Level: "info",
+            Format: "json",
+        },
+        Tracing: TracingConfig{
+            Exporter: "none",
+            ServiceName: "chirpy",
+        },
+        Server: ServerConfig{
+            ReadHeaderTimeout: 5 * time.Second,
+            ReadTimeout: 15 * time.Second,
+            WriteTimeout: 30 * time.Second,
+            IdleTimeout: 2 * time.Minute,
+            ShutdownTimeout: 30 * time.Second,
+            ReadinessTimeout: 2 * time.Second,
+            MaxHeaderBytes: 64 << 10,
+        },
+        Webhooks: WebhooksConfig{
+            PollInterval: 5 * time.Second,
+            BatchSize: 20,
+            MaxAttempts: 8,
+            DisableAfter: 20,
+        },
+        Jobs: JobsConfig{
+            PollInterval: time.Second,
+            Concurrency: 4,
+            MaxAttempts: 10,
+        },
+        TokenGC: TokenGCConfig{
+            Interval: time.Hour,
+            RevokedRetention: 7 * 24 * time.Hour,
+            BatchSize: 1000,
+        },
+        // Rate limiting is opt-in, so upgrading doesn't start rejecting the
+        // requests of existing clients. The policies apply once it is enabled.
+        RateLimit: RateLimitConfig{
+            Store: "memory",
+            Policies: map[string]RateLimitPolicy{
+                "POST /api/login": {Requests: 10, Period: time.Minute, Key: "ip"},
+                "POST /api/users": {Requests: 10, Period: time.Hour, Burst: 5, Key: "ip"},
+                "POST /api/chirps": {Requests: 30, Period: time.Minute, Key: "user"},
+            },
+        },
+    }
+}
+
+// setting binds one Config field to its flag and environment variable.
+type setting struct {
+    flag string
+    env string
+    usage string
+    secret bool
+    isBool bool
+    set func(string) error
+    get func() string
+}
+
+func (c *Config) settings() []setting {
+    return []setting{
+        stringSetting("addr", "ADDR", "address for the HTTP server to listen on", false, &c.Addr),
+        stringSetting("metrics-addr", "METRICS_ADDR", "address to serve /metrics on; empty turns it off", false, &c.MetricsAddr),
+        stringSetting("platform", "PLATFORM", "deployment platform, e.g. dev or prod", false, &c.Platform),
+        stringSetting("db-url", "DB_URL", "PostgreSQL connection URL", true, &c.DBURL),
+        boolSetting("auto-migrate", "AUTO_MIGRATE", "apply pending database migrations at startup", &c.AutoMigrate),
+        boolSetting("run-workers", "RUN_WORKERS", "run the background workers in the API server", &c.RunWorkers),
+        stringSetting("jwt-secret", "JWT_SECRET", "secret used to sign and verify JWTs", true, &c.JWTSecret),
+        stringSetting("polka-key", "POLKA_KEY", "API key Polka uses to authenticate webhooks", true, &c.PolkaKey),
+        durationSetting("jwt-lifetime", "JWT_LIFETIME", "lifetime of issued access tokens", &c.JWTLifetime),
+        durationSetting("refresh-token-lifetime", "REFRESH_TOKEN_LIFETIME", "lifetime of issued refresh tokens", &c.RefreshTokenLifetime),
+        stringSetting("log-level", "LOG_LEVEL", "minimum log level: debug, info, warn or error", false, &c.Log.Level),
+        stringSetting("log-format", "LOG_FORMAT", "log output format: json or text", false, &c.Log.Format),
+        stringSetting("tracing-exporter", "TRACING_EXPORTER", "trace exporter: none, stdout or otlp", false, &c.Tracing.Exporter),
+        stringSetting("tracing-endpoint", "TRACING_ENDPOINT", "OTLP/HTTP collector URL", false, &c.Tracing.Endpoint),
+        stringSetting("tracing-file", "TRACING_FILE", "file the stdout trace exporter writes to", false, &c.Tracing.File),
+        stringSetting("tracing-service-name", "TRACING_SERVICE_NAME", "service name reported with spans", false, &c.Tracing.ServiceName),
+        durationSetting("read-header-timeout", "READ_HEADER_TIMEOUT", "time allowed to read request headers", &c.Server.ReadHeaderTimeout),
+        durationSetting("read-timeout", "READ_TIMEOUT", "time allowed to read an entire request", &c.Server.ReadTimeout),
+        durationSetting("write-timeout", "WRITE_TIMEOUT", "time allowed to write a response", &c.Server.WriteTimeout),
+        durationSetting("idle-timeout", "IDLE_TIMEOUT", "how long idle keep-alive connections are kept open", &c.Server.IdleTimeout),
+        durationSetting("shutdown-timeout", "SHUTDOWN_TIMEOUT", "how long to drain in-flight requests on shutdown", &c.Server.ShutdownTimeout),
+        durationSetting("shutdown-delay", "SHUTDOWN_DELAY", "how long readiness fails before draining on shutdown", &c.Server.ShutdownDelay),
+        durationSetting("readiness-timeout", "READINESS_TIMEOUT", "time allowed for readiness checks", &c.Server.ReadinessTimeout),
+        intSetting("max-header-bytes", "MAX_HEADER_BYTES", "maximum size of request headers", &c.Server.MaxHeaderBytes),
+        durationSetting("webhook-poll-interval", "WEBHOOK_POLL_INTERVAL", "how often to poll for due webhook deliveries", &c.Webhooks.PollInterval),
+        intSetting("webhook-batch-size", "WEBHOOK_BATCH_SIZE", "webhook deliveries claimed per poll", &c.Webhooks.BatchSize),
+        intSetting("webhook-max-attempts", "WEBHOOK_MAX_ATTEMPTS", "attempts before a webhook delivery is abandoned", &c.Webhooks.MaxAttempts),
+        intSetting("webhook-disable-after", "WEBHOOK_DISABLE_AFTER", "consecutive failures before a webhook is disabled", &c.Webhooks.DisableAfter),
+        durationSetting("job-poll-interval", "JOB_POLL_INTERVAL", "how often to poll for due jobs", &c.Jobs.PollInterval),
+        intSetting("job-concurrency", "JOB_CONCURRENCY", "jobs each worker runs at once", &c.Jobs.Concurrency),
+        intSetting("job-max-attempts", "JOB_MAX_ATTEMPTS", "attempts before a job is dead-lettered", &c.Jobs.MaxAttempts),
+        durationSetting("token-gc-interval", "TOKEN_GC_INTERVAL", "how often to delete stale refresh tokens", &c.TokenGC.Interval),
+        durationSetting("token-gc-revoked-retention", "TOKEN_GC_REVOKED_RETENTION", "how long revoked refresh tokens are kept", &c.TokenGC.RevokedRetention),
+        intSetting("token-gc-batch-size", "TOKEN_GC_BATCH_SIZE", "refresh tokens deleted per statement", &c.TokenGC.BatchSize),
+        boolSetting("rate-limit", "RATE_LIMIT", "enforce the per-route rate limits", &c.RateLimit.Enabled),
+        stringSetting("rate-limit-store", "RATE_LIMIT_STORE", "where rate limits are kept: memory or database", false, &c.RateLimit.Store),
+        listSetting("trusted-proxies", "TRUSTED_PROXIES", "comma-separated proxy addresses or CIDRs trusted for X-Forwarded-For", &c.RateLimit.TrustedProxies),
+    }
+}
+
+// Load resolves the configuration from defaults, the config file, the
+// environment and the command-line arguments, then validates it. The config
+// file is taken from the -config flag or the CHIRPY_CONFIG variable.
+func Load(args []string, getenv func(string) string) (Config, error) {
+    cfg, rest, err := Parse(args, getenv)
+    if err != nil {
+        return cfg, err
+    }
+    if len(rest) > 0 {
+        return cfg, fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+    }
+    return cfg, cfg.Validate()
+}
+
+// Parse resolves the configuration like Load but does not validate it, and
+// returns the positional arguments that follow the flags. Subcommands use it
+// when they only need some of the settings.
+func Parse(args []string, getenv func(string) string) (Config, []string, error) {
+    cfg := Default()
+    settings := cfg.settings()
+
+    fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+    configPath := fs.String("config", getenv("CHIRPY_CONFIG"), "path to a YAML config file")
+    flagValues := make(map[string]string)
+    for _, s := range settings {
+        name := s.flag
+        usage := s.usage + " (env " + s.env + ")"
+        record := func(value string) error {
+            flagValues[name] = value
+            return nil
+        }
+        if s.isBool {
+            fs.BoolFunc(name, usage, record)
+        } else {
+            fs.Func(name, usage, record)
+        }
+    }
+    if err := fs.Parse(args); err != nil {
+        return cfg, nil, err
+    }
+
+    if *configPath != "" {
+        if err := cfg.loadFile(*configPath); err != nil {
+            return cfg, nil, err
+        }
+    }
+
+    for _, s := range settings {
+        if value := getenv(s.env); value != "" {
+            if err := s.set(value); err != nil {
+                return cfg, nil, fmt.Errorf("invalid %s: %w", s.env, err)
+            }
+        }
+    }
+
+    for _, s := range settings {
+        if value, ok := flagValues[s.flag]; ok {
+            if err := s.set(value); err != nil {
+                return cfg, nil, fmt.Errorf("invalid -%s: %w", s.flag, err)
+            }
+        }
+    }
+
+    return cfg, fs.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("reading config file: %w", err)
+    }
+    defer f.Close()
+
+    decoder := yaml.NewDecoder(f)
+    decoder.KnownFields(true)
+    if err := decoder.Decode(c); err != nil {
+        return fmt.Errorf("parsing config file %s: %w", path, err)
+    }
+    return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+    var errs []error
+    required := map[string]string{
+        "addr": c.Addr,
+        "platform": c.Platform,
+        "db_url": c.DBURL,
+        "jwt_secret": c.JWTSecret,
+        "polka_key": c.PolkaKey,
+    }
+    for _, key := range []string{"addr", "platform", "db_url", "jwt_secret", "polka_key"} {
+        if required[key] == "" {
+            errs = append(errs, fmt.Errorf("%s is required", key))
+        }
+    }
+
+    positive := []struct{
+        key string
+        value time.Duration
+    }{
+        {"jwt_lifetime", c.JWTLifetime},
+        {"refresh_token_lifetime", c.RefreshTokenLifetime},
+        {"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+        {"server.read_timeout", c.Server.ReadTimeout},
+        {"server.write_timeout", c.Server.WriteTimeout},
+        {"server.idle_timeout", c.Server.IdleTimeout},
+        {"server.shutdown_timeout", c.Server.ShutdownTimeout},
+        {"server.readiness_timeout", c.Server.ReadinessTimeout},
+        {"webhooks.poll_interval", c.Webhooks.PollInterval},
+        {"jobs.poll_interval", c.Jobs.PollInterval},
+        {"token_gc.interval", c.TokenGC.Interval},
+    }
+    for _, p := range positive {
+        if p.value <= 0 {
+            errs = append(errs, fmt.Errorf("%s must be positive", p.key))
+        }
+    }
+
+    if c.Server.ShutdownDelay < 0 {
+        errs = append(errs, errors.New("server.shutdown_delay must not be negative"))
+    }
+    if c.RefreshTokenLifetime > 0 && c.RefreshTokenLifetime <= c.JWTLifetime {
+        errs = append(errs, errors.New("refresh_token_lifetime must be longer than jwt_lifetime"))
+    }
+    switch c.Log.Format {
+    case "json", "text":
+    default:
+        errs = append(errs, errors.New("log.format must be json or text"))
+    }
+    switch c.Tracing.Exporter {
+    case "none", "stdout", "otlp":
+    default:
+        errs = append(errs, errors.New("tracing.exporter must be none, stdout or otlp"))
+    }
+    if c.Server.MaxHeaderBytes < 1024 {
+        errs = append(errs, errors.New("server.max_header_bytes must be at least 1024"))
+    }
+    if c.Webhooks.BatchSize < 1 || c.Webhooks.MaxAttempts < 1 || c.Webhooks.DisableAfter < 1 {
+        errs = append(errs, errors.New("webhooks.batch_size, max_attempts and disable_after must be at least 1"))
+    }
+    if c.Jobs.Concurrency < 1 || c.Jobs.MaxAttempts < 1 {
+        errs = append(errs, errors.New("jobs.concurrency and max_attempts must be at least 1"))
+    }
+    if c.TokenGC.RevokedRetention < 0 {
+        errs = append(errs, errors.New("token_gc.revoked_retention must not be negative"))
+    }
+    if c.TokenGC.BatchSize < 1 {
+        errs = append(errs, errors.New("token_gc.batch_size must be at least 1"))
+    }
+    switch c.RateLimit.Store {
+    case "memory", "database":
+    default:
+        errs = append(errs, errors.New("rate_limit.store must be memory or database"))
+    }
+    for _, proxy := range c.RateLimit.TrustedProxies {
+        if _, err := ParseProxy(proxy); err != nil {
+            errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies: %w", err))
+        }
+    }
+    routes := slices.Sorted(maps.Keys(c.RateLimit.Policies))
+    for _, route := range routes {
+        if err := c.RateLimit.Policies[route].validate(); err != nil {
+            errs = append(errs, fmt.Errorf("rate_limit.policies[%q]: %w", route, err))
+        }
+    }
+
+    return errors.Join(errs...)
+}
+
+func (p RateLimitPolicy) validate() error {
+    if p.Requests == 0 {
+        return nil
+    }
+    switch {
+    case p.Requests < 0:
+        return errors.New("requests must not be negative")
+    case p.Period <= 0:
+        return errors.New("period must be positive")
+    case p.Burst < 0:
+        return errors.New("burst must not be negative")
+    }
+    switch p.Key {
+    case "ip", "user", "api_key":
+        return nil
+    default:
+        return errors.New("key must be ip, user or api_key")
+    }
+}
+
+// ParseProxy parses a trusted proxy given as an address or a CIDR range.
+func ParseProxy(s string) (netip.Prefix, error) {
+    if strings.Contains(s, "/") {
+        prefix, err := netip.ParsePrefix(s)
+        return prefix.Masked(), err
+    }
+    addr, err := netip.ParseAddr(s)
+    if err != nil {
+        return netip.Prefix{}, err
+    }
+    addr = addr.Unmap()
+    return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// String renders the effective configuration with secrets redacted, one
+// setting per line.
+func (c Config) String() string {
+    var b strings.Builder
+    b.WriteString("effective configuration:")
+    for _, kv := range c.redacted() {
+        fmt.Fprintf(&b, "\n  %s = %s", kv[0], kv[1])
+    }
+    return b.String()
+}
+
+// LogValue lets the configuration be logged as a structured group with
+// secrets redacted.
+func (c Config) LogValue() slog.Value {
+    var attrs []slog.Attr
+    for _, kv := range c.redacted() {
+        attrs = append(attrs, slog.String(kv[0], kv[1]))
+    }
+    return slog.GroupValue(attrs...)
+}
+
+func (c Config) redacted() [][2]string {
+    var out [][2]string
+    for _, s := range c.settings() {
+        value := s.get()
+        switch {
+        case s.env == "DB_URL":
+            value = redactURL(value)
+        case s.secret && value != "":
+            value = redacted
+        }
+        out = append(out, [2]string{s.flag, value})
+    }
+    return out
+}
+
+// redactURL masks the password in a database URL, whether it is in the
+// userinfo or, as libpq also accepts, in a password query parameter.
+func redactURL(raw string) string {
+    u, err := url.Parse(raw)
+    if err != nil || u.Scheme == "" {
+        return redacted
+    }
+    if q := u.Query(); q.Has("password") {
+        q.Set("password", "xxxxx")
+        u.RawQuery = q.Encode()
+    }
+    return u.Redacted()
+}
+
+func stringSetting(name, env, usage string, secret bool, p *string) setting {
+    return setting{
+        flag: name,
+        env: env,
+        usage: usage,
+        secret: secret,
+        set: func(value string) error {
+            *p = value
+            return nil
+        },
+        get: func() string { return *p },
+    }
+}
+
+func durationSetting(name, env, usage string, p *time.Duration) setting {
+    return setting{
+        flag: name,
+        env: env,
+        usage: usage,
+        set: func(value string) error {
+            d, err := time.ParseDuration(value)
+            if err != nil {
+                return err
+            }
+            *p = d
+            return nil
+        },
+        get: func() string { return p.String() },
+    }
+}
+
+func boolSetting(name, env, usage string, p *bool) setting {
+    return setting{
+        flag: name,
+        env: env,
+        usage: usage,
+        isBool: true,
+        set: func(value string) error {
+            b, err := strconv.ParseBool(value)
+            if err != nil {
+                return err
+            }
+            *p = b
+            return nil
+        },
+        get: func() string { return strconv.FormatBool(*p) },
+    }
+}
+
+// listSetting binds a comma-separated list.
+func listSetting(name, env, usage string, p *[]string) setting {
+    return setting{
+        flag: name,
+        env: env,
+        usage: usage,
+        set: func(value string) error {
+            var items []string
+            for _, item := range strings.Split(value, ",") {
+                if item = strings.TrimSpace(item); item != "" {
+                    items = append(items, item)
+                }
+            }
+            *p = items
+            return nil
+        },
+        get: func() string { return strings.Join(*p, ",") },
+    }
+}
+
+func intSetting(name, env, usage string, p *int) setting {
+    return setting{
+        flag: name,
+        env: env,
+        usage: usage,
+        set: func(value string) error {
+            i, err := strconv.Atoi(value)
+            if err != nil {
+                return err
+            }
+            *p = i
+            return nil
+        },
+        get: func() string { return strconv.Itoa(*p) },
+    }
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -142,11 +143,63 @@ func (q *Queries) ListChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chi
 	return items, nil
 }
 
+const listChirpsPage = `-- name: ListChirpsPage :many
+SELECT id, user_id, created_at, updated_at, body
+FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
+ORDER BY CASE WHEN $2::boolean THEN created_at END DESC, created_at, id
+LIMIT $3
+OFFSET $4
+`
+
+type ListChirpsPageParams struct {
+	AuthorID   uuid.NullUUID
+	Descending bool
+	MaxRows    sql.NullInt32
+	SkipRows   int32
+}
+
+// author_id matches every chirp when null, and max_rows returns every chirp
+// when null. Chirps are oldest first, or newest first when descending.
+func (q *Queries) ListChirpsPage(ctx context.Context, arg ListChirpsPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsPage,
+		arg.AuthorID,
+		arg.Descending,
+		arg.MaxRows,
+		arg.SkipRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const upgradeUser = `-- name: UpgradeUser :one
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, disabled_at, role
 `
 
 func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
@@ -159,6 +212,8 @@ func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DisabledAt,
+		&i.Role,
 	)
 	return i, err
 }
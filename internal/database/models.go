@@ -6,11 +6,23 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Action    string
+	ActorID   uuid.NullUUID
+	TargetID  uuid.NullUUID
+	Ip        string
+	UserAgent string
+	Metadata  json.RawMessage
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
@@ -19,6 +31,19 @@ type Chirp struct {
 	Body      string
 }
 
+type Job struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Kind       string
+	Payload    json.RawMessage
+	Status     string
+	Attempts   int32
+	RunAt      time.Time
+	LastError  sql.NullString
+	FinishedAt sql.NullTime
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt time.Time
@@ -28,6 +53,16 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type RoleChange struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	OldRole   string
+	NewRole   string
+	ChangedBy uuid.NullUUID
+	Reason    string
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +70,41 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	DisabledAt     sql.NullTime
+	Role           string
+}
+
+type Webhook struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	UserID              uuid.UUID
+	Url                 string
+	Secret              string
+	Events              []string
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	WebhookID     uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	DeliveredAt   sql.NullTime
+	TraceContext  string
+}
+
+type WebhookDeliveryAttempt struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	DeliveryID   uuid.UUID
+	ResponseCode sql.NullInt32
+	Error        sql.NullString
+	DurationMs   int32
 }
@@ -0,0 +1,230 @@
+// Package fixtures loads the named datasets that dev and test environments
+// start from: users with their chirps and refresh tokens, under fixed IDs and
+// tokens so that tests can refer to them. Datasets are the YAML files in
+// datasets/, which are embedded in the binary.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed datasets/*.yaml
+var datasets embed.FS
+
+// ErrUnknownDataset is returned by Load for a name that has no dataset.
+var ErrUnknownDataset = errors.New("unknown dataset")
+
+const (
+    // tokenLifetime is how long seeded refresh tokens without an expiry last,
+    // the default refresh_token_lifetime.
+    tokenLifetime = 60 * 24 * time.Hour
+    // maxChirpLength is the length of the chirps.body column.
+    maxChirpLength = 140
+)
+
+// Dataset is the rows one dataset loads. Chirps and tokens refer to their
+// user by email.
+type Dataset struct {
+    Name string `yaml:"-"`
+    Users []User `yaml:"users"`
+    Chirps []Chirp `yaml:"chirps"`
+    Tokens []Token `yaml:"tokens"`
+}
+
+// User is a user to create. Role defaults to user, and CreatedAt to the time
+// of seeding.
+type User struct {
+    ID uuid.UUID `yaml:"id"`
+    Email string `yaml:"email"`
+    Password string `yaml:"password"`
+    Role auth.Role `yaml:"role"`
+    ChirpyRed bool `yaml:"chirpy_red"`
+    CreatedAt time.Time `yaml:"created_at"`
+}
+
+// Chirp is a chirp by the user whose email is Author. Chirps without a
+// CreatedAt are created a millisecond apart from the time of seeding, so they
+// are listed in the order the dataset gives them.
+type Chirp struct {
+    ID uuid.UUID `yaml:"id"`
+    Author string `yaml:"author"`
+    Body string `yaml:"body"`
+    CreatedAt time.Time `yaml:"created_at"`
+}
+
+// Token is a refresh token for the user whose email is User, which tests can
+// exchange for an access token instead of logging in. ExpiresAt defaults to
+// tokenLifetime after seeding.
+type Token struct {
+    Token string `yaml:"token"`
+    User string `yaml:"user"`
+    ExpiresAt time.Time `yaml:"expires_at"`
+    Revoked bool `yaml:"revoked"`
+}
+
+// Names lists the datasets, sorted.
+func Names() []string {
+    files, _ := fs.Glob(datasets, "datasets/*.yaml")
+    names := make([]string, 0, len(files))
+    for _, f := range files {
+        names = append(names, strings.TrimSuffix(path.Base(f), ".yaml"))
+    }
+    return names
+}
+
+// Load reads the named dataset and checks it, so that seeding it can't fail
+// halfway on a mistake in the dataset.
+func Load(name string) (Dataset, error) {
+    if !slices.Contains(Names(), name) {
+        return Dataset{}, fmt.Errorf("%w %q; must be one of %s", ErrUnknownDataset, name, strings.Join(Names(), ", "))
+    }
+    data, err := datasets.ReadFile("datasets/" + name + ".yaml")
+    if err != nil {
+        return Dataset{}, err
+    }
+    return parse(name, data)
+}
+
+func parse(name string, data []byte) (Dataset, error) {
+    d := Dataset{Name: name}
+    dec := yaml.NewDecoder(bytes.NewReader(data))
+    dec.KnownFields(true)
+    // An empty file is an empty dataset.
+    if err := dec.Decode(&d); err != nil && !errors.Is(err, io.EOF) {
+        return Dataset{}, fmt.Errorf("dataset %s: %w", name, err)
+    }
+    for i := range d.Users {
+        if d.Users[i].Role == "" {
+            d.Users[i].Role = auth.RoleUser
+        }
+    }
+    if err := d.validate(); err != nil {
+        return Dataset{}, fmt.Errorf("dataset %s: %w", name, err)
+    }
+    return d, nil
+}
+
+func (d Dataset) validate() error {
+    var errs []error
+    ids := make(map[uuid.UUID]bool)
+    emails := make(map[string]bool)
+    for i, u := range d.Users {
+        if u.ID == uuid.Nil || ids[u.ID] {
+            errs = append(errs, fmt.Errorf("users[%d]: id must be set and unique", i))
+        }
+        if u.Email == "" || emails[u.Email] {
+            errs = append(errs, fmt.Errorf("users[%d]: email must be set and unique", i))
+        }
+        if u.Password == "" {
+            errs = append(errs, fmt.Errorf("users[%d]: password must be set", i))
+        }
+        if _, err := auth.ParseRole(string(u.Role)); err != nil {
+            errs = append(errs, fmt.Errorf("users[%d]: %w", i, err))
+        }
+        ids[u.ID] = true
+        emails[u.Email] = true
+    }
+    for i, c := range d.Chirps {
+        if c.ID == uuid.Nil || ids[c.ID] {
+            errs = append(errs, fmt.Errorf("chirps[%d]: id must be set and unique", i))
+        }
+        if !emails[c.Author] {
+            errs = append(errs, fmt.Errorf("chirps[%d]: author %q is not one of the dataset's users", i, c.Author))
+        }
+        if n := utf8.RuneCountInString(c.Body); n == 0 || n > maxChirpLength {
+            errs = append(errs, fmt.Errorf("chirps[%d]: body must be 1 to %d characters", i, maxChirpLength))
+        }
+        ids[c.ID] = true
+    }
+    tokens := make(map[string]bool)
+    for i, t := range d.Tokens {
+        if t.Token == "" || tokens[t.Token] {
+            errs = append(errs, fmt.Errorf("tokens[%d]: token must be set and unique", i))
+        }
+        if !emails[t.User] {
+            errs = append(errs, fmt.Errorf("tokens[%d]: user %q is not one of the dataset's users", i, t.User))
+        }
+        tokens[t.Token] = true
+    }
+    return errors.Join(errs...)
+}
+
+// Seed empties every table but the audit logs and loads d. s should be bound
+// to a transaction, so that the previous data is only gone once d is loaded.
+func Seed(ctx context.Context, s store.Store, d Dataset) error {
+    if err := s.TruncateTables(ctx); err != nil {
+        return fmt.Errorf("emptying tables: %w", err)
+    }
+    now := time.Now().UTC().Truncate(time.Millisecond)
+
+    userIDs := make(map[string]uuid.UUID, len(d.Users))
+    for _, u := range d.Users {
+        hashedPassword, err := auth.HashPassword(u.Password)
+        if err != nil {
+            return err
+        }
+        _, err = s.SeedUser(ctx, database.SeedUserParams{
+            ID: u.ID,
+            CreatedAt: orTime(u.CreatedAt, now),
+            Email: u.Email,
+            HashedPassword: hashedPassword,
+            IsChirpyRed: u.ChirpyRed,
+            Role: string(u.Role),
+        })
+        if err != nil {
+            return fmt.Errorf("seeding user %s: %w", u.Email, err)
+        }
+        userIDs[u.Email] = u.ID
+    }
+
+    for i, c := range d.Chirps {
+        _, err := s.SeedChirp(ctx, database.SeedChirpParams{
+            ID: c.ID,
+            CreatedAt: orTime(c.CreatedAt, now.Add(time.Duration(i) * time.Millisecond)),
+            Body: c.Body,
+            UserID: userIDs[c.Author],
+        })
+        if err != nil {
+            return fmt.Errorf("seeding chirp %s: %w", c.ID, err)
+        }
+    }
+
+    for _, t := range d.Tokens {
+        _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+            Token: t.Token,
+            UserID: userIDs[t.User],
+            ExpiresAt: orTime(t.ExpiresAt, now.Add(tokenLifetime)),
+        })
+        if err == nil && t.Revoked {
+            _, err = s.RevokeRefreshToken(ctx, t.Token)
+        }
+        if err != nil {
+            return fmt.Errorf("seeding a token of %s: %w", t.User, err)
+        }
+    }
+    return nil
+}
+
+func orTime(t, fallback time.Time) time.Time {
+    if t.IsZero() {
+        return fallback
+    }
+    return t.UTC()
+}
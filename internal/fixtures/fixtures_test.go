@@ -0,0 +1,152 @@
+package fixtures
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+    t.Run("Every dataset is valid", func(t *testing.T) {
+        require.Equal(t, []string{"demo", "minimal"}, Names())
+        for _, name := range Names() {
+            d, err := Load(name)
+            require.NoError(t, err, name)
+            assert.Equal(t, name, d.Name)
+            assert.NotEmpty(t, d.Users, name)
+        }
+    })
+
+    t.Run("Unknown datasets are rejected", func(t *testing.T) {
+        for _, name := range []string{"missing", "../demo", ""} {
+            _, err := Load(name)
+            assert.ErrorIs(t, err, ErrUnknownDataset, name)
+        }
+    })
+}
+
+func TestParse(t *testing.T) {
+    const user = `
+users:
+  - id: 00000000-0000-4000-8000-000000000001
+    email: walt@example.com
+    password: password
+`
+    t.Run("Roles default to user", func(t *testing.T) {
+        d, err := parse("test", []byte(user))
+
+        require.NoError(t, err)
+        assert.Equal(t, auth.RoleUser, d.Users[0].Role)
+    })
+
+    t.Run("An empty file is an empty dataset", func(t *testing.T) {
+        d, err := parse("test", nil)
+
+        require.NoError(t, err)
+        assert.Empty(t, d.Users)
+    })
+
+    tests := []struct {
+        name string
+        data string
+        want string
+    }{
+        {
+            name: "Unknown fields",
+            data: "follows:\n  - follower: walt@example.com\n",
+            want: "field follows not found",
+        },
+        {
+            name: "Duplicate users",
+            data: user + `
+  - id: 00000000-0000-4000-8000-000000000001
+    email: walt@example.com
+    password: password
+`,
+            want: "users[1]: id must be set and unique\nusers[1]: email must be set and unique",
+        },
+        {
+            name: "Unknown roles",
+            data: "users:\n  - {id: 00000000-0000-4000-8000-000000000001, email: a@example.com, password: p, role: owner}\n",
+            want: `users[0]: unknown role "owner"`,
+        },
+        {
+            name: "Chirps by strangers",
+            data: user + "chirps:\n  - {id: 00000000-0000-4000-9000-000000000001, author: jesse@example.com, body: hi}\n",
+            want: `chirps[0]: author "jesse@example.com" is not one of the dataset's users`,
+        },
+        {
+            name: "Empty chirps",
+            data: user + "chirps:\n  - {id: 00000000-0000-4000-9000-000000000001, author: walt@example.com}\n",
+            want: "chirps[0]: body must be 1 to 140 characters",
+        },
+        {
+            name: "Tokens without a token",
+            data: user + "tokens:\n  - {user: walt@example.com}\n",
+            want: "tokens[0]: token must be set and unique",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name + " are rejected", func(t *testing.T) {
+            _, err := parse("test", []byte(tt.data))
+
+            require.Error(t, err)
+            assert.Contains(t, err.Error(), tt.want)
+        })
+    }
+}
+
+func TestSeed(t *testing.T) {
+    ctx := context.Background()
+    s := store.NewMemory()
+    d, err := Load("demo")
+    require.NoError(t, err)
+    stale, err := s.CreateUser(ctx, database.CreateUserParams{Email: "stale@example.com"})
+    require.NoError(t, err)
+    _, err = s.CreateAuditEvent(ctx, database.CreateAuditEventParams{Action: "admin.reset", Metadata: []byte(`{}`)})
+    require.NoError(t, err)
+
+    require.NoError(t, Seed(ctx, s, d))
+
+    _, err = s.GetUser(ctx, stale.ID)
+    assert.Error(t, err, "the previous data is gone")
+    walt, err := s.GetUserByEmail(ctx, "walt@example.com")
+    require.NoError(t, err)
+    assert.Equal(t, uuid.MustParse("00000000-0000-4000-8000-000000000003"), walt.ID)
+    assert.True(t, walt.IsChirpyRed)
+    assert.NoError(t, auth.CheckPasswordHash(walt.HashedPassword, "password"))
+    admin, err := s.GetUserByEmail(ctx, "admin@example.com")
+    require.NoError(t, err)
+    assert.Equal(t, string(auth.RoleAdmin), admin.Role)
+
+    chirps, err := s.ListChirps(ctx)
+    require.NoError(t, err)
+    require.Len(t, chirps, len(d.Chirps))
+    for i, c := range chirps {
+        assert.Equal(t, d.Chirps[i].ID, c.ID)
+    }
+
+    token, err := s.GetRefreshToken(ctx, "demo-walt-refresh-token")
+    require.NoError(t, err)
+    assert.Equal(t, walt.ID, token.UserID)
+    assert.False(t, token.RevokedAt.Valid)
+    token, err = s.GetRefreshToken(ctx, "demo-jesse-revoked-token")
+    require.NoError(t, err)
+    assert.True(t, token.RevokedAt.Valid)
+
+    events, err := s.ListAuditEvents(ctx, database.ListAuditEventsParams{MaxRows: 10})
+    require.NoError(t, err)
+    assert.Len(t, events, 1, "the audit log is kept")
+
+    // Seeding again starts over instead of clashing with the first load.
+    require.NoError(t, Seed(ctx, s, d))
+    chirps, err = s.ListChirps(ctx)
+    require.NoError(t, err)
+    assert.Len(t, chirps, len(d.Chirps))
+}
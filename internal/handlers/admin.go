@@ -1,25 +1,43 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
 	"net/http"
-	"sync/atomic"
 
-	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/fixtures"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
 )
 
+var metricsPage = template.Must(template.New("metrics").Parse(`<html>
+    <body>
+    <h1>Welcome, Chirpy Admin</h1>
+    <p>Chirpy has been visited {{.Hits}} times!</p>
+    <table>
+    <tr><th>Metric</th><th>Labels</th><th>Value</th></tr>
+    {{range .Samples}}<tr><td>{{.Name}}</td><td>{{.Labels}}</td><td>{{.Value}}</td></tr>
+    {{end}}</table>
+    </body>
+    </html>`))
+
 type AdminHandler struct {
-    dbQueries *database.Queries
+    tx store.Transactor
+    audit audit.Log
     platform string
-    fileserverHits *atomic.Int32
+    metrics *metrics.Metrics
 }
 
-func NewAdminHandler(qs *database.Queries, platform string, fsh *atomic.Int32) AdminHandler {
+func NewAdminHandler(tx store.Transactor, log audit.Log, platform string, m *metrics.Metrics) AdminHandler {
     return AdminHandler {
-        dbQueries: qs,
+        tx: tx,
+        audit: log,
         platform: platform,
-        fileserverHits: fsh,
+        metrics: m,
     }
 }
 
@@ -29,32 +47,110 @@ func Health(w http.ResponseWriter, req *http.Request) {
     fmt.Fprintf(w, "OK")
 }
 
-func (a AdminHandler) GetMetrics(w http.ResponseWriter, req *http.Request) {
+func (a AdminHandler) GetMetrics(w http.ResponseWriter, req *http.Request) error {
+    samples, err := a.metrics.Snapshot()
+    if err != nil {
+        return problem.Internal("failed to gather metrics", err)
+    }
+
     w.Header().Add("Content-Type", "text/html")
-    template := `<html>
-    <body>
-    <h1>Welcome, Chirpy Admin</h1>
-    <p>Chirpy has been visited %d times!</p>
-    </body>
-    </html>`
-    htmlContent := fmt.Sprintf(template, a.fileserverHits.Load())
-    fmt.Fprint(w, htmlContent)
+    data := struct{
+        Hits int64
+        Samples []metrics.Sample
+    }{
+        Hits: int64(metrics.Value(samples, "chirpy_fileserver_hits_total")),
+        Samples: samples,
+    }
+    // Part of the page may already be written, so a problem can't be
+    // rendered any more.
+    if err := metricsPage.Execute(w, data); err != nil {
+        logging.FromContext(req.Context()).Error("failed to render metrics page", "error", err)
+    }
+    return nil
 }
 
-func (a AdminHandler) Reset(w http.ResponseWriter, req *http.Request) {
+// Reset empties every table but the audit logs, audit_events and
+// role_changes. The audit log records the reset.
+func (a AdminHandler) Reset(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
+    if err != nil {
+        return err
+    }
+
     if a.platform != "dev" {
-        w.WriteHeader(http.StatusForbidden)
-        return
+        return problem.Forbidden("reset is only allowed on the dev platform")
     }
 
-    if err := a.dbQueries.DeleteUsers(req.Context()); err != nil {
-        log.Printf("error deleting users; err: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "error deleting users")
-        return
+    err = a.tx.InTx(req.Context(), func(s store.Store) error {
+        if err := s.TruncateTables(req.Context()); err != nil {
+            return err
+        }
+        return a.audit.With(s).Record(req.Context(), audit.Event{Action: audit.ActionAdminReset, ActorID: principal.UserID})
+    })
+    if err != nil {
+        return problem.Internal("error resetting data", err)
     }
 
-    a.fileserverHits.Store(0)
+    a.metrics.ResetFileserverHits()
     w.Header().Add("Content-Type", "text/plain; charset=utf-8")
     w.WriteHeader(http.StatusOK)
+    return nil
+}
+
+type seedRequest struct {
+    Dataset string `json:"dataset" validate:"required"`
 }
 
+type seedResponse struct {
+    Dataset string `json:"dataset"`
+    Users int `json:"users"`
+    Chirps int `json:"chirps"`
+    Tokens int `json:"tokens"`
+}
+
+// Seed replaces everything but the audit logs with a named fixtures dataset,
+// as Reset followed by loading the dataset in the same transaction.
+func (a AdminHandler) Seed(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
+    if err != nil {
+        return err
+    }
+
+    if a.platform != "dev" {
+        return problem.Forbidden("seeding is only allowed on the dev platform")
+    }
+
+    var body seedRequest
+    if err := decodeJSON(w, req, &body); err != nil {
+        return err
+    }
+    dataset, err := fixtures.Load(body.Dataset)
+    if errors.Is(err, fixtures.ErrUnknownDataset) {
+        return problem.Invalid(problem.FieldError{Field: "dataset", Code: "unknown_dataset", Message: err.Error()})
+    }
+    if err != nil {
+        return problem.Internal("failed to load dataset", err)
+    }
+
+    err = a.tx.InTx(req.Context(), func(s store.Store) error {
+        if err := fixtures.Seed(req.Context(), s, dataset); err != nil {
+            return err
+        }
+        return a.audit.With(s).Record(req.Context(), audit.Event{
+            Action: audit.ActionAdminSeed,
+            ActorID: principal.UserID,
+            Metadata: map[string]any{"dataset": dataset.Name},
+        })
+    })
+    if err != nil {
+        return problem.Internal("error seeding data", err)
+    }
+
+    a.metrics.ResetFileserverHits()
+    return respondWithJSON(w, http.StatusOK, seedResponse{
+        Dataset: dataset.Name,
+        Users: len(dataset.Users),
+        Chirps: len(dataset.Chirps),
+        Tokens: len(dataset.Tokens),
+    })
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+    api := newTestAPI(t, "dev")
+
+    rec := api.do(t, http.MethodGet, "/api/healthz", "", nil)
+
+    assert.Equal(t, http.StatusOK, rec.Code)
+    assert.Equal(t, "OK", rec.Body.String())
+}
+
+func TestGetMetrics(t *testing.T) {
+    api := newTestAPI(t, "dev")
+
+    rec := api.do(t, http.MethodGet, "/admin/metrics", adminToken(t), nil)
+
+    assert.Equal(t, http.StatusOK, rec.Code)
+    assert.Contains(t, rec.Body.String(), "Chirpy has been visited 0 times!")
+}
+
+func TestAdminPermissions(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    userId := uuid.New()
+
+    tests := []struct {
+        name string
+        authorization string
+        code int
+    }{
+        {name: "Without a token", code: http.StatusUnauthorized},
+        {name: "As a user", authorization: bearer(t, userId), code: http.StatusForbidden},
+        {name: "As a moderator", authorization: bearerWithRole(t, userId, auth.RoleModerator), code: http.StatusForbidden},
+    }
+    routes := []struct {
+        method string
+        path string
+    }{
+        {http.MethodGet, "/admin/metrics"},
+        {http.MethodPost, "/admin/reset"},
+        {http.MethodPost, "/admin/seed"},
+        {http.MethodGet, "/admin/jobs"},
+        {http.MethodGet, "/admin/jobs/" + uuid.NewString()},
+        {http.MethodPost, "/admin/jobs/" + uuid.NewString() + "/retry"},
+        {http.MethodPut, "/admin/users/" + uuid.NewString() + "/role"},
+        {http.MethodGet, "/admin/users/" + uuid.NewString() + "/role-changes"},
+        {http.MethodGet, "/admin/audit-events"},
+        {http.MethodGet, "/admin/audit-events/export"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            for _, r := range routes {
+                rec := api.do(t, r.method, r.path, tt.authorization, nil)
+
+                assert.Equal(t, tt.code, rec.Code, r.method + " " + r.path)
+                assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+            }
+        })
+    }
+}
+
+func TestReset(t *testing.T) {
+    tests := []struct {
+        name string
+        platform string
+        code int
+        remaining bool
+    }{
+        {name: "Forbidden outside dev", platform: "prod", code: http.StatusForbidden, remaining: true},
+        {name: "Empties every table in dev", platform: "dev", code: http.StatusOK, remaining: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            api := newTestAPI(t, tt.platform)
+            ctx := context.Background()
+            user, _ := api.createUser(t, "user@example.com", "password")
+            chirp, err := api.store.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: user.ID})
+            require.NoError(t, err)
+            job, err := api.store.EnqueueJob(ctx, database.EnqueueJobParams{Kind: "test", Payload: []byte(`{}`), RunAt: time.Now()})
+            require.NoError(t, err)
+
+            rec := api.do(t, http.MethodPost, "/admin/reset", adminToken(t), nil)
+
+            assert.Equal(t, tt.code, rec.Code)
+            for _, get := range []func() error{
+                func() error { _, err := api.store.GetUser(ctx, user.ID); return err },
+                func() error { _, err := api.store.GetChirp(ctx, chirp.ID); return err },
+                func() error { _, err := api.store.GetJob(ctx, job.ID); return err },
+            } {
+                if tt.remaining {
+                    assert.NoError(t, get())
+                } else {
+                    assert.ErrorIs(t, get(), sql.ErrNoRows)
+                }
+            }
+        })
+    }
+}
+
+func TestSeed(t *testing.T) {
+    t.Run("Replaces the data with the dataset in dev", func(t *testing.T) {
+        api := newTestAPI(t, "dev")
+        stale, _ := api.createUser(t, "stale@example.com", "password")
+        admin := adminToken(t)
+
+        rec := api.do(t, http.MethodPost, "/admin/seed", admin, map[string]string{"dataset": "minimal"})
+
+        require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+        assert.JSONEq(t, `{"dataset": "minimal", "users": 2, "chirps": 0, "tokens": 2}`, rec.Body.String())
+        _, err := api.store.GetUser(context.Background(), stale.ID)
+        assert.ErrorIs(t, err, sql.ErrNoRows)
+
+        // The seeded users can log in, and their tokens refresh.
+        rec = api.do(t, http.MethodPost, "/api/login", "", userRequest{Email: "walt@example.com", Password: "password"})
+        require.Equal(t, http.StatusOK, rec.Code)
+        assert.Equal(t, "00000000-0000-4000-8000-000000000003", decode[loginResponse](t, rec).Id)
+        rec = api.do(t, http.MethodPost, "/api/refresh", "Bearer minimal-admin-refresh-token", nil)
+        assert.Equal(t, http.StatusOK, rec.Code)
+
+        events := api.listAuditEvents(t, "action=admin.seed")
+        require.Len(t, events, 1)
+        assert.JSONEq(t, `{"dataset": "minimal"}`, string(events[0].Metadata))
+    })
+
+    t.Run("Forbidden outside dev", func(t *testing.T) {
+        api := newTestAPI(t, "prod")
+        user, _ := api.createUser(t, "user@example.com", "password")
+
+        rec := api.do(t, http.MethodPost, "/admin/seed", adminToken(t), map[string]string{"dataset": "minimal"})
+
+        assert.Equal(t, http.StatusForbidden, rec.Code)
+        _, err := api.store.GetUser(context.Background(), user.ID)
+        assert.NoError(t, err)
+    })
+
+    t.Run("Unknown datasets are rejected", func(t *testing.T) {
+        api := newTestAPI(t, "dev")
+        user, _ := api.createUser(t, "user@example.com", "password")
+
+        rec := api.do(t, http.MethodPost, "/admin/seed", adminToken(t), map[string]string{"dataset": "production"})
+
+        assert.Equal(t, http.StatusBadRequest, rec.Code)
+        assert.Contains(t, rec.Body.String(), "unknown_dataset")
+        _, err := api.store.GetUser(context.Background(), user.ID)
+        assert.NoError(t, err)
+    })
+}
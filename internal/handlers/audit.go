@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+    defaultAuditPageSize = 50
+    maxAuditPageSize = 500
+    // auditExportBatchSize is how many events an export reads at a time.
+    auditExportBatchSize = 500
+    // auditExportBatchTimeout is how long an export may take to write each
+    // batch. It replaces the server's write timeout, which would otherwise cut
+    // large exports short.
+    auditExportBatchTimeout = 30 * time.Second
+)
+
+// AuditHandler serves the audit log to admins.
+type AuditHandler struct {
+    events store.AuditStore
+}
+
+func NewAuditHandler(events store.AuditStore) AuditHandler {
+    return AuditHandler{
+        events: events,
+    }
+}
+
+type auditEventResponse struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    Action string `json:"action"`
+    ActorId *uuid.UUID `json:"actor_id"`
+    TargetId *uuid.UUID `json:"target_id"`
+    Ip string `json:"ip"`
+    UserAgent string `json:"user_agent"`
+    Metadata json.RawMessage `json:"metadata"`
+}
+
+func newAuditEventResponse(event database.AuditEvent) auditEventResponse {
+    res := auditEventResponse{
+        Id: event.ID,
+        CreatedAt: event.CreatedAt,
+        Action: event.Action,
+        Ip: event.Ip,
+        UserAgent: event.UserAgent,
+        Metadata: event.Metadata,
+    }
+    if event.ActorID.Valid {
+        res.ActorId = &event.ActorID.UUID
+    }
+    if event.TargetID.Valid {
+        res.TargetId = &event.TargetID.UUID
+    }
+    return res
+}
+
+// parseAuditFilter reads the filters both routes share: ?action=, ?actor_id=,
+// ?target_id=, and ?since= and ?until= as RFC 3339 times.
+func parseAuditFilter(query url.Values) (database.ListAuditEventsParams, []problem.FieldError) {
+    var params database.ListAuditEventsParams
+    var invalid []problem.FieldError
+
+    params.Action = query.Get("action")
+    if params.Action != "" && !audit.IsValidAction(params.Action) {
+        invalid = append(invalid, problem.FieldError{Field: "action", Code: "unknown_action", Message: "unknown audit action: " + params.Action})
+    }
+    for _, f := range []struct{
+        name string
+        id *uuid.NullUUID
+    }{
+        {"actor_id", &params.ActorID},
+        {"target_id", &params.TargetID},
+    } {
+        if raw := query.Get(f.name); raw != "" {
+            id, err := uuid.Parse(raw)
+            if err != nil {
+                invalid = append(invalid, problem.FieldError{Field: f.name, Code: "invalid_uuid", Message: f.name + " must be a UUID"})
+            }
+            *f.id = uuid.NullUUID{UUID: id, Valid: err == nil}
+        }
+    }
+    for _, f := range []struct{
+        name string
+        t *sql.NullTime
+    }{
+        {"since", &params.Since},
+        {"until", &params.Until},
+    } {
+        if raw := query.Get(f.name); raw != "" {
+            t, err := time.Parse(time.RFC3339, raw)
+            if err != nil {
+                invalid = append(invalid, problem.FieldError{Field: f.name, Code: "invalid_time", Message: f.name + " must be an RFC 3339 time"})
+            }
+            *f.t = sql.NullTime{Time: t.UTC(), Valid: err == nil}
+        }
+    }
+    return params, invalid
+}
+
+// ListEvents lists audit events matching the filters, newest first. ?limit=
+// sets the page size and ?offset= skips events, and a Link header points to
+// the next page while there is one.
+func (h AuditHandler) ListEvents(w http.ResponseWriter, req *http.Request) error {
+    query := req.URL.Query()
+    params, invalid := parseAuditFilter(query)
+
+    limit, offset := defaultAuditPageSize, 0
+    if raw := query.Get("limit"); raw != "" {
+        n, err := strconv.Atoi(raw)
+        if err != nil || n < 1 || n > maxAuditPageSize {
+            invalid = append(invalid, problem.FieldError{Field: "limit", Code: "out_of_range", Message: fmt.Sprintf("limit must be between 1 and %d", maxAuditPageSize)})
+        }
+        limit = n
+    }
+    if raw := query.Get("offset"); raw != "" {
+        n, err := strconv.Atoi(raw)
+        if err != nil || n < 0 {
+            invalid = append(invalid, problem.FieldError{Field: "offset", Code: "out_of_range", Message: "offset must be a non-negative integer"})
+        }
+        offset = n
+    }
+    if len(invalid) > 0 {
+        return problem.Invalid(invalid...)
+    }
+
+    // One extra event tells whether there is a next page.
+    params.MaxRows = int32(limit + 1)
+    params.SkipRows = int32(offset)
+    events, err := h.events.ListAuditEvents(req.Context(), params)
+    if err != nil {
+        return problem.Internal("failed to list audit events", err)
+    }
+
+    if len(events) > limit {
+        events = events[:limit]
+        next := *req.URL
+        q := next.Query()
+        q.Set("offset", strconv.Itoa(offset + limit))
+        next.RawQuery = q.Encode()
+        w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+    }
+
+    res := make([]auditEventResponse, 0, len(events))
+    for _, event := range events {
+        res = append(res, newAuditEventResponse(event))
+    }
+    return respondWithJSON(w, http.StatusOK, res)
+}
+
+// ExportEvents streams every audit event matching the filters as JSON Lines,
+// newest first. Events recorded once the export has started are left out, so
+// that they can't shift the batches it reads.
+func (h AuditHandler) ExportEvents(w http.ResponseWriter, req *http.Request) error {
+    params, invalid := parseAuditFilter(req.URL.Query())
+    if len(invalid) > 0 {
+        return problem.Invalid(invalid...)
+    }
+    if start := time.Now().UTC(); !params.Until.Valid || params.Until.Time.After(start) {
+        params.Until = sql.NullTime{Time: start, Valid: true}
+    }
+
+    params.MaxRows = auditExportBatchSize
+    events, err := h.events.ListAuditEvents(req.Context(), params)
+    if err != nil {
+        return problem.Internal("failed to export audit events", err)
+    }
+
+    rc := http.NewResponseController(w)
+    extendDeadline := func() {
+        err := rc.SetWriteDeadline(time.Now().Add(auditExportBatchTimeout))
+        if err != nil && !errors.Is(err, http.ErrNotSupported) {
+            logging.FromContext(req.Context()).Warn("failed to extend audit export deadline", "error", err)
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/jsonl")
+    w.Header().Set("Content-Disposition", `attachment; filename="audit-events.jsonl"`)
+    extendDeadline()
+    w.WriteHeader(http.StatusOK)
+
+    // The status is sent, so a failure from here on can only cut the export
+    // short. The connection is dropped rather than ended cleanly, so clients
+    // can tell a partial export from a complete one.
+    enc := json.NewEncoder(w)
+    for {
+        for _, event := range events {
+            if err := enc.Encode(newAuditEventResponse(event)); err != nil {
+                // The client has gone.
+                logging.FromContext(req.Context()).Warn("failed to write audit export", "error", err)
+                return nil
+            }
+        }
+        if len(events) < auditExportBatchSize {
+            return nil
+        }
+        params.SkipRows += auditExportBatchSize
+        events, err = h.events.ListAuditEvents(req.Context(), params)
+        if err != nil {
+            logging.FromContext(req.Context()).Error("failed to export audit events", "error", err)
+            panic(http.ErrAbortHandler)
+        }
+        extendDeadline()
+    }
+}
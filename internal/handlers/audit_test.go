@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listAuditEvents lists the audit events matching query as an admin.
+func (a testAPI) listAuditEvents(t *testing.T, query string) []auditEventResponse {
+    t.Helper()
+
+    rec := a.do(t, http.MethodGet, "/admin/audit-events?" + query, adminToken(t), nil)
+    require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+    return decode[[]auditEventResponse](t, rec)
+}
+
+func actions(events []auditEventResponse) []string {
+    var actions []string
+    for _, e := range events {
+        actions = append(actions, e.Action)
+    }
+    return actions
+}
+
+func TestAuditTrail(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, token := api.createUser(t, "user@example.com", "password")
+
+    t.Run("Failed logins are recorded with their reason", func(t *testing.T) {
+        api.do(t, http.MethodPost, "/api/login", "", userRequest{Email: "user@example.com", Password: "wrong"})
+        api.do(t, http.MethodPost, "/api/login", "", userRequest{Email: "nobody@example.com", Password: "password"})
+
+        events := api.listAuditEvents(t, "action=login.failed")
+        require.Len(t, events, 2)
+        assert.Nil(t, events[0].ActorId)
+        assert.Nil(t, events[0].TargetId)
+        assert.JSONEq(t, `{"email": "nobody@example.com", "reason": "unknown_email"}`, string(events[0].Metadata))
+        assert.Equal(t, &user.ID, events[1].TargetId)
+        assert.JSONEq(t, `{"email": "user@example.com", "reason": "wrong_password"}`, string(events[1].Metadata))
+        assert.Equal(t, "192.0.2.1", events[1].Ip)
+    })
+
+    t.Run("Sessions are recorded from login to revocation", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/login", "", userRequest{Email: "user@example.com", Password: "password"})
+        require.Equal(t, http.StatusOK, rec.Code)
+        login := decode[loginResponse](t, rec)
+        rec = api.do(t, http.MethodPost, "/api/refresh", "Bearer " + login.RefreshToken, nil)
+        require.Equal(t, http.StatusOK, rec.Code)
+        rec = api.do(t, http.MethodPost, "/api/revoke", "Bearer " + login.RefreshToken, nil)
+        require.Equal(t, http.StatusNoContent, rec.Code)
+
+        events := api.listAuditEvents(t, "actor_id=" + user.ID.String())
+        assert.Equal(t, []string{audit.ActionTokenRevoked, audit.ActionTokenRefreshed, audit.ActionLoginSucceeded}, actions(events))
+        session := fmt.Sprintf(`{"session": %q}`, login.RefreshToken[:12])
+        for _, e := range events {
+            assert.JSONEq(t, session, string(e.Metadata))
+            assert.Equal(t, &user.ID, e.TargetId)
+        }
+    })
+
+    t.Run("Email and password changes are recorded separately", func(t *testing.T) {
+        rec := api.do(t, http.MethodPut, "/api/users", token, userRequest{Email: "new@example.com", Password: "password"})
+        require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+        rec = api.do(t, http.MethodPut, "/api/users", token, userRequest{Email: "new@example.com", Password: "another password"})
+        require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+        events := api.listAuditEvents(t, "target_id=" + user.ID.String() + "&limit=2")
+        assert.Equal(t, []string{audit.ActionPasswordChanged, audit.ActionEmailChanged}, actions(events))
+        assert.JSONEq(t, `{"old_email": "user@example.com", "new_email": "new@example.com"}`, string(events[1].Metadata))
+    })
+
+    t.Run("Chirp deletions record whether they were moderated", func(t *testing.T) {
+        chirp, err := api.store.CreateChirp(context.Background(), database.CreateChirpParams{Body: "hello", UserID: user.ID})
+        require.NoError(t, err)
+        moderatorId := uuid.New()
+
+        rec := api.do(t, http.MethodDelete, "/api/chirps/" + chirp.ID.String(), bearerWithRole(t, moderatorId, auth.RoleModerator), nil)
+        require.Equal(t, http.StatusNoContent, rec.Code)
+
+        events := api.listAuditEvents(t, "action=chirp.deleted")
+        require.Len(t, events, 1)
+        assert.Equal(t, &moderatorId, events[0].ActorId)
+        assert.Equal(t, &chirp.ID, events[0].TargetId)
+        assert.JSONEq(t, fmt.Sprintf(`{"author_id": %q, "moderated": true}`, user.ID), string(events[0].Metadata))
+    })
+
+    t.Run("Polka upgrades have no actor", func(t *testing.T) {
+        body := map[string]any{"event": "user.upgraded", "data": map[string]any{"user_id": user.ID}}
+        rec := api.do(t, http.MethodPost, "/api/polka/webhooks", "ApiKey " + testPolkaKey, body)
+        require.Equal(t, http.StatusNoContent, rec.Code)
+
+        events := api.listAuditEvents(t, "action=user.upgraded")
+        require.Len(t, events, 1)
+        assert.Nil(t, events[0].ActorId)
+        assert.Equal(t, &user.ID, events[0].TargetId)
+    })
+
+    t.Run("Role changes record the admin and the reason", func(t *testing.T) {
+        adminId := uuid.New()
+        body := map[string]string{"role": "moderator", "reason": "joined the moderators"}
+        rec := api.do(t, http.MethodPut, "/admin/users/" + user.ID.String() + "/role", bearerWithRole(t, adminId, auth.RoleAdmin), body)
+        require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+        events := api.listAuditEvents(t, "action=user.role_changed")
+        require.Len(t, events, 1)
+        assert.Equal(t, &adminId, events[0].ActorId)
+        assert.Equal(t, &user.ID, events[0].TargetId)
+        assert.JSONEq(t, `{"old_role": "user", "new_role": "moderator", "reason": "joined the moderators"}`, string(events[0].Metadata))
+    })
+
+    t.Run("Resets are recorded and the log survives them", func(t *testing.T) {
+        before := len(api.listAuditEvents(t, ""))
+        admin := adminToken(t)
+
+        rec := api.do(t, http.MethodPost, "/admin/reset", admin, nil)
+        require.Equal(t, http.StatusOK, rec.Code)
+
+        events := api.listAuditEvents(t, "")
+        assert.Len(t, events, before + 1)
+        assert.Equal(t, audit.ActionAdminReset, events[0].Action)
+        assert.NotNil(t, events[0].ActorId)
+    })
+}
+
+func TestListAuditEvents(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    for range 3 {
+        api.do(t, http.MethodPost, "/api/login", "", userRequest{Email: "nobody@example.com", Password: "password"})
+        // Apart, so the time range test has distinct times.
+        time.Sleep(time.Millisecond)
+    }
+
+    t.Run("Pages link to the next one", func(t *testing.T) {
+        rec := api.do(t, http.MethodGet, "/admin/audit-events?action=login.failed&limit=2", adminToken(t), nil)
+        require.Equal(t, http.StatusOK, rec.Code)
+        assert.Len(t, decode[[]auditEventResponse](t, rec), 2)
+        assert.Equal(t, `</admin/audit-events?action=login.failed&limit=2&offset=2>; rel="next"`, rec.Header().Get("Link"))
+
+        rec = api.do(t, http.MethodGet, "/admin/audit-events?action=login.failed&limit=2&offset=2", adminToken(t), nil)
+        require.Equal(t, http.StatusOK, rec.Code)
+        assert.Len(t, decode[[]auditEventResponse](t, rec), 1)
+        assert.Empty(t, rec.Header().Get("Link"))
+    })
+
+    t.Run("Filters are validated", func(t *testing.T) {
+        rec := api.do(t, http.MethodGet, "/admin/audit-events?action=login&actor_id=me&since=yesterday&limit=0", adminToken(t), nil)
+
+        require.Equal(t, http.StatusBadRequest, rec.Code)
+        p := decode[struct{
+            Errors []problem.FieldError `json:"errors"`
+        }](t, rec)
+        var fields []string
+        for _, f := range p.Errors {
+            fields = append(fields, f.Field)
+        }
+        assert.Equal(t, []string{"action", "actor_id", "since", "limit"}, fields)
+    })
+
+    t.Run("Time ranges include since and exclude until", func(t *testing.T) {
+        events := api.listAuditEvents(t, "")
+        require.Len(t, events, 3)
+        since := events[2].CreatedAt.Format(time.RFC3339Nano)
+        until := events[0].CreatedAt.Format(time.RFC3339Nano)
+
+        got := api.listAuditEvents(t, "since=" + since + "&until=" + until)
+        assert.Equal(t, []uuid.UUID{events[1].Id, events[2].Id}, []uuid.UUID{got[0].Id, got[1].Id})
+        assert.Len(t, got, 2)
+    })
+}
+
+func TestExportAuditEvents(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    for _, email := range []string{"a@example.com", "b@example.com"} {
+        api.do(t, http.MethodPost, "/api/login", "", userRequest{Email: email, Password: "password"})
+    }
+
+    rec := api.do(t, http.MethodGet, "/admin/audit-events/export?action=login.failed", adminToken(t), nil)
+
+    require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+    assert.Equal(t, "application/jsonl", rec.Header().Get("Content-Type"))
+    var emails []string
+    lines := bufio.NewScanner(rec.Body)
+    for lines.Scan() {
+        var event auditEventResponse
+        require.NoError(t, json.Unmarshal(lines.Bytes(), &event))
+        var metadata struct {
+            Email string `json:"email"`
+        }
+        require.NoError(t, json.Unmarshal(event.Metadata, &metadata))
+        emails = append(emails, metadata.Email)
+    }
+    assert.Equal(t, []string{"b@example.com", "a@example.com"}, emails)
+
+    rec = api.do(t, http.MethodGet, "/admin/audit-events/export?until=soon", adminToken(t), nil)
+    assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// slowEvents is an audit log that takes a while to read each batch.
+type slowEvents struct {
+    store.AuditStore
+    delay time.Duration
+}
+
+func (s slowEvents) ListAuditEvents(ctx context.Context, arg database.ListAuditEventsParams) ([]database.AuditEvent, error) {
+    time.Sleep(s.delay)
+    return s.AuditStore.ListAuditEvents(ctx, arg)
+}
+
+func TestExportOutlastsWriteTimeout(t *testing.T) {
+    s := store.NewMemory()
+    total := 2 * auditExportBatchSize + 1
+    for range total {
+        _, err := s.CreateAuditEvent(context.Background(), database.CreateAuditEventParams{Action: audit.ActionLoginFailed, Metadata: []byte(`{}`)})
+        require.NoError(t, err)
+    }
+    h := NewAuditHandler(slowEvents{AuditStore: s, delay: 60 * time.Millisecond})
+    server := httptest.NewUnstartedServer(HandlerFunc(h.ExportEvents))
+    server.Config.WriteTimeout = 100 * time.Millisecond
+    server.Start()
+    t.Cleanup(server.Close)
+
+    res, err := http.Get(server.URL)
+    require.NoError(t, err)
+    defer res.Body.Close()
+
+    require.Equal(t, http.StatusOK, res.StatusCode)
+    lines := bufio.NewScanner(res.Body)
+    n := 0
+    for lines.Scan() {
+        n++
+    }
+    require.NoError(t, lines.Err())
+    assert.Equal(t, total, n)
+}
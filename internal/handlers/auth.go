@@ -1,81 +1,131 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/bamcmanus/Chirpy/internal/accounts"
+	"github.com/bamcmanus/Chirpy/internal/audit"
 	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/logging"
 	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
 	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
-    dbQueries *database.Queries
+    users store.UserStore
+    tokens store.TokenStore
+    tx store.Transactor
+    audit audit.Log
     jwtSecret string
+    jwtLifetime time.Duration
+    refreshTokenLifetime time.Duration
+    metrics *metrics.Metrics
 }
 
-func NewAuthHandler(qs *database.Queries, secret string) AuthHandler {
+func NewAuthHandler(users store.UserStore, tokens store.TokenStore, tx store.Transactor, log audit.Log, secret string, jwtLifetime, refreshTokenLifetime time.Duration, m *metrics.Metrics) AuthHandler {
     return AuthHandler{
-        dbQueries: qs,
+        users: users,
+        tokens: tokens,
+        tx: tx,
+        audit: log,
         jwtSecret: secret,
+        jwtLifetime: jwtLifetime,
+        refreshTokenLifetime: refreshTokenLifetime,
+        metrics: m,
     }
 }
 
-func (a AuthHandler) Login(w http.ResponseWriter, req *http.Request) {
+var (
+    errInvalidCredentials = errors.New("invalid credentials")
+    errAccountDisabled = errors.New("account disabled")
+)
+
+func (a AuthHandler) Login(w http.ResponseWriter, req *http.Request) error {
     type login struct {
         Password string `json:"password"`
         Email string `json:"email"`
     }
 
     var loginRequest login
-    decoder := json.NewDecoder(req.Body)
-    if err := decoder.Decode(&loginRequest); err != nil {
-        log .Printf("failed to decode request; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed to decode request")
-        return
+    if err := decodeJSON(w, req, &loginRequest); err != nil {
+        return err
     }
 
-    user, err := a.dbQueries.GetUserByEmail(req.Context(), loginRequest.Email)
+    refreshToken, err := auth.MakeRefreshToken()
     if err != nil {
-        log.Printf("failed to get user; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "Incorrect email or Password")
-        return
+        return problem.Internal("failed to create refresh token", err)
     }
 
-    err = auth.CheckPasswordHash(user.HashedPassword, loginRequest.Password)
-    if err != nil {
-        log.Printf("passowrds do not match; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
-        return
-    }
+    // The user is read and the session created in one transaction, so a
+    // session can't be issued for credentials changed in the meantime.
+    var user database.User
+    err = a.tx.InTx(req.Context(), func(s store.Store) error {
+        user, err = s.GetUserByEmail(req.Context(), loginRequest.Email)
+        if errors.Is(err, sql.ErrNoRows) {
+            return fmt.Errorf("%w: unknown user: %w", errInvalidCredentials, err)
+        }
+        if err != nil {
+            return fmt.Errorf("looking up user: %w", err)
+        }
 
-    token, err := auth.MakeJWT(user.ID, a.jwtSecret, time.Duration(3600) * time.Second)
-    if err != nil {
-        log.Printf("could not create JWT; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed to create JWT")
-        return
-    }
+        if err := auth.CheckPasswordHash(user.HashedPassword, loginRequest.Password); err != nil {
+            return fmt.Errorf("%w: passwords do not match", errInvalidCredentials)
+        }
 
-    refreshToken, err := auth.MakeRefreshToken()
+        // Checked after the password, so only the account's owner learns
+        // that it is disabled.
+        if user.DisabledAt.Valid {
+            return fmt.Errorf("%w: %s was disabled at %s", errAccountDisabled, user.ID, user.DisabledAt.Time)
+        }
+
+        _, err = s.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
+            Token: refreshToken,
+            UserID: user.ID,
+            ExpiresAt: time.Now().UTC().Add(a.refreshTokenLifetime),
+        })
+        if err != nil {
+            return fmt.Errorf("persisting refresh token for %s: %w", user.ID, err)
+        }
+
+        return a.audit.With(s).Record(req.Context(), audit.Event{
+            Action: audit.ActionLoginSucceeded,
+            ActorID: user.ID,
+            TargetID: user.ID,
+            Metadata: map[string]any{"session": accounts.SessionID(refreshToken)},
+        })
+    })
+    if errors.Is(err, errInvalidCredentials) {
+        a.metrics.LoginFailed()
+        reason := "wrong_password"
+        if user.ID == uuid.Nil {
+            reason = "unknown_email"
+        }
+        a.recordFailedLogin(req, loginRequest.Email, user.ID, reason)
+        return problem.New(http.StatusUnauthorized, "invalid_credentials", "Incorrect email or password").Wrap(err)
+    }
+    if errors.Is(err, errAccountDisabled) {
+        a.metrics.LoginFailed()
+        a.recordFailedLogin(req, loginRequest.Email, user.ID, "account_disabled")
+        return errDisabled(err)
+    }
     if err != nil {
-        log.Printf("could not create refresh token; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed to create refresh token")
-        return
+        return problem.Internal("login failed", err)
     }
 
-    params := database.CreateRefreshTokenParams{
-        Token: refreshToken,
-        UserID: user.ID,
-    }
-    _, err = a.dbQueries.CreateRefreshToken(req.Context(), params)
+    token, err := auth.MakeJWT(user.ID, auth.Role(user.Role), a.jwtSecret, a.jwtLifetime)
     if err != nil {
-        log.Printf("could not perisste refresh token; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "refresh token creation failed")
-        return
+        return problem.Internal("failed to create JWT", err)
     }
 
+    a.metrics.LoginSucceeded()
+
     userReponse := struct{
         Id uuid.UUID `json:"id"`
         CreatedAt time.Time `json:"created_at"`
@@ -84,6 +134,7 @@ func (a AuthHandler) Login(w http.ResponseWriter, req *http.Request) {
         Token string `json:"token"`
         RefreshToken string `json:"refresh_token"`
         IsChiryRed bool `json:"is_chirpy_red"`
+        Role string `json:"role"`
     }{
         Id: user.ID,
         CreatedAt: user.CreatedAt,
@@ -92,38 +143,80 @@ func (a AuthHandler) Login(w http.ResponseWriter, req *http.Request) {
         Token: token,
         RefreshToken: refreshToken,
         IsChiryRed: user.IsChirpyRed,
+        Role: user.Role,
     }
-    if err := respondWithJSON(w, http.StatusOK, userReponse); err != nil {
-        log.Printf("failed to respond; error: %s", err)
+    return respondWithJSON(w, http.StatusOK, userReponse)
+}
+
+// recordFailedLogin records a failed login outside the rolled back
+// transaction. There is nothing to undo if that fails, so the client still
+// gets the failure it would have.
+func (a AuthHandler) recordFailedLogin(req *http.Request, email string, userID uuid.UUID, reason string) {
+    err := a.audit.Record(req.Context(), audit.Event{
+        Action: audit.ActionLoginFailed,
+        TargetID: userID,
+        Metadata: map[string]any{"email": email, "reason": reason},
+    })
+    if err != nil {
+        logging.FromContext(req.Context()).Error("failed to record failed login", "error", err)
     }
 }
 
-func (a AuthHandler) Refresh(w http.ResponseWriter, req *http.Request) {
+// errInvalidRefreshToken is the response to every refresh token that can't be
+// used, so clients can't tell an unknown token from a revoked one.
+func errInvalidRefreshToken(err error) error {
+    return problem.New(http.StatusUnauthorized, "invalid_refresh_token", "the refresh token is invalid").Wrap(err)
+}
+
+// errDisabled is the response to a disabled user logging in or refreshing.
+func errDisabled(err error) error {
+    return problem.New(http.StatusForbidden, "account_disabled", "this account has been disabled").Wrap(err)
+}
+
+func (a AuthHandler) Refresh(w http.ResponseWriter, req *http.Request) error {
     refreshToken, err := auth.GetBearerToken(req.Header)
     if err != nil {
-        log.Printf("failed to fetch Bearer token; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
+        return problem.Unauthorized("a refresh token is required").Wrap(err)
     }
 
-    token, err := a.dbQueries.GetRefreshToken(req.Context(), refreshToken)
+    token, err := a.tokens.GetRefreshToken(req.Context(), refreshToken)
     if err != nil {
-        log.Printf("error fetching refresh token; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
+        return errInvalidRefreshToken(err)
     }
 
     if token.RevokedAt.Valid {
-        log.Printf("token is expired; expiration time: %v", token.RevokedAt)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
+        return errInvalidRefreshToken(fmt.Errorf("token for %s was revoked at %s", token.UserID, token.RevokedAt.Time))
     }
 
-    jwt, err := auth.MakeJWT(token.UserID, a.jwtSecret, time.Duration(3600) * time.Second)
+    if !token.ExpiresAt.After(time.Now().UTC()) {
+        return errInvalidRefreshToken(fmt.Errorf("token for %s expired at %s", token.UserID, token.ExpiresAt))
+    }
+
+    // The role is read afresh, so refreshing picks up a change of role.
+    user, err := a.users.GetUser(req.Context(), token.UserID)
     if err != nil {
-        log.Printf("could not create JWT; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed to create JWT")
-        return
+        return problem.Internal("failed to get user", err)
+    }
+
+    // Disabling a user revokes their sessions, but a token created by a login
+    // racing it would otherwise keep working.
+    if user.DisabledAt.Valid {
+        return errDisabled(fmt.Errorf("%w: %s was disabled at %s", errAccountDisabled, user.ID, user.DisabledAt.Time))
+    }
+
+    jwt, err := auth.MakeJWT(user.ID, auth.Role(user.Role), a.jwtSecret, a.jwtLifetime)
+    if err != nil {
+        return problem.Internal("failed to create JWT", err)
+    }
+
+    err = a.audit.Record(req.Context(), audit.Event{
+        Action: audit.ActionTokenRefreshed,
+        ActorID: user.ID,
+        TargetID: user.ID,
+        Metadata: map[string]any{"session": accounts.SessionID(refreshToken)},
+    })
+    if err != nil {
+        return problem.Internal("failed to refresh token", err)
     }
 
     res := struct{
@@ -131,25 +224,34 @@ func (a AuthHandler) Refresh(w http.ResponseWriter, req *http.Request) {
     }{
         Token: jwt,
     }
-    if err := respondWithJSON(w, http.StatusOK, res); err != nil {
-        log.Printf("failed to respond; error: %s", err)
-    }
+    return respondWithJSON(w, http.StatusOK, res)
 }
 
-func (a AuthHandler) Revoke(w http.ResponseWriter, req *http.Request) {
+func (a AuthHandler) Revoke(w http.ResponseWriter, req *http.Request) error {
     refreshToken, err := auth.GetBearerToken(req.Header)
     if err != nil {
-        log.Printf("failed to fetch Bearer token; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
+        return problem.Unauthorized("a refresh token is required").Wrap(err)
     }
 
-    _, err = a.dbQueries.RevokeRefreshToken(req.Context(), refreshToken)
+    err = a.tx.InTx(req.Context(), func(s store.Store) error {
+        token, err := s.RevokeRefreshToken(req.Context(), refreshToken)
+        if err != nil {
+            return err
+        }
+        return a.audit.With(s).Record(req.Context(), audit.Event{
+            Action: audit.ActionTokenRevoked,
+            ActorID: token.UserID,
+            TargetID: token.UserID,
+            Metadata: map[string]any{"session": accounts.SessionID(refreshToken)},
+        })
+    })
+    if errors.Is(err, sql.ErrNoRows) {
+        return errInvalidRefreshToken(err)
+    }
     if err != nil {
-        log.Printf("failed to revoke token; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "")
+        return problem.Internal("failed to revoke refresh token", err)
     }
 
-    w.WriteHeader(http.StatusNoContent) 
+    w.WriteHeader(http.StatusNoContent)
+    return nil
 }
-
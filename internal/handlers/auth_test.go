@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loginResponse struct {
+    Id string `json:"id"`
+    Email string `json:"email"`
+    Token string `json:"token"`
+    RefreshToken string `json:"refresh_token"`
+}
+
+func TestLogin(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, _ := api.createUser(t, "user@example.com", "password")
+    disabled, _ := api.createUser(t, "disabled@example.com", "password")
+    _, err := api.store.SetUserDisabledAt(context.Background(), database.SetUserDisabledAtParams{
+        DisabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+        ID: disabled.ID,
+    })
+    require.NoError(t, err)
+
+    tests := []struct {
+        name string
+        body any
+        code int
+    }{
+        {name: "Unknown user", body: userRequest{Email: "nobody@example.com", Password: "password"}, code: http.StatusUnauthorized},
+        {name: "Wrong password", body: userRequest{Email: "user@example.com", Password: "wrong"}, code: http.StatusUnauthorized},
+        {name: "Malformed body", body: "not json", code: http.StatusBadRequest},
+        {name: "Disabled user", body: userRequest{Email: "disabled@example.com", Password: "password"}, code: http.StatusForbidden},
+        {name: "Disabled user with the wrong password", body: userRequest{Email: "disabled@example.com", Password: "wrong"}, code: http.StatusUnauthorized},
+        {name: "Happy path", body: userRequest{Email: "user@example.com", Password: "password"}, code: http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPost, "/api/login", "", tt.body)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    t.Run("Issues working tokens", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/login", "", userRequest{Email: "user@example.com", Password: "password"})
+        require.Equal(t, http.StatusOK, rec.Code)
+        res := decode[loginResponse](t, rec)
+
+        userId, err := auth.ValidateJWT(res.Token, testJWTSecret)
+        require.NoError(t, err)
+        assert.Equal(t, user.ID, userId)
+
+        token, err := api.store.GetRefreshToken(context.Background(), res.RefreshToken)
+        require.NoError(t, err)
+        assert.Equal(t, user.ID, token.UserID)
+        assert.WithinDuration(t, time.Now().Add(24*time.Hour), token.ExpiresAt, time.Minute)
+    })
+
+    samples, err := api.metrics.Snapshot()
+    require.NoError(t, err)
+    logins := make(map[string]float64)
+    for _, s := range samples {
+        if s.Name == "chirpy_logins_total" {
+            logins[s.Labels] = s.Value
+        }
+    }
+    assert.Equal(t, map[string]float64{"result=failure": 4, "result=success": 2}, logins)
+}
+
+// brokenUsers is a store whose user lookups fail, as in a database outage.
+type brokenUsers struct {
+    *store.Memory
+}
+
+func (b brokenUsers) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+    return database.User{}, errors.New("connection refused")
+}
+
+func (b brokenUsers) InTx(ctx context.Context, fn func(store.Store) error) error {
+    return b.Memory.InTx(ctx, func(store.Store) error { return fn(b) })
+}
+
+func TestLoginDatabaseError(t *testing.T) {
+    s := brokenUsers{store.NewMemory()}
+    h := NewAuthHandler(s, s, s, audit.NewLog(s), testJWTSecret, time.Hour, 24*time.Hour, metrics.New(nil))
+    req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"email": "user@example.com", "password": "password"}`))
+    req.Header.Set("Content-Type", "application/json")
+    rec := httptest.NewRecorder()
+
+    HandlerFunc(h.Login).ServeHTTP(rec, req)
+
+    assert.Equal(t, http.StatusInternalServerError, rec.Code)
+    events, err := s.ListAuditEvents(context.Background(), database.ListAuditEventsParams{MaxRows: 10})
+    require.NoError(t, err)
+    assert.Empty(t, events, "an outage is not a failed login")
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, _ := api.createUser(t, "user@example.com", "password")
+
+    disabled, _ := api.createUser(t, "disabled@example.com", "password")
+
+    newToken := func(t *testing.T, userID uuid.UUID, expiresAt time.Time) string {
+        token, err := auth.MakeRefreshToken()
+        require.NoError(t, err)
+        _, err = api.store.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+            Token: token,
+            UserID: userID,
+            ExpiresAt: expiresAt,
+        })
+        require.NoError(t, err)
+        return token
+    }
+
+    valid := newToken(t, user.ID, time.Now().Add(time.Hour))
+    expired := newToken(t, user.ID, time.Now().Add(-time.Minute))
+    revoked := newToken(t, user.ID, time.Now().Add(time.Hour))
+    _, err := api.store.RevokeRefreshToken(context.Background(), revoked)
+    require.NoError(t, err)
+    // A session the user still has after being disabled, as a login racing
+    // the revocation would leave.
+    ofDisabled := newToken(t, disabled.ID, time.Now().Add(time.Hour))
+    _, err = api.store.SetUserDisabledAt(context.Background(), database.SetUserDisabledAtParams{
+        DisabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+        ID: disabled.ID,
+    })
+    require.NoError(t, err)
+
+    tests := []struct {
+        name string
+        authorization string
+        code int
+    }{
+        {name: "Missing token", code: http.StatusUnauthorized},
+        {name: "Unknown token", authorization: "Bearer unknown", code: http.StatusUnauthorized},
+        {name: "Expired token", authorization: "Bearer " + expired, code: http.StatusUnauthorized},
+        {name: "Revoked token", authorization: "Bearer " + revoked, code: http.StatusUnauthorized},
+        {name: "Token of a disabled user", authorization: "Bearer " + ofDisabled, code: http.StatusForbidden},
+        {name: "Happy path", authorization: "Bearer " + valid, code: http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPost, "/api/refresh", tt.authorization, nil)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    t.Run("Revoke", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/revoke", "", nil)
+        assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+        rec = api.do(t, http.MethodPost, "/api/revoke", "Bearer " + valid, nil)
+        assert.Equal(t, http.StatusNoContent, rec.Code)
+
+        rec = api.do(t, http.MethodPost, "/api/refresh", "Bearer " + valid, nil)
+        assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+        rec = api.do(t, http.MethodPost, "/api/revoke", "Bearer unknown", nil)
+        assert.Equal(t, http.StatusUnauthorized, rec.Code)
+    })
+}
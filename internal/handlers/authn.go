@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+)
+
+// Authenticator checks the access token on a request and puts the caller's
+// auth.Principal in the request context. Routes declare whether they need
+// one by wrapping their handler with Required or Optional.
+type Authenticator struct {
+    jwtSecret string
+}
+
+func NewAuthenticator(jwtSecret string) Authenticator {
+    return Authenticator{jwtSecret: jwtSecret}
+}
+
+// Required responds with 401 unless the request has a valid access token.
+func (a Authenticator) Required(next HandlerFunc) HandlerFunc {
+    return a.wrap(next, true)
+}
+
+// Optional lets requests without an Authorization header through with no
+// principal. A header that is present must still hold a valid token.
+func (a Authenticator) Optional(next HandlerFunc) HandlerFunc {
+    return a.wrap(next, false)
+}
+
+// Permit responds with 401 unless the request has a valid access token, and
+// with 403 unless the caller's role grants perm.
+func (a Authenticator) Permit(perm auth.Permission, next HandlerFunc) HandlerFunc {
+    return a.Required(func(w http.ResponseWriter, req *http.Request) error {
+        principal, err := requirePrincipal(req)
+        if err != nil {
+            return err
+        }
+        if !principal.Can(perm) {
+            err := fmt.Errorf("%s with role %s lacks %s", principal.UserID, principal.Role, perm)
+            return problem.Forbidden(fmt.Sprintf("this requires the %s permission", perm)).Wrap(err)
+        }
+        return next(w, req)
+    })
+}
+
+func (a Authenticator) wrap(next HandlerFunc, required bool) HandlerFunc {
+    return func(w http.ResponseWriter, req *http.Request) error {
+        if !required && req.Header.Get("Authorization") == "" {
+            return next(w, req)
+        }
+
+        token, err := auth.GetBearerToken(req.Header)
+        if err != nil {
+            return problem.Unauthorized("a valid bearer token is required").Wrap(err)
+        }
+
+        principal, err := auth.ParseJWT(token, a.jwtSecret)
+        if err != nil {
+            return problem.Unauthorized("a valid bearer token is required").Wrap(err)
+        }
+
+        logger := logging.FromContext(req.Context()).With("user_id", principal.UserID)
+        ctx := auth.WithPrincipal(req.Context(), principal)
+        ctx = logging.WithLogger(ctx, logger)
+        return next(w, req.WithContext(ctx))
+    }
+}
+
+// requirePrincipal returns the caller that Authenticator stored, or a 401 if
+// there is none, e.g. because the route was registered without Required.
+func requirePrincipal(req *http.Request) (auth.Principal, error) {
+    principal, ok := auth.PrincipalFromContext(req.Context())
+    if !ok {
+        err := errors.New("route requires authentication but has no principal")
+        return principal, problem.Unauthorized("a valid bearer token is required").Wrap(err)
+    }
+    return principal, nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticator(t *testing.T) {
+    authn := NewAuthenticator(testJWTSecret)
+    userId := uuid.New()
+
+    var got *auth.Principal
+    handler := func(w http.ResponseWriter, req *http.Request) error {
+        if principal, ok := auth.PrincipalFromContext(req.Context()); ok {
+            got = &principal
+        }
+        return nil
+    }
+
+    tests := []struct{
+        name string
+        required bool
+        authorization string
+        code int
+        principal bool
+    }{
+        {name: "Required without a token", required: true, code: http.StatusUnauthorized},
+        {name: "Required with a token", required: true, authorization: bearer(t, userId), code: http.StatusOK, principal: true},
+        {name: "Required with an invalid token", required: true, authorization: "Bearer nope", code: http.StatusUnauthorized},
+        {name: "Required with a bare token", required: true, authorization: bearer(t, userId)[len("Bearer "):], code: http.StatusUnauthorized},
+        {name: "Optional without a token", code: http.StatusOK},
+        {name: "Optional with a token", authorization: bearer(t, userId), code: http.StatusOK, principal: true},
+        {name: "Optional with an invalid token", authorization: "Bearer nope", code: http.StatusUnauthorized},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            wrapped := authn.Optional(handler)
+            if tt.required {
+                wrapped = authn.Required(handler)
+            }
+            req := httptest.NewRequest(http.MethodGet, "/", nil)
+            if tt.authorization != "" {
+                req.Header.Set("Authorization", tt.authorization)
+            }
+            rec := httptest.NewRecorder()
+            got = nil
+
+            wrapped.ServeHTTP(rec, req)
+
+            assert.Equal(t, tt.code, rec.Code)
+            if tt.code == http.StatusUnauthorized {
+                assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+            }
+            if tt.principal {
+                if assert.NotNil(t, got) {
+                    assert.Equal(t, userId, got.UserID)
+                    assert.Equal(t, auth.MethodJWT, got.Method)
+                }
+            } else {
+                assert.Nil(t, got)
+            }
+        })
+    }
+}
+
+func TestRequirePrincipal(t *testing.T) {
+    _, err := requirePrincipal(httptest.NewRequest(http.MethodGet, "/", nil))
+
+    var p *problem.Error
+    if assert.ErrorAs(t, err, &p) {
+        assert.Equal(t, http.StatusUnauthorized, p.Status)
+    }
+}
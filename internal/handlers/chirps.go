@@ -1,21 +1,35 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bamcmanus/Chirpy/internal/audit"
 	"github.com/bamcmanus/Chirpy/internal/auth"
 	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
 	"github.com/google/uuid"
 )
 
+// maxChirpPageSize bounds ?limit= on GET /api/chirps.
+const maxChirpPageSize = 100
+
 type ChirpsHandler struct {
-    dbQueries *database.Queries
-    jwtSecret string
+    chirps store.ChirpStore
+    tx store.Transactor
+    events webhooks.Dispatcher
+    audit audit.Log
+    metrics *metrics.Metrics
 }
 
 type newChirpResponse struct {
@@ -27,10 +41,13 @@ type newChirpResponse struct {
 }
 
 
-func NewChirpsHandler(qs *database.Queries, jwtSecret string) ChirpsHandler {
+func NewChirpsHandler(chirps store.ChirpStore, tx store.Transactor, events webhooks.Dispatcher, log audit.Log, m *metrics.Metrics) ChirpsHandler {
     return ChirpsHandler{
-        dbQueries: qs,
-        jwtSecret: jwtSecret,
+        chirps: chirps,
+        tx: tx,
+        events: events,
+        audit: log,
+        metrics: m,
     }
 }
 
@@ -49,36 +66,20 @@ func cleanseWords(body string) string {
     return strings.Join(words, " ")
 }
 
-func (c ChirpsHandler) PostChirp(w http.ResponseWriter, req *http.Request) {
+func (c ChirpsHandler) PostChirp(w http.ResponseWriter, req *http.Request) error {
     type newChirpRequest struct {
-        Body string `json:"body"`
+        Body string `json:"body" validate:"required,max=140"`
     }
 
-    token, err := auth.GetBearerToken(req.Header)
+    principal, err := requirePrincipal(req)
     if err != nil {
-        log.Printf("failed to fetch Bearer token; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
-    }
-
-    userId, err := auth.ValidateJWT(token, c.jwtSecret)
-    if err != nil  {
-        log.Printf("JWT validation failed; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
+        return err
     }
+    userId := principal.UserID
 
-    decoder := json.NewDecoder(req.Body)
     var params newChirpRequest
-    if err := decoder.Decode(&params); err != nil {
-        log.Printf("error decoding prameters: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "Something went wrong")
-        return
-    }
-
-    if len(params.Body) > 140 {
-        _ = respondWithError(w, http.StatusBadRequest, "Chirp is too long")
-        return
+    if err := decodeJSON(w, req, &params); err != nil {
+        return err
     }
 
     body := cleanseWords(params.Body)
@@ -88,54 +89,93 @@ func (c ChirpsHandler) PostChirp(w http.ResponseWriter, req *http.Request) {
         UserID: userId,
     }
 
-    chirp, err := c.dbQueries.CreateChirp(req.Context(), cParams)
-    if err != nil {
-        log.Printf("error creating chirp; err: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed to create chirp")
-        return
-    }
+    var resp newChirpResponse
+    err = c.tx.InTx(req.Context(), func(s store.Store) error {
+        chirp, err := s.CreateChirp(req.Context(), cParams)
+        if err != nil {
+            return fmt.Errorf("creating chirp: %w", err)
+        }
 
-    resp := newChirpResponse{
-        Id: chirp.ID,
-        UserId: chirp.UserID,
-        CreatedAt: chirp.CreatedAt,
-        UpdatedAt: chirp.UpdatedAt,
-        Body: chirp.Body,
-    }
-    err = respondWithJSON(w, http.StatusCreated, resp)
+        resp = newChirpResponse{
+            Id: chirp.ID,
+            UserId: chirp.UserID,
+            CreatedAt: chirp.CreatedAt,
+            UpdatedAt: chirp.UpdatedAt,
+            Body: chirp.Body,
+        }
+        event := webhooks.Event{Type: webhooks.EventChirpCreated, UserID: chirp.UserID, Data: resp}
+        return c.events.With(s).Emit(req.Context(), event)
+    })
     if err != nil {
-        log.Fatal("could not marshal response")
+        return problem.Internal("failed to create chirp", err)
     }
+    c.metrics.ChirpCreated()
+
+    return respondWithJSON(w, http.StatusCreated, resp)
 }
 
-func (c ChirpsHandler) GetChirps(w http.ResponseWriter, req *http.Request) {
-    id := req.URL.Query().Get("author_id")
+// GetChirps lists chirps, oldest first unless ?sort=desc, optionally only
+// those by ?author_id=. With ?limit= the list is paginated: ?offset= skips
+// chirps, and a Link header points to the next page while there is one.
+func (c ChirpsHandler) GetChirps(w http.ResponseWriter, req *http.Request) error {
+    query := req.URL.Query()
+    id := query.Get("author_id")
+
+    var invalid []problem.FieldError
+    limit, offset := 0, 0
+    if raw := query.Get("limit"); raw != "" {
+        n, err := strconv.Atoi(raw)
+        if err != nil || n < 1 || n > maxChirpPageSize {
+            invalid = append(invalid, problem.FieldError{Field: "limit", Code: "out_of_range", Message: fmt.Sprintf("limit must be between 1 and %d", maxChirpPageSize)})
+        }
+        limit = n
+    }
+    if raw := query.Get("offset"); raw != "" {
+        n, err := strconv.Atoi(raw)
+        if err != nil || n < 0 || n > math.MaxInt32 {
+            invalid = append(invalid, problem.FieldError{Field: "offset", Code: "out_of_range", Message: "offset must be a non-negative integer"})
+        } else if limit == 0 {
+            invalid = append(invalid, problem.FieldError{Field: "offset", Code: "requires_limit", Message: "offset needs a limit"})
+        }
+        offset = n
+    }
 
-    var chirps []database.Chirp
-    var err error
-    if id == "" {
-        chirps, err = c.dbQueries.ListChirps(req.Context())        
-    } else {
-        userId, err := uuid.Parse(id)
+    var userId uuid.UUID
+    if id != "" {
+        var err error
+        userId, err = uuid.Parse(id)
         if err != nil {
-            log.Fatal("invalid user id")
+            invalid = append(invalid, problem.FieldError{Field: "author_id", Code: "invalid_uuid", Message: "author_id must be a UUID"})
         }
-        chirps, err = c.dbQueries.ListChirpsByUser(req.Context(), userId)
     }
+    if len(invalid) > 0 {
+        return problem.Invalid(invalid...)
+    }
+
+    params := database.ListChirpsPageParams{
+        AuthorID: uuid.NullUUID{UUID: userId, Valid: id != ""},
+        Descending: query.Get("sort") == "desc",
+        SkipRows: int32(offset),
+    }
+    // One chirp past the page tells whether there is a next one.
+    if limit > 0 {
+        params.MaxRows = sql.NullInt32{Int32: int32(limit + 1), Valid: true}
+    }
+    chirps, err := c.chirps.ListChirpsPage(req.Context(), params)
     if err != nil {
-        log.Printf("error fetching chirps: %s", err)
-        _ = respondWithError(w, http.StatusNotFound, "failed to fetch chirps")
-        return
+        return problem.Internal("failed to fetch chirps", err)
     }
 
-    sortOrder := req.URL.Query().Get("sort")
-    if sortOrder == "desc" {
-        sort.Slice(chirps, func(i, j int) bool {
-            return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
-        })
+    if limit > 0 && len(chirps) > limit {
+        chirps = chirps[:limit]
+        next := *req.URL
+        q := next.Query()
+        q.Set("offset", strconv.Itoa(offset + limit))
+        next.RawQuery = q.Encode()
+        w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
     }
 
-    var chirpResponses []newChirpResponse
+    chirpResponses := make([]newChirpResponse, 0, len(chirps))
     for _, chirp := range chirps {
         chirpResponse := newChirpResponse{
             Id: chirp.ID,
@@ -146,24 +186,22 @@ func (c ChirpsHandler) GetChirps(w http.ResponseWriter, req *http.Request) {
         }
         chirpResponses = append(chirpResponses, chirpResponse)
     }
-    _ = respondWithJSON(w, http.StatusOK, chirpResponses)
+    return respondWithJSON(w, http.StatusOK, chirpResponses)
 }
 
-func (c ChirpsHandler) GetChirp(w http.ResponseWriter, req *http.Request) {
-    chirpId := req.PathValue("chirpID")
-    log.Printf("received chirp ID: %s", chirpId)
-    id := uuid.MustParse(chirpId)
-    chirp, err := c.dbQueries.GetChirp(req.Context(), id)
+func (c ChirpsHandler) GetChirp(w http.ResponseWriter, req *http.Request) error {
+    id, err := pathID(req, "chirpID", "invalid_chirp_id")
     if err != nil {
-        log.Printf("error fetching chirp; err: %s", err)
-        _ = respondWithError(w, http.StatusNotFound, "failed to get chirp")
-        return
+        return err
     }
-    log.Printf("chirp: %+v", chirp)
 
-    if chirp.ID.String() == "" {
-        _ = respondWithError(w, http.StatusNotFound, "chirp not found")
-        return
+    logging.FromContext(req.Context()).Debug("fetching chirp", "chirp_id", id)
+    chirp, err := c.chirps.GetChirp(req.Context(), id)
+    if errors.Is(err, sql.ErrNoRows) {
+        return problem.NotFound("chirp_not_found", "chirp not found")
+    }
+    if err != nil {
+        return problem.Internal("failed to get chirp", err)
     }
 
     resp := newChirpResponse{
@@ -173,51 +211,68 @@ func (c ChirpsHandler) GetChirp(w http.ResponseWriter, req *http.Request) {
         Body: chirp.Body,
         UserId: chirp.UserID,
     }
-    _ = respondWithJSON(w, http.StatusOK, resp)
+    return respondWithJSON(w, http.StatusOK, resp)
 }
 
-func (c ChirpsHandler) DeleteChirp(w http.ResponseWriter, req *http.Request) {
-    token, err := auth.GetBearerToken(req.Header)
+func (c ChirpsHandler) DeleteChirp(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
     if err != nil {
-        log.Printf("failed to fetch Bearer token; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
-    }
-
-    userId, err := auth.ValidateJWT(token, c.jwtSecret)
-    if err != nil  {
-        log.Printf("JWT validation failed; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
+        return err
     }
+    userId := principal.UserID
 
-    chirpId, err := uuid.Parse(req.PathValue("chirpID"))
+    chirpId, err := pathID(req, "chirpID", "invalid_chirp_id")
     if err != nil {
-        log.Printf("could not parse chirp ID; error: %s", err)
-        _ = respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
-        return
+        return err
     }
 
-    chirp, err := c.dbQueries.GetChirp(req.Context(), chirpId)
+    chirp, err := c.chirps.GetChirp(req.Context(), chirpId)
+    if errors.Is(err, sql.ErrNoRows) {
+        return problem.NotFound("chirp_not_found", "chirp not found")
+    }
     if err != nil {
-        log.Printf("error fetching chirp; err: %s", err)
-        _ = respondWithError(w, http.StatusNotFound, "failed to get chirps")
-        return
+        return problem.Internal("failed to get chirp", err)
     }
 
-    if userId != chirp.UserID {
-        log.Printf("cannot delete chirp as user is not owner; chirp owner: %s; requsting user: %s", chirp.UserID.String(), userId.String())
-        _ = respondWithError(w, http.StatusForbidden, "forbidden")
-        return
+    // Moderators may delete anyone's chirps.
+    if userId != chirp.UserID && !principal.Can(auth.PermModerateChirps) {
+        err := fmt.Errorf("chirp %s is owned by %s", chirp.ID, chirp.UserID)
+        return problem.Forbidden("only the author or a moderator can delete a chirp").Wrap(err)
     }
 
-    err = c.dbQueries.DeleteChirp(req.Context(), chirpId)
+    event := webhooks.Event{
+        Type: webhooks.EventChirpDeleted,
+        UserID: chirp.UserID,
+        Data: struct{
+            Id uuid.UUID `json:"id"`
+            UserId uuid.UUID `json:"user_id"`
+        }{
+            Id: chirp.ID,
+            UserId: chirp.UserID,
+        },
+    }
+    err = c.tx.InTx(req.Context(), func(s store.Store) error {
+        if err := s.DeleteChirp(req.Context(), chirpId); err != nil {
+            return fmt.Errorf("deleting chirp: %w", err)
+        }
+        err := c.audit.With(s).Record(req.Context(), audit.Event{
+            Action: audit.ActionChirpDeleted,
+            ActorID: userId,
+            TargetID: chirp.ID,
+            Metadata: map[string]any{"author_id": chirp.UserID, "moderated": userId != chirp.UserID},
+        })
+        if err != nil {
+            return err
+        }
+        return c.events.With(s).Emit(req.Context(), event)
+    })
     if err != nil {
-        log.Printf("failed to delete chirp; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed to delete chirp")
-        return
+        return problem.Internal("failed to delete chirp", err)
+    }
+    if userId != chirp.UserID {
+        logging.FromContext(req.Context()).Info("moderator deleted chirp", "chirp_id", chirp.ID, "author_id", chirp.UserID)
     }
 
     w.WriteHeader(http.StatusNoContent)
+    return nil
 }
-
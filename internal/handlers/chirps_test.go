@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chirpRequest struct {
+    Body string `json:"body"`
+}
+
+func TestCleanseWords(t *testing.T) {
+    tests := []struct {
+        body string
+        want string
+    }{
+        {body: "hello world", want: "hello world"},
+        {body: "what a kerfuffle", want: "what a ****"},
+        {body: "Sharbert and FORNAX", want: "**** and ****"},
+        {body: "kerfuffle!", want: "kerfuffle!"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.body, func(t *testing.T) {
+            assert.Equal(t, tt.want, cleanseWords(tt.body))
+        })
+    }
+}
+
+func TestPostChirp(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, token := api.createUser(t, "user@example.com", "password")
+
+    tests := []struct {
+        name string
+        authorization string
+        body any
+        code int
+    }{
+        {name: "Missing token", body: chirpRequest{Body: "hi"}, code: http.StatusUnauthorized},
+        {name: "Invalid token", authorization: "Bearer nope", body: chirpRequest{Body: "hi"}, code: http.StatusUnauthorized},
+        {name: "Too long", authorization: token, body: chirpRequest{Body: strings.Repeat("a", 141)}, code: http.StatusBadRequest},
+        {name: "Empty", authorization: token, body: chirpRequest{}, code: http.StatusBadRequest},
+        {name: "Malformed body", authorization: token, body: "{", code: http.StatusBadRequest},
+        {name: "Unknown user", authorization: bearer(t, uuid.New()), body: chirpRequest{Body: "hi"}, code: http.StatusInternalServerError},
+        {name: "Happy path", authorization: token, body: chirpRequest{Body: "what a kerfuffle"}, code: http.StatusCreated},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPost, "/api/chirps", tt.authorization, tt.body)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    chirps, err := api.store.ListChirps(context.Background())
+    require.NoError(t, err)
+    require.Len(t, chirps, 1)
+    assert.Equal(t, "what a ****", chirps[0].Body)
+    assert.Equal(t, user.ID, chirps[0].UserID)
+
+    t.Run("Length is counted in characters", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/chirps", token, chirpRequest{Body: strings.Repeat("🐦", 140)})
+        assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+        rec = api.do(t, http.MethodPost, "/api/chirps", token, chirpRequest{Body: strings.Repeat("🐦", 141)})
+        assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+    })
+}
+
+func TestPostChirpQueuesWebhookDeliveries(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, token := api.createUser(t, "user@example.com", "password")
+    hook, err := api.store.CreateWebhook(context.Background(), database.CreateWebhookParams{
+        UserID: user.ID,
+        Url: "https://example.com/hook",
+        Secret: "0123456789abcdef",
+        Events: []string{webhooks.EventChirpCreated},
+    })
+    require.NoError(t, err)
+
+    rec := api.do(t, http.MethodPost, "/api/chirps", token, chirpRequest{Body: "hello"})
+    require.Equal(t, http.StatusCreated, rec.Code)
+
+    deliveries, err := api.store.ListWebhookDeliveries(context.Background(), database.ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: 10})
+    require.NoError(t, err)
+    require.Len(t, deliveries, 1)
+    assert.Equal(t, webhooks.EventChirpCreated, deliveries[0].Event)
+}
+
+func TestGetChirps(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    alice, aliceToken := api.createUser(t, "alice@example.com", "password")
+    _, bobToken := api.createUser(t, "bob@example.com", "password")
+    for _, c := range []struct{ token, body string }{
+        {aliceToken, "first"},
+        {bobToken, "second"},
+        {aliceToken, "third"},
+    } {
+        rec := api.do(t, http.MethodPost, "/api/chirps", c.token, chirpRequest{Body: c.body})
+        require.Equal(t, http.StatusCreated, rec.Code)
+    }
+
+    tests := []struct {
+        name string
+        query string
+        want []string
+        next string
+    }{
+        {name: "All chirps in ascending order", query: "", want: []string{"first", "second", "third"}},
+        {name: "Descending order", query: "?sort=desc", want: []string{"third", "second", "first"}},
+        {name: "By author", query: "?author_id=" + alice.ID.String(), want: []string{"first", "third"}},
+        {name: "By author with no chirps", query: "?author_id=" + uuid.NewString(), want: nil},
+        {name: "First page", query: "?limit=2", want: []string{"first", "second"}, next: `</api/chirps?limit=2&offset=2>; rel="next"`},
+        {name: "Last page", query: "?limit=2&offset=2", want: []string{"third"}},
+        {name: "Page past the end", query: "?limit=2&offset=10", want: nil},
+        {name: "Pages keep the filters", query: "?sort=desc&limit=1", want: []string{"third"}, next: `</api/chirps?limit=1&offset=1&sort=desc>; rel="next"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodGet, "/api/chirps" + tt.query, "", nil)
+            require.Equal(t, http.StatusOK, rec.Code)
+
+            var bodies []string
+            for _, c := range decode[[]newChirpResponse](t, rec) {
+                bodies = append(bodies, c.Body)
+            }
+            assert.Equal(t, tt.want, bodies)
+            assert.Equal(t, tt.next, rec.Header().Get("Link"))
+        })
+    }
+
+    for _, query := range []string{"?limit=0", "?limit=101", "?limit=2&offset=-1", "?limit=2&offset=2147483648", "?offset=1"} {
+        t.Run("Rejects " + query, func(t *testing.T) {
+            rec := api.do(t, http.MethodGet, "/api/chirps" + query, "", nil)
+            assert.Equal(t, http.StatusBadRequest, rec.Code)
+        })
+    }
+}
+
+func TestGetChirp(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    _, token := api.createUser(t, "user@example.com", "password")
+    rec := api.do(t, http.MethodPost, "/api/chirps", token, chirpRequest{Body: "hello"})
+    require.Equal(t, http.StatusCreated, rec.Code)
+    chirp := decode[newChirpResponse](t, rec)
+
+    tests := []struct {
+        name string
+        id uuid.UUID
+        code int
+    }{
+        {name: "Happy path", id: chirp.Id, code: http.StatusOK},
+        {name: "Not found", id: uuid.New(), code: http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodGet, "/api/chirps/" + tt.id.String(), "", nil)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+}
+
+func TestDeleteChirp(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    _, ownerToken := api.createUser(t, "owner@example.com", "password")
+    _, otherToken := api.createUser(t, "other@example.com", "password")
+    rec := api.do(t, http.MethodPost, "/api/chirps", ownerToken, chirpRequest{Body: "hello"})
+    require.Equal(t, http.StatusCreated, rec.Code)
+    chirp := decode[newChirpResponse](t, rec)
+    path := "/api/chirps/" + chirp.Id.String()
+
+    tests := []struct {
+        name string
+        authorization string
+        path string
+        code int
+    }{
+        {name: "Missing token", path: path, code: http.StatusUnauthorized},
+        {name: "Invalid ID", authorization: ownerToken, path: "/api/chirps/not-a-uuid", code: http.StatusBadRequest},
+        {name: "Not found", authorization: ownerToken, path: "/api/chirps/" + uuid.NewString(), code: http.StatusNotFound},
+        {name: "Not the owner", authorization: otherToken, path: path, code: http.StatusForbidden},
+        {name: "Happy path", authorization: ownerToken, path: path, code: http.StatusNoContent},
+        {name: "Already deleted", authorization: ownerToken, path: path, code: http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodDelete, tt.path, tt.authorization, nil)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    t.Run("Moderators delete anyone's chirps", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/chirps", ownerToken, chirpRequest{Body: "spam"})
+        require.Equal(t, http.StatusCreated, rec.Code)
+        spam := decode[newChirpResponse](t, rec)
+        moderator := bearerWithRole(t, uuid.New(), auth.RoleModerator)
+
+        rec = api.do(t, http.MethodDelete, "/api/chirps/" + spam.Id.String(), moderator, nil)
+
+        assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+        _, err := api.store.GetChirp(context.Background(), spam.Id)
+        assert.ErrorIs(t, err, sql.ErrNoRows)
+    })
+}
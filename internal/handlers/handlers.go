@@ -2,11 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/validate"
+	"github.com/google/uuid"
 )
 
-type errResponse struct {
-    Error string `json:"error"`
+// HandlerFunc is a handler that returns its errors instead of writing them.
+// Errors are rendered as problem details; see the problem package.
+type HandlerFunc func(w http.ResponseWriter, req *http.Request) error
+
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    if err := f(w, req); err != nil {
+        problem.Write(w, req, err)
+    }
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload any) error {
@@ -20,7 +37,103 @@ func respondWithJSON(w http.ResponseWriter, code int, payload any) error {
     return nil
 }
 
-func respondWithError(w http.ResponseWriter, code int, msg string) error {
-    return respondWithJSON(w, code, errResponse{Error: msg})
+// maxBodyBytes bounds every JSON request body. The largest legitimate one, a
+// webhook registration, is well under a kilobyte.
+const maxBodyBytes = 64 << 10
+
+// decodeJSON decodes a JSON request body into the struct v points to and
+// checks it against its validate tags. The body must be a single JSON object
+// of at most maxBodyBytes with no fields that v doesn't have.
+func decodeJSON(w http.ResponseWriter, req *http.Request, v any) error {
+    return decodeBody(w, req, v, true)
+}
+
+// decodeLenientJSON is decodeJSON for bodies defined by a third party, which
+// may add fields at any time: unknown fields are ignored.
+func decodeLenientJSON(w http.ResponseWriter, req *http.Request, v any) error {
+    return decodeBody(w, req, v, false)
+}
+
+func decodeBody(w http.ResponseWriter, req *http.Request, v any, strict bool) error {
+    mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+    if err != nil || mediaType != "application/json" {
+        return problem.New(http.StatusUnsupportedMediaType, "unsupported_media_type", "the request body must be application/json").Wrap(err)
+    }
+
+    decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodyBytes))
+    if strict {
+        decoder.DisallowUnknownFields()
+    }
+    if err := decoder.Decode(v); err != nil {
+        return decodeError(err)
+    }
+    if _, err := decoder.Token(); err != io.EOF {
+        if err == nil {
+            err = errors.New("data after the JSON value")
+        }
+        return decodeError(err)
+    }
+
+    if fields := validate.Struct(v); len(fields) > 0 {
+        return problem.Invalid(fields...)
+    }
+    return nil
 }
 
+// decodeError explains why a body couldn't be decoded as precisely as the
+// client can use.
+func decodeError(err error) error {
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        detail := fmt.Sprintf("the request body must be at most %d bytes", tooLarge.Limit)
+        return problem.New(http.StatusRequestEntityTooLarge, "body_too_large", detail).Wrap(err)
+    }
+
+    var typeErr *json.UnmarshalTypeError
+    if errors.As(err, &typeErr) && typeErr.Field != "" {
+        return problem.Invalid(problem.FieldError{
+            Field: typeErr.Field,
+            Code: "invalid_type",
+            Message: fmt.Sprintf("%s must be a JSON %s", typeErr.Field, jsonType(typeErr.Type)),
+        }).Wrap(err)
+    }
+
+    // encoding/json has no error type for unknown fields.
+    if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+        field, _ = strconv.Unquote(field)
+        return problem.Invalid(problem.FieldError{
+            Field: field,
+            Code: "unknown_field",
+            Message: "unknown field " + field,
+        }).Wrap(err)
+    }
+
+    if errors.Is(err, io.EOF) {
+        return problem.BadRequest(problem.CodeInvalidJSON, "the request body is empty").Wrap(err)
+    }
+    return problem.BadRequest(problem.CodeInvalidJSON, "the request body is not valid JSON").Wrap(err)
+}
+
+func jsonType(t reflect.Type) string {
+    switch t.Kind() {
+    case reflect.String:
+        return "string"
+    case reflect.Bool:
+        return "boolean"
+    case reflect.Slice, reflect.Array:
+        return "array"
+    case reflect.Struct, reflect.Map:
+        return "object"
+    default:
+        return "number"
+    }
+}
+
+// pathID parses the UUID in the named path value.
+func pathID(req *http.Request, name, code string) (uuid.UUID, error) {
+    id, err := uuid.Parse(req.PathValue(name))
+    if err != nil {
+        return id, problem.BadRequest(code, "invalid " + name).Wrap(err)
+    }
+    return id, nil
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/health"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/openapi"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/ratelimit"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+    testJWTSecret = "test-jwt-secret"
+    testPolkaKey = "test-polka-key"
+)
+
+// testAPI serves every route, with the middleware main applies, from an
+// in-memory store.
+type testAPI struct {
+    handler http.Handler
+    store *store.Memory
+    metrics *metrics.Metrics
+}
+
+func newTestAPI(t *testing.T, platform string) testAPI {
+    t.Helper()
+
+    s := store.NewMemory()
+    m := metrics.New(nil)
+    mux := http.NewServeMux()
+    Routes(mux, Deps{
+        Store: s,
+        Tx: s,
+        Metrics: m,
+        Checker: health.New(time.Second),
+        Platform: platform,
+        JWTSecret: testJWTSecret,
+        PolkaKey: testPolkaKey,
+        JWTLifetime: time.Hour,
+        RefreshTokenLifetime: 24 * time.Hour,
+    })
+
+    handler := Middleware(mux, slog.New(slog.DiscardHandler), m, nil, ratelimit.Proxies(nil).ClientIP)
+    return testAPI{handler: handler, store: s, metrics: m}
+}
+
+// do sends a request with an optional JSON body and Authorization header.
+func (a testAPI) do(t *testing.T, method, path, authorization string, body any) *httptest.ResponseRecorder {
+    t.Helper()
+
+    var buf bytes.Buffer
+    switch b := body.(type) {
+    case nil:
+    case string:
+        buf.WriteString(b)
+    default:
+        require.NoError(t, json.NewEncoder(&buf).Encode(b))
+    }
+
+    req := httptest.NewRequest(method, path, &buf)
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    if authorization != "" {
+        req.Header.Set("Authorization", authorization)
+    }
+    rec := httptest.NewRecorder()
+    a.handler.ServeHTTP(rec, req)
+    return rec
+}
+
+// createUser adds a user directly to the store and returns it with a
+// bearer header for a valid JWT.
+func (a testAPI) createUser(t *testing.T, email, password string) (database.User, string) {
+    t.Helper()
+
+    hash, err := auth.HashPassword(password)
+    require.NoError(t, err)
+    user, err := a.store.CreateUser(context.Background(), database.CreateUserParams{Email: email, HashedPassword: hash})
+    require.NoError(t, err)
+    return user, bearer(t, user.ID)
+}
+
+func bearer(t *testing.T, userId uuid.UUID) string {
+    t.Helper()
+
+    return bearerWithRole(t, userId, auth.RoleUser)
+}
+
+func bearerWithRole(t *testing.T, userId uuid.UUID, role auth.Role) string {
+    t.Helper()
+
+    token, err := auth.MakeJWT(userId, role, testJWTSecret, time.Hour)
+    require.NoError(t, err)
+    return "Bearer " + token
+}
+
+// adminToken returns a bearer header for an admin who needn't exist: the
+// admin routes only check the token.
+func adminToken(t *testing.T) string {
+    t.Helper()
+    return bearerWithRole(t, uuid.New(), auth.RoleAdmin)
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+    t.Helper()
+
+    var v T
+    require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &v), rec.Body.String())
+    return v
+}
+
+// TestMalformedInput fires malformed bodies, path values, query strings and
+// credentials at every route. None of them may panic or cause a 500. The mux
+// isn't wrapped in recovery middleware, so a panic fails the test.
+func TestMalformedInput(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    _, token := api.createUser(t, "user@example.com", "password")
+    admin := adminToken(t)
+    polkaKey := "ApiKey " + testPolkaKey
+
+    bodies := []string{"", "{", "null", "[]", "42", `"chirp"`, `{"email": 5, "password": true}`, `{"body": {}}`, `{"data": []}`, "\x00\xff"}
+    ids := []string{"not-a-uuid", "00000000-0000-0000-0000-00000000000", "%00", "%E2%98%83", "..%2F"}
+
+    type request struct {
+        method string
+        path string
+        authorization string
+        body string
+    }
+    var requests []request
+    for _, body := range bodies {
+        for _, r := range []request{
+            {method: http.MethodPost, path: "/api/login"},
+            {method: http.MethodPost, path: "/api/users"},
+            {method: http.MethodPut, path: "/api/users", authorization: token},
+            {method: http.MethodPost, path: "/api/chirps", authorization: token},
+            {method: http.MethodPost, path: "/api/webhooks", authorization: token},
+            {method: http.MethodPost, path: "/api/polka/webhooks", authorization: polkaKey},
+            {method: http.MethodPut, path: "/admin/users/" + uuid.NewString() + "/role", authorization: admin},
+        } {
+            r.body = body
+            requests = append(requests, r)
+        }
+    }
+    for _, id := range ids {
+        requests = append(requests,
+            request{method: http.MethodGet, path: "/api/chirps/" + id},
+            request{method: http.MethodDelete, path: "/api/chirps/" + id, authorization: token},
+            request{method: http.MethodDelete, path: "/api/webhooks/" + id, authorization: token},
+            request{method: http.MethodGet, path: "/api/webhooks/" + id + "/deliveries", authorization: token},
+            request{method: http.MethodGet, path: "/admin/jobs/" + id, authorization: admin},
+            request{method: http.MethodPost, path: "/admin/jobs/" + id + "/retry", authorization: admin},
+            request{method: http.MethodPut, path: "/admin/users/" + id + "/role", authorization: admin, body: `{"role": "admin", "reason": "x"}`},
+            request{method: http.MethodGet, path: "/admin/users/" + id + "/role-changes", authorization: admin},
+        )
+    }
+    for _, query := range []string{"author_id=nope", "author_id=", "sort=sideways", "sort=desc&sort=asc", "author_id=%00"} {
+        requests = append(requests, request{method: http.MethodGet, path: "/api/chirps?" + query})
+    }
+    for _, query := range []string{"status=nope", "limit=0", "limit=-1", "limit=abc", "limit=99999999999999999999"} {
+        requests = append(requests, request{method: http.MethodGet, path: "/admin/jobs?" + query, authorization: admin})
+    }
+    for _, authorization := range []string{"Bearer", "Bearer ", "Bearer a b", "Bearer a.b.c", "ApiKey", "ApiKey " + testPolkaKey + " x", "Basic dXNlcjpwYXNz"} {
+        requests = append(requests,
+            request{method: http.MethodPost, path: "/api/refresh", authorization: authorization},
+            request{method: http.MethodPost, path: "/api/revoke", authorization: authorization},
+            request{method: http.MethodPut, path: "/api/users", authorization: authorization, body: `{}`},
+            request{method: http.MethodPost, path: "/api/chirps", authorization: authorization, body: `{"body": "hi"}`},
+            request{method: http.MethodGet, path: "/api/webhooks", authorization: authorization},
+            request{method: http.MethodPost, path: "/api/polka/webhooks", authorization: authorization, body: `{}`},
+        )
+    }
+
+    for _, r := range requests {
+        t.Run(r.method + " " + r.path, func(t *testing.T) {
+            rec := api.do(t, r.method, r.path, r.authorization, r.body)
+
+            assert.Less(t, rec.Code, http.StatusInternalServerError, rec.Body.String())
+            if rec.Code >= http.StatusBadRequest {
+                assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+            }
+        })
+    }
+}
+
+func TestDecodeJSON(t *testing.T) {
+    type request struct {
+        Body string `json:"body" validate:"required,max=5"`
+    }
+
+    tests := []struct {
+        name string
+        contentType string
+        body string
+        lenient bool
+        code int
+        problem string
+    }{
+        {name: "Valid", contentType: "application/json", body: `{"body": "hi"}`},
+        {name: "Content-Type with parameters", contentType: "application/json; charset=utf-8", body: `{"body": "hi"}`},
+        {name: "Missing Content-Type", body: `{"body": "hi"}`, code: http.StatusUnsupportedMediaType, problem: "unsupported_media_type"},
+        {name: "Form", contentType: "application/x-www-form-urlencoded", body: "body=hi", code: http.StatusUnsupportedMediaType, problem: "unsupported_media_type"},
+        {name: "Empty", contentType: "application/json", code: http.StatusBadRequest, problem: problem.CodeInvalidJSON},
+        {name: "Too large", contentType: "application/json", body: `{"body": "` + strings.Repeat("a", maxBodyBytes) + `"}`, code: http.StatusRequestEntityTooLarge, problem: "body_too_large"},
+        {name: "Unknown field", contentType: "application/json", body: `{"body": "hi", "admin": true}`, code: http.StatusBadRequest, problem: problem.CodeValidation},
+        {name: "Unknown field when lenient", contentType: "application/json", body: `{"body": "hi", "admin": true}`, lenient: true},
+        {name: "Trailing object", contentType: "application/json", body: `{"body": "hi"}{"body": "again"}`, code: http.StatusBadRequest, problem: problem.CodeInvalidJSON},
+        {name: "Trailing garbage", contentType: "application/json", body: `{"body": "hi"} x`, code: http.StatusBadRequest, problem: problem.CodeInvalidJSON},
+        {name: "Trailing whitespace", contentType: "application/json", body: "{\"body\": \"hi\"}\n"},
+        {name: "Wrong type", contentType: "application/json", body: `{"body": 5}`, code: http.StatusBadRequest, problem: problem.CodeValidation},
+        {name: "Fails validation", contentType: "application/json", body: `{"body": "too long"}`, code: http.StatusBadRequest, problem: problem.CodeValidation},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+            if tt.contentType != "" {
+                req.Header.Set("Content-Type", tt.contentType)
+            }
+
+            var v request
+            var err error
+            if tt.lenient {
+                err = decodeLenientJSON(httptest.NewRecorder(), req, &v)
+            } else {
+                err = decodeJSON(httptest.NewRecorder(), req, &v)
+            }
+
+            if tt.code == 0 {
+                assert.NoError(t, err)
+                assert.Equal(t, "hi", v.Body)
+                return
+            }
+            var p *problem.Error
+            if assert.ErrorAs(t, err, &p) {
+                assert.Equal(t, tt.code, p.Status)
+                assert.Equal(t, tt.problem, p.Code)
+            }
+        })
+    }
+
+    t.Run("Field errors name the field", func(t *testing.T) {
+        req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"body": 5, "admin": true}`))
+        req.Header.Set("Content-Type", "application/json")
+
+        err := decodeJSON(httptest.NewRecorder(), req, &request{})
+
+        var p *problem.Error
+        if assert.ErrorAs(t, err, &p) {
+            assert.Equal(t, []problem.FieldError{{Field: "body", Code: "invalid_type", Message: "body must be a JSON string"}}, p.Fields)
+        }
+    })
+}
+
+// TestOpenAPISchemas checks that the documented schemas have the same
+// properties as the types the handlers encode and decode.
+func TestOpenAPISchemas(t *testing.T) {
+    var spec struct {
+        Components struct {
+            Schemas map[string]struct {
+                Properties map[string]json.RawMessage `json:"properties"`
+            } `json:"schemas"`
+        } `json:"components"`
+    }
+    require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+
+    tests := map[string]any{
+        "UserRequest": userRequest{},
+        "User": userResponse{},
+        "Chirp": newChirpResponse{},
+        "Webhook": webhookResponse{},
+        "WebhookDelivery": webhookDeliveryResponse{},
+        "WebhookAttempt": webhookAttemptResponse{},
+        "Job": jobResponse{},
+        "JobList": jobListResponse{},
+        "RoleChange": roleChangeResponse{},
+        "AuditEvent": auditEventResponse{},
+        "SeedRequest": seedRequest{},
+        "SeedResult": seedResponse{},
+        "FieldError": problem.FieldError{},
+    }
+    for name, v := range tests {
+        t.Run(name, func(t *testing.T) {
+            schema, ok := spec.Components.Schemas[name]
+            require.True(t, ok, "schema %s is missing", name)
+
+            var documented []string
+            for property := range schema.Properties {
+                documented = append(documented, property)
+            }
+            assert.ElementsMatch(t, jsonFields(reflect.TypeOf(v)), documented)
+        })
+    }
+}
+
+func jsonFields(t reflect.Type) []string {
+    var fields []string
+    for i := range t.NumField() {
+        name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+        if name != "" && name != "-" {
+            fields = append(fields, name)
+        }
+    }
+    return fields
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/jobs"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+    defaultJobListLimit = 50
+    maxJobListLimit = 500
+)
+
+// JobsHandler serves the admin view of the background job queue.
+type JobsHandler struct {
+    jobs store.JobStore
+}
+
+func NewJobsHandler(jobs store.JobStore) JobsHandler {
+    return JobsHandler{
+        jobs: jobs,
+    }
+}
+
+type jobResponse struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Kind string `json:"kind"`
+    Payload json.RawMessage `json:"payload"`
+    Status string `json:"status"`
+    Attempts int32 `json:"attempts"`
+    RunAt time.Time `json:"run_at"`
+    LastError string `json:"last_error,omitempty"`
+    FinishedAt *time.Time `json:"finished_at"`
+}
+
+type jobListResponse struct {
+    // Counts holds the number of jobs in each status, across all jobs.
+    Counts map[string]int64 `json:"counts"`
+    Jobs []jobResponse `json:"jobs"`
+}
+
+func newJobResponse(job database.Job) jobResponse {
+    res := jobResponse{
+        Id: job.ID,
+        CreatedAt: job.CreatedAt,
+        UpdatedAt: job.UpdatedAt,
+        Kind: job.Kind,
+        Payload: job.Payload,
+        Status: job.Status,
+        Attempts: job.Attempts,
+        RunAt: job.RunAt,
+        LastError: job.LastError.String,
+    }
+    if job.FinishedAt.Valid {
+        res.FinishedAt = &job.FinishedAt.Time
+    }
+    return res
+}
+
+// ListJobs lists the newest jobs, optionally filtered by ?status=, e.g.
+// ?status=dead for the dead-letter queue.
+func (h JobsHandler) ListJobs(w http.ResponseWriter, req *http.Request) error {
+    var invalid []problem.FieldError
+    status := req.URL.Query().Get("status")
+    if status != "" && !slices.Contains(jobs.Statuses, status) {
+        invalid = append(invalid, problem.FieldError{Field: "status", Code: "unknown_status", Message: "unknown job status: " + status})
+    }
+
+    limit := defaultJobListLimit
+    if raw := req.URL.Query().Get("limit"); raw != "" {
+        n, err := strconv.Atoi(raw)
+        if err != nil || n < 1 || n > maxJobListLimit {
+            invalid = append(invalid, problem.FieldError{Field: "limit", Code: "out_of_range", Message: "limit must be between 1 and 500"})
+        }
+        limit = n
+    }
+    if len(invalid) > 0 {
+        return problem.Invalid(invalid...)
+    }
+
+    counts, err := h.jobs.CountJobsByStatus(req.Context())
+    if err != nil {
+        return problem.Internal("failed to list jobs", fmt.Errorf("counting jobs: %w", err))
+    }
+
+    list, err := h.jobs.ListJobs(req.Context(), database.ListJobsParams{
+        Status: status,
+        MaxRows: int32(limit),
+    })
+    if err != nil {
+        return problem.Internal("failed to list jobs", err)
+    }
+
+    res := jobListResponse{
+        Counts: make(map[string]int64, len(jobs.Statuses)),
+        Jobs: make([]jobResponse, 0, len(list)),
+    }
+    for _, s := range jobs.Statuses {
+        res.Counts[s] = 0
+    }
+    for _, c := range counts {
+        res.Counts[c.Status] = c.Count
+    }
+    for _, job := range list {
+        res.Jobs = append(res.Jobs, newJobResponse(job))
+    }
+    return respondWithJSON(w, http.StatusOK, res)
+}
+
+func (h JobsHandler) GetJob(w http.ResponseWriter, req *http.Request) error {
+    jobId, err := pathID(req, "jobID", "invalid_job_id")
+    if err != nil {
+        return err
+    }
+
+    job, err := h.jobs.GetJob(req.Context(), jobId)
+    if errors.Is(err, sql.ErrNoRows) {
+        return problem.NotFound("job_not_found", "job not found")
+    }
+    if err != nil {
+        return problem.Internal("failed to get job", fmt.Errorf("getting job %s: %w", jobId, err))
+    }
+
+    return respondWithJSON(w, http.StatusOK, newJobResponse(job))
+}
+
+// RetryJob moves a job from the dead-letter queue back to pending, with its
+// attempts reset, so it runs again as soon as a worker is free.
+func (h JobsHandler) RetryJob(w http.ResponseWriter, req *http.Request) error {
+    jobId, err := pathID(req, "jobID", "invalid_job_id")
+    if err != nil {
+        return err
+    }
+
+    job, err := h.jobs.RequeueDeadJob(req.Context(), jobId)
+    if errors.Is(err, sql.ErrNoRows) {
+        if _, err := h.jobs.GetJob(req.Context(), jobId); err == nil {
+            return problem.Conflict("job_not_dead", "only dead jobs can be retried")
+        }
+        return problem.NotFound("job_not_found", "job not found")
+    }
+    if err != nil {
+        return problem.Internal("failed to retry job", fmt.Errorf("requeueing job %s: %w", jobId, err))
+    }
+
+    logging.FromContext(req.Context()).Info("requeued dead job", "job_id", job.ID, "kind", job.Kind)
+    return respondWithJSON(w, http.StatusOK, newJobResponse(job))
+}
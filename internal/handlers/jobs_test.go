@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/jobs"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadJob enqueues a job and moves it straight to the dead-letter queue.
+func (a testAPI) deadJob(t *testing.T) database.Job {
+    t.Helper()
+
+    ctx := context.Background()
+    job, err := jobs.Enqueue(ctx, a.store, "email.send", map[string]string{"to": "user@example.com"}, time.Time{})
+    require.NoError(t, err)
+    _, err = a.store.ClaimDueJobs(ctx, 1)
+    require.NoError(t, err)
+    require.NoError(t, a.store.DeadLetterJob(ctx, database.DeadLetterJobParams{ID: job.ID}))
+    return job
+}
+
+func TestListJobs(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    dead := api.deadJob(t)
+    _, err := jobs.Enqueue(context.Background(), api.store, "email.send", nil, time.Now().Add(time.Hour))
+    require.NoError(t, err)
+
+    t.Run("Lists every job with counts", func(t *testing.T) {
+        rec := api.do(t, http.MethodGet, "/admin/jobs", adminToken(t), nil)
+
+        require.Equal(t, http.StatusOK, rec.Code)
+        res := decode[jobListResponse](t, rec)
+        assert.Len(t, res.Jobs, 2)
+        assert.Equal(t, map[string]int64{"pending": 1, "running": 0, "succeeded": 0, "dead": 1}, res.Counts)
+    })
+
+    t.Run("Filters by status", func(t *testing.T) {
+        rec := api.do(t, http.MethodGet, "/admin/jobs?status=dead", adminToken(t), nil)
+
+        require.Equal(t, http.StatusOK, rec.Code)
+        res := decode[jobListResponse](t, rec)
+        require.Len(t, res.Jobs, 1)
+        assert.Equal(t, dead.ID, res.Jobs[0].Id)
+        assert.JSONEq(t, `{"to":"user@example.com"}`, string(res.Jobs[0].Payload))
+    })
+
+    for _, query := range []string{"?status=lost", "?limit=0", "?limit=many"} {
+        t.Run("Rejects "+query, func(t *testing.T) {
+            rec := api.do(t, http.MethodGet, "/admin/jobs"+query, adminToken(t), nil)
+
+            assert.Equal(t, http.StatusBadRequest, rec.Code)
+        })
+    }
+}
+
+func TestGetJob(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    job := api.deadJob(t)
+
+    tests := []struct {
+        name string
+        path string
+        code int
+    }{
+        {name: "Invalid ID", path: "/admin/jobs/nope", code: http.StatusBadRequest},
+        {name: "Unknown job", path: "/admin/jobs/" + uuid.NewString(), code: http.StatusNotFound},
+        {name: "Happy path", path: "/admin/jobs/" + job.ID.String(), code: http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodGet, tt.path, adminToken(t), nil)
+
+            assert.Equal(t, tt.code, rec.Code)
+        })
+    }
+}
+
+func TestRetryJob(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    dead := api.deadJob(t)
+    pending, err := jobs.Enqueue(context.Background(), api.store, "email.send", nil, time.Time{})
+    require.NoError(t, err)
+
+    t.Run("Requeues dead jobs", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/admin/jobs/"+dead.ID.String()+"/retry", adminToken(t), nil)
+
+        require.Equal(t, http.StatusOK, rec.Code)
+        res := decode[jobResponse](t, rec)
+        assert.Equal(t, jobs.StatusPending, res.Status)
+        assert.Zero(t, res.Attempts)
+    })
+
+    t.Run("Rejects jobs that aren't dead", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/admin/jobs/"+pending.ID.String()+"/retry", adminToken(t), nil)
+
+        assert.Equal(t, http.StatusConflict, rec.Code)
+    })
+
+    t.Run("Unknown job", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/admin/jobs/"+uuid.NewString()+"/retry", adminToken(t), nil)
+
+        assert.Equal(t, http.StatusNotFound, rec.Code)
+    })
+}
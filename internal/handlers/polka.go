@@ -1,41 +1,42 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/bamcmanus/Chirpy/internal/audit"
 	"github.com/bamcmanus/Chirpy/internal/auth"
-	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
 	"github.com/google/uuid"
 )
 
 type PolkaHandler struct {
-    dbQueries *database.Queries
+    tx store.Transactor
+    audit audit.Log
     polkaKey string
 }
 
-func NewPolkaHandler(qs *database.Queries, pk string) PolkaHandler {
+func NewPolkaHandler(tx store.Transactor, log audit.Log, pk string) PolkaHandler {
     return PolkaHandler{
-        dbQueries: qs,
+        tx: tx,
+        audit: log,
         polkaKey: pk,
     }
 }
 
 const USER_UPGRADED = "user.upgraded"
 
-func (p PolkaHandler) UpgradeUser(w http.ResponseWriter, req *http.Request) {
+func (p PolkaHandler) UpgradeUser(w http.ResponseWriter, req *http.Request) error {
     apiKey, err := auth.GetAPIKey(req.Header)
     if err != nil {
-        log.Printf("missing authorization header")
-        _ = respondWithError(w, http.StatusUnauthorized, "missing authorization header")
-        return
+        return problem.Unauthorized("missing authorization header").Wrap(err)
     }
 
     if apiKey != p.polkaKey {
-        log.Printf("invalid ")
-        _ = respondWithError(w, http.StatusUnauthorized, "invalid API key")
-        return
+        return problem.Unauthorized("invalid API key")
     }
 
     type upgradeRequest struct {
@@ -46,30 +47,38 @@ func (p PolkaHandler) UpgradeUser(w http.ResponseWriter, req *http.Request) {
     }
 
     var ugRequest upgradeRequest
-    decoder := json.NewDecoder(req.Body)
-    if err := decoder.Decode(&ugRequest); err != nil {
-        log.Printf("failed to decode request body; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "unknown request structure")
-        return
+    if err := decodeLenientJSON(w, req, &ugRequest); err != nil {
+        return err
     }
 
     if ugRequest.Event != USER_UPGRADED {
         w.WriteHeader(http.StatusNoContent)
-        return
+        return nil
     }
 
     userId, err := uuid.Parse(ugRequest.Data.UserId)
     if err != nil {
-        log.Printf("unable to parse user ID; error: %s", err)
-        _ = respondWithError(w, http.StatusNotFound, "user ID not found")
+        return problem.NotFound("user_not_found", "user ID not found").Wrap(err)
     }
 
-    _, err = p.dbQueries.UpgradeUser(req.Context(), userId)
+    // Polka isn't a user, so upgrades have no actor.
+    err = p.tx.InTx(req.Context(), func(s store.Store) error {
+        if _, err := s.UpgradeUser(req.Context(), userId); err != nil {
+            return err
+        }
+        return p.audit.With(s).Record(req.Context(), audit.Event{
+            Action: audit.ActionUserUpgraded,
+            TargetID: userId,
+            Metadata: map[string]any{"source": "polka"},
+        })
+    })
+    if errors.Is(err, sql.ErrNoRows) {
+        return problem.NotFound("user_not_found", "user ID not found").Wrap(err)
+    }
     if err != nil {
-        log.Printf("failed to upgrade user; error: %s", err)
-        _ = respondWithError(w, http.StatusNotFound, "upgrade failed")
-        return
+        return problem.Internal("upgrade failed", fmt.Errorf("upgrading user %s: %w", userId, err))
     }
 
     w.WriteHeader(http.StatusNoContent)
+    return nil
 }
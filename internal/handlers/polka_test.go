@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeUser(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, _ := api.createUser(t, "user@example.com", "password")
+
+    upgrade := func(event, userId string) map[string]any {
+        return map[string]any{"event": event, "data": map[string]string{"user_id": userId}}
+    }
+
+    tests := []struct {
+        name string
+        authorization string
+        body any
+        code int
+    }{
+        {name: "Missing API key", body: upgrade(USER_UPGRADED, user.ID.String()), code: http.StatusUnauthorized},
+        {name: "Wrong API key", authorization: "ApiKey wrong", body: upgrade(USER_UPGRADED, user.ID.String()), code: http.StatusUnauthorized},
+        {name: "Other events are ignored", authorization: "ApiKey " + testPolkaKey, body: upgrade("user.downgraded", user.ID.String()), code: http.StatusNoContent},
+        {name: "Unknown user", authorization: "ApiKey " + testPolkaKey, body: upgrade(USER_UPGRADED, "00000000-0000-0000-0000-000000000001"), code: http.StatusNotFound},
+        {name: "Malformed user ID", authorization: "ApiKey " + testPolkaKey, body: upgrade(USER_UPGRADED, "nope"), code: http.StatusNotFound},
+        {name: "Happy path", authorization: "ApiKey " + testPolkaKey, body: upgrade(USER_UPGRADED, user.ID.String()), code: http.StatusNoContent},
+        {name: "Unknown fields are ignored", authorization: "ApiKey " + testPolkaKey, body: map[string]any{"event": USER_UPGRADED, "id": "evt_1", "data": map[string]string{"user_id": user.ID.String()}}, code: http.StatusNoContent},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPost, "/api/polka/webhooks", tt.authorization, tt.body)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    upgraded, err := api.store.GetUserByEmail(context.Background(), "user@example.com")
+    require.NoError(t, err)
+    assert.True(t, upgraded.IsChirpyRed)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/accounts"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/google/uuid"
+)
+
+// RolesHandler lets admins change users' roles. Every change is recorded with
+// who made it and why.
+type RolesHandler struct {
+    accounts accounts.Service
+}
+
+func NewRolesHandler(a accounts.Service) RolesHandler {
+    return RolesHandler{
+        accounts: a,
+    }
+}
+
+type roleChangeResponse struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UserId uuid.UUID `json:"user_id"`
+    OldRole string `json:"old_role"`
+    NewRole string `json:"new_role"`
+    // ChangedBy is null for changes made with chirpy admin.
+    ChangedBy *uuid.UUID `json:"changed_by"`
+    Reason string `json:"reason"`
+}
+
+func newRoleChangeResponse(change database.RoleChange) roleChangeResponse {
+    res := roleChangeResponse{
+        Id: change.ID,
+        CreatedAt: change.CreatedAt,
+        UserId: change.UserID,
+        OldRole: change.OldRole,
+        NewRole: change.NewRole,
+        Reason: change.Reason,
+    }
+    if change.ChangedBy.Valid {
+        res.ChangedBy = &change.ChangedBy.UUID
+    }
+    return res
+}
+
+func (h RolesHandler) SetRole(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
+    if err != nil {
+        return err
+    }
+
+    userId, err := pathID(req, "userID", "invalid_user_id")
+    if err != nil {
+        return err
+    }
+
+    var body struct {
+        Role string `json:"role" validate:"required"`
+        Reason string `json:"reason" validate:"required,max=500"`
+    }
+    if err := decodeJSON(w, req, &body); err != nil {
+        return err
+    }
+    role, err := auth.ParseRole(body.Role)
+    if err != nil {
+        return problem.Invalid(problem.FieldError{Field: "role", Code: "invalid_role", Message: "role must be user, moderator or admin"}).Wrap(err)
+    }
+
+    change, err := h.accounts.SetRole(req.Context(), userId, role, principal.UserID, body.Reason)
+    switch {
+    case errors.Is(err, accounts.ErrUserNotFound):
+        return problem.NotFound("user_not_found", "user not found").Wrap(err)
+    case errors.Is(err, accounts.ErrReasonRequired):
+        return problem.Invalid(problem.FieldError{Field: "reason", Code: "required", Message: "reason is required"}).Wrap(err)
+    case errors.Is(err, accounts.ErrOwnRole):
+        return problem.Forbidden("admins can't change their own role").Wrap(err)
+    case errors.Is(err, accounts.ErrRoleUnchanged):
+        return problem.Conflict("role_unchanged", "the user already has that role").Wrap(err)
+    case err != nil:
+        return problem.Internal("failed to change role", err)
+    }
+
+    return respondWithJSON(w, http.StatusOK, newRoleChangeResponse(change))
+}
+
+func (h RolesHandler) ListRoleChanges(w http.ResponseWriter, req *http.Request) error {
+    userId, err := pathID(req, "userID", "invalid_user_id")
+    if err != nil {
+        return err
+    }
+
+    changes, err := h.accounts.RoleChanges(req.Context(), userId)
+    if err != nil {
+        return problem.Internal("failed to list role changes", err)
+    }
+
+    res := make([]roleChangeResponse, len(changes))
+    for i, change := range changes {
+        res[i] = newRoleChangeResponse(change)
+    }
+    return respondWithJSON(w, http.StatusOK, res)
+}
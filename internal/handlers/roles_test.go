@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRole(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, _ := api.createUser(t, "walt@example.com", "password")
+    admin, _ := api.createUser(t, "gus@example.com", "password")
+    adminAuth := bearerWithRole(t, admin.ID, auth.RoleAdmin)
+    path := "/admin/users/" + user.ID.String() + "/role"
+
+    t.Run("Promotes users and records who did it", func(t *testing.T) {
+        rec := api.do(t, http.MethodPut, path, adminAuth, map[string]string{"role": "moderator", "reason": "joined the moderators"})
+
+        require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+        res := decode[roleChangeResponse](t, rec)
+        assert.Equal(t, user.ID, res.UserId)
+        assert.Equal(t, "user", res.OldRole)
+        assert.Equal(t, "moderator", res.NewRole)
+        if assert.NotNil(t, res.ChangedBy) {
+            assert.Equal(t, admin.ID, *res.ChangedBy)
+        }
+
+        got, err := api.store.GetUser(context.Background(), user.ID)
+        require.NoError(t, err)
+        assert.Equal(t, "moderator", got.Role)
+    })
+
+    t.Run("New tokens carry the role", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/login", "", map[string]string{"email": "walt@example.com", "password": "password"})
+
+        require.Equal(t, http.StatusOK, rec.Code)
+        res := decode[struct{
+            Role string `json:"role"`
+            Token string `json:"token"`
+            RefreshToken string `json:"refresh_token"`
+        }](t, rec)
+        assert.Equal(t, "moderator", res.Role)
+        principal, err := auth.ParseJWT(res.Token, testJWTSecret)
+        require.NoError(t, err)
+        assert.Equal(t, auth.RoleModerator, principal.Role)
+
+        // Refreshing picks up a later change.
+        _, err = api.store.SetUserRole(context.Background(), database.SetUserRoleParams{Role: "user", ID: user.ID})
+        require.NoError(t, err)
+        rec = api.do(t, http.MethodPost, "/api/refresh", "Bearer " + res.RefreshToken, nil)
+        require.Equal(t, http.StatusOK, rec.Code)
+        principal, err = auth.ParseJWT(decode[struct{ Token string }](t, rec).Token, testJWTSecret)
+        require.NoError(t, err)
+        assert.Equal(t, auth.RoleUser, principal.Role)
+    })
+
+    tests := []struct {
+        name string
+        path string
+        authorization string
+        body any
+        code int
+        problem string
+    }{
+        {name: "Unknown role", path: path, authorization: adminAuth, body: map[string]string{"role": "root", "reason": "x"}, code: http.StatusBadRequest, problem: "validation_failed"},
+        {name: "Missing reason", path: path, authorization: adminAuth, body: map[string]string{"role": "admin"}, code: http.StatusBadRequest, problem: "validation_failed"},
+        {name: "Blank reason", path: path, authorization: adminAuth, body: map[string]string{"role": "admin", "reason": "  "}, code: http.StatusBadRequest, problem: "validation_failed"},
+        {name: "Unchanged role", path: path, authorization: adminAuth, body: map[string]string{"role": "user", "reason": "x"}, code: http.StatusConflict, problem: "role_unchanged"},
+        {name: "Own role", path: "/admin/users/" + admin.ID.String() + "/role", authorization: adminAuth, body: map[string]string{"role": "user", "reason": "x"}, code: http.StatusForbidden, problem: "forbidden"},
+        {name: "Unknown user", path: "/admin/users/" + uuid.NewString() + "/role", authorization: adminAuth, body: map[string]string{"role": "admin", "reason": "x"}, code: http.StatusNotFound, problem: "user_not_found"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPut, tt.path, tt.authorization, tt.body)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+            assert.Equal(t, tt.problem, decode[struct{ Code string }](t, rec).Code)
+        })
+    }
+}
+
+func TestListRoleChanges(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, _ := api.createUser(t, "walt@example.com", "password")
+    path := "/admin/users/" + user.ID.String() + "/role"
+    for _, role := range []string{"moderator", "admin"} {
+        rec := api.do(t, http.MethodPut, path, adminToken(t), map[string]string{"role": role, "reason": "promoted"})
+        require.Equal(t, http.StatusOK, rec.Code)
+    }
+
+    rec := api.do(t, http.MethodGet, "/admin/users/" + user.ID.String() + "/role-changes", adminToken(t), nil)
+
+    require.Equal(t, http.StatusOK, rec.Code)
+    res := decode[[]roleChangeResponse](t, rec)
+    require.Len(t, res, 2)
+    assert.Equal(t, "admin", res[0].NewRole)
+    assert.Equal(t, "moderator", res[1].NewRole)
+
+    rec = api.do(t, http.MethodGet, "/admin/users/" + uuid.NewString() + "/role-changes", adminToken(t), nil)
+    require.Equal(t, http.StatusOK, rec.Code)
+    assert.JSONEq(t, "[]", rec.Body.String())
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/accounts"
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/health"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/openapi"
+	"github.com/bamcmanus/Chirpy/internal/ratelimit"
+	"github.com/bamcmanus/Chirpy/internal/recovery"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+)
+
+// Deps holds what the API's handlers are built from.
+type Deps struct {
+    Store store.Store
+    Tx store.Transactor
+    Metrics *metrics.Metrics
+    // Checker serves the probes.
+    Checker *health.Checker
+    Platform string
+    JWTSecret string
+    PolkaKey string
+    JWTLifetime time.Duration
+    RefreshTokenLifetime time.Duration
+}
+
+// Routes registers every route of the API on mux, so the server and the tests
+// serve the same ones.
+func Routes(mux *http.ServeMux, d Deps) {
+    s := d.Store
+    dispatcher := webhooks.NewDispatcher(s)
+    auditLog := audit.NewLog(s)
+    authn := NewAuthenticator(d.JWTSecret)
+
+    polkaHandler := NewPolkaHandler(d.Tx, auditLog, d.PolkaKey)
+    mux.Handle("POST /api/polka/webhooks", HandlerFunc(polkaHandler.UpgradeUser))
+
+    authHandler := NewAuthHandler(s, s, d.Tx, auditLog, d.JWTSecret, d.JWTLifetime, d.RefreshTokenLifetime, d.Metrics)
+    mux.Handle("POST /api/login", HandlerFunc(authHandler.Login))
+    mux.Handle("POST /api/refresh", HandlerFunc(authHandler.Refresh))
+    mux.Handle("POST /api/revoke", HandlerFunc(authHandler.Revoke))
+
+    userHandler := NewUserHandler(s, d.Tx, dispatcher, auditLog)
+    mux.Handle("POST /api/users", HandlerFunc(userHandler.CreateUser))
+    mux.Handle("PUT /api/users", authn.Required(userHandler.UpdateUser))
+
+    chirpsHandler := NewChirpsHandler(s, d.Tx, dispatcher, auditLog, d.Metrics)
+    mux.Handle("POST /api/chirps", authn.Required(chirpsHandler.PostChirp))
+    mux.Handle("GET /api/chirps", HandlerFunc(chirpsHandler.GetChirps))
+    mux.Handle("GET /api/chirps/{chirpID}", HandlerFunc(chirpsHandler.GetChirp))
+    mux.Handle("DELETE /api/chirps/{chirpID}", authn.Required(chirpsHandler.DeleteChirp))
+
+    webhooksHandler := NewWebhooksHandler(s)
+    mux.Handle("POST /api/webhooks", authn.Required(webhooksHandler.CreateWebhook))
+    mux.Handle("GET /api/webhooks", authn.Required(webhooksHandler.ListWebhooks))
+    mux.Handle("DELETE /api/webhooks/{webhookID}", authn.Required(webhooksHandler.DeleteWebhook))
+    mux.Handle("GET /api/webhooks/{webhookID}/deliveries", authn.Required(webhooksHandler.ListDeliveries))
+
+    adminHandler := NewAdminHandler(d.Tx, auditLog, d.Platform, d.Metrics)
+    mux.Handle("GET /admin/metrics", authn.Permit(auth.PermViewMetrics, adminHandler.GetMetrics))
+    mux.Handle("POST /admin/reset", authn.Permit(auth.PermResetData, adminHandler.Reset))
+    mux.Handle("POST /admin/seed", authn.Permit(auth.PermResetData, adminHandler.Seed))
+
+    jobsHandler := NewJobsHandler(s)
+    mux.Handle("GET /admin/jobs", authn.Permit(auth.PermManageJobs, jobsHandler.ListJobs))
+    mux.Handle("GET /admin/jobs/{jobID}", authn.Permit(auth.PermManageJobs, jobsHandler.GetJob))
+    mux.Handle("POST /admin/jobs/{jobID}/retry", authn.Permit(auth.PermManageJobs, jobsHandler.RetryJob))
+
+    rolesHandler := NewRolesHandler(accounts.New(s, d.Tx, dispatcher, auditLog))
+    mux.Handle("PUT /admin/users/{userID}/role", authn.Permit(auth.PermManageRoles, rolesHandler.SetRole))
+    mux.Handle("GET /admin/users/{userID}/role-changes", authn.Permit(auth.PermManageRoles, rolesHandler.ListRoleChanges))
+
+    auditHandler := NewAuditHandler(s)
+    mux.Handle("GET /admin/audit-events", authn.Permit(auth.PermReadAudit, auditHandler.ListEvents))
+    mux.Handle("GET /admin/audit-events/export", authn.Permit(auth.PermReadAudit, auditHandler.ExportEvents))
+
+    mux.HandleFunc("GET /api/healthz", Health)
+    mux.Handle("GET /api/openapi.json", openapi.Handler())
+    mux.HandleFunc("GET /livez", d.Checker.Livez)
+    mux.HandleFunc("GET /readyz", d.Checker.Readyz)
+}
+
+// Middleware wraps the routes on mux in the middleware every server applies,
+// outermost first: tracing, request logging, noting the client for the audit
+// log, rate limiting when limiter isn't nil, metrics, naming spans after their
+// route, and panic recovery. The metrics and span naming read the pattern the
+// mux records on the request, so nothing inside them may copy it. clientIP
+// finds the client's address, honouring trusted proxies.
+func Middleware(mux *http.ServeMux, logger *slog.Logger, m *metrics.Metrics, limiter *ratelimit.Limiter, clientIP func(*http.Request) string) http.Handler {
+    routes := m.Middleware(tracing.NameRoutes(recovery.Middleware(mux)))
+    if limiter != nil {
+        routes = limiter.Middleware(mux)(routes)
+    }
+    return tracing.Middleware(logging.Middleware(logger)(audit.Middleware(clientIP)(routes)))
+}
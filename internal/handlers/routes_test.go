@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddlewareSeesTheRoute(t *testing.T) {
+    _, err := tracing.Setup(context.Background(), config.TracingConfig{Exporter: "none"})
+    require.NoError(t, err)
+    recorder := tracetest.NewSpanRecorder()
+    previous := otel.GetTracerProvider()
+    otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+    t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+    api := newTestAPI(t, "dev")
+    rec := api.do(t, http.MethodGet, "/api/chirps/"+uuid.NewString(), "", nil)
+    require.Equal(t, http.StatusNotFound, rec.Code)
+
+    rec = httptest.NewRecorder()
+    api.metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+    assert.Contains(t, rec.Body.String(), `chirpy_http_requests_total{code="404",method="GET",route="GET /api/chirps/{chirpID}"} 1`)
+
+    spans := recorder.Ended()
+    require.Len(t, spans, 1)
+    assert.Equal(t, "GET /api/chirps/{chirpID}", spans[0].Name())
+    assert.Contains(t, spans[0].Attributes(), attribute.String("http.route", "/api/chirps/{chirpID}"))
+}
@@ -1,25 +1,32 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/bamcmanus/Chirpy/internal/audit"
 	"github.com/bamcmanus/Chirpy/internal/auth"
 	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
 	"github.com/google/uuid"
 )
 
 type UserHandler struct {
-    dbQueries *database.Queries
-    jwtSecret string
+    users store.UserStore
+    tx store.Transactor
+    events webhooks.Dispatcher
+    audit audit.Log
 }
 
-func NewUserHandler(qs *database.Queries, secret string) UserHandler {
+func NewUserHandler(users store.UserStore, tx store.Transactor, events webhooks.Dispatcher, log audit.Log) UserHandler {
     return UserHandler{
-        dbQueries: qs,
-        jwtSecret: secret,
+        users: users,
+        tx: tx,
+        events: events,
+        audit: log,
     }
 }
 
@@ -29,40 +36,32 @@ type userResponse struct {
     UpdatedAt time.Time `json:"updated_at"`
     Email string `json:"email"`
     IsChirpyRed bool `json:"is_chirpy_red"`
+    Role string `json:"role"`
 }
 
 type userRequest struct {
-    Email string `json:"email"`
-    Password string `json:"password"`
+    Email string `json:"email" validate:"required,email,max=254"`
+    Password string `json:"password" validate:"required,password"`
 }
 
-func (u UserHandler) CreateUser(w http.ResponseWriter, req *http.Request) {
-
+func (u UserHandler) CreateUser(w http.ResponseWriter, req *http.Request) error {
     var newUserReq userRequest
-    decoder := json.NewDecoder(req.Body)
-    if err := decoder.Decode(&newUserReq); err != nil {
-        log.Printf("failed to decode request body; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "could not decode new user request")
-        return
-    }
-
-    if newUserReq.Password == "" {
-        _ = respondWithError(w, http.StatusBadRequest, "password required")
-        return
+    if err := decodeJSON(w, req, &newUserReq); err != nil {
+        return err
     }
 
     hashedPassword, err := auth.HashPassword(newUserReq.Password)
     if err != nil {
-        log.Printf("failed to hash password; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed hashing password")
-        return
+        return problem.Internal("failed to create user", fmt.Errorf("hashing password: %w", err))
     }
 
     params := database.CreateUserParams{Email: newUserReq.Email, HashedPassword: hashedPassword}
-    user, err := u.dbQueries.CreateUser(req.Context(), params)
+    user, err := u.users.CreateUser(req.Context(), params)
+    if store.IsUniqueViolation(err) {
+        return problem.Conflict("email_taken", "a user with that email already exists").Wrap(err)
+    }
     if err != nil {
-        _ = respondWithError(w, http.StatusInternalServerError, "failed to create user")
-        return
+        return problem.Internal("failed to create user", err)
     }
 
     res := userResponse {
@@ -71,44 +70,27 @@ func (u UserHandler) CreateUser(w http.ResponseWriter, req *http.Request) {
         UpdatedAt: user.UpdatedAt,
         Id: user.ID,
         IsChirpyRed: user.IsChirpyRed,
+        Role: user.Role,
     }
 
-    _ = respondWithJSON(w, http.StatusCreated, res)
+    return respondWithJSON(w, http.StatusCreated, res)
 }
 
-func (u UserHandler) UpdateUser(w http.ResponseWriter, req *http.Request) {
-    token, err := auth.GetBearerToken(req.Header)
+func (u UserHandler) UpdateUser(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
     if err != nil {
-        log.Printf("failed to fetch Bearer token; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
-    }
-
-    userId, err := auth.ValidateJWT(token, u.jwtSecret)
-    if err != nil  {
-        log.Printf("JWT validation failed; error: %s", err)
-        _ = respondWithError(w, http.StatusUnauthorized, "unauthorized")
-        return
+        return err
     }
+    userId := principal.UserID
 
     var updateRequest userRequest
-    decoder := json.NewDecoder(req.Body)
-    if err := decoder.Decode(&updateRequest); err != nil {
-        log.Printf("failed to decode request body; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "could not decode new user request")
-        return
-    }
-
-    if updateRequest.Password == "" {
-        _ = respondWithError(w, http.StatusBadRequest, "password required")
-        return
+    if err := decodeJSON(w, req, &updateRequest); err != nil {
+        return err
     }
 
     hashedPassword, err := auth.HashPassword(updateRequest.Password)
     if err != nil {
-        log.Printf("failed to hash password; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "failed hashing password")
-        return
+        return problem.Internal("failed to update user", fmt.Errorf("hashing password: %w", err))
     }
 
     updateParams := database.UpdateUserParams {
@@ -116,20 +98,63 @@ func (u UserHandler) UpdateUser(w http.ResponseWriter, req *http.Request) {
         HashedPassword: hashedPassword,
         ID: userId,
     }
-    user, err := u.dbQueries.UpdateUser(req.Context(), updateParams)
-    if err != nil {
-        log.Printf("failed to update user; error: %s", err)
-        _ = respondWithError(w, http.StatusInternalServerError, "error updating user")
-        return
+    // Every update sends the password, but sessions are only revoked when it
+    // is a new one, so changing just the email keeps the caller logged in.
+    var res userResponse
+    err = u.tx.InTx(req.Context(), func(s store.Store) error {
+        old, err := s.GetUser(req.Context(), userId)
+        if err != nil {
+            return fmt.Errorf("getting user: %w", err)
+        }
+        passwordChanged := auth.CheckPasswordHash(old.HashedPassword, updateRequest.Password) != nil
+        if !passwordChanged {
+            updateParams.HashedPassword = old.HashedPassword
+        }
+
+        user, err := s.UpdateUser(req.Context(), updateParams)
+        if err != nil {
+            return fmt.Errorf("updating user: %w", err)
+        }
+
+        log := u.audit.With(s)
+        if passwordChanged {
+            err := log.Record(req.Context(), audit.Event{Action: audit.ActionPasswordChanged, ActorID: user.ID, TargetID: user.ID})
+            if err != nil {
+                return err
+            }
+            if err := s.RevokeUserRefreshTokens(req.Context(), user.ID); err != nil {
+                return fmt.Errorf("revoking sessions: %w", err)
+            }
+        }
+        if user.Email != old.Email {
+            err := log.Record(req.Context(), audit.Event{
+                Action: audit.ActionEmailChanged,
+                ActorID: user.ID,
+                TargetID: user.ID,
+                Metadata: map[string]any{"old_email": old.Email, "new_email": user.Email},
+            })
+            if err != nil {
+                return err
+            }
+        }
+
+        res = userResponse{
+            Id: user.ID,
+            CreatedAt: user.CreatedAt,
+            UpdatedAt: user.UpdatedAt,
+            Email: user.Email,
+            IsChirpyRed: user.IsChirpyRed,
+            Role: user.Role,
+        }
+        event := webhooks.Event{Type: webhooks.EventUserUpdated, UserID: user.ID, Data: res}
+        return u.events.With(s).Emit(req.Context(), event)
+    })
+    if store.IsUniqueViolation(err) {
+        return problem.Conflict("email_taken", "a user with that email already exists").Wrap(err)
     }
-
-    res := userResponse{
-        Id: user.ID,
-        CreatedAt: user.CreatedAt,
-        UpdatedAt: user.UpdatedAt,
-        Email: user.Email,
-        IsChirpyRed: user.IsChirpyRed,
+    if err != nil {
+        return problem.Internal("failed to update user", err)
     }
 
-    _ = respondWithJSON(w, http.StatusOK, res)
+    return respondWithJSON(w, http.StatusOK, res)
 }
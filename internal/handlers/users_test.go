@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUser(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    api.createUser(t, "taken@example.com", "password")
+
+    tests := []struct {
+        name string
+        body any
+        code int
+    }{
+        {name: "Happy path", body: userRequest{Email: "new@example.com", Password: "password"}, code: http.StatusCreated},
+        {name: "Missing password", body: userRequest{Email: "nopass@example.com"}, code: http.StatusBadRequest},
+        {name: "Short password", body: userRequest{Email: "short@example.com", Password: "hunter2"}, code: http.StatusBadRequest},
+        {name: "Missing email", body: userRequest{Password: "password"}, code: http.StatusBadRequest},
+        {name: "Invalid email", body: userRequest{Email: "not-an-email", Password: "password"}, code: http.StatusBadRequest},
+        {name: "Unknown field", body: map[string]any{"email": "red@example.com", "password": "password", "is_chirpy_red": true}, code: http.StatusBadRequest},
+        {name: "Duplicate email", body: userRequest{Email: "taken@example.com", Password: "password"}, code: http.StatusConflict},
+        {name: "Malformed body", body: "{", code: http.StatusBadRequest},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPost, "/api/users", "", tt.body)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    t.Run("Response hides the password", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/users", "", userRequest{Email: "shape@example.com", Password: "password"})
+
+        require.Equal(t, http.StatusCreated, rec.Code)
+        res := decode[map[string]any](t, rec)
+        assert.Equal(t, "shape@example.com", res["email"])
+        assert.Equal(t, false, res["is_chirpy_red"])
+        assert.NotContains(t, res, "password")
+        assert.NotContains(t, res, "hashed_password")
+    })
+}
+
+func TestUpdateUser(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, token := api.createUser(t, "old@example.com", "password")
+    api.createUser(t, "other@example.com", "password")
+
+    tests := []struct {
+        name string
+        authorization string
+        body any
+        code int
+    }{
+        {name: "Missing token", body: userRequest{Email: "x@example.com", Password: "password"}, code: http.StatusUnauthorized},
+        {name: "Invalid token", authorization: "Bearer nope", body: userRequest{Email: "x@example.com", Password: "password"}, code: http.StatusUnauthorized},
+        {name: "Missing password", authorization: token, body: userRequest{Email: "x@example.com"}, code: http.StatusBadRequest},
+        {name: "Email taken", authorization: token, body: userRequest{Email: "other@example.com", Password: "password"}, code: http.StatusConflict},
+        {name: "Happy path", authorization: token, body: userRequest{Email: "new@example.com", Password: "new-password"}, code: http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPut, "/api/users", tt.authorization, tt.body)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    updated, err := api.store.GetUserByEmail(context.Background(), "new@example.com")
+    require.NoError(t, err)
+    assert.Equal(t, user.ID, updated.ID)
+    assert.NoError(t, auth.CheckPasswordHash(updated.HashedPassword, "new-password"))
+}
+
+func TestUpdateUserRevokesSessions(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    user, token := api.createUser(t, "user@example.com", "password")
+    _, err := api.store.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+        Token: "session",
+        UserID: user.ID,
+        ExpiresAt: time.Now().Add(time.Hour),
+    })
+    require.NoError(t, err)
+
+    rec := api.do(t, http.MethodPut, "/api/users", token, userRequest{Email: "new@example.com", Password: "password"})
+    require.Equal(t, http.StatusOK, rec.Code)
+    rec = api.do(t, http.MethodPost, "/api/refresh", "Bearer session", nil)
+    assert.Equal(t, http.StatusOK, rec.Code, "changing only the email keeps sessions")
+
+    rec = api.do(t, http.MethodPut, "/api/users", token, userRequest{Email: "new@example.com", Password: "new-password"})
+    require.Equal(t, http.StatusOK, rec.Code)
+    rec = api.do(t, http.MethodPost, "/api/refresh", "Bearer session", nil)
+    assert.Equal(t, http.StatusUnauthorized, rec.Code, "a new password revokes them")
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+    minWebhookSecretLength = 16
+    deliveryListLimit = 50
+)
+
+type WebhooksHandler struct {
+    webhooks store.WebhookStore
+}
+
+func NewWebhooksHandler(hooks store.WebhookStore) WebhooksHandler {
+    return WebhooksHandler{
+        webhooks: hooks,
+    }
+}
+
+type webhookResponse struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Url string `json:"url"`
+    Events []string `json:"events"`
+    Secret string `json:"secret,omitempty"`
+    DisabledAt *time.Time `json:"disabled_at"`
+}
+
+type webhookDeliveryResponse struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    Event string `json:"event"`
+    Status string `json:"status"`
+    Attempts []webhookAttemptResponse `json:"attempts"`
+    DeliveredAt *time.Time `json:"delivered_at"`
+}
+
+type webhookAttemptResponse struct {
+    CreatedAt time.Time `json:"created_at"`
+    ResponseCode *int32 `json:"response_code"`
+    Error string `json:"error,omitempty"`
+    DurationMs int32 `json:"duration_ms"`
+}
+
+func newWebhookResponse(hook database.Webhook) webhookResponse {
+    res := webhookResponse{
+        Id: hook.ID,
+        CreatedAt: hook.CreatedAt,
+        UpdatedAt: hook.UpdatedAt,
+        Url: hook.Url,
+        Events: hook.Events,
+    }
+    if hook.DisabledAt.Valid {
+        res.DisabledAt = &hook.DisabledAt.Time
+    }
+    return res
+}
+
+func (h WebhooksHandler) CreateWebhook(w http.ResponseWriter, req *http.Request) error {
+    type createWebhookRequest struct {
+        Url string `json:"url"`
+        Events []string `json:"events"`
+        Secret string `json:"secret"`
+    }
+
+    principal, err := requirePrincipal(req)
+    if err != nil {
+        return err
+    }
+    userId := principal.UserID
+
+    var params createWebhookRequest
+    if err := decodeJSON(w, req, &params); err != nil {
+        return err
+    }
+
+    var invalid []problem.FieldError
+    callback, err := url.Parse(params.Url)
+    if err != nil || callback.Scheme != "https" || callback.Host == "" {
+        invalid = append(invalid, problem.FieldError{Field: "url", Code: "invalid_url", Message: "url must be an absolute https URL"})
+    }
+
+    if len(params.Events) == 0 {
+        invalid = append(invalid, problem.FieldError{Field: "events", Code: "required", Message: "at least one event is required"})
+    }
+    for _, event := range params.Events {
+        if !webhooks.IsValidEvent(event) {
+            invalid = append(invalid, problem.FieldError{Field: "events", Code: "unknown_event", Message: "unknown event: " + event})
+        }
+    }
+
+    if params.Secret != "" && len(params.Secret) < minWebhookSecretLength {
+        invalid = append(invalid, problem.FieldError{Field: "secret", Code: "too_short", Message: "secret must be at least 16 characters"})
+    }
+    if len(invalid) > 0 {
+        return problem.Invalid(invalid...)
+    }
+
+    secret := params.Secret
+    if secret == "" {
+        secret, err = auth.MakeRefreshToken()
+        if err != nil {
+            return problem.Internal("failed to create webhook", fmt.Errorf("creating webhook secret: %w", err))
+        }
+    }
+
+    hook, err := h.webhooks.CreateWebhook(req.Context(), database.CreateWebhookParams{
+        UserID: userId,
+        Url: callback.String(),
+        Secret: secret,
+        Events: params.Events,
+    })
+    if err != nil {
+        return problem.Internal("failed to create webhook", err)
+    }
+
+    // The secret is only ever returned once, when the webhook is created.
+    res := newWebhookResponse(hook)
+    res.Secret = hook.Secret
+    return respondWithJSON(w, http.StatusCreated, res)
+}
+
+func (h WebhooksHandler) ListWebhooks(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
+    if err != nil {
+        return err
+    }
+    userId := principal.UserID
+
+    hooks, err := h.webhooks.ListWebhooksByUser(req.Context(), userId)
+    if err != nil {
+        return problem.Internal("failed to list webhooks", err)
+    }
+
+    res := make([]webhookResponse, 0, len(hooks))
+    for _, hook := range hooks {
+        res = append(res, newWebhookResponse(hook))
+    }
+    return respondWithJSON(w, http.StatusOK, res)
+}
+
+func (h WebhooksHandler) DeleteWebhook(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
+    if err != nil {
+        return err
+    }
+    userId := principal.UserID
+
+    webhookId, err := pathID(req, "webhookID", "invalid_webhook_id")
+    if err != nil {
+        return err
+    }
+
+    deleted, err := h.webhooks.DeleteWebhook(req.Context(), database.DeleteWebhookParams{
+        ID: webhookId,
+        UserID: userId,
+    })
+    if err != nil {
+        return problem.Internal("failed to delete webhook", fmt.Errorf("deleting webhook %s: %w", webhookId, err))
+    }
+    if deleted == 0 {
+        return problem.NotFound("webhook_not_found", "webhook not found")
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+    return nil
+}
+
+func (h WebhooksHandler) ListDeliveries(w http.ResponseWriter, req *http.Request) error {
+    principal, err := requirePrincipal(req)
+    if err != nil {
+        return err
+    }
+    userId := principal.UserID
+
+    webhookId, err := pathID(req, "webhookID", "invalid_webhook_id")
+    if err != nil {
+        return err
+    }
+
+    hook, err := h.webhooks.GetWebhook(req.Context(), webhookId)
+    if err != nil || hook.UserID != userId {
+        return problem.NotFound("webhook_not_found", "webhook not found").Wrap(err)
+    }
+
+    deliveries, err := h.webhooks.ListWebhookDeliveries(req.Context(), database.ListWebhookDeliveriesParams{
+        WebhookID: hook.ID,
+        Limit: deliveryListLimit,
+    })
+    if err != nil {
+        return problem.Internal("failed to list deliveries", fmt.Errorf("listing deliveries of webhook %s: %w", hook.ID, err))
+    }
+
+    res := make([]webhookDeliveryResponse, 0, len(deliveries))
+    for _, delivery := range deliveries {
+        attempts, err := h.webhooks.ListWebhookDeliveryAttempts(req.Context(), delivery.ID)
+        if err != nil {
+            return problem.Internal("failed to list deliveries", fmt.Errorf("listing attempts of delivery %s: %w", delivery.ID, err))
+        }
+
+        d := webhookDeliveryResponse{
+            Id: delivery.ID,
+            CreatedAt: delivery.CreatedAt,
+            Event: delivery.Event,
+            Status: delivery.Status,
+            Attempts: make([]webhookAttemptResponse, 0, len(attempts)),
+        }
+        if delivery.DeliveredAt.Valid {
+            d.DeliveredAt = &delivery.DeliveredAt.Time
+        }
+        for _, attempt := range attempts {
+            a := webhookAttemptResponse{
+                CreatedAt: attempt.CreatedAt,
+                Error: attempt.Error.String,
+                DurationMs: attempt.DurationMs,
+            }
+            if attempt.ResponseCode.Valid {
+                a.ResponseCode = &attempt.ResponseCode.Int32
+            }
+            d.Attempts = append(d.Attempts, a)
+        }
+        res = append(res, d)
+    }
+    return respondWithJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+    Url string `json:"url"`
+    Events []string `json:"events"`
+    Secret string `json:"secret,omitempty"`
+}
+
+func TestCreateWebhook(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    _, token := api.createUser(t, "user@example.com", "password")
+
+    tests := []struct {
+        name string
+        authorization string
+        body any
+        code int
+    }{
+        {name: "Missing token", body: webhookRequest{Url: "https://example.com", Events: []string{"chirp.created"}}, code: http.StatusUnauthorized},
+        {name: "Malformed body", authorization: token, body: "{", code: http.StatusBadRequest},
+        {name: "Plain HTTP", authorization: token, body: webhookRequest{Url: "http://example.com", Events: []string{"chirp.created"}}, code: http.StatusBadRequest},
+        {name: "Relative URL", authorization: token, body: webhookRequest{Url: "/hook", Events: []string{"chirp.created"}}, code: http.StatusBadRequest},
+        {name: "No events", authorization: token, body: webhookRequest{Url: "https://example.com"}, code: http.StatusBadRequest},
+        {name: "Unknown event", authorization: token, body: webhookRequest{Url: "https://example.com", Events: []string{"chirp.liked"}}, code: http.StatusBadRequest},
+        {name: "Short secret", authorization: token, body: webhookRequest{Url: "https://example.com", Events: []string{"chirp.created"}, Secret: "short"}, code: http.StatusBadRequest},
+        {name: "Happy path", authorization: token, body: webhookRequest{Url: "https://example.com", Events: []string{"chirp.created"}}, code: http.StatusCreated},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodPost, "/api/webhooks", tt.authorization, tt.body)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    t.Run("Secret is only returned on create", func(t *testing.T) {
+        rec := api.do(t, http.MethodPost, "/api/webhooks", token, webhookRequest{
+            Url: "https://example.com/hook",
+            Events: []string{"user.updated"},
+            Secret: "0123456789abcdef",
+        })
+        require.Equal(t, http.StatusCreated, rec.Code)
+        assert.Equal(t, "0123456789abcdef", decode[webhookResponse](t, rec).Secret)
+
+        rec = api.do(t, http.MethodGet, "/api/webhooks", token, nil)
+        require.Equal(t, http.StatusOK, rec.Code)
+        hooks := decode[[]webhookResponse](t, rec)
+        require.Len(t, hooks, 2)
+        for _, hook := range hooks {
+            assert.Empty(t, hook.Secret)
+        }
+    })
+}
+
+func TestListAndDeleteWebhooks(t *testing.T) {
+    api := newTestAPI(t, "dev")
+    owner, ownerToken := api.createUser(t, "owner@example.com", "password")
+    _, otherToken := api.createUser(t, "other@example.com", "password")
+    hook, err := api.store.CreateWebhook(context.Background(), database.CreateWebhookParams{
+        UserID: owner.ID,
+        Url: "https://example.com/hook",
+        Secret: "0123456789abcdef",
+        Events: []string{"chirp.created"},
+    })
+    require.NoError(t, err)
+    _, err = api.store.CreateWebhookDelivery(context.Background(), database.CreateWebhookDeliveryParams{
+        WebhookID: hook.ID,
+        Event: "chirp.created",
+        Payload: []byte(`{}`),
+    })
+    require.NoError(t, err)
+
+    t.Run("Lists only the caller's webhooks", func(t *testing.T) {
+        rec := api.do(t, http.MethodGet, "/api/webhooks", otherToken, nil)
+
+        require.Equal(t, http.StatusOK, rec.Code)
+        assert.Empty(t, decode[[]webhookResponse](t, rec))
+    })
+
+    deliveriesPath := "/api/webhooks/" + hook.ID.String() + "/deliveries"
+    deliveryTests := []struct {
+        name string
+        authorization string
+        path string
+        code int
+    }{
+        {name: "Deliveries without a token", path: deliveriesPath, code: http.StatusUnauthorized},
+        {name: "Deliveries with an invalid ID", authorization: ownerToken, path: "/api/webhooks/nope/deliveries", code: http.StatusBadRequest},
+        {name: "Deliveries of another user's webhook", authorization: otherToken, path: deliveriesPath, code: http.StatusNotFound},
+        {name: "Deliveries happy path", authorization: ownerToken, path: deliveriesPath, code: http.StatusOK},
+    }
+    for _, tt := range deliveryTests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodGet, tt.path, tt.authorization, nil)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+
+    path := "/api/webhooks/" + hook.ID.String()
+    deleteTests := []struct {
+        name string
+        authorization string
+        path string
+        code int
+    }{
+        {name: "Delete without a token", path: path, code: http.StatusUnauthorized},
+        {name: "Delete with an invalid ID", authorization: ownerToken, path: "/api/webhooks/nope", code: http.StatusBadRequest},
+        {name: "Delete another user's webhook", authorization: otherToken, path: path, code: http.StatusNotFound},
+        {name: "Delete an unknown webhook", authorization: ownerToken, path: "/api/webhooks/" + uuid.NewString(), code: http.StatusNotFound},
+        {name: "Delete happy path", authorization: ownerToken, path: path, code: http.StatusNoContent},
+    }
+    for _, tt := range deleteTests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := api.do(t, http.MethodDelete, tt.path, tt.authorization, nil)
+
+            assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+        })
+    }
+}
@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+    statusOK = "ok"
+    statusFail = "fail"
+)
+
+// Check reports whether a dependency is usable. It should return promptly
+// once ctx is done.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+    name string
+    check Check
+}
+
+// Checker serves the liveness and readiness probes.
+type Checker struct {
+    timeout time.Duration
+    checks []namedCheck
+    shuttingDown atomic.Bool
+}
+
+type checkResult struct {
+    Status string `json:"status"`
+    Error string `json:"error,omitempty"`
+    DurationMs int64 `json:"duration_ms"`
+}
+
+type report struct {
+    Status string `json:"status"`
+    Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// New returns a Checker that gives each readiness check at most timeout to
+// complete.
+func New(timeout time.Duration) *Checker {
+    return &Checker{timeout: timeout}
+}
+
+// Add registers a readiness check. It must be called before the probes are
+// served.
+func (c *Checker) Add(name string, check Check) {
+    c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown makes readiness fail from now on, so load balancers stop
+// routing new requests here while in-flight ones drain.
+func (c *Checker) SetShuttingDown() {
+    c.shuttingDown.Store(true)
+}
+
+// Livez reports that the process is up and serving HTTP. It deliberately does
+// not check dependencies: restarting us will not fix a database outage.
+func (c *Checker) Livez(w http.ResponseWriter, req *http.Request) {
+    writeReport(w, http.StatusOK, report{Status: statusOK})
+}
+
+// Readyz runs every readiness check concurrently and reports each result. It
+// fails if any check fails or the server is shutting down.
+func (c *Checker) Readyz(w http.ResponseWriter, req *http.Request) {
+    ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+    defer cancel()
+
+    res := report{
+        Status: statusOK,
+        Checks: make(map[string]checkResult, len(c.checks) + 1),
+    }
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    for _, nc := range c.checks {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            start := time.Now()
+            err := nc.check(ctx)
+
+            result := checkResult{Status: statusOK, DurationMs: time.Since(start).Milliseconds()}
+            if err != nil {
+                result.Status = statusFail
+                result.Error = err.Error()
+            }
+
+            mu.Lock()
+            defer mu.Unlock()
+            res.Checks[nc.name] = result
+            if err != nil {
+                res.Status = statusFail
+            }
+        }()
+    }
+    wg.Wait()
+
+    if c.shuttingDown.Load() {
+        res.Status = statusFail
+        res.Checks["shutdown"] = checkResult{Status: statusFail, Error: "server is shutting down"}
+    }
+
+    code := http.StatusOK
+    if res.Status != statusOK {
+        code = http.StatusServiceUnavailable
+    }
+    writeReport(w, code, res)
+}
+
+func writeReport(w http.ResponseWriter, code int, res report) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(code)
+    _ = json.NewEncoder(w).Encode(res)
+}
+
+// Database checks that the database accepts connections.
+func Database(db *sql.DB) Check {
+    return func(ctx context.Context) error {
+        return db.PingContext(ctx)
+    }
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, report) {
+    rec := httptest.NewRecorder()
+    handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+    var res report
+    require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+    return rec.Code, res
+}
+
+func TestReadyz(t *testing.T) {
+    ok := func(context.Context) error { return nil }
+
+    t.Run("All checks pass", func(t *testing.T) {
+        c := New(time.Second)
+        c.Add("database", ok)
+        c.Add("migrations", ok)
+
+        code, res := probe(t, c.Readyz)
+
+        assert.Equal(t, http.StatusOK, code)
+        assert.Equal(t, "ok", res.Status)
+        assert.Equal(t, "ok", res.Checks["database"].Status)
+        assert.Equal(t, "ok", res.Checks["migrations"].Status)
+    })
+
+    t.Run("A failing check fails readiness", func(t *testing.T) {
+        c := New(time.Second)
+        c.Add("database", func(context.Context) error { return errors.New("connection refused") })
+        c.Add("migrations", ok)
+
+        code, res := probe(t, c.Readyz)
+
+        assert.Equal(t, http.StatusServiceUnavailable, code)
+        assert.Equal(t, "fail", res.Status)
+        assert.Equal(t, "connection refused", res.Checks["database"].Error)
+        assert.Equal(t, "ok", res.Checks["migrations"].Status)
+    })
+
+    t.Run("Checks are bounded by the timeout", func(t *testing.T) {
+        c := New(10 * time.Millisecond)
+        c.Add("database", func(ctx context.Context) error {
+            <-ctx.Done()
+            return ctx.Err()
+        })
+
+        code, res := probe(t, c.Readyz)
+
+        assert.Equal(t, http.StatusServiceUnavailable, code)
+        assert.Equal(t, "context deadline exceeded", res.Checks["database"].Error)
+    })
+
+    t.Run("Shutting down fails readiness but not liveness", func(t *testing.T) {
+        c := New(time.Second)
+        c.Add("database", ok)
+        c.SetShuttingDown()
+
+        code, res := probe(t, c.Readyz)
+        assert.Equal(t, http.StatusServiceUnavailable, code)
+        assert.Equal(t, "server is shutting down", res.Checks["shutdown"].Error)
+
+        code, res = probe(t, c.Livez)
+        assert.Equal(t, http.StatusOK, code)
+        assert.Equal(t, "ok", res.Status)
+    })
+}
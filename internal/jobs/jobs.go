@@ -0,0 +1,115 @@
+// Package jobs runs deferred work from a durable queue in the jobs table.
+// Jobs are enqueued with a kind, a JSON payload and a time to run at; workers
+// claim due jobs, run the Handler registered for their kind, retry failures
+// with exponential backoff and move jobs that keep failing to a dead-letter
+// queue, from which an admin can requeue them.
+//
+// Delivery is at least once: a job whose worker dies mid-run is picked up
+// again when its lease ends, so handlers must be idempotent.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+)
+
+const (
+    StatusPending = "pending"
+    StatusRunning = "running"
+    StatusSucceeded = "succeeded"
+    StatusDead = "dead"
+)
+
+// Statuses lists every status a job can have.
+var Statuses = []string{StatusPending, StatusRunning, StatusSucceeded, StatusDead}
+
+const (
+    backoffBase = 10 * time.Second
+    backoffMax = time.Hour
+)
+
+// Handler runs one job. A returned error retries the job later unless it is
+// wrapped with Permanent.
+type Handler func(ctx context.Context, job database.Job) error
+
+// Typed adapts a function of a decoded payload to a Handler. Payloads that
+// don't decode are dead-lettered straight away, since retrying can't help.
+func Typed[T any](fn func(ctx context.Context, payload T) error) Handler {
+    return func(ctx context.Context, job database.Job) error {
+        var payload T
+        if err := json.Unmarshal(job.Payload, &payload); err != nil {
+            return Permanent(fmt.Errorf("decoding payload: %w", err))
+        }
+        return fn(ctx, payload)
+    }
+}
+
+type permanentError struct {
+    err error
+}
+
+func (e permanentError) Error() string {
+    return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+    return e.err
+}
+
+// Permanent marks err as one that retrying won't fix, so the job is moved to
+// the dead-letter queue at once.
+func Permanent(err error) error {
+    return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+    var p permanentError
+    return errors.As(err, &p)
+}
+
+// Enqueue queues a job of the given kind with payload encoded as JSON. The
+// job runs at runAt, or as soon as possible if runAt is zero. Pass the Store
+// of a unit of work to queue the job only if the transaction commits.
+func Enqueue(ctx context.Context, s store.JobStore, kind string, payload any, runAt time.Time) (database.Job, error) {
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return database.Job{}, fmt.Errorf("encoding %s payload: %w", kind, err)
+    }
+    if runAt.IsZero() {
+        runAt = time.Now()
+    }
+
+    job, err := s.EnqueueJob(ctx, database.EnqueueJobParams{
+        Kind: kind,
+        Payload: data,
+        RunAt: runAt.UTC(),
+    })
+    if err != nil {
+        return database.Job{}, fmt.Errorf("enqueueing %s job: %w", kind, err)
+    }
+    return job, nil
+}
+
+// Backoff returns how long to wait before retrying a job that has been
+// attempted attempts times. The delay doubles with each attempt, up to an
+// hour.
+func Backoff(attempts int32) time.Duration {
+    if attempts < 1 {
+        return backoffBase
+    }
+
+    delay := backoffBase
+    for i := int32(1); i < attempts; i++ {
+        delay *= 2
+        if delay >= backoffMax {
+            return backoffMax
+        }
+    }
+    return delay
+}
@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWorker(s store.JobStore) *Worker {
+    return NewWorker(s, config.JobsConfig{PollInterval: time.Second, Concurrency: 2, MaxAttempts: 3}, metrics.New(nil))
+}
+
+// runOnce claims and runs every due job, then waits for them to finish.
+func runOnce(w *Worker) {
+    var running sync.WaitGroup
+    w.runDue(context.Background(), make(chan struct{}, 10), &running)
+    running.Wait()
+}
+
+func TestWorker(t *testing.T) {
+    ctx := context.Background()
+    type payload struct {
+        Name string `json:"name"`
+    }
+
+    t.Run("Runs due jobs with their payload", func(t *testing.T) {
+        s := store.NewMemory()
+        w := newTestWorker(s)
+        var got []string
+        w.Handle("greet", Typed(func(ctx context.Context, p payload) error {
+            got = append(got, p.Name)
+            return nil
+        }))
+        job, err := Enqueue(ctx, s, "greet", payload{Name: "chirpy"}, time.Time{})
+        require.NoError(t, err)
+        _, err = Enqueue(ctx, s, "greet", payload{Name: "later"}, time.Now().Add(time.Hour))
+        require.NoError(t, err)
+
+        runOnce(w)
+
+        assert.Equal(t, []string{"chirpy"}, got)
+        job, err = s.GetJob(ctx, job.ID)
+        require.NoError(t, err)
+        assert.Equal(t, StatusSucceeded, job.Status)
+        assert.True(t, job.FinishedAt.Valid)
+    })
+
+    t.Run("Failures are retried with backoff", func(t *testing.T) {
+        s := store.NewMemory()
+        w := newTestWorker(s)
+        w.Handle("flaky", func(ctx context.Context, job database.Job) error {
+            return errors.New("try again")
+        })
+        job, err := Enqueue(ctx, s, "flaky", nil, time.Time{})
+        require.NoError(t, err)
+
+        runOnce(w)
+
+        job, err = s.GetJob(ctx, job.ID)
+        require.NoError(t, err)
+        assert.Equal(t, StatusPending, job.Status)
+        assert.Equal(t, int32(1), job.Attempts)
+        assert.Equal(t, "try again", job.LastError.String)
+        assert.WithinDuration(t, time.Now().Add(Backoff(1)), job.RunAt, time.Second)
+    })
+
+    t.Run("Jobs are dead-lettered after the last attempt", func(t *testing.T) {
+        s := store.NewMemory()
+        w := newTestWorker(s)
+        w.Handle("flaky", func(ctx context.Context, job database.Job) error {
+            return errors.New("still broken")
+        })
+        job, err := Enqueue(ctx, s, "flaky", nil, time.Time{})
+        require.NoError(t, err)
+
+        for attempt := int32(1); attempt <= w.MaxAttempts; attempt++ {
+            runOnce(w)
+            if attempt < w.MaxAttempts {
+                // Skip the backoff.
+                require.NoError(t, s.ScheduleJobRetry(ctx, database.ScheduleJobRetryParams{ID: job.ID, RunAt: time.Now()}))
+            }
+        }
+
+        job, err = s.GetJob(ctx, job.ID)
+        require.NoError(t, err)
+        assert.Equal(t, int32(3), job.Attempts)
+        dead, err := s.ListJobs(ctx, database.ListJobsParams{Status: StatusDead, MaxRows: 10})
+        require.NoError(t, err)
+        require.Len(t, dead, 1)
+        assert.Equal(t, "still broken", dead[0].LastError.String)
+    })
+
+    tests := []struct {
+        name string
+        kind string
+        handler Handler
+        lastError string
+    }{
+        {
+            name: "Permanent errors are dead-lettered at once",
+            kind: "broken",
+            handler: func(ctx context.Context, job database.Job) error { return Permanent(errors.New("bad input")) },
+            lastError: "bad input",
+        },
+        {
+            name: "Unknown kinds are dead-lettered",
+            kind: "unknown",
+            lastError: `no handler for job kind "unknown"`,
+        },
+        {
+            name: "Undecodable payloads are dead-lettered",
+            kind: "typed",
+            handler: Typed(func(ctx context.Context, n int) error { return nil }),
+            lastError: "decoding payload: json: cannot unmarshal string into Go value of type int",
+        },
+        {
+            name: "Panics are failures",
+            kind: "panics",
+            handler: func(ctx context.Context, job database.Job) error { panic("boom") },
+            lastError: "panic: boom",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := store.NewMemory()
+            w := newTestWorker(s)
+            if tt.handler != nil {
+                w.Handle(tt.kind, tt.handler)
+            }
+            job, err := Enqueue(ctx, s, tt.kind, "payload", time.Time{})
+            require.NoError(t, err)
+
+            runOnce(w)
+
+            job, err = s.GetJob(ctx, job.ID)
+            require.NoError(t, err)
+            assert.Equal(t, tt.lastError, job.LastError.String)
+            if tt.kind == "panics" {
+                assert.Equal(t, StatusPending, job.Status)
+            } else {
+                assert.Equal(t, StatusDead, job.Status)
+            }
+        })
+    }
+
+    t.Run("Run stops when cancelled", func(t *testing.T) {
+        s := store.NewMemory()
+        w := newTestWorker(s)
+        w.PollInterval = 10 * time.Millisecond
+        done := make(chan struct{})
+        w.Handle("slow", func(ctx context.Context, job database.Job) error {
+            close(done)
+            return nil
+        })
+        job, err := Enqueue(ctx, s, "slow", nil, time.Time{})
+        require.NoError(t, err)
+
+        runCtx, cancel := context.WithCancel(ctx)
+        stopped := make(chan struct{})
+        go func() {
+            w.Run(runCtx)
+            close(stopped)
+        }()
+        <-done
+        cancel()
+        <-stopped
+
+        require.NoError(t, w.Check(ctx))
+        job, err = s.GetJob(ctx, job.ID)
+        require.NoError(t, err)
+        assert.Equal(t, StatusSucceeded, job.Status)
+    })
+}
+
+func TestBackoff(t *testing.T) {
+    assert.Equal(t, 10 * time.Second, Backoff(1))
+    assert.Equal(t, 20 * time.Second, Backoff(2))
+    assert.Equal(t, 80 * time.Second, Backoff(4))
+    assert.Equal(t, time.Hour, Backoff(20))
+}
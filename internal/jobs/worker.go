@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// jobTimeout is kept under the five-minute lease ClaimDueJobs takes, so a job
+// is never claimed again while it is still running.
+const jobTimeout = 4 * time.Minute
+
+// Worker polls the jobs table and runs due jobs, up to Concurrency at a time.
+// Several workers can run against the same database; claimed rows are locked
+// with SKIP LOCKED so each job is only picked up once.
+type Worker struct {
+    store store.JobStore
+    metrics *metrics.Metrics
+    handlers map[string]Handler
+    lastPoll atomic.Int64
+
+    PollInterval time.Duration
+    Concurrency int
+    // MaxAttempts is the number of attempts after which a failing job is
+    // moved to the dead-letter queue.
+    MaxAttempts int32
+}
+
+func NewWorker(s store.JobStore, cfg config.JobsConfig, m *metrics.Metrics) *Worker {
+    return &Worker{
+        store: s,
+        metrics: m,
+        handlers: make(map[string]Handler),
+        PollInterval: cfg.PollInterval,
+        Concurrency: cfg.Concurrency,
+        MaxAttempts: int32(cfg.MaxAttempts),
+    }
+}
+
+// Handle registers the handler for jobs of the given kind. It must be called
+// before Run.
+func (w *Worker) Handle(kind string, h Handler) {
+    w.handlers[kind] = h
+}
+
+// Run runs due jobs until ctx is cancelled, then waits for the jobs already
+// running to finish so their outcome is recorded.
+func (w *Worker) Run(ctx context.Context) {
+    slots := make(chan struct{}, w.Concurrency)
+    var running sync.WaitGroup
+    defer running.Wait()
+
+    ticker := time.NewTicker(w.PollInterval)
+    defer ticker.Stop()
+
+    for {
+        w.lastPoll.Store(time.Now().UnixNano())
+        w.runDue(ctx, slots, &running)
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Check is a readiness check reporting whether the worker has polled the
+// queue recently.
+func (w *Worker) Check(ctx context.Context) error {
+    last := w.lastPoll.Load()
+    if last == 0 {
+        return errors.New("job worker has not started")
+    }
+
+    if since := time.Since(time.Unix(0, last)); since > 3 * w.PollInterval + time.Minute {
+        return fmt.Errorf("job worker last polled %s ago", since.Round(time.Second))
+    }
+    return nil
+}
+
+// runDue claims as many due jobs as there are free slots and starts them.
+// Only Run's goroutine takes slots, so sending to slots never blocks.
+func (w *Worker) runDue(ctx context.Context, slots chan struct{}, running *sync.WaitGroup) {
+    free := cap(slots) - len(slots)
+    if free == 0 {
+        return
+    }
+
+    claimed, err := w.store.ClaimDueJobs(ctx, int32(free))
+    if err != nil {
+        if ctx.Err() == nil {
+            slog.Error("failed to claim jobs", "error", err)
+        }
+        return
+    }
+
+    for _, job := range claimed {
+        slots <- struct{}{}
+        running.Add(1)
+        go func() {
+            defer func() {
+                <-slots
+                running.Done()
+            }()
+            w.run(context.WithoutCancel(ctx), job)
+        }()
+    }
+}
+
+func (w *Worker) run(ctx context.Context, job database.Job) {
+    ctx, span := tracing.Tracer().Start(ctx, "job.run",
+        trace.WithAttributes(
+            attribute.String("job.id", job.ID.String()),
+            attribute.String("job.kind", job.Kind),
+            attribute.Int("job.attempt", int(job.Attempts)),
+        ),
+    )
+    defer span.End()
+
+    start := time.Now()
+    runErr := w.call(ctx, job)
+    if runErr != nil {
+        span.RecordError(runErr)
+        span.SetStatus(codes.Error, runErr.Error())
+    }
+
+    outcome, err := w.record(ctx, job, runErr)
+    if err != nil {
+        // The job stays leased, so it is retried once the lease ends.
+        slog.Error("failed to record job outcome", "job_id", job.ID, "kind", job.Kind, "error", err)
+        return
+    }
+    w.metrics.JobRun(job.Kind, outcome, time.Since(start))
+}
+
+// call runs the job's handler with a timeout, turning a panic into an error.
+func (w *Worker) call(ctx context.Context, job database.Job) (err error) {
+    handler, ok := w.handlers[job.Kind]
+    if !ok {
+        return Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+    defer cancel()
+    defer func() {
+        if r := recover(); r != nil {
+            err = fmt.Errorf("panic: %v", r)
+        }
+    }()
+    return handler(ctx, job)
+}
+
+// record stores the outcome of a run and returns it as a metrics outcome.
+func (w *Worker) record(ctx context.Context, job database.Job, runErr error) (string, error) {
+    if runErr == nil {
+        if err := w.store.CompleteJob(ctx, job.ID); err != nil {
+            return "", fmt.Errorf("completing job: %w", err)
+        }
+        slog.Info("ran job", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+        return metrics.JobSucceeded, nil
+    }
+
+    lastError := sql.NullString{String: runErr.Error(), Valid: true}
+    if isPermanent(runErr) || job.Attempts >= w.MaxAttempts {
+        if err := w.store.DeadLetterJob(ctx, database.DeadLetterJobParams{ID: job.ID, LastError: lastError}); err != nil {
+            return "", fmt.Errorf("dead-lettering job: %w", err)
+        }
+        slog.Error("job moved to dead-letter queue", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "error", runErr)
+        return metrics.JobDead, nil
+    }
+
+    retryAt := time.Now().UTC().Add(Backoff(job.Attempts))
+    if err := w.store.ScheduleJobRetry(ctx, database.ScheduleJobRetryParams{ID: job.ID, RunAt: retryAt, LastError: lastError}); err != nil {
+        return "", fmt.Errorf("scheduling job retry: %w", err)
+    }
+    slog.Warn("job failed", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "retry_at", retryAt, "error", runErr)
+    return metrics.JobRetrying, nil
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never written to the log.
+var sensitiveKeys = map[string]struct{}{
+    "token": {},
+    "refresh_token": {},
+    "jwt": {},
+    "password": {},
+    "secret": {},
+    "authorization": {},
+    "api_key": {},
+}
+
+type ctxKey struct{}
+
+// New returns a logger writing to w. format is "json" or "text"; level is one
+// of debug, info, warn or error.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+    var lvl slog.Level
+    if err := lvl.UnmarshalText([]byte(level)); err != nil {
+        return nil, fmt.Errorf("invalid log level %q", level)
+    }
+
+    opts := &slog.HandlerOptions{
+        Level: lvl,
+        ReplaceAttr: redact,
+    }
+    switch format {
+    case "json":
+        return slog.New(slog.NewJSONHandler(w, opts)), nil
+    case "text":
+        return slog.New(slog.NewTextHandler(w, opts)), nil
+    default:
+        return nil, fmt.Errorf("invalid log format %q", format)
+    }
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+    return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger stored in ctx, falling back to
+// the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+    if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+        return logger
+    }
+    return slog.Default()
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+    key := strings.ToLower(a.Key)
+    if _, ok := sensitiveKeys[key]; ok {
+        return slog.String(a.Key, redacted)
+    }
+    if key == "email" {
+        return slog.String(a.Key, MaskEmail(a.Value.String()))
+    }
+    return a
+}
+
+// MaskEmail keeps the first character of the local part and the domain, so
+// log lines can still be correlated without exposing the address.
+func MaskEmail(email string) string {
+    local, domain, ok := strings.Cut(email, "@")
+    if !ok || local == "" {
+        return redacted
+    }
+    return local[:1] + "***@" + domain
+}
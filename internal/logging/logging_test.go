@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+    var buf bytes.Buffer
+    logger, err := New(&buf, "json", "info")
+    require.NoError(t, err)
+
+    logger.Info("login", "email", "saul@bettercall.com", "token", "abc.def.ghi", "password", "hunter2")
+
+    assert.Contains(t, buf.String(), `"email":"s***@bettercall.com"`)
+    assert.Contains(t, buf.String(), `"token":"[REDACTED]"`)
+    assert.NotContains(t, buf.String(), "hunter2")
+    assert.NotContains(t, buf.String(), "abc.def.ghi")
+}
+
+func TestNew(t *testing.T) {
+    _, err := New(&bytes.Buffer{}, "xml", "info")
+    assert.EqualError(t, err, `invalid log format "xml"`)
+
+    _, err = New(&bytes.Buffer{}, "json", "loud")
+    assert.EqualError(t, err, `invalid log level "loud"`)
+}
+
+func TestMiddleware(t *testing.T) {
+    var buf bytes.Buffer
+    logger, err := New(&buf, "json", "info")
+    require.NoError(t, err)
+
+    handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        FromContext(req.Context()).Info("handling")
+        w.WriteHeader(http.StatusTeapot)
+    }))
+
+    t.Run("Propagates request ID", func(t *testing.T) {
+        buf.Reset()
+        req := httptest.NewRequest(http.MethodGet, "/api/healthz", nil)
+        req.Header.Set(RequestIDHeader, "abc-123")
+        rec := httptest.NewRecorder()
+
+        handler.ServeHTTP(rec, req)
+
+        assert.Equal(t, "abc-123", rec.Header().Get(RequestIDHeader))
+        assert.Contains(t, buf.String(), `"msg":"handling","request_id":"abc-123"`)
+        assert.Contains(t, buf.String(), `"status":418`)
+    })
+
+    t.Run("Replaces malformed request ID", func(t *testing.T) {
+        req := httptest.NewRequest(http.MethodGet, "/api/healthz", nil)
+        req.Header.Set(RequestIDHeader, "not valid\n")
+        rec := httptest.NewRecorder()
+
+        handler.ServeHTTP(rec, req)
+
+        assert.Len(t, rec.Header().Get(RequestIDHeader), 36)
+    })
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+    r.status = code
+    r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+    return r.ResponseWriter
+}
+
+// Middleware assigns every request an ID, taken from the X-Request-ID header
+// when the client sent a well-formed one, echoes it in the response, and
+// stores a logger annotated with it, and with the trace ID when the request is
+// traced, in the request context. Each request is logged once it completes.
+func Middleware(base *slog.Logger) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            requestId := req.Header.Get(RequestIDHeader)
+            if !validRequestID.MatchString(requestId) {
+                requestId = uuid.NewString()
+            }
+            w.Header().Set(RequestIDHeader, requestId)
+
+            logger := base.With("request_id", requestId)
+            if sc := trace.SpanContextFromContext(req.Context()); sc.HasTraceID() {
+                logger = logger.With("trace_id", sc.TraceID().String())
+            }
+            req = req.WithContext(WithLogger(req.Context(), logger))
+
+            start := time.Now()
+            rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+            next.ServeHTTP(rec, req)
+
+            logger.Info("request completed",
+                "method", req.Method,
+                "path", req.URL.Path,
+                "status", rec.status,
+                "duration_ms", time.Since(start).Milliseconds(),
+                "remote_addr", req.RemoteAddr,
+            )
+        })
+    }
+}
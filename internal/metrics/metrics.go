@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chirpy"
+
+// Outcomes recorded for webhook delivery attempts.
+const (
+    WebhookSucceeded = "succeeded"
+    WebhookRetrying = "retrying"
+    WebhookAbandoned = "abandoned"
+    WebhookDisabled = "disabled"
+)
+
+// Outcomes recorded for job runs.
+const (
+    JobSucceeded = "succeeded"
+    JobRetrying = "retrying"
+    JobDead = "dead"
+)
+
+// Reasons recorded for deleted refresh tokens.
+const (
+    TokenExpired = "expired"
+    TokenRevoked = "revoked"
+)
+
+// Metrics owns the Prometheus registry and every collector Chirpy exports.
+type Metrics struct {
+    registry *prometheus.Registry
+
+    fileserverHits atomic.Int64
+    requests *prometheus.CounterVec
+    requestDuration *prometheus.HistogramVec
+    logins *prometheus.CounterVec
+    chirpsCreated prometheus.Counter
+    webhookDeliveries *prometheus.CounterVec
+    jobs *prometheus.CounterVec
+    jobDuration *prometheus.HistogramVec
+    refreshTokensDeleted *prometheus.CounterVec
+    rateLimited *prometheus.CounterVec
+}
+
+// New creates the registry. db may be nil, in which case connection pool
+// statistics are not exported.
+func New(db *sql.DB) *Metrics {
+    m := &Metrics{
+        registry: prometheus.NewRegistry(),
+        requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "http_requests_total",
+            Help: "HTTP requests handled, by route, method and status code.",
+        }, []string{"route", "method", "code"}),
+        requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Namespace: namespace,
+            Name: "http_request_duration_seconds",
+            Help: "HTTP request latency, by route and method.",
+            Buckets: prometheus.DefBuckets,
+        }, []string{"route", "method"}),
+        logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "logins_total",
+            Help: "Login attempts, by result.",
+        }, []string{"result"}),
+        chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "chirps_created_total",
+            Help: "Chirps created.",
+        }),
+        webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "webhook_deliveries_total",
+            Help: "Webhook delivery attempts, by outcome.",
+        }, []string{"outcome"}),
+        jobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "jobs_total",
+            Help: "Background job runs, by kind and outcome.",
+        }, []string{"kind", "outcome"}),
+        jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Namespace: namespace,
+            Name: "job_duration_seconds",
+            Help: "Background job run time, by kind.",
+            Buckets: prometheus.DefBuckets,
+        }, []string{"kind"}),
+        refreshTokensDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "refresh_tokens_deleted_total",
+            Help: "Stale refresh tokens deleted by garbage collection, by reason.",
+        }, []string{"reason"}),
+        rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "rate_limited_requests_total",
+            Help: "Requests rejected by rate limiting, by route.",
+        }, []string{"route"}),
+    }
+
+    m.registry.MustRegister(
+        collectors.NewGoCollector(),
+        collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+        prometheus.NewCounterFunc(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name: "fileserver_hits_total",
+            Help: "Requests served from /app since the last admin reset.",
+        }, func() float64 {
+            return float64(m.fileserverHits.Load())
+        }),
+        m.requests,
+        m.requestDuration,
+        m.logins,
+        m.chirpsCreated,
+        m.webhookDeliveries,
+        m.jobs,
+        m.jobDuration,
+        m.refreshTokensDeleted,
+        m.rateLimited,
+    )
+    if db != nil {
+        m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+    }
+
+    // Pre-create the fixed label values so they are exported as zero.
+    m.logins.WithLabelValues("success")
+    m.logins.WithLabelValues("failure")
+    for _, outcome := range []string{WebhookSucceeded, WebhookRetrying, WebhookAbandoned, WebhookDisabled} {
+        m.webhookDeliveries.WithLabelValues(outcome)
+    }
+    m.refreshTokensDeleted.WithLabelValues(TokenExpired)
+    m.refreshTokensDeleted.WithLabelValues(TokenRevoked)
+    return m
+}
+
+func (m *Metrics) Registry() *prometheus.Registry {
+    return m.registry
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+    return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (m *Metrics) FileserverHits() int64 {
+    return m.fileserverHits.Load()
+}
+
+func (m *Metrics) ResetFileserverHits() {
+    m.fileserverHits.Store(0)
+}
+
+func (m *Metrics) LoginSucceeded() {
+    m.logins.WithLabelValues("success").Inc()
+}
+
+func (m *Metrics) LoginFailed() {
+    m.logins.WithLabelValues("failure").Inc()
+}
+
+func (m *Metrics) ChirpCreated() {
+    m.chirpsCreated.Inc()
+}
+
+func (m *Metrics) WebhookDelivery(outcome string) {
+    m.webhookDeliveries.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) JobRun(kind, outcome string, duration time.Duration) {
+    m.jobs.WithLabelValues(kind, outcome).Inc()
+    m.jobDuration.WithLabelValues(kind).Observe(duration.Seconds())
+}
+
+func (m *Metrics) RefreshTokensDeleted(reason string, n int64) {
+    m.refreshTokensDeleted.WithLabelValues(reason).Add(float64(n))
+}
+
+func (m *Metrics) RateLimited(route string) {
+    m.rateLimited.WithLabelValues(route).Inc()
+}
+
+// CountFileserverHits counts every request passed to next.
+func (m *Metrics) CountFileserverHits(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        m.fileserverHits.Add(1)
+        next.ServeHTTP(w, req)
+    })
+}
+
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+    r.status = code
+    r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+    return r.ResponseWriter
+}
+
+// Middleware records the count, status and latency of every request. Requests
+// are labelled with the ServeMux pattern that matched them rather than the raw
+// path, which keeps the label cardinality bounded.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        start := time.Now()
+        rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+        next.ServeHTTP(rec, req)
+
+        route := req.Pattern
+        if route == "" {
+            route = "unmatched"
+        }
+        m.requests.WithLabelValues(route, req.Method, strconv.Itoa(rec.status)).Inc()
+        m.requestDuration.WithLabelValues(route, req.Method).Observe(time.Since(start).Seconds())
+    })
+}
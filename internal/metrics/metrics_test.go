@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+    m := New(nil)
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, req *http.Request) {
+        w.WriteHeader(http.StatusNotFound)
+    })
+    handler := m.Middleware(mux)
+
+    handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/chirps/123", nil))
+    handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope", nil))
+
+    rec := httptest.NewRecorder()
+    m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+    body := rec.Body.String()
+    assert.Contains(t, body, `chirpy_http_requests_total{code="404",method="GET",route="GET /api/chirps/{chirpID}"} 1`)
+    assert.Contains(t, body, `chirpy_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+    assert.Contains(t, body, `chirpy_http_request_duration_seconds_count{method="GET",route="GET /api/chirps/{chirpID}"} 1`)
+}
+
+func TestCounters(t *testing.T) {
+    m := New(nil)
+    app := m.CountFileserverHits(http.NotFoundHandler())
+
+    app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/app/", nil))
+    app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/app/", nil))
+    m.LoginFailed()
+    m.ChirpCreated()
+    m.WebhookDelivery(WebhookDisabled)
+    m.JobRun("email.send", JobRetrying, time.Second)
+    m.RefreshTokensDeleted(TokenExpired, 3)
+    m.RateLimited("POST /api/login")
+
+    rec := httptest.NewRecorder()
+    m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+    body := rec.Body.String()
+    assert.Contains(t, body, "chirpy_fileserver_hits_total 2")
+    assert.Contains(t, body, `chirpy_logins_total{result="failure"} 1`)
+    assert.Contains(t, body, `chirpy_logins_total{result="success"} 0`)
+    assert.Contains(t, body, "chirpy_chirps_created_total 1")
+    assert.Contains(t, body, `chirpy_webhook_deliveries_total{outcome="disabled"} 1`)
+    assert.Contains(t, body, `chirpy_jobs_total{kind="email.send",outcome="retrying"} 1`)
+    assert.Contains(t, body, `chirpy_job_duration_seconds_count{kind="email.send"} 1`)
+    assert.Contains(t, body, `chirpy_refresh_tokens_deleted_total{reason="expired"} 3`)
+    assert.Contains(t, body, `chirpy_refresh_tokens_deleted_total{reason="revoked"} 0`)
+    assert.Contains(t, body, `chirpy_rate_limited_requests_total{route="POST /api/login"} 1`)
+
+    m.ResetFileserverHits()
+    assert.Equal(t, int64(0), m.FileserverHits())
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Sample is one exported value, flattened for display.
+type Sample struct {
+    Name string
+    Labels string
+    Value float64
+}
+
+// Snapshot gathers the chirpy_* metrics from the registry. Histograms are
+// reported as their _count and _sum series.
+func (m *Metrics) Snapshot() ([]Sample, error) {
+    families, err := m.registry.Gather()
+    if err != nil {
+        return nil, err
+    }
+
+    var samples []Sample
+    for _, family := range families {
+        name := family.GetName()
+        if !strings.HasPrefix(name, namespace + "_") {
+            continue
+        }
+
+        for _, metric := range family.GetMetric() {
+            labels := formatLabels(metric.GetLabel())
+            switch family.GetType() {
+            case dto.MetricType_COUNTER:
+                samples = append(samples, Sample{name, labels, metric.GetCounter().GetValue()})
+            case dto.MetricType_GAUGE:
+                samples = append(samples, Sample{name, labels, metric.GetGauge().GetValue()})
+            case dto.MetricType_HISTOGRAM:
+                h := metric.GetHistogram()
+                samples = append(samples,
+                    Sample{name + "_count", labels, float64(h.GetSampleCount())},
+                    Sample{name + "_sum", labels, h.GetSampleSum()},
+                )
+            }
+        }
+    }
+
+    sort.SliceStable(samples, func(i, j int) bool {
+        return samples[i].Name < samples[j].Name
+    })
+    return samples, nil
+}
+
+// Value returns the value of the first sample named name, or 0 if there is
+// none.
+func Value(samples []Sample, name string) float64 {
+    for _, s := range samples {
+        if s.Name == name {
+            return s.Value
+        }
+    }
+    return 0
+}
+
+func formatLabels(pairs []*dto.LabelPair) string {
+    parts := make([]string, 0, len(pairs))
+    for _, p := range pairs {
+        parts = append(parts, p.GetName() + "=" + p.GetValue())
+    }
+    return strings.Join(parts, ", ")
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/sql/schema"
+	sqliteschema "github.com/bamcmanus/Chirpy/sql/sqlite/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Migrator applies the migrations embedded from sql/schema, or sql/sqlite/schema
+// for SQLite. On Postgres, operations that change the schema hold an advisory
+// lock, so replicas that start at the same time apply each migration exactly
+// once. SQLite databases are single-node and already serialise writers.
+type Migrator struct {
+    provider *goose.Provider
+}
+
+// New returns a Migrator for db, whose dialect is one reported by store.Open.
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+    var provider *goose.Provider
+    var err error
+    if dialect == store.DialectSQLite {
+        provider, err = goose.NewProvider(goose.DialectSQLite3, db, sqliteschema.FS)
+    } else {
+        locker, lockErr := lock.NewPostgresSessionLocker()
+        if lockErr != nil {
+            return nil, fmt.Errorf("creating migration lock: %w", lockErr)
+        }
+        provider, err = goose.NewProvider(goose.DialectPostgres, db, schema.FS, goose.WithSessionLocker(locker))
+    }
+    if err != nil {
+        return nil, fmt.Errorf("loading migrations: %w", err)
+    }
+    return &Migrator{provider: provider}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context, w io.Writer) error {
+    results, err := m.provider.Up(ctx)
+    for _, r := range results {
+        printResult(w, r)
+    }
+    return err
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context, w io.Writer) error {
+    result, err := m.provider.Down(ctx)
+    if result != nil {
+        printResult(w, result)
+    }
+    return err
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context, w io.Writer) error {
+    if err := m.Down(ctx, w); err != nil {
+        return err
+    }
+
+    result, err := m.provider.UpByOne(ctx)
+    if result != nil {
+        printResult(w, result)
+    }
+    return err
+}
+
+// Status writes a table of every migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context, w io.Writer) error {
+    statuses, err := m.provider.Status(ctx)
+    if err != nil {
+        return err
+    }
+
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+    for _, s := range statuses {
+        appliedAt := "-"
+        if !s.AppliedAt.IsZero() {
+            appliedAt = s.AppliedAt.Format(time.RFC3339)
+        }
+        fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, filepath.Base(s.Source.Path))
+    }
+    return tw.Flush()
+}
+
+// Check returns an error if the database schema is behind the migrations
+// embedded in this binary. It does not take the migration lock.
+func (m *Migrator) Check(ctx context.Context) error {
+    current, target, err := m.provider.GetVersions(ctx)
+    if err != nil {
+        return err
+    }
+    if current < target {
+        return fmt.Errorf("schema is at version %d; expected %d", current, target)
+    }
+    return nil
+}
+
+func printResult(w io.Writer, r *goose.MigrationResult) {
+    if r.Error != nil {
+        fmt.Fprintf(w, "FAIL  %s %s: %s\n", r.Direction, filepath.Base(r.Source.Path), r.Error)
+        return
+    }
+    fmt.Fprintf(w, "OK    %s %s (%s)\n", r.Direction, filepath.Base(r.Source.Path), r.Duration.Round(time.Millisecond))
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/store"
+	sqliteschema "github.com/bamcmanus/Chirpy/sql/sqlite/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMigrator returns a Migrator for an empty in-memory SQLite database.
+func newMigrator(t *testing.T) *Migrator {
+    t.Helper()
+
+    db, dialect, err := store.Open("sqlite::memory:")
+    require.NoError(t, err)
+    t.Cleanup(func() { db.Close() })
+
+    m, err := New(db, dialect)
+    require.NoError(t, err)
+    return m
+}
+
+// migrations lists the embedded SQLite migrations in order.
+func migrations(t *testing.T) []string {
+    t.Helper()
+
+    files, err := fs.Glob(sqliteschema.FS, "*.sql")
+    require.NoError(t, err)
+    require.NotEmpty(t, files)
+    return files
+}
+
+func TestUp(t *testing.T) {
+    ctx := context.Background()
+    files := migrations(t)
+
+    t.Run("Every migration is applied in order", func(t *testing.T) {
+        m := newMigrator(t)
+
+        var out bytes.Buffer
+        require.NoError(t, m.Up(ctx, &out))
+
+        lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+        require.Len(t, lines, len(files))
+        for i, file := range files {
+            assert.True(t, strings.HasPrefix(lines[i], "OK    up "+file+" ("), lines[i])
+        }
+    })
+
+    t.Run("Nothing is applied twice", func(t *testing.T) {
+        m := newMigrator(t)
+        require.NoError(t, m.Up(ctx, io.Discard))
+
+        var out bytes.Buffer
+        require.NoError(t, m.Up(ctx, &out))
+        assert.Empty(t, out.String())
+    })
+}
+
+func TestDown(t *testing.T) {
+    ctx := context.Background()
+    files := migrations(t)
+
+    t.Run("The latest migration is rolled back", func(t *testing.T) {
+        m := newMigrator(t)
+        require.NoError(t, m.Up(ctx, io.Discard))
+
+        var out bytes.Buffer
+        require.NoError(t, m.Down(ctx, &out))
+        assert.True(t, strings.HasPrefix(out.String(), "OK    down "+files[len(files)-1]+" ("), out.String())
+
+        out.Reset()
+        require.NoError(t, m.Up(ctx, &out))
+        assert.True(t, strings.HasPrefix(out.String(), "OK    up "+files[len(files)-1]+" ("), out.String())
+    })
+
+    t.Run("Every migration can be rolled back and applied again", func(t *testing.T) {
+        m := newMigrator(t)
+        require.NoError(t, m.Up(ctx, io.Discard))
+
+        for range files {
+            require.NoError(t, m.Down(ctx, io.Discard))
+        }
+        require.Error(t, m.Down(ctx, io.Discard), "there is nothing left to roll back")
+        require.NoError(t, m.Up(ctx, io.Discard))
+        assert.NoError(t, m.Check(ctx))
+    })
+}
+
+func TestRedo(t *testing.T) {
+    ctx := context.Background()
+    files := migrations(t)
+    latest := files[len(files)-1]
+
+    m := newMigrator(t)
+    require.NoError(t, m.Up(ctx, io.Discard))
+
+    var out bytes.Buffer
+    require.NoError(t, m.Redo(ctx, &out))
+
+    lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+    require.Len(t, lines, 2)
+    assert.True(t, strings.HasPrefix(lines[0], "OK    down "+latest+" ("), lines[0])
+    assert.True(t, strings.HasPrefix(lines[1], "OK    up "+latest+" ("), lines[1])
+    assert.NoError(t, m.Check(ctx))
+}
+
+func TestStatus(t *testing.T) {
+    ctx := context.Background()
+    files := migrations(t)
+
+    m := newMigrator(t)
+    require.NoError(t, m.Up(ctx, io.Discard))
+    require.NoError(t, m.Down(ctx, io.Discard))
+
+    var out bytes.Buffer
+    require.NoError(t, m.Status(ctx, &out))
+
+    lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+    require.Len(t, lines, len(files)+1)
+    assert.Equal(t, []string{"VERSION", "STATE", "APPLIED", "AT", "SOURCE"}, strings.Fields(lines[0]))
+    for i, file := range files {
+        fields := strings.Fields(lines[i+1])
+        assert.Equal(t, fmt.Sprint(i+1), fields[0])
+        assert.Equal(t, file, fields[len(fields)-1])
+        if i == len(files)-1 {
+            assert.Equal(t, []string{"pending", "-"}, fields[1:3], "the rolled back migration")
+        } else {
+            assert.Equal(t, "applied", fields[1])
+        }
+    }
+}
+
+func TestCheck(t *testing.T) {
+    ctx := context.Background()
+    files := migrations(t)
+
+    m := newMigrator(t)
+    assert.EqualError(t, m.Check(ctx), fmt.Sprintf("schema is at version 0; expected %d", len(files)))
+
+    require.NoError(t, m.Up(ctx, io.Discard))
+    assert.NoError(t, m.Check(ctx))
+
+    require.NoError(t, m.Down(ctx, io.Discard))
+    assert.EqualError(t, m.Check(ctx), fmt.Sprintf("schema is at version %d; expected %d", len(files)-1, len(files)))
+}
@@ -0,0 +1,21 @@
+// Package openapi embeds the OpenAPI 3.1 document that describes the HTTP
+// API. The document is maintained by hand next to the handlers; tests check
+// that it covers every registered route and matches the response types.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+// Handler serves the document.
+func Handler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusOK)
+        w.Write(Spec)
+    })
+}
@@ -0,0 +1,94 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec(t *testing.T) {
+    var spec map[string]any
+    require.NoError(t, json.Unmarshal(Spec, &spec))
+    assert.Equal(t, "3.1.0", spec["openapi"])
+
+    t.Run("References resolve", func(t *testing.T) {
+        walk(spec, func(m map[string]any) {
+            ref, ok := m["$ref"].(string)
+            if !ok {
+                return
+            }
+            assert.NotNil(t, resolve(spec, ref), "unresolved $ref %s", ref)
+        })
+    })
+
+    t.Run("Operation IDs are unique", func(t *testing.T) {
+        seen := make(map[string]bool)
+        walk(spec["paths"], func(m map[string]any) {
+            id, ok := m["operationId"].(string)
+            if !ok {
+                return
+            }
+            assert.False(t, seen[id], "duplicate operationId %s", id)
+            seen[id] = true
+        })
+    })
+
+    t.Run("Path parameters are declared", func(t *testing.T) {
+        param := regexp.MustCompile(`\{(\w+)\}`)
+        for path, item := range spec["paths"].(map[string]any) {
+            declared := make(map[string]bool)
+            walk(item, func(m map[string]any) {
+                if m["in"] == "path" {
+                    declared[m["name"].(string)] = true
+                }
+            })
+            for _, match := range param.FindAllStringSubmatch(path, -1) {
+                assert.True(t, declared[match[1]], "%s doesn't declare path parameter %s", path, match[1])
+            }
+        }
+    })
+}
+
+func TestHandler(t *testing.T) {
+    rec := httptest.NewRecorder()
+
+    Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+    assert.Equal(t, http.StatusOK, rec.Code)
+    assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+    assert.JSONEq(t, string(Spec), rec.Body.String())
+}
+
+// walk calls fn for every object in v.
+func walk(v any, fn func(map[string]any)) {
+    switch v := v.(type) {
+    case map[string]any:
+        fn(v)
+        for _, child := range v {
+            walk(child, fn)
+        }
+    case []any:
+        for _, child := range v {
+            walk(child, fn)
+        }
+    }
+}
+
+// resolve looks up a local reference such as #/components/schemas/User.
+func resolve(spec map[string]any, ref string) any {
+    var v any = spec
+    for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+        m, ok := v.(map[string]any)
+        if !ok {
+            return nil
+        }
+        v = m[key]
+    }
+    return v
+}
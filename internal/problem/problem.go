@@ -0,0 +1,155 @@
+// Package problem renders errors as RFC 7807 problem details
+// (application/problem+json). Handlers return an *Error carrying the status,
+// a stable machine-readable code and a message for the client; any other
+// error is reported as an opaque 500 and only logged.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ContentType = "application/problem+json"
+
+// typePrefix makes each code a URI, as the type member requires.
+const typePrefix = "urn:chirpy:problem:"
+
+// Codes shared by many routes. Routes add more specific ones, e.g.
+// "chirp_not_found". Codes are part of the API: never change one.
+const (
+    CodeInvalidJSON = "invalid_json"
+    CodeValidation = "validation_failed"
+    CodeUnauthorized = "unauthorized"
+    CodeForbidden = "forbidden"
+    CodeNotFound = "not_found"
+    CodeRateLimited = "rate_limited"
+    CodeInternal = "internal_error"
+)
+
+// Error is an error with everything needed to render it for a client.
+type Error struct {
+    Status int
+    Code string
+    // Detail is shown to the client, so it must not leak internals.
+    Detail string
+    Fields []FieldError
+    // Err is the underlying cause. It is logged but never shown.
+    Err error
+}
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+    Field string `json:"field"`
+    Code string `json:"code"`
+    Message string `json:"message"`
+}
+
+func New(status int, code, detail string) *Error {
+    return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(code, detail string) *Error {
+    return New(http.StatusBadRequest, code, detail)
+}
+
+func Unauthorized(detail string) *Error {
+    return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Error {
+    return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(code, detail string) *Error {
+    return New(http.StatusNotFound, code, detail)
+}
+
+func Conflict(code, detail string) *Error {
+    return New(http.StatusConflict, code, detail)
+}
+
+// Internal reports err as a 500 with detail as the only explanation the
+// client gets.
+func Internal(detail string, err error) *Error {
+    return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: detail, Err: err}
+}
+
+// Invalid reports a request whose fields failed validation.
+func Invalid(fields ...FieldError) *Error {
+    return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Detail: "the request is invalid", Fields: fields}
+}
+
+// Wrap records err as the cause of e.
+func (e *Error) Wrap(err error) *Error {
+    e.Err = err
+    return e
+}
+
+func (e *Error) Error() string {
+    if e.Err != nil {
+        return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+    }
+    return e.Code + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+    return e.Err
+}
+
+// body is the problem details object. Error repeats the detail for clients
+// written against the older {"error": "..."} responses.
+type body struct {
+    Type string `json:"type"`
+    Title string `json:"title"`
+    Status int `json:"status"`
+    Detail string `json:"detail,omitempty"`
+    Instance string `json:"instance,omitempty"`
+    Code string `json:"code"`
+    Error string `json:"error"`
+    Errors []FieldError `json:"errors,omitempty"`
+    TraceID string `json:"trace_id,omitempty"`
+    RequestID string `json:"request_id,omitempty"`
+}
+
+// Write logs err and renders it as a problem response. Errors that aren't an
+// *Error become a generic 500.
+func Write(w http.ResponseWriter, req *http.Request, err error) {
+    var e *Error
+    if !errors.As(err, &e) {
+        e = Internal("an internal error occurred", err)
+    }
+
+    logger := logging.FromContext(req.Context())
+    if e.Status >= http.StatusInternalServerError {
+        logger.Error("request failed", "status", e.Status, "code", e.Code, "error", err)
+    } else {
+        logger.Info("request rejected", "status", e.Status, "code", e.Code, "error", err)
+    }
+
+    res := body{
+        Type: typePrefix + e.Code,
+        Title: http.StatusText(e.Status),
+        Status: e.Status,
+        Detail: e.Detail,
+        Instance: req.URL.Path,
+        Code: e.Code,
+        Error: e.Detail,
+        Errors: e.Fields,
+        RequestID: w.Header().Get(logging.RequestIDHeader),
+    }
+    if res.Error == "" {
+        res.Error = res.Title
+    }
+    if sc := trace.SpanContextFromContext(req.Context()); sc.HasTraceID() {
+        res.TraceID = sc.TraceID().String()
+    }
+
+    w.Header().Set("Content-Type", ContentType)
+    w.WriteHeader(e.Status)
+    _ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,132 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+    tests := []struct{
+        name string
+        err error
+        want body
+    }{
+        {
+            name: "Problem",
+            err: NotFound("chirp_not_found", "chirp not found"),
+            want: body{
+                Type: "urn:chirpy:problem:chirp_not_found",
+                Title: "Not Found",
+                Status: http.StatusNotFound,
+                Detail: "chirp not found",
+                Instance: "/api/chirps/1",
+                Code: "chirp_not_found",
+                Error: "chirp not found",
+                RequestID: "req-1",
+            },
+        },
+        {
+            name: "Wrapped problem",
+            err: fmt.Errorf("handling: %w", Invalid(FieldError{Field: "body", Code: "too_long", Message: "Chirp is too long"})),
+            want: body{
+                Type: "urn:chirpy:problem:validation_failed",
+                Title: "Bad Request",
+                Status: http.StatusBadRequest,
+                Detail: "the request is invalid",
+                Instance: "/api/chirps/1",
+                Code: CodeValidation,
+                Error: "the request is invalid",
+                Errors: []FieldError{{Field: "body", Code: "too_long", Message: "Chirp is too long"}},
+                RequestID: "req-1",
+            },
+        },
+        {
+            name: "Plain error",
+            err: errors.New("connection refused"),
+            want: body{
+                Type: "urn:chirpy:problem:internal_error",
+                Title: "Internal Server Error",
+                Status: http.StatusInternalServerError,
+                Detail: "an internal error occurred",
+                Instance: "/api/chirps/1",
+                Code: CodeInternal,
+                Error: "an internal error occurred",
+                RequestID: "req-1",
+            },
+        },
+        {
+            name: "No detail",
+            err: New(http.StatusTeapot, "teapot", ""),
+            want: body{
+                Type: "urn:chirpy:problem:teapot",
+                Title: "I'm a teapot",
+                Status: http.StatusTeapot,
+                Instance: "/api/chirps/1",
+                Code: "teapot",
+                Error: "I'm a teapot",
+                RequestID: "req-1",
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/api/chirps/1", nil)
+            rec := httptest.NewRecorder()
+            rec.Header().Set(logging.RequestIDHeader, "req-1")
+
+            Write(rec, req, tt.err)
+
+            assert.Equal(t, tt.want.Status, rec.Code)
+            assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+            var got body
+            require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+            assert.Equal(t, tt.want, got)
+        })
+    }
+}
+
+func TestErrorDoesNotLeakCause(t *testing.T) {
+    cause := errors.New("pq: password authentication failed")
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    rec := httptest.NewRecorder()
+
+    err := Internal("failed to list chirps", cause)
+    Write(rec, req, err)
+
+    assert.ErrorIs(t, err, cause)
+    assert.NotContains(t, rec.Body.String(), "password")
+}
+
+func TestOpenAPISchema(t *testing.T) {
+    var spec struct {
+        Components struct {
+            Schemas map[string]struct {
+                Properties map[string]json.RawMessage `json:"properties"`
+            } `json:"schemas"`
+        } `json:"components"`
+    }
+    require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+
+    var documented []string
+    for property := range spec.Components.Schemas["Problem"].Properties {
+        documented = append(documented, property)
+    }
+    var fields []string
+    rt := reflect.TypeOf(body{})
+    for i := range rt.NumField() {
+        name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+        fields = append(fields, name)
+    }
+    assert.ElementsMatch(t, fields, documented)
+}
@@ -0,0 +1,238 @@
+// Package ratelimit limits how often each client can call a route. Limits are
+// token buckets, tracked with the generic cell rate algorithm so that each
+// key needs a single timestamp: a bucket of Burst tokens that refills at
+// Requests per Period.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+)
+
+// Limiter enforces the configured policies on the routes of a mux.
+type Limiter struct {
+    store Store
+    metrics *metrics.Metrics
+    policies map[string]policy
+    proxies Proxies
+    jwtSecret string
+    now func() time.Time
+}
+
+type policy struct {
+    // interval is the time it takes to refill one token, and window the time
+    // it takes to refill the whole bucket.
+    interval time.Duration
+    window time.Duration
+    burst int
+    key string
+    header string
+}
+
+// result describes a key's bucket after a request.
+type result struct {
+    allowed bool
+    limit int
+    remaining int
+    // reset is how long until the bucket is full again.
+    reset time.Duration
+    // retryAfter is how long until the next request would be allowed, when
+    // this one was not.
+    retryAfter time.Duration
+}
+
+// New builds a Limiter from cfg. jwtSecret verifies the tokens that user
+// policies count by.
+func New(s Store, cfg config.RateLimitConfig, jwtSecret string, m *metrics.Metrics) (*Limiter, error) {
+    proxies, err := ParseProxies(cfg.TrustedProxies)
+    if err != nil {
+        return nil, err
+    }
+    l := &Limiter{
+        store: s,
+        metrics: m,
+        policies: make(map[string]policy),
+        proxies: proxies,
+        jwtSecret: jwtSecret,
+        now: time.Now,
+    }
+    for route, p := range cfg.Policies {
+        if p.Requests == 0 {
+            continue
+        }
+        burst := p.Burst
+        if burst == 0 {
+            burst = p.Requests
+        }
+        interval := p.Period / time.Duration(p.Requests)
+        l.policies[route] = policy{
+            interval: interval,
+            window: interval * time.Duration(burst),
+            burst: burst,
+            key: p.Key,
+            header: fmt.Sprintf("%d;w=%d", p.Requests, int(math.Ceil(p.Period.Seconds()))),
+        }
+    }
+    return l, nil
+}
+
+// Middleware limits the requests mux would route to a pattern with a policy,
+// passing the rest on to next. Limited responses carry the RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers, and
+// rejected requests get a 429 with Retry-After. If the store fails, the
+// request is let through.
+func (l *Limiter) Middleware(mux *http.ServeMux) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            _, pattern := mux.Handler(req)
+            p, ok := l.policies[pattern]
+            if !ok {
+                next.ServeHTTP(w, req)
+                return
+            }
+
+            res, err := l.take(req.Context(), pattern+" "+l.key(p, req), p)
+            if err != nil {
+                logging.FromContext(req.Context()).Error("failed to check rate limit; allowing request", "route", pattern, "error", err)
+                next.ServeHTTP(w, req)
+                return
+            }
+
+            header := w.Header()
+            header.Set("RateLimit-Limit", strconv.Itoa(res.limit))
+            header.Set("RateLimit-Remaining", strconv.Itoa(res.remaining))
+            header.Set("RateLimit-Reset", seconds(res.reset))
+            header.Set("RateLimit-Policy", p.header)
+            if res.allowed {
+                next.ServeHTTP(w, req)
+                return
+            }
+
+            // The mux never sees the request, so name the span here.
+            tracing.NameRoute(req.Context(), pattern)
+            l.metrics.RateLimited(pattern)
+            header.Set("Retry-After", seconds(res.retryAfter))
+            problem.Write(w, req, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited, "rate limit exceeded"))
+        })
+    }
+}
+
+func (l *Limiter) take(ctx context.Context, key string, p policy) (result, error) {
+    now := l.now()
+    tat, allowed, err := l.store.Take(ctx, key, now, p.interval, p.window)
+    if err != nil {
+        return result{}, err
+    }
+
+    res := result{allowed: allowed, limit: p.burst, reset: max(tat.Sub(now), 0)}
+    if allowed {
+        res.remaining = int((p.window - tat.Sub(now)) / p.interval)
+    } else {
+        res.retryAfter = tat.Add(p.interval).Sub(now) - p.window
+    }
+    return res, nil
+}
+
+// key identifies who a request is counted against. User and API key policies
+// fall back to the client IP for requests without the credential, which the
+// route will reject anyway.
+func (l *Limiter) key(p policy, req *http.Request) string {
+    switch p.key {
+    case "user":
+        if token, err := auth.GetBearerToken(req.Header); err == nil {
+            if userID, err := auth.ValidateJWT(token, l.jwtSecret); err == nil {
+                return "user:" + userID.String()
+            }
+        }
+    case "api_key":
+        if key, err := auth.GetAPIKey(req.Header); err == nil {
+            // Keys are hashed so the rate_limits table holds no secrets.
+            sum := sha256.Sum256([]byte(key))
+            return "api_key:" + hex.EncodeToString(sum[:16])
+        }
+    }
+    return "ip:" + l.ClientIP(req)
+}
+
+// ClientIP returns the address of the client that sent req; see
+// Proxies.ClientIP.
+func (l *Limiter) ClientIP(req *http.Request) string {
+    return l.proxies.ClientIP(req)
+}
+
+// Proxies are the trusted proxies, whose X-Forwarded-For header is believed
+// when finding the client IP.
+type Proxies []netip.Prefix
+
+// ParseProxies parses trusted proxies given as addresses or CIDR ranges.
+func ParseProxies(list []string) (Proxies, error) {
+    var proxies Proxies
+    for _, proxy := range list {
+        prefix, err := config.ParseProxy(proxy)
+        if err != nil {
+            return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+        }
+        proxies = append(proxies, prefix)
+    }
+    return proxies, nil
+}
+
+// ClientIP returns the address of the client that sent req. When the peer is
+// a trusted proxy, X-Forwarded-For is walked from the right past every
+// trusted proxy; the first address that isn't one is the client. Entries
+// left of it could be forged by the client, so they are never used.
+func (p Proxies) ClientIP(req *http.Request) string {
+    peer, err := netip.ParseAddrPort(req.RemoteAddr)
+    if err != nil {
+        return req.RemoteAddr
+    }
+    ip := peer.Addr().Unmap()
+    if !p.trusted(ip) {
+        return ip.String()
+    }
+
+    var hops []string
+    for _, value := range req.Header.Values("X-Forwarded-For") {
+        hops = append(hops, strings.Split(value, ",")...)
+    }
+    for i := len(hops) - 1; i >= 0; i-- {
+        hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+        if err != nil {
+            break
+        }
+        ip = hop.Unmap()
+        if !p.trusted(ip) {
+            break
+        }
+    }
+    return ip.String()
+}
+
+func (p Proxies) trusted(ip netip.Addr) bool {
+    for _, prefix := range p {
+        if prefix.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+// seconds rounds d up to whole seconds, as the headers require.
+func seconds(d time.Duration) string {
+    return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,279 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const secret = "secret"
+
+// clock is a fake time source for the limiter.
+type clock struct {
+    t time.Time
+}
+
+func (c *clock) now() time.Time {
+    return c.t
+}
+
+func newLimiter(t *testing.T, s Store, cfg config.RateLimitConfig) (*Limiter, *clock, http.Handler) {
+    t.Helper()
+    l, err := New(s, cfg, secret, metrics.New(nil))
+    require.NoError(t, err)
+    c := &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+    l.now = c.now
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, req *http.Request) {})
+    mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, req *http.Request) {})
+    mux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, req *http.Request) {})
+    return l, c, l.Middleware(mux)(mux)
+}
+
+func send(h http.Handler, method, path string, edit func(*http.Request)) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, path, nil)
+    if edit != nil {
+        edit(req)
+    }
+    rec := httptest.NewRecorder()
+    h.ServeHTTP(rec, req)
+    return rec
+}
+
+func TestMiddleware(t *testing.T) {
+    cfg := config.RateLimitConfig{
+        Policies: map[string]config.RateLimitPolicy{
+            "POST /api/login": {Requests: 6, Period: time.Minute, Burst: 2, Key: "ip"},
+        },
+    }
+    stores := map[string]func() Store{
+        "Memory": func() Store { return NewMemoryStore() },
+        "Database": func() Store { return NewDBStore(store.NewMemory()) },
+    }
+
+    for name, newStore := range stores {
+        t.Run(name, func(t *testing.T) {
+            _, clock, h := newLimiter(t, newStore(), cfg)
+            login := func() *httptest.ResponseRecorder {
+                return send(h, http.MethodPost, "/api/login", nil)
+            }
+
+            rec := login()
+            assert.Equal(t, http.StatusOK, rec.Code)
+            assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+            assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+            assert.Equal(t, "10", rec.Header().Get("RateLimit-Reset"))
+            assert.Equal(t, "6;w=60", rec.Header().Get("RateLimit-Policy"))
+
+            rec = login()
+            assert.Equal(t, http.StatusOK, rec.Code)
+            assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+            assert.Equal(t, "20", rec.Header().Get("RateLimit-Reset"))
+
+            rec = login()
+            assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+            assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+            assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+            assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+            assert.Contains(t, rec.Body.String(), `"code":"rate_limited"`)
+
+            clock.t = clock.t.Add(4 * time.Second)
+            rec = login()
+            assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+            assert.Equal(t, "6", rec.Header().Get("Retry-After"))
+
+            clock.t = clock.t.Add(6 * time.Second)
+            rec = login()
+            assert.Equal(t, http.StatusOK, rec.Code, "one token refills every 10s")
+            assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+            clock.t = clock.t.Add(time.Hour)
+            rec = login()
+            assert.Equal(t, http.StatusOK, rec.Code)
+            assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"), "the bucket never holds more than the burst")
+        })
+    }
+}
+
+func TestMiddlewareSkipsRoutesWithoutPolicies(t *testing.T) {
+    cfg := config.RateLimitConfig{
+        Policies: map[string]config.RateLimitPolicy{
+            "POST /api/login": {Requests: 0},
+            "POST /api/chirps": {Requests: 1, Period: time.Minute, Key: "ip"},
+        },
+    }
+    _, _, h := newLimiter(t, NewMemoryStore(), cfg)
+
+    for range 3 {
+        rec := send(h, http.MethodPost, "/api/login", nil)
+        assert.Equal(t, http.StatusOK, rec.Code)
+        assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+
+        rec = send(h, http.MethodGet, "/api/chirps", nil)
+        assert.Equal(t, http.StatusOK, rec.Code)
+        assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+    }
+}
+
+func TestMiddlewareNamesRejectedRoutes(t *testing.T) {
+    _, err := tracing.Setup(context.Background(), config.TracingConfig{Exporter: "none"})
+    require.NoError(t, err)
+    recorder := tracetest.NewSpanRecorder()
+    previous := otel.GetTracerProvider()
+    otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+    t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+    cfg := config.RateLimitConfig{
+        Policies: map[string]config.RateLimitPolicy{
+            "POST /api/login": {Requests: 1, Period: time.Minute, Key: "ip"},
+        },
+    }
+    _, _, h := newLimiter(t, NewMemoryStore(), cfg)
+    h = tracing.Middleware(h)
+
+    send(h, http.MethodPost, "/api/login", nil)
+    rec := send(h, http.MethodPost, "/api/login", nil)
+    assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+    spans := recorder.Ended()
+    require.Len(t, spans, 2)
+    assert.Equal(t, "POST /api/login", spans[1].Name(), "the rejected request is named after its route")
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, now time.Time, interval, window time.Duration) (time.Time, bool, error) {
+    return time.Time{}, false, errors.New("connection refused")
+}
+
+func TestMiddlewareAllowsRequestsWhenTheStoreFails(t *testing.T) {
+    cfg := config.RateLimitConfig{
+        Policies: map[string]config.RateLimitPolicy{
+            "POST /api/login": {Requests: 1, Period: time.Minute, Key: "ip"},
+        },
+    }
+    _, _, h := newLimiter(t, failingStore{}, cfg)
+
+    for range 2 {
+        rec := send(h, http.MethodPost, "/api/login", nil)
+        assert.Equal(t, http.StatusOK, rec.Code)
+    }
+}
+
+func TestKeys(t *testing.T) {
+    cfg := config.RateLimitConfig{
+        Policies: map[string]config.RateLimitPolicy{
+            "POST /api/chirps": {Requests: 1, Period: time.Minute, Key: "user"},
+            "POST /api/login": {Requests: 1, Period: time.Minute, Key: "api_key"},
+        },
+    }
+
+    t.Run("Users are limited separately", func(t *testing.T) {
+        _, _, h := newLimiter(t, NewMemoryStore(), cfg)
+        post := func(userID uuid.UUID) int {
+            token, err := auth.MakeJWT(userID, auth.RoleUser, secret, time.Hour)
+            require.NoError(t, err)
+            return send(h, http.MethodPost, "/api/chirps", func(req *http.Request) {
+                req.Header.Set("Authorization", "Bearer "+token)
+            }).Code
+        }
+
+        alice, bob := uuid.New(), uuid.New()
+        assert.Equal(t, http.StatusOK, post(alice))
+        assert.Equal(t, http.StatusTooManyRequests, post(alice))
+        assert.Equal(t, http.StatusOK, post(bob))
+    })
+
+    t.Run("Invalid tokens fall back to the client IP", func(t *testing.T) {
+        _, _, h := newLimiter(t, NewMemoryStore(), cfg)
+        post := func(token string) int {
+            return send(h, http.MethodPost, "/api/chirps", func(req *http.Request) {
+                req.Header.Set("Authorization", "Bearer "+token)
+            }).Code
+        }
+
+        assert.Equal(t, http.StatusOK, post("forged-1"))
+        assert.Equal(t, http.StatusTooManyRequests, post("forged-2"))
+    })
+
+    t.Run("API keys are limited separately", func(t *testing.T) {
+        _, _, h := newLimiter(t, NewMemoryStore(), cfg)
+        post := func(key string) int {
+            return send(h, http.MethodPost, "/api/login", func(req *http.Request) {
+                req.Header.Set("Authorization", "ApiKey "+key)
+            }).Code
+        }
+
+        assert.Equal(t, http.StatusOK, post("key-1"))
+        assert.Equal(t, http.StatusTooManyRequests, post("key-1"))
+        assert.Equal(t, http.StatusOK, post("key-2"))
+    })
+}
+
+func TestClientIP(t *testing.T) {
+    l, err := New(NewMemoryStore(), config.RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}}, secret, metrics.New(nil))
+    require.NoError(t, err)
+
+    tests := []struct{
+        name string
+        remoteAddr string
+        forwardedFor []string
+        want string
+    }{
+        {"Untrusted peers are the client", "203.0.113.7:4000", []string{"198.51.100.1"}, "203.0.113.7"},
+        {"Trusted proxies are skipped", "10.1.2.3:4000", []string{"198.51.100.1, 192.0.2.1"}, "198.51.100.1"},
+        {"Spoofed entries left of the client are ignored", "10.1.2.3:4000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+        {"Repeated headers are joined", "10.1.2.3:4000", []string{"1.1.1.1", "198.51.100.1, 10.0.0.1"}, "198.51.100.1"},
+        {"Garbage stops the walk", "10.1.2.3:4000", []string{"198.51.100.1, junk"}, "10.1.2.3"},
+        {"A proxy without the header is the client", "10.1.2.3:4000", nil, "10.1.2.3"},
+        {"Only proxies", "10.1.2.3:4000", []string{"10.0.0.2"}, "10.0.0.2"},
+        {"IPv6", "[2001:db8::1]:4000", nil, "2001:db8::1"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/", nil)
+            req.RemoteAddr = tt.remoteAddr
+            for _, value := range tt.forwardedFor {
+                req.Header.Add("X-Forwarded-For", value)
+            }
+
+            assert.Equal(t, tt.want, l.ClientIP(req))
+        })
+    }
+}
+
+func TestDBStorePrunesExpiredKeys(t *testing.T) {
+    ctx := context.Background()
+    mem := store.NewMemory()
+    s := NewDBStore(mem)
+    start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    _, ok, err := s.Take(ctx, "old", start, time.Second, time.Second)
+    require.NoError(t, err)
+    require.True(t, ok)
+
+    _, _, err = s.Take(ctx, "new", start.Add(pruneInterval), time.Second, time.Second)
+    require.NoError(t, err)
+
+    _, err = mem.GetRateLimit(ctx, "old")
+    assert.Error(t, err)
+    _, err = mem.GetRateLimit(ctx, "new")
+    assert.NoError(t, err)
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/store"
+)
+
+// pruneInterval is how often the stores delete keys that are back to a full
+// burst, which behave exactly like keys that were never seen.
+const pruneInterval = time.Minute
+
+// pruneBatchSize bounds the rows one prune deletes from the database, so the
+// request that runs it is not held up for long.
+const pruneBatchSize = 1000
+
+// Store holds each key's theoretical arrival time (TAT): the time at which
+// the key's bucket will be full again.
+type Store interface {
+    // Take advances key's TAT to max(TAT, now)+interval, unless that is later
+    // than now+window. It returns the new TAT and true, or the unchanged TAT
+    // and false when the request is over the limit.
+    Take(ctx context.Context, key string, now time.Time, interval, window time.Duration) (time.Time, bool, error)
+}
+
+// MemoryStore keeps the limits in process memory, so each server enforces
+// them separately. It is safe for concurrent use.
+type MemoryStore struct {
+    mu sync.Mutex
+    tats map[string]time.Time
+    lastPrune time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{tats: make(map[string]time.Time)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, now time.Time, interval, window time.Duration) (time.Time, bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if now.Sub(s.lastPrune) >= pruneInterval {
+        for k, tat := range s.tats {
+            if tat.Before(now) {
+                delete(s.tats, k)
+            }
+        }
+        s.lastPrune = now
+    }
+
+    tat := s.tats[key]
+    next := now
+    if tat.After(now) {
+        next = tat
+    }
+    next = next.Add(interval)
+    if next.After(now.Add(window)) {
+        return tat, false, nil
+    }
+    s.tats[key] = next
+    return next, true, nil
+}
+
+// DBStore keeps the limits in the rate_limits table, so every server using
+// the database shares them. Each Take is a single upsert.
+type DBStore struct {
+    store store.RateLimitStore
+    lastPrune atomic.Int64
+}
+
+func NewDBStore(s store.RateLimitStore) *DBStore {
+    return &DBStore{store: s}
+}
+
+func (s *DBStore) Take(ctx context.Context, key string, now time.Time, interval, window time.Duration) (time.Time, bool, error) {
+    s.prune(ctx, now)
+
+    tat, err := s.store.TakeRateLimit(ctx, database.TakeRateLimitParams{
+        Key: key,
+        Now: now.UnixMicro(),
+        EmissionInterval: interval.Microseconds(),
+        Window: window.Microseconds(),
+    })
+    if err == nil {
+        return time.UnixMicro(tat), true, nil
+    }
+    if !errors.Is(err, sql.ErrNoRows) {
+        return time.Time{}, false, err
+    }
+
+    tat, err = s.store.GetRateLimit(ctx, key)
+    if err != nil {
+        return time.Time{}, false, err
+    }
+    return time.UnixMicro(tat), false, nil
+}
+
+// prune deletes one batch of expired keys, at most once per pruneInterval
+// across all the requests this server handles. Failures are only logged; the
+// next prune tries again.
+func (s *DBStore) prune(ctx context.Context, now time.Time) {
+    last := s.lastPrune.Load()
+    if now.UnixMicro()-last < pruneInterval.Microseconds() || !s.lastPrune.CompareAndSwap(last, now.UnixMicro()) {
+        return
+    }
+
+    _, err := s.store.DeleteExpiredRateLimits(ctx, database.DeleteExpiredRateLimitsParams{
+        Now: now.UnixMicro(),
+        BatchSize: pruneBatchSize,
+    })
+    if err != nil {
+        logging.FromContext(ctx).Error("failed to delete expired rate limits", "error", err)
+    }
+}
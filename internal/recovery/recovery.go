@@ -0,0 +1,64 @@
+// Package recovery keeps a panicking handler from taking down the server.
+package recovery
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+)
+
+type responseRecorder struct {
+    http.ResponseWriter
+    wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+    r.wroteHeader = true
+    r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+    r.wroteHeader = true
+    return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+    return r.ResponseWriter
+}
+
+// Middleware recovers from panics in next, logs them with their stack and
+// responds with a 500 problem if nothing has been written yet. Panics with
+// http.ErrAbortHandler are passed on, since they are how a handler asks for
+// the connection to be dropped.
+func Middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        rec := &responseRecorder{ResponseWriter: w}
+        defer func() {
+            v := recover()
+            if v == nil {
+                return
+            }
+            if v == http.ErrAbortHandler {
+                panic(v)
+            }
+
+            logging.FromContext(req.Context()).Error("handler panicked",
+                "method", req.Method,
+                "path", req.URL.Path,
+                "panic", v,
+                "stack", string(debug.Stack()),
+            )
+            if rec.wroteHeader {
+                // The client already has part of a response, so the best
+                // we can do is cut it short.
+                panic(http.ErrAbortHandler)
+            }
+            problem.Write(w, req, problem.Internal("an internal error occurred", fmt.Errorf("panic: %v", v)))
+        }()
+
+        next.ServeHTTP(rec, req)
+    })
+}
@@ -0,0 +1,68 @@
+package recovery
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+    var buf bytes.Buffer
+    logger, err := logging.New(&buf, "json", "info")
+    require.NoError(t, err)
+
+    serve := func(h http.HandlerFunc) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/api/chirps/nope", nil)
+        req = req.WithContext(logging.WithLogger(req.Context(), logger))
+        rec := httptest.NewRecorder()
+        Middleware(h).ServeHTTP(rec, req)
+        return rec
+    }
+
+    t.Run("Panics become a 500 problem", func(t *testing.T) {
+        buf.Reset()
+
+        rec := serve(func(w http.ResponseWriter, req *http.Request) {
+            var m map[string]int
+            m["boom"]++
+        })
+
+        assert.Equal(t, http.StatusInternalServerError, rec.Code)
+        assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+        assert.Contains(t, rec.Body.String(), `"code":"internal_error"`)
+        assert.NotContains(t, rec.Body.String(), "nil map")
+        assert.Contains(t, buf.String(), `"msg":"handler panicked"`)
+        assert.Contains(t, buf.String(), "recovery_test.go")
+    })
+
+    t.Run("Partial responses are aborted", func(t *testing.T) {
+        assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+            serve(func(w http.ResponseWriter, req *http.Request) {
+                w.WriteHeader(http.StatusOK)
+                panic("halfway")
+            })
+        })
+    })
+
+    t.Run("Aborts are passed on", func(t *testing.T) {
+        assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+            serve(func(w http.ResponseWriter, req *http.Request) {
+                panic(http.ErrAbortHandler)
+            })
+        })
+    })
+
+    t.Run("Handlers that don't panic are untouched", func(t *testing.T) {
+        rec := serve(func(w http.ResponseWriter, req *http.Request) {
+            w.WriteHeader(http.StatusTeapot)
+        })
+
+        assert.Equal(t, http.StatusTeapot, rec.Code)
+    })
+}
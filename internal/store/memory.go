@@ -0,0 +1,1066 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// claimLease matches the lease ClaimDueWebhookDeliveries and ClaimDueJobs take
+// in Postgres.
+const claimLease = 5 * time.Minute
+
+var (
+    errDuplicateEmail = errors.New("duplicate key value violates unique constraint \"users_email_key\"")
+    errDuplicateToken = errors.New("duplicate key value violates unique constraint \"refresh_tokens_pkey\"")
+    errDuplicateID = errors.New("duplicate key value violates primary key constraint on id")
+    errUnknownUser = errors.New("insert violates foreign key constraint on user_id")
+    errUnknownWebhook = errors.New("insert violates foreign key constraint on webhook_id")
+    errUnknownDelivery = errors.New("insert violates foreign key constraint on delivery_id")
+)
+
+// Memory is an in-memory Store with the same semantics as the Postgres
+// queries: missing rows are reported with sql.ErrNoRows, unique and foreign
+// keys are enforced, deleting users cascades, and lists share the queries'
+// ordering. It is safe for concurrent use.
+type Memory struct {
+    mu sync.Mutex
+    // txMu serialises units of work; see InTx.
+    txMu sync.Mutex
+    tables
+}
+
+type tables struct {
+    users []database.User
+    roleChanges []database.RoleChange
+    chirps []database.Chirp
+    tokens []database.RefreshToken
+    webhooks []database.Webhook
+    deliveries []database.WebhookDelivery
+    attempts []database.WebhookDeliveryAttempt
+    jobs []database.Job
+    rateLimits map[string]int64
+    auditEvents []database.AuditEvent
+}
+
+func NewMemory() *Memory {
+    return &Memory{}
+}
+
+// snapshot copies every table. Rows are values, so copying the slices is
+// enough to undo later writes.
+func (m *Memory) snapshot() tables {
+    return tables{
+        users: slices.Clone(m.users),
+        roleChanges: slices.Clone(m.roleChanges),
+        chirps: slices.Clone(m.chirps),
+        tokens: slices.Clone(m.tokens),
+        webhooks: slices.Clone(m.webhooks),
+        deliveries: slices.Clone(m.deliveries),
+        attempts: slices.Clone(m.attempts),
+        jobs: slices.Clone(m.jobs),
+        rateLimits: maps.Clone(m.rateLimits),
+        auditEvents: slices.Clone(m.auditEvents),
+    }
+}
+
+func (m *Memory) restore(t tables) {
+    m.tables = t
+}
+
+// now mirrors NOW() stored in a TIMESTAMP column, which keeps microseconds.
+func now() time.Time {
+    return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, u := range m.users {
+        if u.Email == arg.Email {
+            return database.User{}, errDuplicateEmail
+        }
+    }
+
+    ts := now()
+    user := database.User{
+        ID: uuid.New(),
+        CreatedAt: ts,
+        UpdatedAt: ts,
+        Email: arg.Email,
+        HashedPassword: arg.HashedPassword,
+        Role: "user",
+    }
+    m.users = append(m.users, user)
+    return user, nil
+}
+
+func (m *Memory) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.userIndex(id)
+    if i < 0 {
+        return database.User{}, sql.ErrNoRows
+    }
+    return m.users[i], nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, u := range m.users {
+        if u.Email == email {
+            return u, nil
+        }
+    }
+    return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, u := range m.users {
+        if u.Email == arg.Email && u.ID != arg.ID {
+            return database.User{}, errDuplicateEmail
+        }
+    }
+
+    i := m.userIndex(arg.ID)
+    if i < 0 {
+        return database.User{}, sql.ErrNoRows
+    }
+    m.users[i].Email = arg.Email
+    m.users[i].HashedPassword = arg.HashedPassword
+    m.users[i].UpdatedAt = now()
+    return m.users[i], nil
+}
+
+func (m *Memory) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+    return m.updateUser(arg.ID, func(u *database.User) {
+        u.HashedPassword = arg.HashedPassword
+        u.UpdatedAt = now()
+    })
+}
+
+func (m *Memory) UpgradeUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.userIndex(id)
+    if i < 0 {
+        return database.User{}, sql.ErrNoRows
+    }
+    m.users[i].IsChirpyRed = true
+    return m.users[i], nil
+}
+
+func (m *Memory) SetUserChirpyRed(ctx context.Context, arg database.SetUserChirpyRedParams) (database.User, error) {
+    return m.updateUser(arg.ID, func(u *database.User) {
+        u.IsChirpyRed = arg.IsChirpyRed
+        u.UpdatedAt = now()
+    })
+}
+
+func (m *Memory) SetUserDisabledAt(ctx context.Context, arg database.SetUserDisabledAtParams) (database.User, error) {
+    return m.updateUser(arg.ID, func(u *database.User) {
+        u.DisabledAt = arg.DisabledAt
+        if u.DisabledAt.Valid {
+            u.DisabledAt.Time = u.DisabledAt.Time.UTC().Truncate(time.Microsecond)
+        }
+        u.UpdatedAt = now()
+    })
+}
+
+func (m *Memory) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+    return m.updateUser(arg.ID, func(u *database.User) {
+        u.Role = arg.Role
+        u.UpdatedAt = now()
+    })
+}
+
+// CreateRoleChange records a change of role. Like the table, the log has no
+// foreign keys, so it accepts and keeps entries for any user.
+func (m *Memory) CreateRoleChange(ctx context.Context, arg database.CreateRoleChangeParams) (database.RoleChange, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    change := database.RoleChange{
+        ID: uuid.New(),
+        CreatedAt: now(),
+        UserID: arg.UserID,
+        OldRole: arg.OldRole,
+        NewRole: arg.NewRole,
+        ChangedBy: arg.ChangedBy,
+        Reason: arg.Reason,
+    }
+    m.roleChanges = append(m.roleChanges, change)
+    return change, nil
+}
+
+func (m *Memory) ListRoleChanges(ctx context.Context, userID uuid.UUID) ([]database.RoleChange, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var changes []database.RoleChange
+    for _, c := range slices.Backward(m.roleChanges) {
+        if c.UserID == userID {
+            changes = append(changes, c)
+        }
+    }
+    return changes, nil
+}
+
+// updateUser applies update to the user with the given ID and returns the
+// updated row.
+func (m *Memory) updateUser(id uuid.UUID, update func(*database.User)) (database.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.userIndex(id)
+    if i < 0 {
+        return database.User{}, sql.ErrNoRows
+    }
+    update(&m.users[i])
+    return m.users[i], nil
+}
+
+// DeleteUser deletes a user along with the rows that cascade from them.
+func (m *Memory) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.userIndex(id)
+    if i < 0 {
+        return 0, nil
+    }
+    m.users = slices.Delete(m.users, i, i+1)
+    m.chirps = slices.DeleteFunc(m.chirps, func(c database.Chirp) bool { return c.UserID == id })
+    m.tokens = slices.DeleteFunc(m.tokens, func(t database.RefreshToken) bool { return t.UserID == id })
+
+    var webhooks, deliveries []uuid.UUID
+    m.webhooks = slices.DeleteFunc(m.webhooks, func(w database.Webhook) bool {
+        if w.UserID == id {
+            webhooks = append(webhooks, w.ID)
+            return true
+        }
+        return false
+    })
+    m.deliveries = slices.DeleteFunc(m.deliveries, func(d database.WebhookDelivery) bool {
+        if slices.Contains(webhooks, d.WebhookID) {
+            deliveries = append(deliveries, d.ID)
+            return true
+        }
+        return false
+    })
+    m.attempts = slices.DeleteFunc(m.attempts, func(a database.WebhookDeliveryAttempt) bool {
+        return slices.Contains(deliveries, a.DeliveryID)
+    })
+    return 1, nil
+}
+
+// DeleteUsers deletes every user along with the rows that cascade from them.
+func (m *Memory) DeleteUsers(ctx context.Context) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    // Jobs, rate limits and the role change log don't reference users, so
+    // they survive.
+    m.tables = tables{roleChanges: m.roleChanges, jobs: m.jobs, rateLimits: m.rateLimits, auditEvents: m.auditEvents}
+    return nil
+}
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.userIndex(arg.UserID) < 0 {
+        return database.Chirp{}, errUnknownUser
+    }
+
+    ts := now()
+    chirp := database.Chirp{
+        ID: uuid.New(),
+        UserID: arg.UserID,
+        CreatedAt: ts,
+        UpdatedAt: ts,
+        Body: arg.Body,
+    }
+    m.chirps = append(m.chirps, chirp)
+    return chirp, nil
+}
+
+func (m *Memory) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, c := range m.chirps {
+        if c.ID == id {
+            return c, nil
+        }
+    }
+    return database.Chirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) ListChirps(ctx context.Context) ([]database.Chirp, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return slices.Clone(m.chirps), nil
+}
+
+func (m *Memory) ListChirpsByUser(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var chirps []database.Chirp
+    for _, c := range m.chirps {
+        if c.UserID == userID {
+            chirps = append(chirps, c)
+        }
+    }
+    return chirps, nil
+}
+
+// ListChirpsPage returns the chirps by AuthorID, or every chirp when it isn't
+// set, ordered by creation and then ID, skipping SkipRows of them and
+// returning up to MaxRows.
+func (m *Memory) ListChirpsPage(ctx context.Context, arg database.ListChirpsPageParams) ([]database.Chirp, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var chirps []database.Chirp
+    for _, c := range m.chirps {
+        if !arg.AuthorID.Valid || c.UserID == arg.AuthorID.UUID {
+            chirps = append(chirps, c)
+        }
+    }
+    slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+        if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+            if arg.Descending {
+                return -c
+            }
+            return c
+        }
+        return slices.Compare(a.ID[:], b.ID[:])
+    })
+    chirps = chirps[min(int(arg.SkipRows), len(chirps)):]
+    if arg.MaxRows.Valid {
+        chirps = chirps[:min(int(arg.MaxRows.Int32), len(chirps))]
+    }
+    return chirps, nil
+}
+
+func (m *Memory) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.chirps = slices.DeleteFunc(m.chirps, func(c database.Chirp) bool { return c.ID == id })
+    return nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.userIndex(arg.UserID) < 0 {
+        return database.RefreshToken{}, errUnknownUser
+    }
+    if m.tokenIndex(arg.Token) >= 0 {
+        return database.RefreshToken{}, errDuplicateToken
+    }
+
+    ts := now()
+    token := database.RefreshToken{
+        Token: arg.Token,
+        CreatedAt: ts,
+        UpdatedAt: ts,
+        UserID: arg.UserID,
+        ExpiresAt: arg.ExpiresAt,
+    }
+    m.tokens = append(m.tokens, token)
+    return token, nil
+}
+
+func (m *Memory) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.tokenIndex(token)
+    if i < 0 {
+        return database.RefreshToken{}, sql.ErrNoRows
+    }
+    return m.tokens[i], nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.tokenIndex(token)
+    if i < 0 {
+        return database.RefreshToken{}, sql.ErrNoRows
+    }
+    ts := now()
+    m.tokens[i].UpdatedAt = ts
+    m.tokens[i].RevokedAt = sql.NullTime{Time: ts, Valid: true}
+    return m.tokens[i], nil
+}
+
+func (m *Memory) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    ts := now()
+    for i, t := range m.tokens {
+        if t.UserID == userID && !t.RevokedAt.Valid {
+            m.tokens[i].UpdatedAt = ts
+            m.tokens[i].RevokedAt = sql.NullTime{Time: ts, Valid: true}
+        }
+    }
+    return nil
+}
+
+// ListUserRefreshTokens lists a user's tokens, newest first.
+func (m *Memory) ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var tokens []database.RefreshToken
+    for _, t := range slices.Backward(m.tokens) {
+        if t.UserID == userID {
+            tokens = append(tokens, t)
+        }
+    }
+    return tokens, nil
+}
+
+// DeleteExpiredRefreshTokens deletes up to limit tokens past their expiry.
+func (m *Memory) DeleteExpiredRefreshTokens(ctx context.Context, limit int32) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    ts := now()
+    return m.deleteTokens(limit, func(t database.RefreshToken) bool { return t.ExpiresAt.Before(ts) }), nil
+}
+
+// DeleteRevokedRefreshTokens deletes up to BatchSize tokens revoked before
+// RevokedBefore.
+func (m *Memory) DeleteRevokedRefreshTokens(ctx context.Context, arg database.DeleteRevokedRefreshTokensParams) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return m.deleteTokens(arg.BatchSize, func(t database.RefreshToken) bool {
+        return t.RevokedAt.Valid && t.RevokedAt.Time.Before(arg.RevokedBefore)
+    }), nil
+}
+
+func (m *Memory) deleteTokens(limit int32, match func(database.RefreshToken) bool) int64 {
+    var deleted int64
+    m.tokens = slices.DeleteFunc(m.tokens, func(t database.RefreshToken) bool {
+        if deleted < int64(limit) && match(t) {
+            deleted++
+            return true
+        }
+        return false
+    })
+    return deleted
+}
+
+func (m *Memory) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.userIndex(arg.UserID) < 0 {
+        return database.Webhook{}, errUnknownUser
+    }
+
+    ts := now()
+    hook := database.Webhook{
+        ID: uuid.New(),
+        CreatedAt: ts,
+        UpdatedAt: ts,
+        UserID: arg.UserID,
+        Url: arg.Url,
+        Secret: arg.Secret,
+        Events: slices.Clone(arg.Events),
+    }
+    m.webhooks = append(m.webhooks, hook)
+    return cloneWebhook(hook), nil
+}
+
+func (m *Memory) GetWebhook(ctx context.Context, id uuid.UUID) (database.Webhook, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.webhookIndex(id)
+    if i < 0 {
+        return database.Webhook{}, sql.ErrNoRows
+    }
+    return cloneWebhook(m.webhooks[i]), nil
+}
+
+func (m *Memory) ListWebhooksByUser(ctx context.Context, userID uuid.UUID) ([]database.Webhook, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var hooks []database.Webhook
+    for _, h := range m.webhooks {
+        if h.UserID == userID {
+            hooks = append(hooks, cloneWebhook(h))
+        }
+    }
+    return hooks, nil
+}
+
+func (m *Memory) ListActiveWebhooksForEvent(ctx context.Context, event string) ([]database.Webhook, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var hooks []database.Webhook
+    for _, h := range m.webhooks {
+        if !h.DisabledAt.Valid && slices.Contains(h.Events, event) {
+            hooks = append(hooks, cloneWebhook(h))
+        }
+    }
+    return hooks, nil
+}
+
+func (m *Memory) DeleteWebhook(ctx context.Context, arg database.DeleteWebhookParams) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.webhookIndex(arg.ID)
+    if i < 0 || m.webhooks[i].UserID != arg.UserID {
+        return 0, nil
+    }
+    m.webhooks = slices.Delete(m.webhooks, i, i+1)
+
+    var deleted []uuid.UUID
+    m.deliveries = slices.DeleteFunc(m.deliveries, func(d database.WebhookDelivery) bool {
+        if d.WebhookID == arg.ID {
+            deleted = append(deleted, d.ID)
+            return true
+        }
+        return false
+    })
+    m.attempts = slices.DeleteFunc(m.attempts, func(a database.WebhookDeliveryAttempt) bool {
+        return slices.Contains(deleted, a.DeliveryID)
+    })
+    return 1, nil
+}
+
+func (m *Memory) RecordWebhookSuccess(ctx context.Context, id uuid.UUID) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if i := m.webhookIndex(id); i >= 0 {
+        m.webhooks[i].ConsecutiveFailures = 0
+        m.webhooks[i].UpdatedAt = now()
+    }
+    return nil
+}
+
+func (m *Memory) RecordWebhookFailure(ctx context.Context, id uuid.UUID) (database.Webhook, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.webhookIndex(id)
+    if i < 0 {
+        return database.Webhook{}, sql.ErrNoRows
+    }
+    m.webhooks[i].ConsecutiveFailures++
+    m.webhooks[i].UpdatedAt = now()
+    return cloneWebhook(m.webhooks[i]), nil
+}
+
+func (m *Memory) DisableWebhook(ctx context.Context, id uuid.UUID) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if i := m.webhookIndex(id); i >= 0 {
+        ts := now()
+        m.webhooks[i].DisabledAt = sql.NullTime{Time: ts, Valid: true}
+        m.webhooks[i].UpdatedAt = ts
+    }
+    return nil
+}
+
+func (m *Memory) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.webhookIndex(arg.WebhookID) < 0 {
+        return database.WebhookDelivery{}, errUnknownWebhook
+    }
+
+    ts := now()
+    delivery := database.WebhookDelivery{
+        ID: uuid.New(),
+        CreatedAt: ts,
+        UpdatedAt: ts,
+        WebhookID: arg.WebhookID,
+        Event: arg.Event,
+        Payload: slices.Clone(arg.Payload),
+        Status: "pending",
+        NextAttemptAt: ts,
+        TraceContext: arg.TraceContext,
+    }
+    m.deliveries = append(m.deliveries, delivery)
+    return delivery, nil
+}
+
+// ClaimDueWebhookDeliveries leases up to limit due deliveries, oldest first,
+// by pushing their next attempt into the future.
+func (m *Memory) ClaimDueWebhookDeliveries(ctx context.Context, limit int32) ([]database.WebhookDelivery, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    ts := now()
+    var due []int
+    for i, d := range m.deliveries {
+        if d.Status == "pending" && !d.NextAttemptAt.After(ts) {
+            due = append(due, i)
+        }
+    }
+    sort.SliceStable(due, func(a, b int) bool {
+        return m.deliveries[due[a]].NextAttemptAt.Before(m.deliveries[due[b]].NextAttemptAt)
+    })
+    if len(due) > int(limit) {
+        due = due[:limit]
+    }
+
+    claimed := make([]database.WebhookDelivery, 0, len(due))
+    for _, i := range due {
+        m.deliveries[i].NextAttemptAt = ts.Add(claimLease)
+        m.deliveries[i].UpdatedAt = ts
+        claimed = append(claimed, m.deliveries[i])
+    }
+    return claimed, nil
+}
+
+func (m *Memory) MarkWebhookDeliverySucceeded(ctx context.Context, id uuid.UUID) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if i := m.deliveryIndex(id); i >= 0 {
+        ts := now()
+        m.deliveries[i].Status = "succeeded"
+        m.deliveries[i].Attempts++
+        m.deliveries[i].DeliveredAt = sql.NullTime{Time: ts, Valid: true}
+        m.deliveries[i].UpdatedAt = ts
+    }
+    return nil
+}
+
+func (m *Memory) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if i := m.deliveryIndex(arg.ID); i >= 0 {
+        m.deliveries[i].Status = arg.Status
+        m.deliveries[i].Attempts++
+        m.deliveries[i].NextAttemptAt = arg.NextAttemptAt
+        m.deliveries[i].UpdatedAt = now()
+    }
+    return nil
+}
+
+// ListWebhookDeliveries returns a webhook's deliveries, newest first.
+func (m *Memory) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var deliveries []database.WebhookDelivery
+    for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < int(arg.Limit); i-- {
+        if m.deliveries[i].WebhookID == arg.WebhookID {
+            deliveries = append(deliveries, m.deliveries[i])
+        }
+    }
+    return deliveries, nil
+}
+
+func (m *Memory) CreateWebhookDeliveryAttempt(ctx context.Context, arg database.CreateWebhookDeliveryAttemptParams) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.deliveryIndex(arg.DeliveryID) < 0 {
+        return errUnknownDelivery
+    }
+
+    m.attempts = append(m.attempts, database.WebhookDeliveryAttempt{
+        ID: uuid.New(),
+        CreatedAt: now(),
+        DeliveryID: arg.DeliveryID,
+        ResponseCode: arg.ResponseCode,
+        Error: arg.Error,
+        DurationMs: arg.DurationMs,
+    })
+    return nil
+}
+
+func (m *Memory) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]database.WebhookDeliveryAttempt, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var attempts []database.WebhookDeliveryAttempt
+    for _, a := range m.attempts {
+        if a.DeliveryID == deliveryID {
+            attempts = append(attempts, a)
+        }
+    }
+    return attempts, nil
+}
+
+func (m *Memory) EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (database.Job, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    ts := now()
+    job := database.Job{
+        ID: uuid.New(),
+        CreatedAt: ts,
+        UpdatedAt: ts,
+        Kind: arg.Kind,
+        Payload: slices.Clone(arg.Payload),
+        Status: "pending",
+        RunAt: arg.RunAt.UTC().Truncate(time.Microsecond),
+    }
+    m.jobs = append(m.jobs, job)
+    return cloneJob(job), nil
+}
+
+// ClaimDueJobs marks up to limit due jobs running, oldest first, counting the
+// attempt and leasing them by pushing run_at into the future. Running jobs
+// whose lease has ended are due again.
+func (m *Memory) ClaimDueJobs(ctx context.Context, limit int32) ([]database.Job, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    ts := now()
+    var due []int
+    for i, j := range m.jobs {
+        if (j.Status == "pending" || j.Status == "running") && !j.RunAt.After(ts) {
+            due = append(due, i)
+        }
+    }
+    sort.SliceStable(due, func(a, b int) bool {
+        return m.jobs[due[a]].RunAt.Before(m.jobs[due[b]].RunAt)
+    })
+    if len(due) > int(limit) {
+        due = due[:limit]
+    }
+
+    claimed := make([]database.Job, 0, len(due))
+    for _, i := range due {
+        m.jobs[i].Status = "running"
+        m.jobs[i].Attempts++
+        m.jobs[i].RunAt = ts.Add(claimLease)
+        m.jobs[i].UpdatedAt = ts
+        claimed = append(claimed, cloneJob(m.jobs[i]))
+    }
+    return claimed, nil
+}
+
+func (m *Memory) CompleteJob(ctx context.Context, id uuid.UUID) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if i := m.jobIndex(id); i >= 0 {
+        ts := now()
+        m.jobs[i].Status = "succeeded"
+        m.jobs[i].LastError = sql.NullString{}
+        m.jobs[i].FinishedAt = sql.NullTime{Time: ts, Valid: true}
+        m.jobs[i].UpdatedAt = ts
+    }
+    return nil
+}
+
+func (m *Memory) ScheduleJobRetry(ctx context.Context, arg database.ScheduleJobRetryParams) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if i := m.jobIndex(arg.ID); i >= 0 {
+        m.jobs[i].Status = "pending"
+        m.jobs[i].RunAt = arg.RunAt.UTC().Truncate(time.Microsecond)
+        m.jobs[i].LastError = arg.LastError
+        m.jobs[i].UpdatedAt = now()
+    }
+    return nil
+}
+
+func (m *Memory) DeadLetterJob(ctx context.Context, arg database.DeadLetterJobParams) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if i := m.jobIndex(arg.ID); i >= 0 {
+        ts := now()
+        m.jobs[i].Status = "dead"
+        m.jobs[i].LastError = arg.LastError
+        m.jobs[i].FinishedAt = sql.NullTime{Time: ts, Valid: true}
+        m.jobs[i].UpdatedAt = ts
+    }
+    return nil
+}
+
+// RequeueDeadJob moves a dead job back to pending with its attempts reset. It
+// reports sql.ErrNoRows if the job doesn't exist or isn't dead.
+func (m *Memory) RequeueDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.jobIndex(id)
+    if i < 0 || m.jobs[i].Status != "dead" {
+        return database.Job{}, sql.ErrNoRows
+    }
+    ts := now()
+    m.jobs[i].Status = "pending"
+    m.jobs[i].Attempts = 0
+    m.jobs[i].RunAt = ts
+    m.jobs[i].FinishedAt = sql.NullTime{}
+    m.jobs[i].UpdatedAt = ts
+    return cloneJob(m.jobs[i]), nil
+}
+
+func (m *Memory) GetJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    i := m.jobIndex(id)
+    if i < 0 {
+        return database.Job{}, sql.ErrNoRows
+    }
+    return cloneJob(m.jobs[i]), nil
+}
+
+// ListJobs returns up to MaxRows jobs with the given status, or of any status
+// if it is empty, newest first.
+func (m *Memory) ListJobs(ctx context.Context, arg database.ListJobsParams) ([]database.Job, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var jobs []database.Job
+    for i := len(m.jobs) - 1; i >= 0 && len(jobs) < int(arg.MaxRows); i-- {
+        if arg.Status == "" || m.jobs[i].Status == arg.Status {
+            jobs = append(jobs, cloneJob(m.jobs[i]))
+        }
+    }
+    return jobs, nil
+}
+
+func (m *Memory) CountJobsByStatus(ctx context.Context) ([]database.CountJobsByStatusRow, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    counts := make(map[string]int64)
+    for _, j := range m.jobs {
+        counts[j.Status]++
+    }
+
+    var rows []database.CountJobsByStatusRow
+    for status, count := range counts {
+        rows = append(rows, database.CountJobsByStatusRow{Status: status, Count: count})
+    }
+    slices.SortFunc(rows, func(a, b database.CountJobsByStatusRow) int { return strings.Compare(a.Status, b.Status) })
+    return rows, nil
+}
+
+// TakeRateLimit advances the key's tat by EmissionInterval unless that would
+// put it more than Window past Now, in which case it reports sql.ErrNoRows.
+func (m *Memory) TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    tat := max(m.rateLimits[arg.Key], arg.Now) + arg.EmissionInterval
+    if tat > arg.Now+arg.Window {
+        return 0, sql.ErrNoRows
+    }
+    if m.rateLimits == nil {
+        m.rateLimits = make(map[string]int64)
+    }
+    m.rateLimits[arg.Key] = tat
+    return tat, nil
+}
+
+func (m *Memory) GetRateLimit(ctx context.Context, key string) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    tat, ok := m.rateLimits[key]
+    if !ok {
+        return 0, sql.ErrNoRows
+    }
+    return tat, nil
+}
+
+// DeleteExpiredRateLimits deletes up to BatchSize keys whose tat is before Now.
+func (m *Memory) DeleteExpiredRateLimits(ctx context.Context, arg database.DeleteExpiredRateLimitsParams) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var deleted int64
+    for key, tat := range m.rateLimits {
+        if deleted == int64(arg.BatchSize) {
+            break
+        }
+        if tat < arg.Now {
+            delete(m.rateLimits, key)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+// CreateAuditEvent appends to the audit log. Like the table, the log has no
+// foreign keys.
+func (m *Memory) CreateAuditEvent(ctx context.Context, arg database.CreateAuditEventParams) (database.AuditEvent, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    event := database.AuditEvent{
+        ID: uuid.New(),
+        CreatedAt: now(),
+        Action: arg.Action,
+        ActorID: arg.ActorID,
+        TargetID: arg.TargetID,
+        Ip: arg.Ip,
+        UserAgent: arg.UserAgent,
+        Metadata: slices.Clone(arg.Metadata),
+    }
+    m.auditEvents = append(m.auditEvents, event)
+    return event, nil
+}
+
+// ListAuditEvents returns the events matching every filter that is set,
+// newest first, skipping SkipRows of them and returning up to MaxRows.
+func (m *Memory) ListAuditEvents(ctx context.Context, arg database.ListAuditEventsParams) ([]database.AuditEvent, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var events []database.AuditEvent
+    skip := int(arg.SkipRows)
+    for _, e := range slices.Backward(m.auditEvents) {
+        if len(events) == int(arg.MaxRows) {
+            break
+        }
+        if arg.Action != "" && e.Action != arg.Action ||
+            arg.ActorID.Valid && e.ActorID != arg.ActorID ||
+            arg.TargetID.Valid && e.TargetID != arg.TargetID ||
+            arg.Since.Valid && e.CreatedAt.Before(arg.Since.Time) ||
+            arg.Until.Valid && !e.CreatedAt.Before(arg.Until.Time) {
+            continue
+        }
+        if skip > 0 {
+            skip--
+            continue
+        }
+        e.Metadata = slices.Clone(e.Metadata)
+        events = append(events, e)
+    }
+    return events, nil
+}
+
+// TruncateTables empties every table but the audit logs.
+func (m *Memory) TruncateTables(ctx context.Context) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.tables = tables{roleChanges: m.roleChanges, auditEvents: m.auditEvents}
+    return nil
+}
+
+func (m *Memory) SeedUser(ctx context.Context, arg database.SeedUserParams) (database.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.userIndex(arg.ID) >= 0 {
+        return database.User{}, errDuplicateID
+    }
+    for _, u := range m.users {
+        if u.Email == arg.Email {
+            return database.User{}, errDuplicateEmail
+        }
+    }
+
+    user := database.User{
+        ID: arg.ID,
+        CreatedAt: arg.CreatedAt,
+        UpdatedAt: arg.CreatedAt,
+        Email: arg.Email,
+        HashedPassword: arg.HashedPassword,
+        IsChirpyRed: arg.IsChirpyRed,
+        Role: arg.Role,
+    }
+    m.users = append(m.users, user)
+    return user, nil
+}
+
+// SeedChirp inserts the chirp in order of creation, which ListChirps relies
+// on, as seeded chirps may be older than others.
+func (m *Memory) SeedChirp(ctx context.Context, arg database.SeedChirpParams) (database.Chirp, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == arg.ID }) {
+        return database.Chirp{}, errDuplicateID
+    }
+    if m.userIndex(arg.UserID) < 0 {
+        return database.Chirp{}, errUnknownUser
+    }
+
+    chirp := database.Chirp{
+        ID: arg.ID,
+        UserID: arg.UserID,
+        CreatedAt: arg.CreatedAt,
+        UpdatedAt: arg.CreatedAt,
+        Body: arg.Body,
+    }
+    i := slices.IndexFunc(m.chirps, func(c database.Chirp) bool { return c.CreatedAt.After(chirp.CreatedAt) })
+    if i < 0 {
+        i = len(m.chirps)
+    }
+    m.chirps = slices.Insert(m.chirps, i, chirp)
+    return chirp, nil
+}
+
+func (m *Memory) userIndex(id uuid.UUID) int {
+    return slices.IndexFunc(m.users, func(u database.User) bool { return u.ID == id })
+}
+
+func (m *Memory) tokenIndex(token string) int {
+    return slices.IndexFunc(m.tokens, func(t database.RefreshToken) bool { return t.Token == token })
+}
+
+func (m *Memory) webhookIndex(id uuid.UUID) int {
+    return slices.IndexFunc(m.webhooks, func(h database.Webhook) bool { return h.ID == id })
+}
+
+func (m *Memory) deliveryIndex(id uuid.UUID) int {
+    return slices.IndexFunc(m.deliveries, func(d database.WebhookDelivery) bool { return d.ID == id })
+}
+
+func (m *Memory) jobIndex(id uuid.UUID) int {
+    return slices.IndexFunc(m.jobs, func(j database.Job) bool { return j.ID == id })
+}
+
+func cloneJob(j database.Job) database.Job {
+    j.Payload = slices.Clone(j.Payload)
+    return j
+}
+
+func cloneWebhook(h database.Webhook) database.Webhook {
+    h.Events = slices.Clone(h.Events)
+    return h
+}
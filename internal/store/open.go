@@ -0,0 +1,78 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+    DialectPostgres = "postgres"
+    DialectSQLite = "sqlite"
+)
+
+// sqlitePragmas enforce foreign keys, which SQLite leaves off by default, and
+// make concurrent readers wait for the writer instead of failing.
+const sqlitePragmas = "_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL"
+
+// Open connects to the database named by a DB_URL and reports its dialect.
+// URLs of the form sqlite:///path/to/chirpy.db, sqlite://chirpy.db or
+// sqlite::memory: select SQLite; postgres:// URLs and key=value connection
+// strings select Postgres.
+func Open(dbURL string) (*sql.DB, string, error) {
+    dialect, dsn, err := parseURL(dbURL)
+    if err != nil {
+        return nil, "", err
+    }
+
+    driver := dialect
+    if dialect == DialectSQLite {
+        driver = "sqlite3"
+    }
+    db, err := sql.Open(driver, dsn)
+    if err != nil {
+        return nil, "", err
+    }
+    if dialect == DialectSQLite {
+        // SQLite allows one writer at a time, and every connection to
+        // :memory: would otherwise be a separate database.
+        db.SetMaxOpenConns(1)
+    }
+    return db, dialect, nil
+}
+
+func parseURL(dbURL string) (string, string, error) {
+    scheme, rest, found := strings.Cut(dbURL, ":")
+    if !found || !strings.HasPrefix(rest, "//") && scheme != "sqlite" {
+        return DialectPostgres, dbURL, nil
+    }
+
+    switch scheme {
+    case "postgres", "postgresql":
+        return DialectPostgres, dbURL, nil
+    case "sqlite":
+        path := strings.TrimPrefix(rest, "//")
+        if path == "" {
+            return "", "", fmt.Errorf("sqlite URL %q has no path", dbURL)
+        }
+        sep := "?"
+        if strings.Contains(path, "?") {
+            sep = "&"
+        }
+        return DialectSQLite, "file:" + path + sep + sqlitePragmas, nil
+    default:
+        return "", "", fmt.Errorf("unsupported database scheme %q", scheme)
+    }
+}
+
+// New returns the Store for a dialect reported by Open.
+func New(dialect string, db database.DBTX) Store {
+    if dialect == DialectSQLite {
+        return NewSQLite(db)
+    }
+    return database.New(db)
+}
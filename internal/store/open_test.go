@@ -0,0 +1,38 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+    tests := []struct {
+        url string
+        dialect string
+        dsn string
+        err string
+    }{
+        {url: "postgres://chirpy@localhost/chirpy?sslmode=disable", dialect: DialectPostgres, dsn: "postgres://chirpy@localhost/chirpy?sslmode=disable"},
+        {url: "postgresql://localhost/chirpy", dialect: DialectPostgres, dsn: "postgresql://localhost/chirpy"},
+        {url: "host=localhost dbname=chirpy", dialect: DialectPostgres, dsn: "host=localhost dbname=chirpy"},
+        {url: "sqlite:///var/lib/chirpy.db", dialect: DialectSQLite, dsn: "file:/var/lib/chirpy.db?" + sqlitePragmas},
+        {url: "sqlite://chirpy.db?cache=shared", dialect: DialectSQLite, dsn: "file:chirpy.db?cache=shared&" + sqlitePragmas},
+        {url: "sqlite::memory:", dialect: DialectSQLite, dsn: "file::memory:?" + sqlitePragmas},
+        {url: "sqlite://", err: `sqlite URL "sqlite://" has no path`},
+        {url: "mysql://localhost/chirpy", err: `unsupported database scheme "mysql"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.url, func(t *testing.T) {
+            dialect, dsn, err := parseURL(tt.url)
+
+            if tt.err != "" {
+                assert.EqualError(t, err, tt.err)
+                return
+            }
+            assert.NoError(t, err)
+            assert.Equal(t, tt.dialect, dialect)
+            assert.Equal(t, tt.dsn, dsn)
+        })
+    }
+}
@@ -0,0 +1,562 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/database/sqlite"
+	"github.com/google/uuid"
+)
+
+// SQLite adapts the queries generated for SQLite to Store. SQLite has no
+// gen_random_uuid() or NOW(), so IDs and timestamps are generated here, and
+// the TEXT[] and JSONB columns are stored as JSON text.
+type SQLite struct {
+    q *sqlite.Queries
+}
+
+func NewSQLite(db sqlite.DBTX) *SQLite {
+    return &SQLite{q: sqlite.New(db)}
+}
+
+func (s *SQLite) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+    user, err := s.q.CreateUser(ctx, sqlite.CreateUserParams{
+        ID: uuid.New(),
+        Now: now(),
+        Email: arg.Email,
+        HashedPassword: arg.HashedPassword,
+    })
+    return database.User(user), err
+}
+
+func (s *SQLite) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+    user, err := s.q.GetUser(ctx, id)
+    return database.User(user), err
+}
+
+func (s *SQLite) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+    user, err := s.q.GetUserByEmail(ctx, email)
+    return database.User(user), err
+}
+
+func (s *SQLite) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+    user, err := s.q.UpdateUser(ctx, sqlite.UpdateUserParams{
+        Email: arg.Email,
+        HashedPassword: arg.HashedPassword,
+        Now: now(),
+        ID: arg.ID,
+    })
+    return database.User(user), err
+}
+
+func (s *SQLite) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+    user, err := s.q.UpdateUserPassword(ctx, sqlite.UpdateUserPasswordParams{
+        HashedPassword: arg.HashedPassword,
+        Now: now(),
+        ID: arg.ID,
+    })
+    return database.User(user), err
+}
+
+func (s *SQLite) UpgradeUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+    user, err := s.q.UpgradeUser(ctx, id)
+    return database.User(user), err
+}
+
+func (s *SQLite) SetUserChirpyRed(ctx context.Context, arg database.SetUserChirpyRedParams) (database.User, error) {
+    user, err := s.q.SetUserChirpyRed(ctx, sqlite.SetUserChirpyRedParams{
+        IsChirpyRed: arg.IsChirpyRed,
+        Now: now(),
+        ID: arg.ID,
+    })
+    return database.User(user), err
+}
+
+func (s *SQLite) SetUserDisabledAt(ctx context.Context, arg database.SetUserDisabledAtParams) (database.User, error) {
+    disabledAt := arg.DisabledAt
+    disabledAt.Time = disabledAt.Time.UTC()
+    user, err := s.q.SetUserDisabledAt(ctx, sqlite.SetUserDisabledAtParams{
+        DisabledAt: disabledAt,
+        Now: now(),
+        ID: arg.ID,
+    })
+    return database.User(user), err
+}
+
+func (s *SQLite) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+    user, err := s.q.SetUserRole(ctx, sqlite.SetUserRoleParams{
+        Role: arg.Role,
+        Now: now(),
+        ID: arg.ID,
+    })
+    return database.User(user), err
+}
+
+func (s *SQLite) CreateRoleChange(ctx context.Context, arg database.CreateRoleChangeParams) (database.RoleChange, error) {
+    change, err := s.q.CreateRoleChange(ctx, sqlite.CreateRoleChangeParams{
+        ID: uuid.New(),
+        Now: now(),
+        UserID: arg.UserID,
+        OldRole: arg.OldRole,
+        NewRole: arg.NewRole,
+        ChangedBy: arg.ChangedBy,
+        Reason: arg.Reason,
+    })
+    return database.RoleChange(change), err
+}
+
+func (s *SQLite) ListRoleChanges(ctx context.Context, userID uuid.UUID) ([]database.RoleChange, error) {
+    changes, err := s.q.ListRoleChanges(ctx, userID)
+    return convertAll(changes, func(c sqlite.RoleChange) database.RoleChange { return database.RoleChange(c) }), err
+}
+
+func (s *SQLite) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+    return s.q.DeleteUser(ctx, id)
+}
+
+func (s *SQLite) DeleteUsers(ctx context.Context) error {
+    return s.q.DeleteUsers(ctx)
+}
+
+func (s *SQLite) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+    chirp, err := s.q.CreateChirp(ctx, sqlite.CreateChirpParams{
+        ID: uuid.New(),
+        Now: now(),
+        Body: arg.Body,
+        UserID: arg.UserID,
+    })
+    return database.Chirp(chirp), err
+}
+
+func (s *SQLite) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+    chirp, err := s.q.GetChirp(ctx, id)
+    return database.Chirp(chirp), err
+}
+
+func (s *SQLite) ListChirps(ctx context.Context) ([]database.Chirp, error) {
+    chirps, err := s.q.ListChirps(ctx)
+    return convertAll(chirps, func(c sqlite.Chirp) database.Chirp { return database.Chirp(c) }), err
+}
+
+func (s *SQLite) ListChirpsByUser(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+    chirps, err := s.q.ListChirpsByUser(ctx, userID)
+    return convertAll(chirps, func(c sqlite.Chirp) database.Chirp { return database.Chirp(c) }), err
+}
+
+func (s *SQLite) ListChirpsPage(ctx context.Context, arg database.ListChirpsPageParams) ([]database.Chirp, error) {
+    chirps, err := s.q.ListChirpsPage(ctx, sqlite.ListChirpsPageParams{
+        AuthorID: arg.AuthorID,
+        Descending: arg.Descending,
+        MaxRows: arg.MaxRows,
+        SkipRows: int64(arg.SkipRows),
+    })
+    return convertAll(chirps, func(c sqlite.Chirp) database.Chirp { return database.Chirp(c) }), err
+}
+
+func (s *SQLite) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+    return s.q.DeleteChirp(ctx, id)
+}
+
+func (s *SQLite) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+    token, err := s.q.CreateRefreshToken(ctx, sqlite.CreateRefreshTokenParams{
+        Token: arg.Token,
+        Now: now(),
+        UserID: arg.UserID,
+        ExpiresAt: arg.ExpiresAt.UTC(),
+    })
+    return database.RefreshToken(token), err
+}
+
+func (s *SQLite) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+    t, err := s.q.GetRefreshToken(ctx, token)
+    return database.RefreshToken(t), err
+}
+
+func (s *SQLite) RevokeRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+    t, err := s.q.RevokeRefreshToken(ctx, sqlite.RevokeRefreshTokenParams{Now: now(), Token: token})
+    return database.RefreshToken(t), err
+}
+
+func (s *SQLite) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+    return s.q.RevokeUserRefreshTokens(ctx, sqlite.RevokeUserRefreshTokensParams{Now: now(), UserID: userID})
+}
+
+func (s *SQLite) ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+    tokens, err := s.q.ListUserRefreshTokens(ctx, userID)
+    return convertAll(tokens, func(t sqlite.RefreshToken) database.RefreshToken { return database.RefreshToken(t) }), err
+}
+
+func (s *SQLite) DeleteExpiredRefreshTokens(ctx context.Context, limit int32) (int64, error) {
+    return s.q.DeleteExpiredRefreshTokens(ctx, sqlite.DeleteExpiredRefreshTokensParams{Now: now(), BatchSize: int64(limit)})
+}
+
+func (s *SQLite) DeleteRevokedRefreshTokens(ctx context.Context, arg database.DeleteRevokedRefreshTokensParams) (int64, error) {
+    return s.q.DeleteRevokedRefreshTokens(ctx, sqlite.DeleteRevokedRefreshTokensParams{
+        RevokedBefore: sql.NullTime{Time: arg.RevokedBefore.UTC(), Valid: true},
+        BatchSize: int64(arg.BatchSize),
+    })
+}
+
+func (s *SQLite) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+    events, err := json.Marshal(arg.Events)
+    if err != nil {
+        return database.Webhook{}, err
+    }
+
+    hook, err := s.q.CreateWebhook(ctx, sqlite.CreateWebhookParams{
+        ID: uuid.New(),
+        Now: now(),
+        UserID: arg.UserID,
+        Url: arg.Url,
+        Secret: arg.Secret,
+        Events: string(events),
+    })
+    if err != nil {
+        return database.Webhook{}, err
+    }
+    return toWebhook(hook)
+}
+
+func (s *SQLite) GetWebhook(ctx context.Context, id uuid.UUID) (database.Webhook, error) {
+    hook, err := s.q.GetWebhook(ctx, id)
+    if err != nil {
+        return database.Webhook{}, err
+    }
+    return toWebhook(hook)
+}
+
+func (s *SQLite) ListWebhooksByUser(ctx context.Context, userID uuid.UUID) ([]database.Webhook, error) {
+    hooks, err := s.q.ListWebhooksByUser(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    return toWebhooks(hooks)
+}
+
+func (s *SQLite) ListActiveWebhooksForEvent(ctx context.Context, event string) ([]database.Webhook, error) {
+    rows, err := s.q.ListActiveWebhooks(ctx)
+    if err != nil {
+        return nil, err
+    }
+    hooks, err := toWebhooks(rows)
+    if err != nil {
+        return nil, err
+    }
+    return slices.DeleteFunc(hooks, func(h database.Webhook) bool { return !slices.Contains(h.Events, event) }), nil
+}
+
+func (s *SQLite) DeleteWebhook(ctx context.Context, arg database.DeleteWebhookParams) (int64, error) {
+    return s.q.DeleteWebhook(ctx, sqlite.DeleteWebhookParams{ID: arg.ID, UserID: arg.UserID})
+}
+
+func (s *SQLite) RecordWebhookSuccess(ctx context.Context, id uuid.UUID) error {
+    return s.q.RecordWebhookSuccess(ctx, sqlite.RecordWebhookSuccessParams{Now: now(), ID: id})
+}
+
+func (s *SQLite) RecordWebhookFailure(ctx context.Context, id uuid.UUID) (database.Webhook, error) {
+    hook, err := s.q.RecordWebhookFailure(ctx, sqlite.RecordWebhookFailureParams{Now: now(), ID: id})
+    if err != nil {
+        return database.Webhook{}, err
+    }
+    return toWebhook(hook)
+}
+
+func (s *SQLite) DisableWebhook(ctx context.Context, id uuid.UUID) error {
+    return s.q.DisableWebhook(ctx, sqlite.DisableWebhookParams{Now: now(), ID: id})
+}
+
+func (s *SQLite) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+    delivery, err := s.q.CreateWebhookDelivery(ctx, sqlite.CreateWebhookDeliveryParams{
+        ID: uuid.New(),
+        Now: now(),
+        WebhookID: arg.WebhookID,
+        Event: arg.Event,
+        Payload: string(arg.Payload),
+        TraceContext: arg.TraceContext,
+    })
+    return toWebhookDelivery(delivery), err
+}
+
+func (s *SQLite) ClaimDueWebhookDeliveries(ctx context.Context, limit int32) ([]database.WebhookDelivery, error) {
+    ts := now()
+    deliveries, err := s.q.ClaimDueWebhookDeliveries(ctx, sqlite.ClaimDueWebhookDeliveriesParams{
+        LeaseUntil: ts.Add(claimLease),
+        Now: ts,
+        Limit: int64(limit),
+    })
+    return convertAll(deliveries, toWebhookDelivery), err
+}
+
+func (s *SQLite) MarkWebhookDeliverySucceeded(ctx context.Context, id uuid.UUID) error {
+    return s.q.MarkWebhookDeliverySucceeded(ctx, sqlite.MarkWebhookDeliverySucceededParams{Now: now(), ID: id})
+}
+
+func (s *SQLite) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+    return s.q.MarkWebhookDeliveryFailed(ctx, sqlite.MarkWebhookDeliveryFailedParams{
+        Status: arg.Status,
+        NextAttemptAt: arg.NextAttemptAt.UTC(),
+        Now: now(),
+        ID: arg.ID,
+    })
+}
+
+func (s *SQLite) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+    deliveries, err := s.q.ListWebhookDeliveries(ctx, sqlite.ListWebhookDeliveriesParams{
+        WebhookID: arg.WebhookID,
+        Limit: int64(arg.Limit),
+    })
+    return convertAll(deliveries, toWebhookDelivery), err
+}
+
+func (s *SQLite) CreateWebhookDeliveryAttempt(ctx context.Context, arg database.CreateWebhookDeliveryAttemptParams) error {
+    return s.q.CreateWebhookDeliveryAttempt(ctx, sqlite.CreateWebhookDeliveryAttemptParams{
+        ID: uuid.New(),
+        Now: now(),
+        DeliveryID: arg.DeliveryID,
+        ResponseCode: sql.NullInt64{Int64: int64(arg.ResponseCode.Int32), Valid: arg.ResponseCode.Valid},
+        Error: arg.Error,
+        DurationMs: int64(arg.DurationMs),
+    })
+}
+
+func (s *SQLite) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]database.WebhookDeliveryAttempt, error) {
+    attempts, err := s.q.ListWebhookDeliveryAttempts(ctx, deliveryID)
+    return convertAll(attempts, func(a sqlite.WebhookDeliveryAttempt) database.WebhookDeliveryAttempt {
+        return database.WebhookDeliveryAttempt{
+            ID: a.ID,
+            CreatedAt: a.CreatedAt,
+            DeliveryID: a.DeliveryID,
+            ResponseCode: sql.NullInt32{Int32: int32(a.ResponseCode.Int64), Valid: a.ResponseCode.Valid},
+            Error: a.Error,
+            DurationMs: int32(a.DurationMs),
+        }
+    }), err
+}
+
+func (s *SQLite) EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (database.Job, error) {
+    job, err := s.q.EnqueueJob(ctx, sqlite.EnqueueJobParams{
+        ID: uuid.New(),
+        Now: now(),
+        Kind: arg.Kind,
+        Payload: string(arg.Payload),
+        RunAt: arg.RunAt.UTC(),
+    })
+    return toJob(job), err
+}
+
+func (s *SQLite) ClaimDueJobs(ctx context.Context, limit int32) ([]database.Job, error) {
+    ts := now()
+    jobs, err := s.q.ClaimDueJobs(ctx, sqlite.ClaimDueJobsParams{
+        LeaseUntil: ts.Add(claimLease),
+        Now: ts,
+        Limit: int64(limit),
+    })
+    return convertAll(jobs, toJob), err
+}
+
+func (s *SQLite) CompleteJob(ctx context.Context, id uuid.UUID) error {
+    return s.q.CompleteJob(ctx, sqlite.CompleteJobParams{Now: now(), ID: id})
+}
+
+func (s *SQLite) ScheduleJobRetry(ctx context.Context, arg database.ScheduleJobRetryParams) error {
+    return s.q.ScheduleJobRetry(ctx, sqlite.ScheduleJobRetryParams{
+        RunAt: arg.RunAt.UTC(),
+        LastError: arg.LastError,
+        Now: now(),
+        ID: arg.ID,
+    })
+}
+
+func (s *SQLite) DeadLetterJob(ctx context.Context, arg database.DeadLetterJobParams) error {
+    return s.q.DeadLetterJob(ctx, sqlite.DeadLetterJobParams{
+        LastError: arg.LastError,
+        Now: now(),
+        ID: arg.ID,
+    })
+}
+
+func (s *SQLite) RequeueDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+    job, err := s.q.RequeueDeadJob(ctx, sqlite.RequeueDeadJobParams{Now: now(), ID: id})
+    return toJob(job), err
+}
+
+func (s *SQLite) GetJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+    job, err := s.q.GetJob(ctx, id)
+    return toJob(job), err
+}
+
+func (s *SQLite) ListJobs(ctx context.Context, arg database.ListJobsParams) ([]database.Job, error) {
+    jobs, err := s.q.ListJobs(ctx, sqlite.ListJobsParams{
+        Status: arg.Status,
+        MaxRows: int64(arg.MaxRows),
+    })
+    return convertAll(jobs, toJob), err
+}
+
+func (s *SQLite) CountJobsByStatus(ctx context.Context) ([]database.CountJobsByStatusRow, error) {
+    rows, err := s.q.CountJobsByStatus(ctx)
+    return convertAll(rows, func(r sqlite.CountJobsByStatusRow) database.CountJobsByStatusRow {
+        return database.CountJobsByStatusRow(r)
+    }), err
+}
+
+func (s *SQLite) TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (int64, error) {
+    return s.q.TakeRateLimit(ctx, sqlite.TakeRateLimitParams(arg))
+}
+
+func (s *SQLite) GetRateLimit(ctx context.Context, key string) (int64, error) {
+    return s.q.GetRateLimit(ctx, key)
+}
+
+func (s *SQLite) DeleteExpiredRateLimits(ctx context.Context, arg database.DeleteExpiredRateLimitsParams) (int64, error) {
+    return s.q.DeleteExpiredRateLimits(ctx, sqlite.DeleteExpiredRateLimitsParams{Now: arg.Now, BatchSize: int64(arg.BatchSize)})
+}
+
+func (s *SQLite) CreateAuditEvent(ctx context.Context, arg database.CreateAuditEventParams) (database.AuditEvent, error) {
+    event, err := s.q.CreateAuditEvent(ctx, sqlite.CreateAuditEventParams{
+        ID: uuid.New(),
+        Now: now(),
+        Action: arg.Action,
+        ActorID: arg.ActorID,
+        TargetID: arg.TargetID,
+        Ip: arg.Ip,
+        UserAgent: arg.UserAgent,
+        Metadata: string(arg.Metadata),
+    })
+    return toAuditEvent(event), err
+}
+
+func (s *SQLite) ListAuditEvents(ctx context.Context, arg database.ListAuditEventsParams) ([]database.AuditEvent, error) {
+    since, until := arg.Since, arg.Until
+    since.Time, until.Time = since.Time.UTC(), until.Time.UTC()
+    events, err := s.q.ListAuditEvents(ctx, sqlite.ListAuditEventsParams{
+        Action: arg.Action,
+        ActorID: arg.ActorID,
+        TargetID: arg.TargetID,
+        Since: since,
+        Until: until,
+        MaxRows: int64(arg.MaxRows),
+        SkipRows: int64(arg.SkipRows),
+    })
+    return convertAll(events, toAuditEvent), err
+}
+
+// TruncateTables empties the tables one at a time, as SQLite has no
+// TRUNCATE, with each table emptied before the tables it references.
+func (s *SQLite) TruncateTables(ctx context.Context) error {
+    for _, deleteAll := range []func(context.Context) error{
+        s.q.DeleteAllWebhookDeliveryAttempts,
+        s.q.DeleteAllWebhookDeliveries,
+        s.q.DeleteAllWebhooks,
+        s.q.DeleteAllRefreshTokens,
+        s.q.DeleteAllChirps,
+        s.q.DeleteUsers,
+        s.q.DeleteAllJobs,
+        s.q.DeleteAllRateLimits,
+    } {
+        if err := deleteAll(ctx); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (s *SQLite) SeedUser(ctx context.Context, arg database.SeedUserParams) (database.User, error) {
+    user, err := s.q.SeedUser(ctx, sqlite.SeedUserParams(arg))
+    return database.User(user), err
+}
+
+func (s *SQLite) SeedChirp(ctx context.Context, arg database.SeedChirpParams) (database.Chirp, error) {
+    chirp, err := s.q.SeedChirp(ctx, sqlite.SeedChirpParams(arg))
+    return database.Chirp(chirp), err
+}
+
+func toWebhook(h sqlite.Webhook) (database.Webhook, error) {
+    hook := database.Webhook{
+        ID: h.ID,
+        CreatedAt: h.CreatedAt,
+        UpdatedAt: h.UpdatedAt,
+        UserID: h.UserID,
+        Url: h.Url,
+        Secret: h.Secret,
+        ConsecutiveFailures: int32(h.ConsecutiveFailures),
+        DisabledAt: h.DisabledAt,
+    }
+    if err := json.Unmarshal([]byte(h.Events), &hook.Events); err != nil {
+        return database.Webhook{}, fmt.Errorf("decoding events of webhook %s: %w", h.ID, err)
+    }
+    return hook, nil
+}
+
+func toWebhooks(rows []sqlite.Webhook) ([]database.Webhook, error) {
+    var hooks []database.Webhook
+    for _, row := range rows {
+        hook, err := toWebhook(row)
+        if err != nil {
+            return nil, err
+        }
+        hooks = append(hooks, hook)
+    }
+    return hooks, nil
+}
+
+func toWebhookDelivery(d sqlite.WebhookDelivery) database.WebhookDelivery {
+    return database.WebhookDelivery{
+        ID: d.ID,
+        CreatedAt: d.CreatedAt,
+        UpdatedAt: d.UpdatedAt,
+        WebhookID: d.WebhookID,
+        Event: d.Event,
+        Payload: json.RawMessage(d.Payload),
+        Status: d.Status,
+        Attempts: int32(d.Attempts),
+        NextAttemptAt: d.NextAttemptAt,
+        DeliveredAt: d.DeliveredAt,
+        TraceContext: d.TraceContext,
+    }
+}
+
+func toJob(j sqlite.Job) database.Job {
+    return database.Job{
+        ID: j.ID,
+        CreatedAt: j.CreatedAt,
+        UpdatedAt: j.UpdatedAt,
+        Kind: j.Kind,
+        Payload: json.RawMessage(j.Payload),
+        Status: j.Status,
+        Attempts: int32(j.Attempts),
+        RunAt: j.RunAt,
+        LastError: j.LastError,
+        FinishedAt: j.FinishedAt,
+    }
+}
+
+func toAuditEvent(e sqlite.AuditEvent) database.AuditEvent {
+    return database.AuditEvent{
+        ID: e.ID,
+        CreatedAt: e.CreatedAt,
+        Action: e.Action,
+        ActorID: e.ActorID,
+        TargetID: e.TargetID,
+        Ip: e.Ip,
+        UserAgent: e.UserAgent,
+        Metadata: json.RawMessage(e.Metadata),
+    }
+}
+
+// convertAll maps rows to the Postgres model types, keeping nil for no rows
+// as the generated queries do.
+func convertAll[S, T any](rows []S, convert func(S) T) []T {
+    if rows == nil {
+        return nil
+    }
+    out := make([]T, 0, len(rows))
+    for _, row := range rows {
+        out = append(out, convert(row))
+    }
+    return out
+}
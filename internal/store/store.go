@@ -0,0 +1,123 @@
+// Package store defines the persistence interfaces the handlers and background
+// workers depend on. *database.Queries implements all of them; Memory is an
+// in-memory implementation for tests.
+package store
+
+import (
+	"context"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type UserStore interface {
+    CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+    GetUser(ctx context.Context, id uuid.UUID) (database.User, error)
+    GetUserByEmail(ctx context.Context, email string) (database.User, error)
+    UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error)
+    UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error)
+    UpgradeUser(ctx context.Context, id uuid.UUID) (database.User, error)
+    SetUserChirpyRed(ctx context.Context, arg database.SetUserChirpyRedParams) (database.User, error)
+    SetUserDisabledAt(ctx context.Context, arg database.SetUserDisabledAtParams) (database.User, error)
+    SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error)
+    CreateRoleChange(ctx context.Context, arg database.CreateRoleChangeParams) (database.RoleChange, error)
+    ListRoleChanges(ctx context.Context, userID uuid.UUID) ([]database.RoleChange, error)
+    DeleteUser(ctx context.Context, id uuid.UUID) (int64, error)
+    DeleteUsers(ctx context.Context) error
+}
+
+type ChirpStore interface {
+    CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+    GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+    ListChirps(ctx context.Context) ([]database.Chirp, error)
+    ListChirpsByUser(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+    ListChirpsPage(ctx context.Context, arg database.ListChirpsPageParams) ([]database.Chirp, error)
+    DeleteChirp(ctx context.Context, id uuid.UUID) error
+}
+
+type TokenStore interface {
+    CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+    GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error)
+    RevokeRefreshToken(ctx context.Context, token string) (database.RefreshToken, error)
+    RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+    ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error)
+    DeleteExpiredRefreshTokens(ctx context.Context, limit int32) (int64, error)
+    DeleteRevokedRefreshTokens(ctx context.Context, arg database.DeleteRevokedRefreshTokensParams) (int64, error)
+}
+
+type WebhookStore interface {
+    CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error)
+    GetWebhook(ctx context.Context, id uuid.UUID) (database.Webhook, error)
+    ListWebhooksByUser(ctx context.Context, userID uuid.UUID) ([]database.Webhook, error)
+    ListActiveWebhooksForEvent(ctx context.Context, event string) ([]database.Webhook, error)
+    DeleteWebhook(ctx context.Context, arg database.DeleteWebhookParams) (int64, error)
+    RecordWebhookSuccess(ctx context.Context, id uuid.UUID) error
+    RecordWebhookFailure(ctx context.Context, id uuid.UUID) (database.Webhook, error)
+    DisableWebhook(ctx context.Context, id uuid.UUID) error
+
+    CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error)
+    ClaimDueWebhookDeliveries(ctx context.Context, limit int32) ([]database.WebhookDelivery, error)
+    MarkWebhookDeliverySucceeded(ctx context.Context, id uuid.UUID) error
+    MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error
+    ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+    CreateWebhookDeliveryAttempt(ctx context.Context, arg database.CreateWebhookDeliveryAttemptParams) error
+    ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]database.WebhookDeliveryAttempt, error)
+}
+
+type JobStore interface {
+    EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (database.Job, error)
+    ClaimDueJobs(ctx context.Context, limit int32) ([]database.Job, error)
+    CompleteJob(ctx context.Context, id uuid.UUID) error
+    ScheduleJobRetry(ctx context.Context, arg database.ScheduleJobRetryParams) error
+    DeadLetterJob(ctx context.Context, arg database.DeadLetterJobParams) error
+    RequeueDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error)
+    GetJob(ctx context.Context, id uuid.UUID) (database.Job, error)
+    ListJobs(ctx context.Context, arg database.ListJobsParams) ([]database.Job, error)
+    CountJobsByStatus(ctx context.Context) ([]database.CountJobsByStatusRow, error)
+}
+
+// RateLimitStore holds each rate limit key's theoretical arrival time, in
+// Unix microseconds; see the ratelimit package.
+type RateLimitStore interface {
+    TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (int64, error)
+    GetRateLimit(ctx context.Context, key string) (int64, error)
+    DeleteExpiredRateLimits(ctx context.Context, arg database.DeleteExpiredRateLimitsParams) (int64, error)
+}
+
+// AuditStore holds the audit log. It is append-only, so it has no way to
+// change or delete events.
+type AuditStore interface {
+    CreateAuditEvent(ctx context.Context, arg database.CreateAuditEventParams) (database.AuditEvent, error)
+    ListAuditEvents(ctx context.Context, arg database.ListAuditEventsParams) ([]database.AuditEvent, error)
+}
+
+// FixtureStore empties the database and loads rows with fixed IDs, for the
+// dev and test fixtures; see the fixtures package.
+type FixtureStore interface {
+    // TruncateTables deletes every row of every table but the audit logs,
+    // audit_events and role_changes, which outlive the rows they mention.
+    TruncateTables(ctx context.Context) error
+    SeedUser(ctx context.Context, arg database.SeedUserParams) (database.User, error)
+    SeedChirp(ctx context.Context, arg database.SeedChirpParams) (database.Chirp, error)
+}
+
+// Store is every store interface together.
+type Store interface {
+    UserStore
+    ChirpStore
+    TokenStore
+    WebhookStore
+    JobStore
+    RateLimitStore
+    AuditStore
+    FixtureStore
+}
+
+var (
+    _ Store = (*database.Queries)(nil)
+    _ Store = (*Memory)(nil)
+    _ Store = (*SQLite)(nil)
+
+    _ Transactor = (*DB)(nil)
+    _ Transactor = (*Memory)(nil)
+)
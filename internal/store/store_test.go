@@ -0,0 +1,756 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/migrate"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type txStore interface {
+    store.Store
+    store.Transactor
+}
+
+// backends returns a constructor for every Store that can run without
+// external services. Each call gets an empty store.
+func backends() map[string]func(t *testing.T) txStore {
+    return map[string]func(t *testing.T) txStore{
+        "Memory": func(t *testing.T) txStore {
+            return store.NewMemory()
+        },
+        "SQLite": func(t *testing.T) txStore {
+            db, dialect, err := store.Open("sqlite::memory:")
+            require.NoError(t, err)
+            t.Cleanup(func() { db.Close() })
+
+            migrator, err := migrate.New(db, dialect)
+            require.NoError(t, err)
+            require.NoError(t, migrator.Up(context.Background(), io.Discard))
+            return store.NewDB(db, dialect, nil)
+        },
+    }
+}
+
+func TestStore(t *testing.T) {
+    ctx := context.Background()
+
+    for name, newStore := range backends() {
+        t.Run(name, func(t *testing.T) {
+            t.Run("Missing rows return sql.ErrNoRows", func(t *testing.T) {
+                s := newStore(t)
+
+                _, err := s.GetUserByEmail(ctx, "nobody@example.com")
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.GetChirp(ctx, uuid.New())
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.GetRefreshToken(ctx, "token")
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.UpgradeUser(ctx, uuid.New())
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+            })
+
+            t.Run("Users round-trip", func(t *testing.T) {
+                s := newStore(t)
+
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com", HashedPassword: "hash"})
+                require.NoError(t, err)
+                got, err := s.GetUserByEmail(ctx, "a@example.com")
+                require.NoError(t, err)
+                assert.Equal(t, user, got)
+
+                upgraded, err := s.UpgradeUser(ctx, user.ID)
+                require.NoError(t, err)
+                assert.True(t, upgraded.IsChirpyRed)
+            })
+
+            t.Run("Emails are unique", func(t *testing.T) {
+                s := newStore(t)
+
+                _, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                _, err = s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                assert.True(t, store.IsUniqueViolation(err), "got %v", err)
+            })
+
+            t.Run("Foreign keys are enforced", func(t *testing.T) {
+                s := newStore(t)
+
+                _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: uuid.New()})
+                assert.Error(t, err)
+                assert.False(t, store.IsUniqueViolation(err))
+                _, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "t", UserID: uuid.New()})
+                assert.Error(t, err)
+            })
+
+            t.Run("Chirps are listed oldest first", func(t *testing.T) {
+                s := newStore(t)
+                alice, err := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+                require.NoError(t, err)
+                bob, err := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com"})
+                require.NoError(t, err)
+                for _, c := range []database.CreateChirpParams{
+                    {Body: "first", UserID: alice.ID},
+                    {Body: "second", UserID: bob.ID},
+                    {Body: "third", UserID: alice.ID},
+                } {
+                    _, err := s.CreateChirp(ctx, c)
+                    require.NoError(t, err)
+                }
+
+                chirps, err := s.ListChirps(ctx)
+                require.NoError(t, err)
+                assert.Equal(t, []string{"first", "second", "third"}, bodies(chirps))
+
+                chirps, err = s.ListChirpsByUser(ctx, alice.ID)
+                require.NoError(t, err)
+                assert.Equal(t, []string{"first", "third"}, bodies(chirps))
+            })
+
+            t.Run("Chirps are paged in either order", func(t *testing.T) {
+                s := newStore(t)
+                alice, err := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+                require.NoError(t, err)
+                bob, err := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com"})
+                require.NoError(t, err)
+                now := time.Now().UTC().Truncate(time.Millisecond)
+                for i, c := range []struct{ body string; user uuid.UUID }{
+                    {"first", alice.ID}, {"second", bob.ID}, {"third", alice.ID}, {"fourth", alice.ID},
+                } {
+                    _, err := s.SeedChirp(ctx, database.SeedChirpParams{ID: uuid.New(), CreatedAt: now.Add(time.Duration(i) * time.Second), Body: c.body, UserID: c.user})
+                    require.NoError(t, err)
+                }
+
+                page := func(arg database.ListChirpsPageParams) []string {
+                    t.Helper()
+                    chirps, err := s.ListChirpsPage(ctx, arg)
+                    require.NoError(t, err)
+                    return bodies(chirps)
+                }
+                rows := func(n int32) sql.NullInt32 { return sql.NullInt32{Int32: n, Valid: true} }
+                byAlice := uuid.NullUUID{UUID: alice.ID, Valid: true}
+                assert.Equal(t, []string{"first", "second", "third", "fourth"}, page(database.ListChirpsPageParams{}))
+                assert.Equal(t, []string{"fourth", "third", "second", "first"}, page(database.ListChirpsPageParams{Descending: true}))
+                assert.Equal(t, []string{"second", "third"}, page(database.ListChirpsPageParams{MaxRows: rows(2), SkipRows: 1}))
+                assert.Equal(t, []string{"third", "second"}, page(database.ListChirpsPageParams{Descending: true, MaxRows: rows(2), SkipRows: 1}))
+                assert.Equal(t, []string{"third", "fourth"}, page(database.ListChirpsPageParams{AuthorID: byAlice, SkipRows: 1}))
+                assert.Empty(t, page(database.ListChirpsPageParams{MaxRows: rows(2), SkipRows: 4}))
+            })
+
+            t.Run("Refresh tokens can be revoked", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Microsecond)
+                _, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "t", UserID: user.ID, ExpiresAt: expiresAt})
+                require.NoError(t, err)
+
+                revoked, err := s.RevokeRefreshToken(ctx, "t")
+                require.NoError(t, err)
+                assert.True(t, revoked.RevokedAt.Valid)
+                assert.True(t, revoked.ExpiresAt.Equal(expiresAt))
+            })
+
+            t.Run("Deleting users cascades", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                _, err = s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user.ID})
+                require.NoError(t, err)
+
+                require.NoError(t, s.DeleteUsers(ctx))
+
+                chirps, err := s.ListChirps(ctx)
+                require.NoError(t, err)
+                assert.Empty(t, chirps)
+            })
+
+            t.Run("Users can be administered", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com", HashedPassword: "old"})
+                require.NoError(t, err)
+
+                got, err := s.GetUser(ctx, user.ID)
+                require.NoError(t, err)
+                assert.Equal(t, user, got)
+                assert.False(t, got.DisabledAt.Valid)
+
+                updated, err := s.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{HashedPassword: "new", ID: user.ID})
+                require.NoError(t, err)
+                assert.Equal(t, "new", updated.HashedPassword)
+
+                updated, err = s.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{IsChirpyRed: true, ID: user.ID})
+                require.NoError(t, err)
+                assert.True(t, updated.IsChirpyRed)
+                updated, err = s.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{IsChirpyRed: false, ID: user.ID})
+                require.NoError(t, err)
+                assert.False(t, updated.IsChirpyRed)
+
+                disabledAt := time.Now().UTC().Truncate(time.Microsecond)
+                updated, err = s.SetUserDisabledAt(ctx, database.SetUserDisabledAtParams{DisabledAt: sql.NullTime{Time: disabledAt, Valid: true}, ID: user.ID})
+                require.NoError(t, err)
+                assert.True(t, updated.DisabledAt.Valid)
+                assert.True(t, updated.DisabledAt.Time.Equal(disabledAt))
+                got, err = s.GetUserByEmail(ctx, "a@example.com")
+                require.NoError(t, err)
+                assert.Equal(t, updated, got)
+
+                updated, err = s.SetUserDisabledAt(ctx, database.SetUserDisabledAtParams{ID: user.ID})
+                require.NoError(t, err)
+                assert.False(t, updated.DisabledAt.Valid)
+
+                _, err = s.GetUser(ctx, uuid.New())
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.SetUserDisabledAt(ctx, database.SetUserDisabledAtParams{ID: uuid.New()})
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+            })
+
+            t.Run("Roles change and the changes are logged", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                assert.Equal(t, "user", user.Role)
+                admin, err := s.CreateUser(ctx, database.CreateUserParams{Email: "b@example.com"})
+                require.NoError(t, err)
+
+                updated, err := s.SetUserRole(ctx, database.SetUserRoleParams{Role: "moderator", ID: user.ID})
+                require.NoError(t, err)
+                assert.Equal(t, "moderator", updated.Role)
+                _, err = s.SetUserRole(ctx, database.SetUserRoleParams{Role: "moderator", ID: uuid.New()})
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+
+                first, err := s.CreateRoleChange(ctx, database.CreateRoleChangeParams{
+                    UserID: user.ID,
+                    OldRole: "user",
+                    NewRole: "moderator",
+                    Reason: "joined the moderation team",
+                })
+                require.NoError(t, err)
+                assert.False(t, first.ChangedBy.Valid)
+                time.Sleep(time.Millisecond)
+                second, err := s.CreateRoleChange(ctx, database.CreateRoleChangeParams{
+                    UserID: user.ID,
+                    OldRole: "moderator",
+                    NewRole: "user",
+                    ChangedBy: uuid.NullUUID{UUID: admin.ID, Valid: true},
+                    Reason: "left the moderation team",
+                })
+                require.NoError(t, err)
+
+                // The log outlives the users it mentions.
+                _, err = s.DeleteUser(ctx, user.ID)
+                require.NoError(t, err)
+                changes, err := s.ListRoleChanges(ctx, user.ID)
+                require.NoError(t, err)
+                require.Len(t, changes, 2)
+                assert.Equal(t, second.ID, changes[0].ID)
+                assert.Equal(t, admin.ID, changes[0].ChangedBy.UUID)
+                assert.Equal(t, first, changes[1])
+            })
+
+            t.Run("The audit log is filtered and paginated", func(t *testing.T) {
+                s := newStore(t)
+                actor, target := uuid.New(), uuid.New()
+                var events []database.AuditEvent
+                for _, params := range []database.CreateAuditEventParams{
+                    {Action: "login.failed", TargetID: uuid.NullUUID{UUID: target, Valid: true}, Metadata: []byte(`{"reason":"wrong_password"}`)},
+                    {Action: "login.succeeded", ActorID: uuid.NullUUID{UUID: target, Valid: true}, TargetID: uuid.NullUUID{UUID: target, Valid: true}},
+                    {Action: "chirp.deleted", ActorID: uuid.NullUUID{UUID: actor, Valid: true}, Ip: "192.0.2.1", UserAgent: "curl/8.0"},
+                } {
+                    if params.Metadata == nil {
+                        params.Metadata = []byte(`{}`)
+                    }
+                    event, err := s.CreateAuditEvent(ctx, params)
+                    require.NoError(t, err)
+                    events = append(events, event)
+                    time.Sleep(time.Millisecond)
+                }
+                assert.JSONEq(t, `{"reason":"wrong_password"}`, string(events[0].Metadata))
+                assert.Equal(t, "curl/8.0", events[2].UserAgent)
+
+                list := func(arg database.ListAuditEventsParams) []uuid.UUID {
+                    t.Helper()
+                    if arg.MaxRows == 0 {
+                        arg.MaxRows = 10
+                    }
+                    got, err := s.ListAuditEvents(ctx, arg)
+                    require.NoError(t, err)
+                    var ids []uuid.UUID
+                    for _, e := range got {
+                        ids = append(ids, e.ID)
+                    }
+                    return ids
+                }
+                assert.Equal(t, []uuid.UUID{events[2].ID, events[1].ID, events[0].ID}, list(database.ListAuditEventsParams{}))
+                assert.Equal(t, []uuid.UUID{events[1].ID}, list(database.ListAuditEventsParams{MaxRows: 1, SkipRows: 1}))
+                assert.Equal(t, []uuid.UUID{events[0].ID}, list(database.ListAuditEventsParams{Action: "login.failed"}))
+                assert.Equal(t, []uuid.UUID{events[2].ID}, list(database.ListAuditEventsParams{ActorID: uuid.NullUUID{UUID: actor, Valid: true}}))
+                assert.Equal(t, []uuid.UUID{events[1].ID, events[0].ID}, list(database.ListAuditEventsParams{TargetID: uuid.NullUUID{UUID: target, Valid: true}}))
+                assert.Equal(t, []uuid.UUID{events[2].ID, events[1].ID}, list(database.ListAuditEventsParams{
+                    Since: sql.NullTime{Time: events[1].CreatedAt, Valid: true},
+                }))
+                assert.Equal(t, []uuid.UUID{events[0].ID}, list(database.ListAuditEventsParams{
+                    Until: sql.NullTime{Time: events[1].CreatedAt, Valid: true},
+                }))
+
+                // The log outlives the data it mentions.
+                require.NoError(t, s.DeleteUsers(ctx))
+                assert.Len(t, list(database.ListAuditEventsParams{}), 3)
+            })
+
+            t.Run("Seeded rows keep their IDs and times", func(t *testing.T) {
+                s := newStore(t)
+                created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+                user, err := s.SeedUser(ctx, database.SeedUserParams{
+                    ID: uuid.New(),
+                    CreatedAt: created,
+                    Email: "seed@example.com",
+                    HashedPassword: "hash",
+                    IsChirpyRed: true,
+                    Role: "moderator",
+                })
+                require.NoError(t, err)
+                assert.True(t, created.Equal(user.CreatedAt))
+                assert.True(t, user.IsChirpyRed)
+                assert.Equal(t, "moderator", user.Role)
+
+                _, err = s.CreateChirp(ctx, database.CreateChirpParams{Body: "now", UserID: user.ID})
+                require.NoError(t, err)
+                chirp, err := s.SeedChirp(ctx, database.SeedChirpParams{ID: uuid.New(), CreatedAt: created, Body: "then", UserID: user.ID})
+                require.NoError(t, err)
+                got, err := s.GetChirp(ctx, chirp.ID)
+                require.NoError(t, err)
+                assert.Equal(t, "then", got.Body)
+                chirps, err := s.ListChirps(ctx)
+                require.NoError(t, err)
+                assert.Equal(t, []string{"then", "now"}, bodies(chirps))
+
+                _, err = s.SeedUser(ctx, database.SeedUserParams{ID: user.ID, CreatedAt: created, Email: "other@example.com", Role: "user"})
+                assert.True(t, store.IsUniqueViolation(err))
+                _, err = s.SeedChirp(ctx, database.SeedChirpParams{ID: uuid.New(), CreatedAt: created, Body: "orphan", UserID: uuid.New()})
+                assert.Error(t, err)
+            })
+
+            t.Run("Truncating empties every table but the audit logs", func(t *testing.T) {
+                s := newStore(t)
+                hook := createWebhook(t, s)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: user.ID})
+                require.NoError(t, err)
+                _, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "t", UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)})
+                require.NoError(t, err)
+                _, err = s.CreateRoleChange(ctx, database.CreateRoleChangeParams{UserID: user.ID, OldRole: "user", NewRole: "admin", Reason: "test"})
+                require.NoError(t, err)
+                job, err := s.EnqueueJob(ctx, database.EnqueueJobParams{Kind: "test", Payload: []byte(`{}`), RunAt: time.Now()})
+                require.NoError(t, err)
+                _, err = s.TakeRateLimit(ctx, database.TakeRateLimitParams{Key: "k", Now: 100, EmissionInterval: 10, Window: 20})
+                require.NoError(t, err)
+                _, err = s.CreateAuditEvent(ctx, database.CreateAuditEventParams{Action: "admin.reset", Metadata: []byte(`{}`)})
+                require.NoError(t, err)
+
+                require.NoError(t, s.TruncateTables(ctx))
+
+                _, err = s.GetUser(ctx, user.ID)
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.GetChirp(ctx, chirp.ID)
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.GetRefreshToken(ctx, "t")
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.GetWebhook(ctx, hook.ID)
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.GetJob(ctx, job.ID)
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                _, err = s.GetRateLimit(ctx, "k")
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+                changes, err := s.ListRoleChanges(ctx, user.ID)
+                require.NoError(t, err)
+                assert.Len(t, changes, 1)
+                events, err := s.ListAuditEvents(ctx, database.ListAuditEventsParams{MaxRows: 10})
+                require.NoError(t, err)
+                assert.Len(t, events, 1)
+            })
+
+            t.Run("A user's refresh tokens are listed newest first", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                other, err := s.CreateUser(ctx, database.CreateUserParams{Email: "b@example.com"})
+                require.NoError(t, err)
+                expiresAt := time.Now().UTC().Add(time.Hour)
+                for _, token := range []string{"first", "second"} {
+                    _, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: token, UserID: user.ID, ExpiresAt: expiresAt})
+                    require.NoError(t, err)
+                    time.Sleep(time.Millisecond)
+                }
+                _, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "other", UserID: other.ID, ExpiresAt: expiresAt})
+                require.NoError(t, err)
+
+                tokens, err := s.ListUserRefreshTokens(ctx, user.ID)
+                require.NoError(t, err)
+                require.Len(t, tokens, 2)
+                assert.Equal(t, "second", tokens[0].Token)
+                assert.Equal(t, "first", tokens[1].Token)
+            })
+
+            t.Run("Deleting a user cascades", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                other, err := s.CreateUser(ctx, database.CreateUserParams{Email: "b@example.com"})
+                require.NoError(t, err)
+                for _, id := range []uuid.UUID{user.ID, other.ID} {
+                    _, err = s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: id})
+                    require.NoError(t, err)
+                    _, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: id.String(), UserID: id, ExpiresAt: time.Now().Add(time.Hour)})
+                    require.NoError(t, err)
+                }
+
+                n, err := s.DeleteUser(ctx, user.ID)
+                require.NoError(t, err)
+                assert.Equal(t, int64(1), n)
+                n, err = s.DeleteUser(ctx, user.ID)
+                require.NoError(t, err)
+                assert.Zero(t, n)
+
+                chirps, err := s.ListChirps(ctx)
+                require.NoError(t, err)
+                require.Len(t, chirps, 1)
+                assert.Equal(t, other.ID, chirps[0].UserID)
+                tokens, err := s.ListUserRefreshTokens(ctx, user.ID)
+                require.NoError(t, err)
+                assert.Empty(t, tokens)
+                _, err = s.GetRefreshToken(ctx, other.ID.String())
+                assert.NoError(t, err)
+            })
+
+            t.Run("Claiming leases due deliveries", func(t *testing.T) {
+                s := newStore(t)
+                hook := createWebhook(t, s)
+                for range 3 {
+                    _, err := s.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{WebhookID: hook.ID, Event: "chirp.created", Payload: []byte(`{}`)})
+                    require.NoError(t, err)
+                }
+
+                claimed, err := s.ClaimDueWebhookDeliveries(ctx, 2)
+                require.NoError(t, err)
+                require.Len(t, claimed, 2)
+                assert.WithinDuration(t, time.Now().Add(5*time.Minute), claimed[0].NextAttemptAt, time.Second)
+                assert.JSONEq(t, `{}`, string(claimed[0].Payload))
+
+                claimed, err = s.ClaimDueWebhookDeliveries(ctx, 2)
+                require.NoError(t, err)
+                assert.Len(t, claimed, 1)
+            })
+
+            t.Run("Delivery outcomes are recorded", func(t *testing.T) {
+                s := newStore(t)
+                hook := createWebhook(t, s)
+                delivery, err := s.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{WebhookID: hook.ID, Event: "chirp.created", Payload: []byte(`{}`)})
+                require.NoError(t, err)
+
+                require.NoError(t, s.CreateWebhookDeliveryAttempt(ctx, database.CreateWebhookDeliveryAttemptParams{
+                    DeliveryID: delivery.ID,
+                    ResponseCode: sql.NullInt32{Int32: 500, Valid: true},
+                    DurationMs: 12,
+                }))
+                require.NoError(t, s.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+                    ID: delivery.ID,
+                    Status: "pending",
+                    NextAttemptAt: time.Now().Add(time.Minute),
+                }))
+                failed, err := s.RecordWebhookFailure(ctx, hook.ID)
+                require.NoError(t, err)
+                assert.Equal(t, int32(1), failed.ConsecutiveFailures)
+
+                deliveries, err := s.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: 10})
+                require.NoError(t, err)
+                require.Len(t, deliveries, 1)
+                assert.Equal(t, int32(1), deliveries[0].Attempts)
+
+                attempts, err := s.ListWebhookDeliveryAttempts(ctx, delivery.ID)
+                require.NoError(t, err)
+                require.Len(t, attempts, 1)
+                assert.Equal(t, sql.NullInt32{Int32: 500, Valid: true}, attempts[0].ResponseCode)
+            })
+
+            t.Run("Active webhooks are filtered by event", func(t *testing.T) {
+                s := newStore(t)
+                hook := createWebhook(t, s)
+
+                hooks, err := s.ListActiveWebhooksForEvent(ctx, "chirp.created")
+                require.NoError(t, err)
+                require.Len(t, hooks, 1)
+                assert.Equal(t, []string{"chirp.created"}, hooks[0].Events)
+
+                hooks, err = s.ListActiveWebhooksForEvent(ctx, "user.updated")
+                require.NoError(t, err)
+                assert.Empty(t, hooks)
+
+                require.NoError(t, s.DisableWebhook(ctx, hook.ID))
+                hooks, err = s.ListActiveWebhooksForEvent(ctx, "chirp.created")
+                require.NoError(t, err)
+                assert.Empty(t, hooks)
+            })
+
+            t.Run("Claiming jobs skips future and leased ones", func(t *testing.T) {
+                s := newStore(t)
+                due, err := s.EnqueueJob(ctx, database.EnqueueJobParams{Kind: "test", Payload: []byte(`{"n":1}`), RunAt: time.Now()})
+                require.NoError(t, err)
+                _, err = s.EnqueueJob(ctx, database.EnqueueJobParams{Kind: "test", Payload: []byte(`{}`), RunAt: time.Now().Add(time.Hour)})
+                require.NoError(t, err)
+
+                claimed, err := s.ClaimDueJobs(ctx, 10)
+                require.NoError(t, err)
+                require.Len(t, claimed, 1)
+                assert.Equal(t, due.ID, claimed[0].ID)
+                assert.Equal(t, "running", claimed[0].Status)
+                assert.Equal(t, int32(1), claimed[0].Attempts)
+                assert.JSONEq(t, `{"n":1}`, string(claimed[0].Payload))
+                assert.WithinDuration(t, time.Now().Add(5*time.Minute), claimed[0].RunAt, time.Second)
+
+                claimed, err = s.ClaimDueJobs(ctx, 10)
+                require.NoError(t, err)
+                assert.Empty(t, claimed)
+            })
+
+            t.Run("Job outcomes are recorded", func(t *testing.T) {
+                s := newStore(t)
+                var ids []uuid.UUID
+                for range 3 {
+                    job, err := s.EnqueueJob(ctx, database.EnqueueJobParams{Kind: "test", Payload: []byte(`{}`), RunAt: time.Now()})
+                    require.NoError(t, err)
+                    ids = append(ids, job.ID)
+                }
+                _, err := s.ClaimDueJobs(ctx, 10)
+                require.NoError(t, err)
+
+                require.NoError(t, s.CompleteJob(ctx, ids[0]))
+                require.NoError(t, s.ScheduleJobRetry(ctx, database.ScheduleJobRetryParams{
+                    ID: ids[1],
+                    RunAt: time.Now().Add(time.Minute),
+                    LastError: sql.NullString{String: "boom", Valid: true},
+                }))
+                require.NoError(t, s.DeadLetterJob(ctx, database.DeadLetterJobParams{
+                    ID: ids[2],
+                    LastError: sql.NullString{String: "gave up", Valid: true},
+                }))
+
+                counts, err := s.CountJobsByStatus(ctx)
+                require.NoError(t, err)
+                assert.Equal(t, []database.CountJobsByStatusRow{
+                    {Status: "dead", Count: 1},
+                    {Status: "pending", Count: 1},
+                    {Status: "succeeded", Count: 1},
+                }, counts)
+
+                dead, err := s.ListJobs(ctx, database.ListJobsParams{Status: "dead", MaxRows: 10})
+                require.NoError(t, err)
+                require.Len(t, dead, 1)
+                assert.Equal(t, "gave up", dead[0].LastError.String)
+                assert.True(t, dead[0].FinishedAt.Valid)
+
+                all, err := s.ListJobs(ctx, database.ListJobsParams{MaxRows: 10})
+                require.NoError(t, err)
+                assert.Len(t, all, 3)
+            })
+
+            t.Run("Only dead jobs can be requeued", func(t *testing.T) {
+                s := newStore(t)
+                job, err := s.EnqueueJob(ctx, database.EnqueueJobParams{Kind: "test", Payload: []byte(`{}`), RunAt: time.Now()})
+                require.NoError(t, err)
+
+                _, err = s.RequeueDeadJob(ctx, job.ID)
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+
+                _, err = s.ClaimDueJobs(ctx, 1)
+                require.NoError(t, err)
+                require.NoError(t, s.DeadLetterJob(ctx, database.DeadLetterJobParams{ID: job.ID}))
+                requeued, err := s.RequeueDeadJob(ctx, job.ID)
+                require.NoError(t, err)
+                assert.Equal(t, "pending", requeued.Status)
+                assert.Zero(t, requeued.Attempts)
+                assert.False(t, requeued.FinishedAt.Valid)
+
+                _, err = s.GetJob(ctx, uuid.New())
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+            })
+
+            t.Run("Rate limits stop advancing past the window", func(t *testing.T) {
+                s := newStore(t)
+                take := func(now int64) (int64, error) {
+                    return s.TakeRateLimit(ctx, database.TakeRateLimitParams{Key: "k", Now: now, EmissionInterval: 10, Window: 20})
+                }
+
+                tat, err := take(100)
+                require.NoError(t, err)
+                assert.Equal(t, int64(110), tat)
+                tat, err = take(100)
+                require.NoError(t, err)
+                assert.Equal(t, int64(120), tat)
+                _, err = take(100)
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+
+                tat, err = s.GetRateLimit(ctx, "k")
+                require.NoError(t, err)
+                assert.Equal(t, int64(120), tat, "a rejected take leaves the tat alone")
+
+                tat, err = take(110)
+                require.NoError(t, err)
+                assert.Equal(t, int64(130), tat)
+
+                _, err = s.GetRateLimit(ctx, "other")
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+            })
+
+            t.Run("Expired rate limits are deleted", func(t *testing.T) {
+                s := newStore(t)
+                for _, key := range []string{"a", "b", "c"} {
+                    _, err := s.TakeRateLimit(ctx, database.TakeRateLimitParams{Key: key, Now: 100, EmissionInterval: 10, Window: 10})
+                    require.NoError(t, err)
+                }
+                _, err := s.TakeRateLimit(ctx, database.TakeRateLimitParams{Key: "d", Now: 200, EmissionInterval: 10, Window: 10})
+                require.NoError(t, err)
+
+                deleted, err := s.DeleteExpiredRateLimits(ctx, database.DeleteExpiredRateLimitsParams{Now: 200, BatchSize: 2})
+                require.NoError(t, err)
+                assert.Equal(t, int64(2), deleted)
+                deleted, err = s.DeleteExpiredRateLimits(ctx, database.DeleteExpiredRateLimitsParams{Now: 200, BatchSize: 2})
+                require.NoError(t, err)
+                assert.Equal(t, int64(1), deleted)
+
+                _, err = s.GetRateLimit(ctx, "d")
+                assert.NoError(t, err)
+            })
+
+            t.Run("Units of work commit", func(t *testing.T) {
+                s := newStore(t)
+
+                err := s.InTx(ctx, func(tx store.Store) error {
+                    user, err := tx.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                    if err != nil {
+                        return err
+                    }
+                    _, err = tx.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user.ID})
+                    return err
+                })
+
+                require.NoError(t, err)
+                chirps, err := s.ListChirps(ctx)
+                require.NoError(t, err)
+                assert.Len(t, chirps, 1)
+            })
+
+            t.Run("Units of work roll back on error", func(t *testing.T) {
+                s := newStore(t)
+
+                err := s.InTx(ctx, func(tx store.Store) error {
+                    _, err := tx.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                    if err != nil {
+                        return err
+                    }
+                    _, err = tx.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: uuid.New()})
+                    return err
+                })
+
+                assert.Error(t, err)
+                _, err = s.GetUserByEmail(ctx, "a@example.com")
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+            })
+
+            t.Run("Sessions can be revoked per user", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                for _, token := range []string{"one", "two"} {
+                    _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: token, UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)})
+                    require.NoError(t, err)
+                }
+
+                require.NoError(t, s.RevokeUserRefreshTokens(ctx, user.ID))
+
+                for _, token := range []string{"one", "two"} {
+                    got, err := s.GetRefreshToken(ctx, token)
+                    require.NoError(t, err)
+                    assert.True(t, got.RevokedAt.Valid, token)
+                }
+            })
+
+            t.Run("Stale refresh tokens are deleted in batches", func(t *testing.T) {
+                s := newStore(t)
+                user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+                require.NoError(t, err)
+                tokens := map[string]time.Duration{"expired-1": -time.Hour, "expired-2": -time.Minute, "revoked": time.Hour, "live": time.Hour}
+                for token, lifetime := range tokens {
+                    _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: token, UserID: user.ID, ExpiresAt: time.Now().Add(lifetime)})
+                    require.NoError(t, err)
+                }
+                _, err = s.RevokeRefreshToken(ctx, "revoked")
+                require.NoError(t, err)
+
+                deleted, err := s.DeleteExpiredRefreshTokens(ctx, 1)
+                require.NoError(t, err)
+                assert.Equal(t, int64(1), deleted)
+                deleted, err = s.DeleteExpiredRefreshTokens(ctx, 10)
+                require.NoError(t, err)
+                assert.Equal(t, int64(1), deleted)
+
+                deleted, err = s.DeleteRevokedRefreshTokens(ctx, database.DeleteRevokedRefreshTokensParams{RevokedBefore: time.Now().Add(-time.Hour), BatchSize: 10})
+                require.NoError(t, err)
+                assert.Zero(t, deleted)
+                deleted, err = s.DeleteRevokedRefreshTokens(ctx, database.DeleteRevokedRefreshTokensParams{RevokedBefore: time.Now().Add(time.Second), BatchSize: 10})
+                require.NoError(t, err)
+                assert.Equal(t, int64(1), deleted)
+
+                _, err = s.GetRefreshToken(ctx, "live")
+                assert.NoError(t, err)
+                _, err = s.GetRefreshToken(ctx, "revoked")
+                assert.ErrorIs(t, err, sql.ErrNoRows)
+            })
+
+            t.Run("Webhooks are only deleted by their owner", func(t *testing.T) {
+                s := newStore(t)
+                hook := createWebhook(t, s)
+
+                deleted, err := s.DeleteWebhook(ctx, database.DeleteWebhookParams{ID: hook.ID, UserID: uuid.New()})
+                require.NoError(t, err)
+                assert.Zero(t, deleted)
+
+                deleted, err = s.DeleteWebhook(ctx, database.DeleteWebhookParams{ID: hook.ID, UserID: hook.UserID})
+                require.NoError(t, err)
+                assert.Equal(t, int64(1), deleted)
+            })
+        })
+    }
+}
+
+func createWebhook(t *testing.T, s store.Store) database.Webhook {
+    t.Helper()
+
+    user, err := s.CreateUser(context.Background(), database.CreateUserParams{Email: "hooks@example.com"})
+    require.NoError(t, err)
+    hook, err := s.CreateWebhook(context.Background(), database.CreateWebhookParams{
+        UserID: user.ID,
+        Url: "https://example.com",
+        Secret: "0123456789abcdef",
+        Events: []string{"chirp.created"},
+    })
+    require.NoError(t, err)
+    return hook
+}
+
+func bodies(chirps []database.Chirp) []string {
+    var out []string
+    for _, c := range chirps {
+        out = append(out, c.Body)
+    }
+    return out
+}
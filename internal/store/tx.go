@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+    maxTxAttempts = 3
+    txRetryDelay = 20 * time.Millisecond
+)
+
+// Transactor runs a unit of work: fn gets a Store bound to one transaction,
+// which commits if fn returns nil and rolls back otherwise. fn may be run more
+// than once, so it should not have side effects outside the Store.
+type Transactor interface {
+    InTx(ctx context.Context, fn func(Store) error) error
+}
+
+// DB is a Store backed by a SQL database that can also run units of work.
+type DB struct {
+    Store
+    db *sql.DB
+    dialect string
+    wrap func(database.DBTX) database.DBTX
+}
+
+// NewDB returns a DB for a database and dialect reported by Open. wrap, if not
+// nil, decorates the connection and every transaction, e.g. for tracing.
+func NewDB(db *sql.DB, dialect string, wrap func(database.DBTX) database.DBTX) *DB {
+    if wrap == nil {
+        wrap = func(db database.DBTX) database.DBTX { return db }
+    }
+    return &DB{
+        Store: New(dialect, wrap(db)),
+        db: db,
+        dialect: dialect,
+        wrap: wrap,
+    }
+}
+
+// InTx runs fn in a serializable transaction, retrying it when the database
+// reports a serialization failure or deadlock.
+func (d *DB) InTx(ctx context.Context, fn func(Store) error) error {
+    for attempt := 1; ; attempt++ {
+        err := d.inTx(ctx, fn)
+        if err == nil || attempt == maxTxAttempts || !isRetryable(err) {
+            return err
+        }
+
+        select {
+        case <-ctx.Done():
+            return err
+        case <-time.After(time.Duration(attempt) * txRetryDelay):
+        }
+    }
+}
+
+func (d *DB) inTx(ctx context.Context, fn func(Store) error) error {
+    tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+    if err != nil {
+        return err
+    }
+    // Rolling back after a commit is a no-op; this covers errors and panics.
+    defer tx.Rollback()
+
+    if err := fn(New(d.dialect, d.wrap(tx))); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// isRetryable reports whether err means the transaction lost a race with
+// another one and can simply be run again.
+func isRetryable(err error) bool {
+    var pqErr *pq.Error
+    if errors.As(err, &pqErr) {
+        // serialization_failure and deadlock_detected
+        return pqErr.Code == "40001" || pqErr.Code == "40P01"
+    }
+
+    var sqliteErr sqlite3.Error
+    if errors.As(err, &sqliteErr) {
+        return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+    }
+    return false
+}
+
+// IsUniqueViolation reports whether err means a write was rejected by a
+// unique constraint, whichever backend reported it.
+func IsUniqueViolation(err error) bool {
+    var pqErr *pq.Error
+    if errors.As(err, &pqErr) {
+        // unique_violation
+        return pqErr.Code == "23505"
+    }
+
+    var sqliteErr sqlite3.Error
+    if errors.As(err, &sqliteErr) {
+        return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+    }
+    return errors.Is(err, errDuplicateEmail) || errors.Is(err, errDuplicateToken) || errors.Is(err, errDuplicateID)
+}
+
+// InTx runs fn against the store and restores the store's previous contents
+// if fn fails. Writes from outside fn that happen meanwhile are also undone, so
+// Memory's transactions are only suitable for tests.
+func (m *Memory) InTx(ctx context.Context, fn func(Store) error) error {
+    m.txMu.Lock()
+    defer m.txMu.Unlock()
+
+    m.mu.Lock()
+    saved := m.snapshot()
+    m.mu.Unlock()
+
+    if err := fn(m); err != nil {
+        m.mu.Lock()
+        m.restore(saved)
+        m.mu.Unlock()
+        return err
+    }
+    return nil
+}
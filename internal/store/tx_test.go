@@ -0,0 +1,32 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+    tests := []struct {
+        name string
+        err error
+        want bool
+    }{
+        {name: "Serialization failure", err: &pq.Error{Code: "40001"}, want: true},
+        {name: "Deadlock", err: &pq.Error{Code: "40P01"}, want: true},
+        {name: "Wrapped", err: fmt.Errorf("creating chirp: %w", &pq.Error{Code: "40001"}), want: true},
+        {name: "Unique violation", err: &pq.Error{Code: "23505"}, want: false},
+        {name: "SQLite busy", err: sqlite3.Error{Code: sqlite3.ErrBusy}, want: true},
+        {name: "SQLite constraint", err: sqlite3.Error{Code: sqlite3.ErrConstraint}, want: false},
+        {name: "Other errors", err: errors.New("boom"), want: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            assert.Equal(t, tt.want, isRetryable(tt.err))
+        })
+    }
+}
@@ -0,0 +1,127 @@
+// Package tokengc deletes refresh tokens that can no longer be used: those
+// past their expiry, and those revoked longer ago than a retention window.
+// Revoked tokens are kept for a while so that recent sessions can still be
+// looked up when investigating an account. Sweeps run on the job queue, so a
+// failed one is retried and shows up among the jobs.
+package tokengc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/jobs"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/store"
+)
+
+// JobKind is the kind of the jobs that sweep the table.
+const JobKind = "tokengc.sweep"
+
+// Result counts the tokens a sweep deleted.
+type Result struct {
+    Expired int64
+    Revoked int64
+}
+
+// Sweeper deletes stale refresh tokens in batches, each in its own
+// statement, so no sweep holds locks on many rows at once.
+type Sweeper struct {
+    store store.TokenStore
+    metrics *metrics.Metrics
+
+    Interval time.Duration
+    RevokedRetention time.Duration
+    BatchSize int32
+}
+
+func NewSweeper(s store.TokenStore, cfg config.TokenGCConfig, m *metrics.Metrics) *Sweeper {
+    return &Sweeper{
+        store: s,
+        metrics: m,
+        Interval: cfg.Interval,
+        RevokedRetention: cfg.RevokedRetention,
+        BatchSize: int32(cfg.BatchSize),
+    }
+}
+
+// Run queues a sweep through q once at startup and then every Interval until
+// ctx is cancelled, for a job worker with Handle registered to run. Sweeps are
+// idempotent and skip rows locked by another sweep, so every worker process
+// can queue them.
+func (s *Sweeper) Run(ctx context.Context, q store.JobStore) {
+    ticker := time.NewTicker(s.Interval)
+    defer ticker.Stop()
+
+    for {
+        if _, err := jobs.Enqueue(ctx, q, JobKind, struct{}{}, time.Time{}); err != nil && ctx.Err() == nil {
+            slog.Error("failed to queue refresh token sweep", "error", err)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Handle is the jobs.Handler for JobKind. A failed sweep is retried from the
+// start, keeping the batches it already deleted.
+func (s *Sweeper) Handle(ctx context.Context, job database.Job) error {
+    res, err := s.Sweep(ctx)
+    if res.Expired > 0 || res.Revoked > 0 {
+        slog.Info("deleted stale refresh tokens", "expired", res.Expired, "revoked", res.Revoked)
+    }
+    return err
+}
+
+// Sweep deletes every stale token, batch by batch, and returns how many it
+// deleted, including those deleted before an error.
+func (s *Sweeper) Sweep(ctx context.Context) (Result, error) {
+    var res Result
+
+    expired, err := s.drain(ctx, metrics.TokenExpired, func() (int64, error) {
+        return s.store.DeleteExpiredRefreshTokens(ctx, s.BatchSize)
+    })
+    res.Expired = expired
+    if err != nil {
+        return res, fmt.Errorf("deleting expired tokens: %w", err)
+    }
+
+    revokedBefore := time.Now().UTC().Add(-s.RevokedRetention)
+    revoked, err := s.drain(ctx, metrics.TokenRevoked, func() (int64, error) {
+        return s.store.DeleteRevokedRefreshTokens(ctx, database.DeleteRevokedRefreshTokensParams{
+            RevokedBefore: revokedBefore,
+            BatchSize: s.BatchSize,
+        })
+    })
+    res.Revoked = revoked
+    if err != nil {
+        return res, fmt.Errorf("deleting revoked tokens: %w", err)
+    }
+    return res, nil
+}
+
+// drain runs deleteBatch until a batch comes back short.
+func (s *Sweeper) drain(ctx context.Context, reason string, deleteBatch func() (int64, error)) (int64, error) {
+    var total int64
+    for {
+        if err := ctx.Err(); err != nil {
+            return total, err
+        }
+
+        n, err := deleteBatch()
+        if err != nil {
+            return total, err
+        }
+        total += n
+        s.metrics.RefreshTokensDeleted(reason, n)
+        if n < int64(s.BatchSize) {
+            return total, nil
+        }
+    }
+}
@@ -0,0 +1,104 @@
+package tokengc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/jobs"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweep(t *testing.T) {
+    ctx := context.Background()
+    s := store.NewMemory()
+    user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "user@example.com"})
+    require.NoError(t, err)
+
+    create := func(token string, expiresIn time.Duration) {
+        _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: token, UserID: user.ID, ExpiresAt: time.Now().Add(expiresIn)})
+        require.NoError(t, err)
+    }
+    for i := range 5 {
+        create(fmt.Sprintf("expired-%d", i), -time.Minute)
+    }
+    create("revoked", time.Hour)
+    _, err = s.RevokeRefreshToken(ctx, "revoked")
+    require.NoError(t, err)
+    create("live", time.Hour)
+
+    m := metrics.New(nil)
+    sweeper := NewSweeper(s, config.TokenGCConfig{Interval: time.Hour, BatchSize: 2}, m)
+
+    res, err := sweeper.Sweep(ctx)
+
+    require.NoError(t, err)
+    assert.Equal(t, Result{Expired: 5, Revoked: 1}, res)
+    _, err = s.GetRefreshToken(ctx, "live")
+    assert.NoError(t, err)
+    _, err = s.GetRefreshToken(ctx, "revoked")
+    assert.ErrorIs(t, err, sql.ErrNoRows)
+
+    samples, err := m.Snapshot()
+    require.NoError(t, err)
+    deleted := map[string]float64{}
+    for _, sample := range samples {
+        if sample.Name == "chirpy_refresh_tokens_deleted_total" {
+            deleted[sample.Labels] = sample.Value
+        }
+    }
+    assert.Equal(t, map[string]float64{"reason=expired": 5, "reason=revoked": 1}, deleted)
+}
+
+func TestSweepKeepsRecentlyRevokedTokens(t *testing.T) {
+    ctx := context.Background()
+    s := store.NewMemory()
+    user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "user@example.com"})
+    require.NoError(t, err)
+    _, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "revoked", UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)})
+    require.NoError(t, err)
+    _, err = s.RevokeRefreshToken(ctx, "revoked")
+    require.NoError(t, err)
+
+    sweeper := NewSweeper(s, config.TokenGCConfig{Interval: time.Hour, RevokedRetention: time.Hour, BatchSize: 100}, metrics.New(nil))
+    res, err := sweeper.Sweep(ctx)
+
+    require.NoError(t, err)
+    assert.Equal(t, Result{}, res)
+    _, err = s.GetRefreshToken(ctx, "revoked")
+    assert.NoError(t, err)
+}
+
+func TestSweepsRunAsJobs(t *testing.T) {
+    s := store.NewMemory()
+    user, err := s.CreateUser(context.Background(), database.CreateUserParams{Email: "user@example.com"})
+    require.NoError(t, err)
+    _, err = s.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{Token: "expired", UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)})
+    require.NoError(t, err)
+
+    m := metrics.New(nil)
+    sweeper := NewSweeper(s, config.TokenGCConfig{Interval: time.Hour, BatchSize: 100}, m)
+    worker := jobs.NewWorker(s, config.JobsConfig{PollInterval: time.Hour, Concurrency: 1, MaxAttempts: 3}, m)
+    worker.Handle(JobKind, sweeper.Handle)
+
+    // Both loops go round once before seeing the cancelled context.
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    sweeper.Run(ctx, s)
+    worker.Run(ctx)
+
+    _, err = s.GetRefreshToken(context.Background(), "expired")
+    assert.ErrorIs(t, err, sql.ErrNoRows)
+    queued, err := s.ListJobs(context.Background(), database.ListJobsParams{MaxRows: 10})
+    require.NoError(t, err)
+    require.Len(t, queued, 1)
+    assert.Equal(t, JobKind, queued[0].Kind)
+    assert.Equal(t, jobs.StatusSucceeded, queued[0].Status)
+}
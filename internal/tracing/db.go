@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var queryName = regexp.MustCompile(`^-- name: (\w+)`)
+
+// DB wraps a database.DBTX so that every sqlc query runs in a client span
+// named after the query, e.g. "db.GetUserByEmail".
+type DB struct {
+    db database.DBTX
+    system attribute.KeyValue
+}
+
+// WrapDB wraps db, whose dialect is one reported by store.Open.
+func WrapDB(db database.DBTX, dialect string) DB {
+    system := semconv.DBSystemPostgreSQL
+    if dialect == "sqlite" {
+        system = semconv.DBSystemSqlite
+    }
+    return DB{db: db, system: system}
+}
+
+func (d DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+    ctx, span := d.startQuery(ctx, query)
+    defer span.End()
+
+    res, err := d.db.ExecContext(ctx, query, args...)
+    recordError(span, err)
+    return res, err
+}
+
+func (d DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+    ctx, span := d.startQuery(ctx, query)
+    defer span.End()
+
+    stmt, err := d.db.PrepareContext(ctx, query)
+    recordError(span, err)
+    return stmt, err
+}
+
+func (d DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+    ctx, span := d.startQuery(ctx, query)
+    defer span.End()
+
+    rows, err := d.db.QueryContext(ctx, query, args...)
+    recordError(span, err)
+    return rows, err
+}
+
+func (d DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+    ctx, span := d.startQuery(ctx, query)
+    defer span.End()
+
+    row := d.db.QueryRowContext(ctx, query, args...)
+    recordError(span, row.Err())
+    return row
+}
+
+func (d DB) startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+    name := "db.query"
+    operation := "query"
+    if m := queryName.FindStringSubmatch(query); m != nil {
+        name = "db." + m[1]
+        operation = m[1]
+    }
+
+    return Tracer().Start(ctx, name,
+        trace.WithSpanKind(trace.SpanKindClient),
+        trace.WithAttributes(
+            d.system,
+            semconv.DBOperationName(operation),
+            semconv.DBQueryText(query),
+        ),
+    )
+}
+
+func recordError(span trace.Span, err error) {
+    if err == nil || errors.Is(err, sql.ErrNoRows) {
+        return
+    }
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+}
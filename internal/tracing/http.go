@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing any trace
+// propagated by the client in the traceparent header.
+func Middleware(next http.Handler) http.Handler {
+    return otelhttp.NewHandler(next, "http.server")
+}
+
+// NameRoutes renames the server span after the ServeMux pattern that matched
+// the request. The mux records the pattern on the request it is given, so
+// this must wrap the mux with nothing in between that copies the request.
+func NameRoutes(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        next.ServeHTTP(w, req)
+
+        if req.Pattern == "" {
+            return
+        }
+        NameRoute(req.Context(), req.Pattern)
+    })
+}
+
+// NameRoute renames the server span in ctx after the ServeMux pattern, for
+// middleware that answers before the mux sees the request.
+func NameRoute(ctx context.Context, pattern string) {
+    span := trace.SpanFromContext(ctx)
+    span.SetName(pattern)
+    route := pattern
+    if _, path, ok := strings.Cut(route, " "); ok {
+        route = path
+    }
+    span.SetAttributes(semconv.HTTPRoute(route))
+}
+
+// Transport wraps base so that outgoing requests get a client span and carry
+// the traceparent header.
+func Transport(base http.RoundTripper) http.RoundTripper {
+    return otelhttp.NewTransport(base)
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/bamcmanus/Chirpy"
+
+func Tracer() trace.Tracer {
+    return otel.Tracer(tracerName)
+}
+
+// Setup installs the W3C trace context propagator and a global tracer
+// provider exporting to the exporter named in cfg: "otlp", "stdout" or
+// "none". The returned function flushes pending spans and stops the exporter.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+    var exporter sdktrace.SpanExporter
+    var closer io.Closer
+    switch cfg.Exporter {
+    case "none":
+        return func(context.Context) error { return nil }, nil
+    case "stdout":
+        var w io.Writer = os.Stdout
+        if cfg.File != "" {
+            f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+            if err != nil {
+                return nil, fmt.Errorf("opening trace file: %w", err)
+            }
+            w, closer = f, f
+        }
+
+        var err error
+        exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+        if err != nil {
+            return nil, fmt.Errorf("creating stdout exporter: %w", err)
+        }
+    case "otlp":
+        var opts []otlptracehttp.Option
+        if cfg.Endpoint != "" {
+            opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+        }
+
+        var err error
+        exporter, err = otlptracehttp.New(ctx, opts...)
+        if err != nil {
+            return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+        }
+    default:
+        return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+    }
+
+    provider := sdktrace.NewTracerProvider(
+        sdktrace.WithBatcher(exporter),
+        sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))),
+    )
+    otel.SetTracerProvider(provider)
+
+    return func(ctx context.Context) error {
+        err := provider.Shutdown(ctx)
+        if closer != nil {
+            closer.Close()
+        }
+        return err
+    }, nil
+}
+
+// Inject returns the W3C traceparent for the span in ctx, or "" if there is
+// none. It is used to carry a trace across the webhook delivery queue.
+func Inject(ctx context.Context) string {
+    carrier := propagation.MapCarrier{}
+    otel.GetTextMapPropagator().Inject(ctx, carrier)
+    return carrier.Get("traceparent")
+}
+
+// Extract returns a copy of ctx continuing the trace in traceparent.
+func Extract(ctx context.Context, traceparent string) context.Context {
+    if traceparent == "" {
+        return ctx
+    }
+    return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type fakeDB struct {
+    err error
+}
+
+func (f fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+    return nil, f.err
+}
+
+func (f fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+    return nil, f.err
+}
+
+func (f fakeDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+    return nil, f.err
+}
+
+func (f fakeDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+    return nil
+}
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+    _, err := Setup(context.Background(), config.TracingConfig{Exporter: "none"})
+    require.NoError(t, err)
+
+    recorder := tracetest.NewSpanRecorder()
+    previous := otel.GetTracerProvider()
+    otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+    t.Cleanup(func() { otel.SetTracerProvider(previous) })
+    return recorder
+}
+
+func TestDB(t *testing.T) {
+    t.Run("Spans are named after the sqlc query", func(t *testing.T) {
+        recorder := recordSpans(t)
+
+        _, err := WrapDB(fakeDB{}, "postgres").ExecContext(context.Background(), "-- name: DeleteChirp :exec\nDELETE FROM chirps WHERE id = $1")
+
+        require.NoError(t, err)
+        spans := recorder.Ended()
+        require.Len(t, spans, 1)
+        assert.Equal(t, "db.DeleteChirp", spans[0].Name())
+        assert.Equal(t, codes.Unset, spans[0].Status().Code)
+    })
+
+    t.Run("Errors are recorded", func(t *testing.T) {
+        recorder := recordSpans(t)
+
+        _, err := WrapDB(fakeDB{err: errors.New("connection refused")}, "postgres").QueryContext(context.Background(), "SELECT 1")
+
+        assert.Error(t, err)
+        spans := recorder.Ended()
+        require.Len(t, spans, 1)
+        assert.Equal(t, "db.query", spans[0].Name())
+        assert.Equal(t, codes.Error, spans[0].Status().Code)
+    })
+}
+
+func TestNameRoutes(t *testing.T) {
+    recorder := recordSpans(t)
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, req *http.Request) {})
+
+    req := httptest.NewRequest(http.MethodGet, "/api/chirps/123", nil)
+    req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+    Middleware(NameRoutes(mux)).ServeHTTP(httptest.NewRecorder(), req)
+
+    spans := recorder.Ended()
+    require.Len(t, spans, 1)
+    assert.Equal(t, "GET /api/chirps/{chirpID}", spans[0].Name())
+    assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+}
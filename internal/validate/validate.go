@@ -0,0 +1,132 @@
+// Package validate checks request structs against rules declared in their
+// validate struct tags, e.g.
+//
+//	Email string `json:"email" validate:"required,email,max=254"`
+//
+// Rules are separated by commas. Every rule but required is skipped for a
+// zero value, so optional fields are only checked when they are set.
+//
+//   - required: the field must not be the zero value
+//   - email: a bare email address such as user@example.com
+//   - min=N, max=N: a string's length in characters, or a slice's length
+//   - password: the password policy, see MinPasswordLength
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bamcmanus/Chirpy/internal/problem"
+)
+
+const (
+    MinPasswordLength = 8
+    // MaxPasswordBytes is the most bcrypt will hash.
+    MaxPasswordBytes = 72
+)
+
+// Struct checks the fields of the struct v points to and returns one
+// FieldError per field that breaks a rule. Fields are named by their JSON
+// names. It panics if a tag has an unknown rule, as that is a programming
+// error.
+func Struct(v any) []problem.FieldError {
+    rv := reflect.Indirect(reflect.ValueOf(v))
+    rt := rv.Type()
+
+    var errs []problem.FieldError
+    for i := range rt.NumField() {
+        field := rt.Field(i)
+        tag, ok := field.Tag.Lookup("validate")
+        if !ok {
+            continue
+        }
+        name := jsonName(field)
+        if fe, ok := checkField(name, rv.Field(i), tag); !ok {
+            errs = append(errs, fe)
+        }
+    }
+    return errs
+}
+
+func jsonName(field reflect.StructField) string {
+    name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+    if name == "" || name == "-" {
+        return field.Name
+    }
+    return name
+}
+
+// checkField applies the rules in tag to v, stopping at the first one that
+// fails.
+func checkField(name string, v reflect.Value, tag string) (problem.FieldError, bool) {
+    for _, rule := range strings.Split(tag, ",") {
+        rule, arg, _ := strings.Cut(rule, "=")
+        if rule != "required" && v.IsZero() {
+            continue
+        }
+
+        switch rule {
+        case "required":
+            if v.IsZero() {
+                return fieldError(name, "required", "%s is required", name), false
+            }
+        case "email":
+            s := v.String()
+            addr, err := mail.ParseAddress(s)
+            if err != nil || addr.Address != s {
+                return fieldError(name, "invalid_email", "%s must be a valid email address", name), false
+            }
+        case "min":
+            if n := mustAtoi(rule, arg); length(v) < n {
+                return fieldError(name, "too_short", "%s must be at least %d %s", name, n, unit(v)), false
+            }
+        case "max":
+            if n := mustAtoi(rule, arg); length(v) > n {
+                return fieldError(name, "too_long", "%s must be at most %d %s", name, n, unit(v)), false
+            }
+        case "password":
+            s := v.String()
+            if utf8.RuneCountInString(s) < MinPasswordLength {
+                return fieldError(name, "too_short", "%s must be at least %d characters", name, MinPasswordLength), false
+            }
+            if len(s) > MaxPasswordBytes {
+                return fieldError(name, "too_long", "%s must be at most %d bytes", name, MaxPasswordBytes), false
+            }
+        default:
+            panic(fmt.Sprintf("validate: unknown rule %q on %s", rule, name))
+        }
+    }
+    return problem.FieldError{}, true
+}
+
+func fieldError(name, code, format string, args ...any) problem.FieldError {
+    return problem.FieldError{Field: name, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// length counts characters rather than bytes, so a limit means the same
+// for every language.
+func length(v reflect.Value) int {
+    if v.Kind() == reflect.String {
+        return utf8.RuneCountInString(v.String())
+    }
+    return v.Len()
+}
+
+func unit(v reflect.Value) string {
+    if v.Kind() == reflect.String {
+        return "characters"
+    }
+    return "items"
+}
+
+func mustAtoi(rule, arg string) int {
+    n, err := strconv.Atoi(arg)
+    if err != nil {
+        panic(fmt.Sprintf("validate: %s needs a number, got %q", rule, arg))
+    }
+    return n
+}
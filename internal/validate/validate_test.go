@@ -0,0 +1,87 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+type signup struct {
+    Email string `json:"email" validate:"required,email,max=254"`
+    Password string `json:"password" validate:"required,password"`
+    Bio string `json:"bio" validate:"max=5"`
+    Tags []string `json:"tags" validate:"min=1"`
+    Untagged string
+}
+
+func TestStruct(t *testing.T) {
+    valid := signup{Email: "user@example.com", Password: "correct horse"}
+
+    tests := []struct {
+        name string
+        modify func(s *signup)
+        want []problem.FieldError
+    }{
+        {name: "Valid", modify: func(s *signup) {}},
+        {
+            name: "Missing required fields",
+            modify: func(s *signup) { *s = signup{} },
+            want: []problem.FieldError{
+                {Field: "email", Code: "required", Message: "email is required"},
+                {Field: "password", Code: "required", Message: "password is required"},
+            },
+        },
+        {
+            name: "Invalid email",
+            modify: func(s *signup) { s.Email = "not an email" },
+            want: []problem.FieldError{{Field: "email", Code: "invalid_email", Message: "email must be a valid email address"}},
+        },
+        {
+            name: "Email with a display name",
+            modify: func(s *signup) { s.Email = "Saul <saul@example.com>" },
+            want: []problem.FieldError{{Field: "email", Code: "invalid_email", Message: "email must be a valid email address"}},
+        },
+        {
+            name: "Short password",
+            modify: func(s *signup) { s.Password = "hunter2" },
+            want: []problem.FieldError{{Field: "password", Code: "too_short", Message: "password must be at least 8 characters"}},
+        },
+        {
+            name: "Password too long for bcrypt",
+            modify: func(s *signup) { s.Password = strings.Repeat("é", 37) },
+            want: []problem.FieldError{{Field: "password", Code: "too_long", Message: "password must be at most 72 bytes"}},
+        },
+        {
+            name: "Length counts characters, not bytes",
+            modify: func(s *signup) { s.Bio = "🐦🐦🐦🐦🐦" },
+        },
+        {
+            name: "Too long",
+            modify: func(s *signup) { s.Bio = "chirps" },
+            want: []problem.FieldError{{Field: "bio", Code: "too_long", Message: "bio must be at most 5 characters"}},
+        },
+        {
+            name: "Slices count items",
+            modify: func(s *signup) { s.Tags = []string{} },
+            want: []problem.FieldError{{Field: "tags", Code: "too_short", Message: "tags must be at least 1 items"}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := valid
+            tt.modify(&s)
+
+            assert.Equal(t, tt.want, Struct(&s))
+        })
+    }
+}
+
+func TestUnknownRulePanics(t *testing.T) {
+    v := struct {
+        Name string `validate:"shiny"`
+    }{Name: "x"}
+
+    assert.Panics(t, func() { Struct(&v) })
+}
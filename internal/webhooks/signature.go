@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SignatureHeader = "Chirpy-Signature"
+
+// Sign returns the value of the Chirpy-Signature header for a payload, in the
+// form "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">".
+// Including the timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+    ts := strconv.FormatInt(timestamp.Unix(), 10)
+    return "t=" + ts + ",v1=" + hex.EncodeToString(computeMAC(secret, ts, body))
+}
+
+// Verify checks a Chirpy-Signature header against the payload and rejects
+// signatures older than tolerance.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+    var ts, sig string
+    for _, part := range strings.Split(header, ",") {
+        key, value, _ := strings.Cut(part, "=")
+        switch key {
+        case "t":
+            ts = value
+        case "v1":
+            sig = value
+        }
+    }
+    if ts == "" || sig == "" {
+        return errors.New("malformed signature header")
+    }
+
+    unix, err := strconv.ParseInt(ts, 10, 64)
+    if err != nil {
+        return errors.New("malformed signature timestamp")
+    }
+    if time.Since(time.Unix(unix, 0)) > tolerance {
+        return errors.New("signature timestamp outside tolerance")
+    }
+
+    expected, err := hex.DecodeString(sig)
+    if err != nil {
+        return errors.New("malformed signature")
+    }
+    if !hmac.Equal(expected, computeMAC(secret, ts, body)) {
+        return errors.New("signature mismatch")
+    }
+    return nil
+}
+
+func computeMAC(secret, timestamp string, body []byte) []byte {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(timestamp))
+    mac.Write([]byte("."))
+    mac.Write(body)
+    return mac.Sum(nil)
+}
@@ -0,0 +1,105 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+    EventChirpCreated = "chirp.created"
+    EventChirpDeleted = "chirp.deleted"
+    EventUserUpdated = "user.updated"
+)
+
+// Events lists every event type a webhook can subscribe to.
+var Events = []string{EventChirpCreated, EventChirpDeleted, EventUserUpdated}
+
+func IsValidEvent(event string) bool {
+    return slices.Contains(Events, event)
+}
+
+// Event is something that happened in Chirpy. UserID is the user the event is
+// about; user.* events are only delivered to webhooks owned by that user.
+type Event struct {
+    Type string
+    UserID uuid.UUID
+    Data any
+}
+
+type envelope struct {
+    Id uuid.UUID `json:"id"`
+    Event string `json:"event"`
+    CreatedAt time.Time `json:"created_at"`
+    Data any `json:"data"`
+}
+
+type Dispatcher struct {
+    store store.WebhookStore
+}
+
+func NewDispatcher(s store.WebhookStore) Dispatcher {
+    return Dispatcher{
+        store: s,
+    }
+}
+
+// With returns a Dispatcher that queues deliveries through s, so that they
+// are written in the same transaction as the change that caused the event.
+func (d Dispatcher) With(s store.WebhookStore) Dispatcher {
+    return Dispatcher{
+        store: s,
+    }
+}
+
+// Emit queues a delivery of the event for every active webhook subscribed to
+// it. Deliveries are sent asynchronously by the Worker.
+func (d Dispatcher) Emit(ctx context.Context, event Event) error {
+    hooks, err := d.store.ListActiveWebhooksForEvent(ctx, event.Type)
+    if err != nil {
+        return fmt.Errorf("listing webhooks for %s: %w", event.Type, err)
+    }
+    if len(hooks) == 0 {
+        return nil
+    }
+
+    payload, err := json.Marshal(envelope{
+        Id: uuid.New(),
+        Event: event.Type,
+        CreatedAt: time.Now().UTC(),
+        Data: event.Data,
+    })
+    if err != nil {
+        return fmt.Errorf("marshalling %s payload: %w", event.Type, err)
+    }
+
+    for _, hook := range hooks {
+        if isUserScoped(event.Type) && hook.UserID != event.UserID {
+            continue
+        }
+
+        params := database.CreateWebhookDeliveryParams{
+            WebhookID: hook.ID,
+            Event: event.Type,
+            Payload: payload,
+            TraceContext: tracing.Inject(ctx),
+        }
+        if _, err := d.store.CreateWebhookDelivery(ctx, params); err != nil {
+            return fmt.Errorf("queueing delivery for webhook %s: %w", hook.ID, err)
+        }
+    }
+
+    return nil
+}
+
+func isUserScoped(event string) bool {
+    return strings.HasPrefix(event, "user.")
+}
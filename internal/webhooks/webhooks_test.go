@@ -0,0 +1,50 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+    body := []byte(`{"event":"chirp.created"}`)
+
+    t.Run("Happy path", func(t *testing.T) {
+        header := Sign("whsec", time.Now(), body)
+
+        assert.NoError(t, Verify("whsec", header, body, time.Minute))
+    })
+
+    t.Run("Wrong secret fails", func(t *testing.T) {
+        header := Sign("whsec", time.Now(), body)
+
+        assert.EqualError(t, Verify("not-the-secret", header, body, time.Minute), "signature mismatch")
+    })
+
+    t.Run("Tampered body fails", func(t *testing.T) {
+        header := Sign("whsec", time.Now(), body)
+
+        assert.EqualError(t, Verify("whsec", header, []byte(`{}`), time.Minute), "signature mismatch")
+    })
+
+    t.Run("Stale timestamp fails", func(t *testing.T) {
+        header := Sign("whsec", time.Now().Add(-time.Hour), body)
+
+        assert.EqualError(t, Verify("whsec", header, body, time.Minute), "signature timestamp outside tolerance")
+    })
+}
+
+func TestBackoff(t *testing.T) {
+    assert.Equal(t, 30 * time.Second, Backoff(1))
+    assert.Equal(t, 60 * time.Second, Backoff(2))
+    assert.Equal(t, 4 * time.Minute, Backoff(4))
+    assert.Equal(t, 6 * time.Hour, Backoff(20))
+}
+
+func TestEvents(t *testing.T) {
+    assert.True(t, IsValidEvent(EventChirpCreated))
+    assert.False(t, IsValidEvent("chirp.liked"))
+    assert.True(t, isUserScoped(EventUserUpdated))
+    assert.False(t, isUserScoped(EventChirpDeleted))
+}
@@ -0,0 +1,282 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+    StatusPending = "pending"
+    StatusSucceeded = "succeeded"
+    StatusFailed = "failed"
+)
+
+const (
+    deliveryTimeout = 10 * time.Second
+
+    backoffBase = 30 * time.Second
+    backoffMax = 6 * time.Hour
+)
+
+// Worker polls the webhook_deliveries queue and sends due deliveries. Several
+// workers can run against the same database; claimed rows are locked with
+// SKIP LOCKED so each delivery is only picked up once.
+type Worker struct {
+    store store.WebhookStore
+    tx store.Transactor
+    client *http.Client
+    metrics *metrics.Metrics
+    lastPoll atomic.Int64
+
+    BatchSize int32
+    PollInterval time.Duration
+    // MaxAttempts is the number of attempts after which a delivery is
+    // marked failed and no longer retried.
+    MaxAttempts int32
+    // DisableAfter is the number of consecutive failed attempts, across all
+    // deliveries, after which a webhook is disabled.
+    DisableAfter int32
+}
+
+func NewWorker(s store.WebhookStore, tx store.Transactor, cfg config.WebhooksConfig, m *metrics.Metrics) *Worker {
+    return &Worker{
+        store: s,
+        tx: tx,
+        client: &http.Client{
+            Timeout: deliveryTimeout,
+            Transport: tracing.Transport(http.DefaultTransport),
+        },
+        metrics: m,
+        BatchSize: int32(cfg.BatchSize),
+        PollInterval: cfg.PollInterval,
+        MaxAttempts: int32(cfg.MaxAttempts),
+        DisableAfter: int32(cfg.DisableAfter),
+    }
+}
+
+// Run delivers due webhooks until ctx is cancelled. A delivery that is already
+// in flight when ctx is cancelled is allowed to finish so its outcome is
+// recorded; no new deliveries are claimed afterwards.
+func (w *Worker) Run(ctx context.Context) {
+    ticker := time.NewTicker(w.PollInterval)
+    defer ticker.Stop()
+
+    for {
+        w.lastPoll.Store(time.Now().UnixNano())
+        w.deliverDue(ctx)
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Check is a readiness check reporting whether the worker has polled the
+// queue recently.
+func (w *Worker) Check(ctx context.Context) error {
+    last := w.lastPoll.Load()
+    if last == 0 {
+        return errors.New("webhook worker has not started")
+    }
+
+    if since := time.Since(time.Unix(0, last)); since > 3 * w.PollInterval + deliveryTimeout {
+        return fmt.Errorf("webhook worker last polled %s ago", since.Round(time.Second))
+    }
+    return nil
+}
+
+func (w *Worker) deliverDue(ctx context.Context) {
+    deliveries, err := w.store.ClaimDueWebhookDeliveries(ctx, w.BatchSize)
+    if err != nil {
+        if ctx.Err() == nil {
+            slog.Error("failed to claim webhook deliveries", "error", err)
+        }
+        return
+    }
+
+    // Claimed rows are leased for a few minutes, so any that are skipped
+    // here because of shutdown are picked up again once the lease expires.
+    for _, delivery := range deliveries {
+        if ctx.Err() != nil {
+            return
+        }
+        w.lastPoll.Store(time.Now().UnixNano())
+        if err := w.deliver(context.WithoutCancel(ctx), delivery); err != nil {
+            slog.Error("failed to process webhook delivery", "delivery_id", delivery.ID, "error", err)
+        }
+    }
+}
+
+func (w *Worker) deliver(ctx context.Context, delivery database.WebhookDelivery) (err error) {
+    // Continue the trace of the request that emitted the event, so the
+    // delivery shows up alongside it.
+    ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, delivery.TraceContext), "webhook.deliver",
+        trace.WithAttributes(
+            attribute.String("webhook.delivery_id", delivery.ID.String()),
+            attribute.String("webhook.event", delivery.Event),
+        ),
+    )
+    defer func() {
+        if err != nil {
+            span.RecordError(err)
+            span.SetStatus(codes.Error, err.Error())
+        }
+        span.End()
+    }()
+
+    hook, err := w.store.GetWebhook(ctx, delivery.WebhookID)
+    if err != nil {
+        return fmt.Errorf("fetching webhook: %w", err)
+    }
+
+    if hook.DisabledAt.Valid {
+        return w.store.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+            ID: delivery.ID,
+            Status: StatusFailed,
+            NextAttemptAt: delivery.NextAttemptAt,
+        })
+    }
+
+    start := time.Now()
+    code, sendErr := w.send(ctx, hook, delivery)
+    if sendErr != nil {
+        span.SetStatus(codes.Error, sendErr.Error())
+    }
+    attempt := database.CreateWebhookDeliveryAttemptParams{
+        DeliveryID: delivery.ID,
+        DurationMs: int32(time.Since(start).Milliseconds()),
+    }
+    if code != 0 {
+        attempt.ResponseCode = sql.NullInt32{Int32: int32(code), Valid: true}
+    }
+    if sendErr != nil {
+        attempt.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+    }
+    if sendErr == nil {
+        slog.Info("delivered webhook", "webhook_id", hook.ID, "delivery_id", delivery.ID, "event", delivery.Event, "status", code)
+    } else {
+        slog.Warn("webhook delivery failed", "webhook_id", hook.ID, "delivery_id", delivery.ID, "event", delivery.Event, "status", code, "error", sendErr)
+    }
+
+    // The attempt, the delivery's new state and the webhook's failure count
+    // are recorded together, so a crash can't leave them disagreeing.
+    var outcomes []string
+    err = w.tx.InTx(ctx, func(s store.Store) error {
+        var err error
+        outcomes, err = w.record(ctx, s, hook, delivery, attempt, sendErr)
+        return err
+    })
+    if err != nil {
+        return err
+    }
+    for _, outcome := range outcomes {
+        w.metrics.WebhookDelivery(outcome)
+    }
+    return nil
+}
+
+// record stores the result of an attempt and returns the metrics outcomes it
+// led to.
+func (w *Worker) record(ctx context.Context, s store.WebhookStore, hook database.Webhook, delivery database.WebhookDelivery, attempt database.CreateWebhookDeliveryAttemptParams, sendErr error) ([]string, error) {
+    if err := s.CreateWebhookDeliveryAttempt(ctx, attempt); err != nil {
+        return nil, fmt.Errorf("recording attempt: %w", err)
+    }
+
+    if sendErr == nil {
+        if err := s.MarkWebhookDeliverySucceeded(ctx, delivery.ID); err != nil {
+            return nil, fmt.Errorf("marking delivery succeeded: %w", err)
+        }
+        if err := s.RecordWebhookSuccess(ctx, hook.ID); err != nil {
+            return nil, fmt.Errorf("recording webhook success: %w", err)
+        }
+        return []string{metrics.WebhookSucceeded}, nil
+    }
+
+    attempts := delivery.Attempts + 1
+    params := database.MarkWebhookDeliveryFailedParams{
+        ID: delivery.ID,
+        Status: StatusPending,
+        NextAttemptAt: time.Now().UTC().Add(Backoff(attempts)),
+    }
+    outcomes := []string{metrics.WebhookRetrying}
+    if attempts >= w.MaxAttempts {
+        params.Status = StatusFailed
+        outcomes = []string{metrics.WebhookAbandoned}
+    }
+    if err := s.MarkWebhookDeliveryFailed(ctx, params); err != nil {
+        return nil, fmt.Errorf("marking delivery failed: %w", err)
+    }
+
+    hook, err := s.RecordWebhookFailure(ctx, hook.ID)
+    if err != nil {
+        return nil, fmt.Errorf("recording webhook failure: %w", err)
+    }
+    if hook.ConsecutiveFailures >= w.DisableAfter {
+        slog.Warn("disabling webhook", "webhook_id", hook.ID, "consecutive_failures", hook.ConsecutiveFailures)
+        if err := s.DisableWebhook(ctx, hook.ID); err != nil {
+            return nil, fmt.Errorf("disabling webhook: %w", err)
+        }
+        outcomes = append(outcomes, metrics.WebhookDisabled)
+    }
+    return outcomes, nil
+}
+
+func (w *Worker) send(ctx context.Context, hook database.Webhook, delivery database.WebhookDelivery) (int, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(delivery.Payload))
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+    req.Header.Set("Chirpy-Event", delivery.Event)
+    req.Header.Set("Chirpy-Delivery", delivery.ID.String())
+    req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now(), delivery.Payload))
+
+    res, err := w.client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer res.Body.Close()
+    _, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+    if res.StatusCode < 200 || res.StatusCode > 299 {
+        return res.StatusCode, errors.New("non-2xx response")
+    }
+    return res.StatusCode, nil
+}
+
+// Backoff returns how long to wait before retrying a delivery that has failed
+// attempts times. The delay doubles with each attempt, up to six hours.
+func Backoff(attempts int32) time.Duration {
+    if attempts < 1 {
+        return backoffBase
+    }
+
+    delay := backoffBase
+    for i := int32(1); i < attempts; i++ {
+        delay *= 2
+        if delay >= backoffMax {
+            return backoffMax
+        }
+    }
+    return delay
+}
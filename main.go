@@ -1,106 +1,283 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"sync/atomic"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/bamcmanus/Chirpy/internal/config"
 	"github.com/bamcmanus/Chirpy/internal/database"
 	"github.com/bamcmanus/Chirpy/internal/handlers"
+	"github.com/bamcmanus/Chirpy/internal/health"
+	"github.com/bamcmanus/Chirpy/internal/jobs"
+	"github.com/bamcmanus/Chirpy/internal/logging"
+	"github.com/bamcmanus/Chirpy/internal/metrics"
+	"github.com/bamcmanus/Chirpy/internal/migrate"
+	"github.com/bamcmanus/Chirpy/internal/ratelimit"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/bamcmanus/Chirpy/internal/tokengc"
+	"github.com/bamcmanus/Chirpy/internal/tracing"
+	"github.com/bamcmanus/Chirpy/internal/webhooks"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 )
 
-type apiConfig struct {
-    fileserverHits atomic.Int32
-    platform string
-    jwtSecret string
-    polkaKey string
+func main() {
+    godotenv.Load()
+
+    args := os.Args[1:]
+    if len(args) > 0 && args[0] == "migrate" {
+        runMigrate(args[1:])
+        return
+    }
+    if len(args) > 0 && args[0] == "gc" {
+        runGC(args[1:])
+        return
+    }
+    if len(args) > 0 && args[0] == "admin" {
+        runAdmin(args[1:])
+        return
+    }
+    if len(args) > 0 && args[0] == "worker" {
+        runWorker(args[1:])
+        return
+    }
+    if len(args) > 0 && args[0] == "seed" {
+        runSeed(args[1:])
+        return
+    }
+    serve(args)
 }
 
-func (c *apiConfig) middlewareMetricsInt(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-        c.fileserverHits.Add(1)
-        next.ServeHTTP(w, req)
-    })
+// app holds what the API server and the worker process both need.
+type app struct {
+    cfg config.Config
+    logger *slog.Logger
+    db *sql.DB
+    store *store.DB
+    migrator *migrate.Migrator
+    metrics *metrics.Metrics
+    shutdownTracing func(context.Context) error
 }
 
-func main() {
-    godotenv.Load()
-    dbUrl := os.Getenv("DB_URL")
-    db, err := sql.Open("postgres", dbUrl)
+// newApp loads the configuration, sets up logging and tracing, and connects
+// to the database, exiting if any of that fails or the schema is out of date.
+func newApp(args []string) *app {
+    cfg, err := config.Load(args, os.Getenv)
     if err != nil {
-        log.Fatalf("failed to connect to database; err: %s", err)
+        log.Fatalf("invalid configuration: %s", err)
     }
-    
-    var cfg apiConfig
-    cfg.platform = os.Getenv("PLATFORM")
-    if cfg.platform == "" {
-        log.Fatal("PLATFORM not set")
+
+    logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+    if err != nil {
+        log.Fatalf("invalid configuration: %s", err)
     }
-    
-    cfg.jwtSecret = os.Getenv("JWT_SECRET")
-    if cfg.jwtSecret == "" {
-        log.Fatal("JWT_SECRET was not set")
+    slog.SetDefault(logger)
+    logger.Info("loaded configuration", "config", cfg)
+
+    shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+    if err != nil {
+        fatal("failed to set up tracing", err)
     }
 
-    cfg.polkaKey = os.Getenv("POLKA_KEY")
-    if cfg.polkaKey == "" {
-        log.Fatal("POLKA_KEY was not set")
+    db, dialect, err := store.Open(cfg.DBURL)
+    if err != nil {
+        fatal("failed to connect to database", err)
     }
 
-    mux := http.NewServeMux()
+    migrator, err := migrate.New(db, dialect)
+    if err != nil {
+        fatal("failed to load migrations", err)
+    }
+    if cfg.AutoMigrate {
+        logger.Info("applying database migrations")
+        if err := migrator.Up(context.Background(), os.Stdout); err != nil {
+            fatal("failed to apply migrations", err)
+        }
+    }
+    if err := migrator.Check(context.Background()); err != nil {
+        fatal("database schema is out of date; run chirpy migrate up", err)
+    }
 
-    dbQueries := database.New(db)
+    return &app{
+        cfg: cfg,
+        logger: logger,
+        db: db,
+        store: store.NewDB(db, dialect, func(db database.DBTX) database.DBTX {
+            return tracing.WrapDB(db, dialect)
+        }),
+        migrator: migrator,
+        metrics: metrics.New(db),
+        shutdownTracing: shutdownTracing,
+    }
+}
 
-    polkaHandler := handlers.NewPolkaHandler(dbQueries, cfg.polkaKey)
+// startWorkers starts the webhook and job workers and the refresh token
+// sweeper, whose sweeps the job worker runs, and adds the workers' readiness
+// checks to checker. The returned function stops them and waits for the work
+// in flight to finish.
+func (a *app) startWorkers(checker *health.Checker) func() {
+    webhookWorker := webhooks.NewWorker(a.store, a.store, a.cfg.Webhooks, a.metrics)
+    jobWorker := jobs.NewWorker(a.store, a.cfg.Jobs, a.metrics)
+    sweeper := tokengc.NewSweeper(a.store, a.cfg.TokenGC, a.metrics)
+    jobWorker.Handle(tokengc.JobKind, sweeper.Handle)
 
-    mux.HandleFunc("POST /api/polka/webhooks", polkaHandler.UpgradeUser)
+    checker.Add("webhook_worker", webhookWorker.Check)
+    checker.Add("job_worker", jobWorker.Check)
 
-    authHandler := handlers.NewAuthHandler(dbQueries, cfg.jwtSecret)
+    ctx, cancel := context.WithCancel(context.Background())
+    var workers sync.WaitGroup
+    workers.Add(3)
+    go func() {
+        defer workers.Done()
+        webhookWorker.Run(ctx)
+    }()
+    go func() {
+        defer workers.Done()
+        jobWorker.Run(ctx)
+    }()
+    go func() {
+        defer workers.Done()
+        sweeper.Run(ctx, a.store)
+    }()
 
-    mux.HandleFunc("POST /api/login", authHandler.Login)
+    return func() {
+        cancel()
+        workers.Wait()
+    }
+}
 
-    mux.HandleFunc("POST /api/refresh", authHandler.Refresh)
+// run serves handler, and /metrics on the metrics address, until SIGINT or
+// SIGTERM, then shuts down gracefully: readiness fails for the configured
+// delay, in-flight requests drain, the workers stop, and the database and
+// trace exporter are closed.
+func (a *app) run(handler http.Handler, checker *health.Checker, stopWorkers func()) {
+    servers := []*http.Server{a.newServer(a.cfg.Addr, handler)}
+    if a.cfg.MetricsAddr != "" {
+        metricsMux := http.NewServeMux()
+        metricsMux.Handle("GET /metrics", a.metrics.Handler())
+        servers = append(servers, a.newServer(a.cfg.MetricsAddr, metricsMux))
+    }
 
-    mux.HandleFunc("POST /api/revoke", authHandler.Revoke)
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
 
-    userHandler := handlers.NewUserHandler(dbQueries, cfg.jwtSecret)
+    // Either server failing stops both.
+    serverErr := make(chan error, len(servers))
+    for _, server := range servers {
+        go func() {
+            a.logger.Info("starting server", "addr", server.Addr)
+            serverErr <- server.ListenAndServe()
+        }()
+    }
 
-    mux.HandleFunc("POST /api/users", userHandler.CreateUser)
+    select {
+    case err := <-serverErr:
+        if !errors.Is(err, http.ErrServerClosed) {
+            a.logger.Error("server failed", "error", err)
+        }
+    case <-ctx.Done():
+        a.logger.Info("shutting down", "delay", a.cfg.Server.ShutdownDelay)
+        checker.SetShuttingDown()
+        time.Sleep(a.cfg.Server.ShutdownDelay)
+    }
+    stop()
 
-    mux.HandleFunc("PUT /api/users", userHandler.UpdateUser)
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
+    defer cancel()
+    for _, server := range servers {
+        if err := server.Shutdown(shutdownCtx); err != nil {
+            a.logger.Error("failed to drain in-flight requests", "addr", server.Addr, "error", err)
+        }
+    }
 
-    mux.Handle("/app/", cfg.middlewareMetricsInt(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
+    stopWorkers()
 
-    mux.HandleFunc("GET /api/healthz", handlers.Health)
+    if err := a.db.Close(); err != nil {
+        a.logger.Error("failed to close database", "error", err)
+    }
 
-    chirpsHandler := handlers.NewChirpsHandler(dbQueries, cfg.jwtSecret)
+    if err := a.shutdownTracing(shutdownCtx); err != nil {
+        a.logger.Error("failed to flush traces", "error", err)
+    }
+    a.logger.Info("server stopped")
+}
 
-    mux.HandleFunc("POST /api/chirps", chirpsHandler.PostChirp)
+func (a *app) newServer(addr string, handler http.Handler) *http.Server {
+    return &http.Server{
+        Addr: addr,
+        Handler: handler,
+        ReadHeaderTimeout: a.cfg.Server.ReadHeaderTimeout,
+        ReadTimeout: a.cfg.Server.ReadTimeout,
+        WriteTimeout: a.cfg.Server.WriteTimeout,
+        IdleTimeout: a.cfg.Server.IdleTimeout,
+        MaxHeaderBytes: a.cfg.Server.MaxHeaderBytes,
+    }
+}
 
-    mux.HandleFunc("GET /api/chirps", chirpsHandler.GetChirps)
+func serve(args []string) {
+    a := newApp(args)
+    cfg := a.cfg
 
-    mux.HandleFunc("GET /api/chirps/{chirpID}", chirpsHandler.GetChirp)
+    checker := health.New(cfg.Server.ReadinessTimeout)
+    checker.Add("database", health.Database(a.db))
+    checker.Add("migrations", a.migrator.Check)
 
-    mux.HandleFunc("DELETE /api/chirps/{chirpID}", chirpsHandler.DeleteChirp)
+    mux := http.NewServeMux()
+    handlers.Routes(mux, handlers.Deps{
+        Store: a.store,
+        Tx: a.store,
+        Metrics: a.metrics,
+        Checker: checker,
+        Platform: cfg.Platform,
+        JWTSecret: cfg.JWTSecret,
+        PolkaKey: cfg.PolkaKey,
+        JWTLifetime: cfg.JWTLifetime,
+        RefreshTokenLifetime: cfg.RefreshTokenLifetime,
+    })
+    mux.Handle("/app/", a.metrics.CountFileserverHits(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
 
-    adminHandler := handlers.NewAdminHandler(dbQueries, cfg.platform, &cfg.fileserverHits)
+    stopWorkers := func() {}
+    if cfg.RunWorkers {
+        stopWorkers = a.startWorkers(checker)
+    } else {
+        a.logger.Info("not running workers; run chirpy worker separately")
+    }
 
-    mux.HandleFunc("GET /admin/metrics", adminHandler.GetMetrics)
+    var limiter *ratelimit.Limiter
+    if cfg.RateLimit.Enabled {
+        var limits ratelimit.Store = ratelimit.NewMemoryStore()
+        if cfg.RateLimit.Store == "database" {
+            limits = ratelimit.NewDBStore(a.store)
+        }
+        var err error
+        limiter, err = ratelimit.New(limits, cfg.RateLimit, cfg.JWTSecret, a.metrics)
+        if err != nil {
+            fatal("invalid rate limit configuration", err)
+        }
+    }
 
-    mux.HandleFunc("POST /admin/reset", adminHandler.Reset)
+    a.run(a.middleware(mux, limiter), checker, stopWorkers)
+}
 
-    server := http.Server{
-        Addr: ":8080",
-        Handler: mux,
+// middleware wraps the routes on mux in handlers.Middleware, finding clients'
+// addresses behind the configured trusted proxies.
+func (a *app) middleware(mux *http.ServeMux, limiter *ratelimit.Limiter) http.Handler {
+    proxies, err := ratelimit.ParseProxies(a.cfg.RateLimit.TrustedProxies)
+    if err != nil {
+        fatal("invalid rate limit configuration", err)
     }
-    defer server.Close()
+    return handlers.Middleware(mux, a.logger, a.metrics, limiter, proxies.ClientIP)
+}
 
-    log.Println("Starting server ...")
-    if err := server.ListenAndServe(); err != nil {
-        log.Fatalf("failed to start server: %s", err)
-    }
+func fatal(msg string, err error) {
+    slog.Error(msg, "error", err)
+    os.Exit(1)
 }
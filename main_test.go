@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// undocumented lists the routes that aren't part of the API.
+var undocumented = map[string]bool{
+    // The static file server.
+    "/app/": true,
+}
+
+// registeredRoutes finds every pattern passed to mux.Handle or mux.HandleFunc
+// in this package and in handlers.Routes, so routes can't be added without the
+// test seeing them.
+func registeredRoutes(t *testing.T) []string {
+    t.Helper()
+
+    files, err := filepath.Glob("*.go")
+    require.NoError(t, err)
+    files = append(files, filepath.Join("internal", "handlers", "routes.go"))
+
+    var routes []string
+    fset := token.NewFileSet()
+    for _, file := range files {
+        if strings.HasSuffix(file, "_test.go") {
+            continue
+        }
+        f, err := parser.ParseFile(fset, file, nil, 0)
+        require.NoError(t, err)
+
+        ast.Inspect(f, func(n ast.Node) bool {
+            call, ok := n.(*ast.CallExpr)
+            if !ok || len(call.Args) != 2 {
+                return true
+            }
+            sel, ok := call.Fun.(*ast.SelectorExpr)
+            if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+                return true
+            }
+            if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "mux" {
+                return true
+            }
+            lit, ok := call.Args[0].(*ast.BasicLit)
+            require.True(t, ok, "%s: route patterns must be string literals", fset.Position(call.Pos()))
+            pattern, err := strconv.Unquote(lit.Value)
+            require.NoError(t, err)
+            routes = append(routes, pattern)
+            return true
+        })
+    }
+    require.NotEmpty(t, routes)
+    return routes
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+    var spec struct {
+        Paths map[string]map[string]json.RawMessage `json:"paths"`
+    }
+    require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+
+    registered := make(map[string]bool)
+    for _, pattern := range registeredRoutes(t) {
+        method, path, ok := strings.Cut(pattern, " ")
+        if !ok {
+            path = method
+            method = ""
+        }
+        if undocumented[path] {
+            continue
+        }
+        require.NotEmpty(t, method, "%q matches every method; register it with one", pattern)
+
+        registered[strings.ToLower(method) + " " + path] = true
+        assert.Contains(t, spec.Paths[path], strings.ToLower(method), "%s is registered but missing from the OpenAPI document", pattern)
+    }
+
+    for path, item := range spec.Paths {
+        for method := range item {
+            if method == "parameters" {
+                continue
+            }
+            assert.True(t, registered[method + " " + path], "%s %s is documented but not registered", strings.ToUpper(method), path)
+        }
+    }
+}
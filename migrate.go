@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bamcmanus/Chirpy/internal/config"
+	"github.com/bamcmanus/Chirpy/internal/migrate"
+	"github.com/bamcmanus/Chirpy/internal/store"
+)
+
+const migrateUsage = "usage: chirpy migrate [flags] up|down|status|redo"
+
+// runMigrate implements the migrate subcommand. It only needs the database
+// URL, so the rest of the configuration is not validated.
+func runMigrate(args []string) {
+    cfg, rest, err := config.Parse(args, os.Getenv)
+    if err != nil {
+        log.Fatalf("invalid configuration: %s", err)
+    }
+    if len(rest) != 1 {
+        log.Fatal(migrateUsage)
+    }
+    if cfg.DBURL == "" {
+        log.Fatal("DB_URL must be set")
+    }
+
+    db, dialect, err := store.Open(cfg.DBURL)
+    if err != nil {
+        log.Fatalf("failed to connect to database: %s", err)
+    }
+    defer db.Close()
+
+    migrator, err := migrate.New(db, dialect)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    ctx := context.Background()
+    switch rest[0] {
+    case "up":
+        err = migrator.Up(ctx, os.Stdout)
+    case "down":
+        err = migrator.Down(ctx, os.Stdout)
+    case "redo":
+        err = migrator.Redo(ctx, os.Stdout)
+    case "status":
+        err = migrator.Status(ctx, os.Stdout)
+    default:
+        err = fmt.Errorf("unknown migrate command %q\n%s", rest[0], migrateUsage)
+    }
+    if err != nil {
+        db.Close()
+        log.Fatal(err)
+    }
+}
@@ -0,0 +1,234 @@
+package chirpyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles a user may have. Moderators may delete any chirp; admins may also use
+// the admin methods.
+const (
+    RoleUser = "user"
+    RoleModerator = "moderator"
+    RoleAdmin = "admin"
+)
+
+// RoleChange is a recorded change of a user's role.
+type RoleChange struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UserId uuid.UUID `json:"user_id"`
+    OldRole string `json:"old_role"`
+    NewRole string `json:"new_role"`
+    // ChangedBy is the admin who made the change, or nil for changes made
+    // with chirpy admin.
+    ChangedBy *uuid.UUID `json:"changed_by"`
+    Reason string `json:"reason"`
+}
+
+type Job struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Kind string `json:"kind"`
+    Payload json.RawMessage `json:"payload"`
+    Status string `json:"status"`
+    Attempts int `json:"attempts"`
+    RunAt time.Time `json:"run_at"`
+    LastError string `json:"last_error,omitempty"`
+    FinishedAt *time.Time `json:"finished_at"`
+}
+
+type JobList struct {
+    // Counts holds the number of jobs in each status, across all jobs.
+    Counts map[string]int64 `json:"counts"`
+    Jobs []Job `json:"jobs"`
+}
+
+// ListJobsParams filters ListJobs. The zero value lists the newest jobs of
+// any status, as many as the API returns by default.
+type ListJobsParams struct {
+    // Status is pending, running, succeeded or dead.
+    Status string
+    Limit int
+}
+
+// HealthReport is the result of a liveness or readiness probe.
+type HealthReport struct {
+    Status string `json:"status"`
+    Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+    Status string `json:"status"`
+    Error string `json:"error,omitempty"`
+    DurationMs int64 `json:"duration_ms"`
+}
+
+func (c *Client) ListJobs(ctx context.Context, params ListJobsParams) (JobList, error) {
+    query := url.Values{}
+    if params.Status != "" {
+        query.Set("status", params.Status)
+    }
+    if params.Limit > 0 {
+        query.Set("limit", strconv.Itoa(params.Limit))
+    }
+
+    var list JobList
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/admin/jobs",
+        auth: withAccessToken,
+        query: query,
+    }, &list)
+    return list, err
+}
+
+func (c *Client) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+    var job Job
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/admin/jobs/" + id.String(),
+        auth: withAccessToken,
+    }, &job)
+    return job, err
+}
+
+// RetryJob moves a dead job back to pending.
+func (c *Client) RetryJob(ctx context.Context, id uuid.UUID) (Job, error) {
+    var job Job
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/admin/jobs/" + id.String() + "/retry",
+        auth: withAccessToken,
+    }, &job)
+    return job, err
+}
+
+// SetRole changes a user's role. The reason is recorded with the change.
+func (c *Client) SetRole(ctx context.Context, userID uuid.UUID, role, reason string) (RoleChange, error) {
+    var change RoleChange
+    _, err := c.do(ctx, call{
+        method: http.MethodPut,
+        path: "/admin/users/" + userID.String() + "/role",
+        body: struct{
+            Role string `json:"role"`
+            Reason string `json:"reason"`
+        }{Role: role, Reason: reason},
+        auth: withAccessToken,
+    }, &change)
+    return change, err
+}
+
+// ListRoleChanges lists the changes of a user's role, newest first.
+func (c *Client) ListRoleChanges(ctx context.Context, userID uuid.UUID) ([]RoleChange, error) {
+    var changes []RoleChange
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/admin/users/" + userID.String() + "/role-changes",
+        auth: withAccessToken,
+    }, &changes)
+    return changes, err
+}
+
+// Reset empties every table but the audit log and the log of role changes.
+// The API only allows it for
+// admins on the dev platform.
+func (c *Client) Reset(ctx context.Context) error {
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/admin/reset",
+        auth: withAccessToken,
+    }, nil)
+    return err
+}
+
+// SeedResult counts the rows a fixtures dataset loaded.
+type SeedResult struct {
+    Dataset string `json:"dataset"`
+    Users int `json:"users"`
+    Chirps int `json:"chirps"`
+    Tokens int `json:"tokens"`
+}
+
+// Seed replaces everything but those logs with a named fixtures dataset,
+// such as "demo". Like Reset, the API only allows it for admins on the dev
+// platform. The admin's own user is replaced too, so log in again as one of
+// the dataset's users afterwards.
+func (c *Client) Seed(ctx context.Context, dataset string) (SeedResult, error) {
+    var res SeedResult
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/admin/seed",
+        body: struct{
+            Dataset string `json:"dataset"`
+        }{Dataset: dataset},
+        auth: withAccessToken,
+    }, &res)
+    return res, err
+}
+
+// AdminMetrics returns the HTML metrics page.
+func (c *Client) AdminMetrics(ctx context.Context) (string, error) {
+    var page []byte
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/admin/metrics",
+        auth: withAccessToken,
+    }, &page)
+    return string(page), err
+}
+
+// OpenAPI returns the API's OpenAPI document.
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+    var spec []byte
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/api/openapi.json",
+    }, &spec)
+    return spec, err
+}
+
+// Health returns nil if the server is up.
+func (c *Client) Health(ctx context.Context) error {
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/api/healthz",
+    }, nil)
+    return err
+}
+
+// Livez runs the liveness probe.
+func (c *Client) Livez(ctx context.Context) (HealthReport, error) {
+    return c.probe(ctx, "/livez")
+}
+
+// Readyz runs the readiness probe. If the server isn't ready, the report
+// says which checks failed and the error matches ErrServer.
+func (c *Client) Readyz(ctx context.Context) (HealthReport, error) {
+    return c.probe(ctx, "/readyz")
+}
+
+func (c *Client) probe(ctx context.Context, path string) (HealthReport, error) {
+    res, err := c.send(ctx, call{method: http.MethodGet, path: path})
+    if err != nil {
+        return HealthReport{}, err
+    }
+    defer res.Body.Close()
+
+    var report HealthReport
+    if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+        return report, fmt.Errorf("chirpyclient: reading %s response: %w", path, err)
+    }
+    if res.StatusCode != http.StatusOK {
+        return report, &Error{StatusCode: res.StatusCode, Code: "not_ready", Detail: "status " + report.Status}
+    }
+    return report, nil
+}
@@ -0,0 +1,133 @@
+package chirpyclient
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultPageSize is how many chirps Chirps fetches per request unless told
+// otherwise.
+const DefaultPageSize = 50
+
+type Chirp struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Body string `json:"body"`
+    UserId uuid.UUID `json:"user_id"`
+}
+
+// ListChirpsParams filters and orders Chirps. The zero value lists every
+// chirp, oldest first.
+type ListChirpsParams struct {
+    // AuthorId, if set, only lists chirps by this user.
+    AuthorId uuid.UUID
+    Descending bool
+    // PageSize is how many chirps to fetch per request, up to 100.
+    PageSize int
+}
+
+// CreateChirp posts a chirp as the logged in user.
+func (c *Client) CreateChirp(ctx context.Context, body string) (Chirp, error) {
+    var chirp Chirp
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/api/chirps",
+        body: struct{
+            Body string `json:"body"`
+        }{Body: body},
+        auth: withAccessToken,
+    }, &chirp)
+    return chirp, err
+}
+
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+    var chirp Chirp
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/api/chirps/" + id.String(),
+    }, &chirp)
+    return chirp, err
+}
+
+// DeleteChirp deletes one of the logged in user's chirps.
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+    _, err := c.do(ctx, call{
+        method: http.MethodDelete,
+        path: "/api/chirps/" + id.String(),
+        auth: withAccessToken,
+    }, nil)
+    return err
+}
+
+// Chirps iterates over the chirps matching params, fetching a page at a time
+// as the loop needs them. Iteration stops at the first error, which is
+// yielded with a zero Chirp.
+func (c *Client) Chirps(ctx context.Context, params ListChirpsParams) iter.Seq2[Chirp, error] {
+    return func(yield func(Chirp, error) bool) {
+        pageSize := params.PageSize
+        if pageSize <= 0 {
+            pageSize = DefaultPageSize
+        }
+        query := url.Values{"limit": {strconv.Itoa(pageSize)}}
+        if params.AuthorId != uuid.Nil {
+            query.Set("author_id", params.AuthorId.String())
+        }
+        if params.Descending {
+            query.Set("sort", "desc")
+        }
+
+        for {
+            var page []Chirp
+            header, err := c.do(ctx, call{
+                method: http.MethodGet,
+                path: "/api/chirps",
+                query: query,
+            }, &page)
+            if err != nil {
+                yield(Chirp{}, err)
+                return
+            }
+            for _, chirp := range page {
+                if !yield(chirp, nil) {
+                    return
+                }
+            }
+
+            next, ok := nextLink(header.Get("Link"))
+            if !ok {
+                return
+            }
+            u, err := url.Parse(next)
+            if err != nil {
+                yield(Chirp{}, fmt.Errorf("chirpyclient: invalid next page link %q: %w", next, err))
+                return
+            }
+            query = u.Query()
+        }
+    }
+}
+
+// nextLink finds the rel="next" target in a Link header.
+func nextLink(header string) (string, bool) {
+    for _, link := range strings.Split(header, ",") {
+        target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+        if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+            continue
+        }
+        for _, param := range strings.Split(params, ";") {
+            if strings.TrimSpace(param) == `rel="next"` {
+                return strings.Trim(target, "<>"), true
+            }
+        }
+    }
+    return "", false
+}
@@ -0,0 +1,406 @@
+package chirpyclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bamcmanus/Chirpy/internal/apitest"
+	"github.com/bamcmanus/Chirpy/internal/auth"
+	"github.com/bamcmanus/Chirpy/internal/openapi"
+	"github.com/bamcmanus/Chirpy/pkg/chirpyclient"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "correct horse"
+
+func newServer(t *testing.T) *httptest.Server {
+    t.Helper()
+    return apitest.NewServer(t).Server
+}
+
+func newClient(t *testing.T, server *httptest.Server) *chirpyclient.Client {
+    t.Helper()
+    c, err := chirpyclient.New(server.URL, server.Client())
+    require.NoError(t, err)
+    return c
+}
+
+// login signs up a user and logs the client in as them.
+func login(t *testing.T, c *chirpyclient.Client, email string) chirpyclient.Session {
+    t.Helper()
+    ctx := context.Background()
+    _, err := c.CreateUser(ctx, email, testPassword)
+    require.NoError(t, err)
+    session, err := c.Login(ctx, email, testPassword)
+    require.NoError(t, err)
+    return session
+}
+
+func TestNew(t *testing.T) {
+    for _, baseURL := range []string{"", "localhost:8080", "ftp://example.com", "http://[::1"} {
+        t.Run(baseURL, func(t *testing.T) {
+            _, err := chirpyclient.New(baseURL, nil)
+            assert.Error(t, err)
+        })
+    }
+}
+
+func TestUsers(t *testing.T) {
+    ctx := context.Background()
+    server := newServer(t)
+
+    t.Run("Signs up and logs in", func(t *testing.T) {
+        c := newClient(t, server)
+        user, err := c.CreateUser(ctx, "walt@example.com", testPassword)
+        require.NoError(t, err)
+        assert.Equal(t, "walt@example.com", user.Email)
+        assert.False(t, user.IsChirpyRed)
+
+        session, err := c.Login(ctx, "walt@example.com", testPassword)
+        require.NoError(t, err)
+        assert.Equal(t, user.Id, session.Id)
+        accessToken, refreshToken := c.Tokens()
+        assert.Equal(t, session.Token, accessToken)
+        assert.Equal(t, session.RefreshToken, refreshToken)
+    })
+
+    t.Run("Updates the user", func(t *testing.T) {
+        c := newClient(t, server)
+        session := login(t, c, "jesse@example.com")
+
+        user, err := c.UpdateUser(ctx, "pinkman@example.com", "a new password")
+        require.NoError(t, err)
+        assert.Equal(t, session.Id, user.Id)
+        assert.Equal(t, "pinkman@example.com", user.Email)
+    })
+
+    t.Run("Upgrades through Polka", func(t *testing.T) {
+        c := newClient(t, server)
+        session := login(t, c, "skyler@example.com")
+
+        require.NoError(t, c.SendPolkaEvent(ctx, apitest.PolkaKey, "user.upgraded", session.Id))
+        session, err := c.Login(ctx, "skyler@example.com", testPassword)
+        require.NoError(t, err)
+        assert.True(t, session.IsChirpyRed)
+
+        err = c.SendPolkaEvent(ctx, "wrong-key", "user.upgraded", session.Id)
+        assert.ErrorIs(t, err, chirpyclient.ErrUnauthorized)
+    })
+
+    t.Run("Revokes the session", func(t *testing.T) {
+        c := newClient(t, server)
+        login(t, c, "hank@example.com")
+        _, refreshToken := c.Tokens()
+
+        require.NoError(t, c.Revoke(ctx))
+        accessToken, stored := c.Tokens()
+        assert.Empty(t, accessToken)
+        assert.Empty(t, stored)
+
+        c.SetTokens("", refreshToken)
+        _, err := c.Refresh(ctx)
+        assert.ErrorIs(t, err, chirpyclient.ErrUnauthorized)
+    })
+}
+
+func TestRefresh(t *testing.T) {
+    ctx := context.Background()
+    server := newServer(t)
+
+    t.Run("Refreshes an expired access token", func(t *testing.T) {
+        c := newClient(t, server)
+        session := login(t, c, "saul@example.com")
+        c.SetTokens("expired", session.RefreshToken)
+
+        chirp, err := c.CreateChirp(ctx, "Better call me")
+        require.NoError(t, err)
+        assert.Equal(t, session.Id, chirp.UserId)
+
+        accessToken, _ := c.Tokens()
+        assert.NotEqual(t, "expired", accessToken)
+    })
+
+    t.Run("Shares one refresh between concurrent requests", func(t *testing.T) {
+        c := newClient(t, server)
+        session := login(t, c, "mike@example.com")
+        c.SetTokens("expired", session.RefreshToken)
+
+        errs := make(chan error, 5)
+        for i := range 5 {
+            go func() {
+                _, err := c.CreateChirp(ctx, fmt.Sprintf("Half measures %d", i))
+                errs <- err
+            }()
+        }
+        for range 5 {
+            assert.NoError(t, <-errs)
+        }
+    })
+
+    t.Run("Returns the error without a refresh token", func(t *testing.T) {
+        c := newClient(t, server)
+        c.SetTokens("expired", "")
+
+        _, err := c.CreateChirp(ctx, "Say my name")
+        assert.ErrorIs(t, err, chirpyclient.ErrUnauthorized)
+    })
+
+    t.Run("Returns the error if the refresh fails", func(t *testing.T) {
+        c := newClient(t, server)
+        c.SetTokens("expired", "revoked")
+
+        _, err := c.CreateChirp(ctx, "Say my name")
+        var apiErr *chirpyclient.Error
+        require.ErrorAs(t, err, &apiErr)
+        assert.Equal(t, "invalid_refresh_token", apiErr.Code)
+    })
+}
+
+func TestChirps(t *testing.T) {
+    ctx := context.Background()
+    server := newServer(t)
+    c := newClient(t, server)
+    author := login(t, c, "gus@example.com")
+
+    var created []chirpyclient.Chirp
+    for i := range 5 {
+        chirp, err := c.CreateChirp(ctx, fmt.Sprintf("Chirp %d", i))
+        require.NoError(t, err)
+        created = append(created, chirp)
+    }
+    other := newClient(t, server)
+    login(t, other, "lydia@example.com")
+    _, err := other.CreateChirp(ctx, "Not by Gus")
+    require.NoError(t, err)
+
+    t.Run("Gets a chirp", func(t *testing.T) {
+        chirp, err := c.GetChirp(ctx, created[0].Id)
+        require.NoError(t, err)
+        assert.Equal(t, created[0], chirp)
+    })
+
+    t.Run("Iterates over every page", func(t *testing.T) {
+        var bodies []string
+        for chirp, err := range c.Chirps(ctx, chirpyclient.ListChirpsParams{AuthorId: author.Id, PageSize: 2}) {
+            require.NoError(t, err)
+            bodies = append(bodies, chirp.Body)
+        }
+        assert.Equal(t, []string{"Chirp 0", "Chirp 1", "Chirp 2", "Chirp 3", "Chirp 4"}, bodies)
+    })
+
+    t.Run("Keeps the order across pages", func(t *testing.T) {
+        var bodies []string
+        for chirp, err := range c.Chirps(ctx, chirpyclient.ListChirpsParams{Descending: true, PageSize: 4}) {
+            require.NoError(t, err)
+            bodies = append(bodies, chirp.Body)
+        }
+        assert.Equal(t, []string{"Not by Gus", "Chirp 4", "Chirp 3", "Chirp 2", "Chirp 1", "Chirp 0"}, bodies)
+    })
+
+    t.Run("Stops when the loop breaks", func(t *testing.T) {
+        n := 0
+        for _, err := range c.Chirps(ctx, chirpyclient.ListChirpsParams{PageSize: 1}) {
+            require.NoError(t, err)
+            n++
+            if n == 2 {
+                break
+            }
+        }
+        assert.Equal(t, 2, n)
+    })
+
+    t.Run("Yields the error of a bad page", func(t *testing.T) {
+        var errs []error
+        for _, err := range c.Chirps(ctx, chirpyclient.ListChirpsParams{PageSize: 1000}) {
+            errs = append(errs, err)
+        }
+        require.Len(t, errs, 1)
+        var apiErr *chirpyclient.Error
+        require.ErrorAs(t, errs[0], &apiErr)
+        assert.ErrorIs(t, apiErr, chirpyclient.ErrBadRequest)
+        require.Len(t, apiErr.Fields, 1)
+        assert.Equal(t, "limit", apiErr.Fields[0].Field)
+    })
+
+    t.Run("Deletes a chirp", func(t *testing.T) {
+        err := other.DeleteChirp(ctx, created[4].Id)
+        assert.ErrorIs(t, err, chirpyclient.ErrForbidden)
+
+        require.NoError(t, c.DeleteChirp(ctx, created[4].Id))
+        _, err = c.GetChirp(ctx, created[4].Id)
+        assert.ErrorIs(t, err, chirpyclient.ErrNotFound)
+    })
+}
+
+func TestWebhooks(t *testing.T) {
+    ctx := context.Background()
+    c := newClient(t, newServer(t))
+    login(t, c, "tuco@example.com")
+
+    hook, err := c.CreateWebhook(ctx, chirpyclient.CreateWebhookParams{
+        Url: "https://example.com/hook",
+        Events: []string{chirpyclient.EventChirpCreated},
+    })
+    require.NoError(t, err)
+    assert.NotEmpty(t, hook.Secret)
+
+    _, err = c.CreateChirp(ctx, "Tight tight tight")
+    require.NoError(t, err)
+
+    hooks, err := c.ListWebhooks(ctx)
+    require.NoError(t, err)
+    require.Len(t, hooks, 1)
+    assert.Equal(t, hook.Id, hooks[0].Id)
+    assert.Empty(t, hooks[0].Secret)
+
+    deliveries, err := c.ListWebhookDeliveries(ctx, hook.Id)
+    require.NoError(t, err)
+    require.Len(t, deliveries, 1)
+    assert.Equal(t, chirpyclient.EventChirpCreated, deliveries[0].Event)
+
+    require.NoError(t, c.DeleteWebhook(ctx, hook.Id))
+    err = c.DeleteWebhook(ctx, hook.Id)
+    assert.ErrorIs(t, err, chirpyclient.ErrNotFound)
+}
+
+func TestAdmin(t *testing.T) {
+    ctx := context.Background()
+    server := apitest.NewServer(t)
+    c := newClient(t, server.Server)
+
+    require.NoError(t, c.Health(ctx))
+
+    report, err := c.Readyz(ctx)
+    require.NoError(t, err)
+    assert.Equal(t, "ok", report.Status)
+    assert.Contains(t, report.Checks, "database")
+
+    spec, err := c.OpenAPI(ctx)
+    require.NoError(t, err)
+    assert.Equal(t, openapi.Spec, spec)
+
+    todd := login(t, c, "todd@example.com")
+    _, err = c.ListJobs(ctx, chirpyclient.ListJobsParams{})
+    assert.ErrorIs(t, err, chirpyclient.ErrForbidden)
+
+    // Roles are carried in access tokens, so log in again after the change.
+    server.SetRole(t, "todd@example.com", auth.RoleAdmin)
+    _, err = c.Login(ctx, "todd@example.com", testPassword)
+    require.NoError(t, err)
+
+    jobs, err := c.ListJobs(ctx, chirpyclient.ListJobsParams{Status: "dead", Limit: 10})
+    require.NoError(t, err)
+    assert.Empty(t, jobs.Jobs)
+
+    _, err = c.ListJobs(ctx, chirpyclient.ListJobsParams{Status: "lost"})
+    assert.ErrorIs(t, err, chirpyclient.ErrBadRequest)
+
+    _, err = c.GetJob(ctx, uuid.New())
+    assert.ErrorIs(t, err, chirpyclient.ErrNotFound)
+
+    walt, err := c.CreateUser(ctx, "walt@example.com", testPassword)
+    require.NoError(t, err)
+    change, err := c.SetRole(ctx, walt.Id, chirpyclient.RoleModerator, "helps with spam")
+    require.NoError(t, err)
+    assert.Equal(t, chirpyclient.RoleUser, change.OldRole)
+    assert.Equal(t, &todd.User.Id, change.ChangedBy)
+    changes, err := c.ListRoleChanges(ctx, walt.Id)
+    require.NoError(t, err)
+    assert.Equal(t, []chirpyclient.RoleChange{change}, changes)
+
+    seeded, err := c.Seed(ctx, "demo")
+    require.NoError(t, err)
+    assert.Equal(t, chirpyclient.SeedResult{Dataset: "demo", Users: 4, Chirps: 4, Tokens: 3}, seeded)
+    chirp, err := c.GetChirp(ctx, uuid.MustParse("00000000-0000-4000-9000-000000000001"))
+    require.NoError(t, err)
+    assert.Equal(t, "I am the one who knocks!", chirp.Body)
+
+    require.NoError(t, c.Reset(ctx))
+    _, err = c.Login(ctx, "todd@example.com", testPassword)
+    assert.ErrorIs(t, err, chirpyclient.ErrUnauthorized)
+}
+
+func TestErrors(t *testing.T) {
+    ctx := context.Background()
+    c := newClient(t, newServer(t))
+
+    t.Run("Maps problem details", func(t *testing.T) {
+        _, err := c.CreateUser(ctx, "gale@example.com", testPassword)
+        require.NoError(t, err)
+        _, err = c.CreateUser(ctx, "gale@example.com", testPassword)
+
+        var apiErr *chirpyclient.Error
+        require.ErrorAs(t, err, &apiErr)
+        assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+        assert.Equal(t, "email_taken", apiErr.Code)
+        assert.ErrorIs(t, err, chirpyclient.ErrConflict)
+        assert.NotErrorIs(t, err, chirpyclient.ErrNotFound)
+    })
+
+    t.Run("Lists invalid fields", func(t *testing.T) {
+        _, err := c.CreateUser(ctx, "not an email", "short")
+
+        var apiErr *chirpyclient.Error
+        require.ErrorAs(t, err, &apiErr)
+        assert.ErrorIs(t, err, chirpyclient.ErrBadRequest)
+        var fields []string
+        for _, f := range apiErr.Fields {
+            fields = append(fields, f.Field)
+        }
+        assert.ElementsMatch(t, []string{"email", "password"}, fields)
+    })
+
+    t.Run("Reads Retry-After", func(t *testing.T) {
+        limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            w.Header().Set("Retry-After", "7")
+            w.Header().Set("Content-Type", "application/problem+json")
+            w.WriteHeader(http.StatusTooManyRequests)
+            w.Write([]byte(`{"status":429,"code":"rate_limited","detail":"rate limit exceeded"}`))
+        }))
+        defer limited.Close()
+
+        err := newClient(t, limited).Health(ctx)
+        var apiErr *chirpyclient.Error
+        require.ErrorAs(t, err, &apiErr)
+        assert.ErrorIs(t, err, chirpyclient.ErrRateLimited)
+        assert.Equal(t, "rate_limited", apiErr.Code)
+        assert.Equal(t, 7*time.Second, apiErr.RetryAfter)
+    })
+
+    t.Run("Summarises responses without problem details", func(t *testing.T) {
+        proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            http.Error(w, "<html>Bad Gateway</html>", http.StatusBadGateway)
+        }))
+        defer proxy.Close()
+
+        err := newClient(t, proxy).Health(ctx)
+        var apiErr *chirpyclient.Error
+        require.ErrorAs(t, err, &apiErr)
+        assert.ErrorIs(t, err, chirpyclient.ErrServer)
+        assert.Equal(t, "http_502", apiErr.Code)
+    })
+
+    t.Run("Stops on a cancelled context", func(t *testing.T) {
+        blocked := make(chan struct{})
+        slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            select {
+            case <-req.Context().Done():
+            case <-blocked:
+            }
+        }))
+        defer slow.Close()
+        defer close(blocked)
+
+        ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+        defer cancel()
+        err := newClient(t, slow).Health(ctx)
+        assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+    })
+}
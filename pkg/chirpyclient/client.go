@@ -0,0 +1,209 @@
+// Package chirpyclient is a Go client for the Chirpy HTTP API.
+//
+// A Client logs in once and then keeps its access token fresh by itself: when
+// the API rejects the access token, the client trades its refresh token for a
+// new one and retries the request. Errors from the API are returned as *Error
+// and can be matched with errors.Is against ErrNotFound, ErrUnauthorized and
+// the other sentinel errors.
+package chirpyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const userAgent = "chirpyclient"
+
+// Client calls the Chirpy API. It is safe for concurrent use.
+type Client struct {
+    baseURL *url.URL
+    httpClient *http.Client
+
+    mu sync.Mutex
+    accessToken string
+    refreshToken string
+    // refreshMu makes concurrent requests that find the access token expired
+    // share one refresh.
+    refreshMu sync.Mutex
+}
+
+// New returns a client for the API at baseURL, e.g. http://localhost:8080.
+// If httpClient is nil, http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) (*Client, error) {
+    u, err := url.Parse(baseURL)
+    if err != nil {
+        return nil, fmt.Errorf("chirpyclient: invalid base URL: %w", err)
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return nil, fmt.Errorf("chirpyclient: base URL %q must be http or https", baseURL)
+    }
+    u.Path = strings.TrimSuffix(u.Path, "/")
+
+    if httpClient == nil {
+        httpClient = http.DefaultClient
+    }
+    return &Client{baseURL: u, httpClient: httpClient}, nil
+}
+
+// SetTokens replaces the client's access and refresh tokens, e.g. with ones
+// saved from an earlier Login.
+func (c *Client) SetTokens(accessToken, refreshToken string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.accessToken = accessToken
+    c.refreshToken = refreshToken
+}
+
+// Tokens returns the client's current access and refresh tokens.
+func (c *Client) Tokens() (accessToken, refreshToken string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.accessToken, c.refreshToken
+}
+
+type authMethod int
+
+const (
+    noAuth authMethod = iota
+    // withAccessToken sends the access token and refreshes it once if the
+    // API rejects it.
+    withAccessToken
+    withRefreshToken
+    withAPIKey
+)
+
+// call describes one API request.
+type call struct {
+    method string
+    path string
+    query url.Values
+    body any
+    auth authMethod
+    apiKey string
+}
+
+// do sends c and decodes a successful response into out, which may be nil,
+// a *[]byte for the raw body, or a pointer to decode JSON into. Responses
+// with an error status are returned as *Error.
+func (c *Client) do(ctx context.Context, cl call, out any) (http.Header, error) {
+    res, err := c.send(ctx, cl)
+    if err != nil {
+        return nil, err
+    }
+    defer res.Body.Close()
+
+    if res.StatusCode >= http.StatusBadRequest {
+        return res.Header, parseError(res)
+    }
+
+    switch out := out.(type) {
+    case nil:
+        _, _ = io.Copy(io.Discard, res.Body)
+    case *[]byte:
+        *out, err = io.ReadAll(res.Body)
+    default:
+        err = json.NewDecoder(res.Body).Decode(out)
+    }
+    if err != nil {
+        return res.Header, fmt.Errorf("chirpyclient: reading %s %s response: %w", cl.method, cl.path, err)
+    }
+    return res.Header, nil
+}
+
+// send sends cl and returns the response whatever its status. The caller
+// must close the body.
+func (c *Client) send(ctx context.Context, cl call) (*http.Response, error) {
+    var body []byte
+    if cl.body != nil {
+        var err error
+        body, err = json.Marshal(cl.body)
+        if err != nil {
+            return nil, fmt.Errorf("chirpyclient: encoding %s %s request: %w", cl.method, cl.path, err)
+        }
+    }
+
+    accessToken, _ := c.Tokens()
+    res, err := c.sendOnce(ctx, cl, body, accessToken)
+    if err != nil || res.StatusCode != http.StatusUnauthorized || cl.auth != withAccessToken {
+        return res, err
+    }
+
+    // The access token may have expired. Try again with a fresh one, as
+    // long as there is a refresh token to get it with.
+    if err := c.refreshAfter(ctx, accessToken); err != nil {
+        if errors.Is(err, errNoRefreshToken) {
+            return res, nil
+        }
+        res.Body.Close()
+        return nil, err
+    }
+    res.Body.Close()
+    accessToken, _ = c.Tokens()
+    return c.sendOnce(ctx, cl, body, accessToken)
+}
+
+func (c *Client) sendOnce(ctx context.Context, cl call, body []byte, accessToken string) (*http.Response, error) {
+    u := *c.baseURL
+    u.Path += cl.path
+    u.RawQuery = cl.query.Encode()
+
+    var r io.Reader
+    if body != nil {
+        r = bytes.NewReader(body)
+    }
+    req, err := http.NewRequestWithContext(ctx, cl.method, u.String(), r)
+    if err != nil {
+        return nil, fmt.Errorf("chirpyclient: %w", err)
+    }
+    req.Header.Set("User-Agent", userAgent)
+    req.Header.Set("Accept", "application/json")
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+
+    switch cl.auth {
+    case withAccessToken:
+        if accessToken != "" {
+            req.Header.Set("Authorization", "Bearer " + accessToken)
+        }
+    case withRefreshToken:
+        if _, refreshToken := c.Tokens(); refreshToken != "" {
+            req.Header.Set("Authorization", "Bearer " + refreshToken)
+        }
+    case withAPIKey:
+        req.Header.Set("Authorization", "ApiKey " + cl.apiKey)
+    }
+
+    res, err := c.httpClient.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("chirpyclient: %s %s: %w", cl.method, cl.path, err)
+    }
+    return res, nil
+}
+
+var errNoRefreshToken = errors.New("chirpyclient: no refresh token")
+
+// refreshAfter gets a new access token unless the one that was rejected,
+// stale, has already been replaced.
+func (c *Client) refreshAfter(ctx context.Context, stale string) error {
+    c.refreshMu.Lock()
+    defer c.refreshMu.Unlock()
+
+    accessToken, refreshToken := c.Tokens()
+    if accessToken != stale {
+        return nil
+    }
+    if refreshToken == "" {
+        return errNoRefreshToken
+    }
+    _, err := c.Refresh(ctx)
+    return err
+}
@@ -0,0 +1,110 @@
+package chirpyclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Sentinel errors that an *Error matches with errors.Is, by status code.
+var (
+    ErrBadRequest = errors.New("bad request")
+    ErrUnauthorized = errors.New("unauthorized")
+    ErrForbidden = errors.New("forbidden")
+    ErrNotFound = errors.New("not found")
+    ErrConflict = errors.New("conflict")
+    ErrRateLimited = errors.New("rate limited")
+    ErrServer = errors.New("server error")
+)
+
+// Error is an error response from the API. Branch on Code, which the API
+// never changes, rather than on Detail.
+type Error struct {
+    StatusCode int
+    Code string
+    Detail string
+    // Fields lists the invalid fields of a request that failed validation.
+    Fields []FieldError
+    RequestID string
+    TraceID string
+    // RetryAfter is how long to wait before retrying a rate limited request.
+    RetryAfter time.Duration
+}
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+    Field string `json:"field"`
+    Code string `json:"code"`
+    Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+    msg := fmt.Sprintf("chirpy: %d %s", e.StatusCode, e.Code)
+    if e.Detail != "" {
+        msg += ": " + e.Detail
+    }
+    for _, f := range e.Fields {
+        msg += "; " + f.Message
+    }
+    return msg
+}
+
+func (e *Error) Is(target error) bool {
+    switch target {
+    case ErrBadRequest:
+        return e.StatusCode == http.StatusBadRequest
+    case ErrUnauthorized:
+        return e.StatusCode == http.StatusUnauthorized
+    case ErrForbidden:
+        return e.StatusCode == http.StatusForbidden
+    case ErrNotFound:
+        return e.StatusCode == http.StatusNotFound
+    case ErrConflict:
+        return e.StatusCode == http.StatusConflict
+    case ErrRateLimited:
+        return e.StatusCode == http.StatusTooManyRequests
+    case ErrServer:
+        return e.StatusCode >= http.StatusInternalServerError
+    }
+    return false
+}
+
+// parseError reads an error response. Problem details are used when the body
+// has them; anything else, such as a proxy's error page, is summarised.
+func parseError(res *http.Response) error {
+    e := &Error{StatusCode: res.StatusCode, RequestID: res.Header.Get("X-Request-ID")}
+    if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+        e.RetryAfter = time.Duration(s) * time.Second
+    }
+
+    var problem struct {
+        Code string `json:"code"`
+        Detail string `json:"detail"`
+        Error string `json:"error"`
+        Errors []FieldError `json:"errors"`
+        RequestID string `json:"request_id"`
+        TraceID string `json:"trace_id"`
+    }
+    body, _ := io.ReadAll(io.LimitReader(res.Body, 64 << 10))
+    if err := json.Unmarshal(body, &problem); err != nil {
+        e.Code = "http_" + strconv.Itoa(res.StatusCode)
+        e.Detail = http.StatusText(res.StatusCode)
+        return e
+    }
+
+    e.Code = problem.Code
+    e.Detail = problem.Detail
+    if e.Detail == "" {
+        e.Detail = problem.Error
+    }
+    e.Fields = problem.Errors
+    e.TraceID = problem.TraceID
+    if problem.RequestID != "" {
+        e.RequestID = problem.RequestID
+    }
+    return e
+}
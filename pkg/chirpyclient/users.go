@@ -0,0 +1,106 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type User struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Email string `json:"email"`
+    IsChirpyRed bool `json:"is_chirpy_red"`
+    // Role is user, moderator or admin.
+    Role string `json:"role"`
+}
+
+// Session is a logged in user with their tokens.
+type Session struct {
+    User
+    Token string `json:"token"`
+    RefreshToken string `json:"refresh_token"`
+}
+
+type credentials struct {
+    Email string `json:"email"`
+    Password string `json:"password"`
+}
+
+// CreateUser signs up a new user. It doesn't log in.
+func (c *Client) CreateUser(ctx context.Context, email, password string) (User, error) {
+    var user User
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/api/users",
+        body: credentials{Email: email, Password: password},
+    }, &user)
+    return user, err
+}
+
+// UpdateUser changes the logged in user's email and password. If the password
+// is new, the API revokes every refresh token of the user, including the
+// client's, so the client keeps working only until its access token expires.
+func (c *Client) UpdateUser(ctx context.Context, email, password string) (User, error) {
+    var user User
+    _, err := c.do(ctx, call{
+        method: http.MethodPut,
+        path: "/api/users",
+        body: credentials{Email: email, Password: password},
+        auth: withAccessToken,
+    }, &user)
+    return user, err
+}
+
+// Login logs in and keeps the session's tokens for later requests.
+func (c *Client) Login(ctx context.Context, email, password string) (Session, error) {
+    var session Session
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/api/login",
+        body: credentials{Email: email, Password: password},
+    }, &session)
+    if err != nil {
+        return Session{}, err
+    }
+    c.SetTokens(session.Token, session.RefreshToken)
+    return session, nil
+}
+
+// Refresh trades the refresh token for a new access token, which it keeps and
+// returns. Requests do this by themselves when the access token expires.
+func (c *Client) Refresh(ctx context.Context) (string, error) {
+    var res struct {
+        Token string `json:"token"`
+    }
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/api/refresh",
+        auth: withRefreshToken,
+    }, &res)
+    if err != nil {
+        return "", err
+    }
+
+    c.mu.Lock()
+    c.accessToken = res.Token
+    c.mu.Unlock()
+    return res.Token, nil
+}
+
+// Revoke revokes the refresh token and forgets both tokens, logging out.
+func (c *Client) Revoke(ctx context.Context) error {
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/api/revoke",
+        auth: withRefreshToken,
+    }, nil)
+    if err != nil {
+        return err
+    }
+    c.SetTokens("", "")
+    return nil
+}
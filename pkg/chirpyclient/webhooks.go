@@ -0,0 +1,114 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Events a webhook can subscribe to.
+const (
+    EventChirpCreated = "chirp.created"
+    EventChirpDeleted = "chirp.deleted"
+    EventUserUpdated = "user.updated"
+)
+
+type Webhook struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Url string `json:"url"`
+    Events []string `json:"events"`
+    // Secret signs deliveries. It is only returned by CreateWebhook.
+    Secret string `json:"secret,omitempty"`
+    DisabledAt *time.Time `json:"disabled_at"`
+}
+
+type CreateWebhookParams struct {
+    // Url must be an absolute https URL.
+    Url string `json:"url"`
+    Events []string `json:"events"`
+    // Secret, if not empty, must be at least 16 characters. The API
+    // generates one otherwise.
+    Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+    Id uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    Event string `json:"event"`
+    Status string `json:"status"`
+    Attempts []WebhookAttempt `json:"attempts"`
+    DeliveredAt *time.Time `json:"delivered_at"`
+}
+
+type WebhookAttempt struct {
+    CreatedAt time.Time `json:"created_at"`
+    // ResponseCode is nil if the request failed before a response arrived.
+    ResponseCode *int `json:"response_code"`
+    Error string `json:"error,omitempty"`
+    DurationMs int `json:"duration_ms"`
+}
+
+// CreateWebhook registers a webhook for the logged in user.
+func (c *Client) CreateWebhook(ctx context.Context, params CreateWebhookParams) (Webhook, error) {
+    var hook Webhook
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/api/webhooks",
+        body: params,
+        auth: withAccessToken,
+    }, &hook)
+    return hook, err
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+    var hooks []Webhook
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/api/webhooks",
+        auth: withAccessToken,
+    }, &hooks)
+    return hooks, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+    _, err := c.do(ctx, call{
+        method: http.MethodDelete,
+        path: "/api/webhooks/" + id.String(),
+        auth: withAccessToken,
+    }, nil)
+    return err
+}
+
+// ListWebhookDeliveries returns the newest deliveries of a webhook.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id uuid.UUID) ([]WebhookDelivery, error) {
+    var deliveries []WebhookDelivery
+    _, err := c.do(ctx, call{
+        method: http.MethodGet,
+        path: "/api/webhooks/" + id.String() + "/deliveries",
+        auth: withAccessToken,
+    }, &deliveries)
+    return deliveries, err
+}
+
+// SendPolkaEvent delivers an event as Polka does, authenticated with the
+// shared API key. It is meant for testing integrations.
+func (c *Client) SendPolkaEvent(ctx context.Context, apiKey, event string, userId uuid.UUID) error {
+    type data struct {
+        UserId uuid.UUID `json:"user_id"`
+    }
+    _, err := c.do(ctx, call{
+        method: http.MethodPost,
+        path: "/api/polka/webhooks",
+        body: struct{
+            Event string `json:"event"`
+            Data data `json:"data"`
+        }{Event: event, Data: data{UserId: userId}},
+        auth: withAPIKey,
+        apiKey: apiKey,
+    }, nil)
+    return err
+}
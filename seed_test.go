@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/bamcmanus/Chirpy/internal/audit"
+	"github.com/bamcmanus/Chirpy/internal/database"
+	"github.com/bamcmanus/Chirpy/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeed(t *testing.T) {
+    ctx := context.Background()
+    m := store.NewMemory()
+    var stdout bytes.Buffer
+
+    err := seed(ctx, m, "dev", []string{"demo"}, &stdout)
+
+    require.NoError(t, err)
+    assert.Equal(t, "loaded demo: 4 users, 4 chirps and 3 refresh tokens\n", stdout.String())
+    chirps, err := m.ListChirps(ctx)
+    require.NoError(t, err)
+    assert.Len(t, chirps, 4)
+
+    t.Run("Resets without a dataset", func(t *testing.T) {
+        stdout.Reset()
+        require.NoError(t, seed(ctx, m, "dev", []string{"-reset"}, &stdout))
+
+        chirps, err := m.ListChirps(ctx)
+        require.NoError(t, err)
+        assert.Empty(t, chirps)
+        events, err := m.ListAuditEvents(ctx, database.ListAuditEventsParams{MaxRows: 10})
+        require.NoError(t, err)
+        require.Len(t, events, 2)
+        assert.Equal(t, audit.ActionAdminReset, events[0].Action)
+        assert.JSONEq(t, `{"source": "chirpy seed"}`, string(events[0].Metadata))
+        assert.JSONEq(t, `{"source": "chirpy seed", "dataset": "demo"}`, string(events[1].Metadata))
+    })
+
+    tests := []struct {
+        name string
+        platform string
+        args []string
+        want string
+    }{
+        {name: "Outside dev", platform: "prod", args: []string{"demo"}, want: "only allowed on the dev platform"},
+        {name: "Unknown datasets", platform: "dev", args: []string{"production"}, want: `unknown dataset "production"`},
+        {name: "No dataset", platform: "dev", args: nil, want: "usage: chirpy seed"},
+        {name: "A dataset with -reset", platform: "dev", args: []string{"-reset", "demo"}, want: "usage: chirpy seed"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name + " is refused", func(t *testing.T) {
+            m := store.NewMemory()
+            user, err := m.CreateUser(ctx, database.CreateUserParams{Email: "walt@example.com"})
+            require.NoError(t, err)
+
+            err = seed(ctx, m, tt.platform, tt.args, &bytes.Buffer{})
+
+            require.Error(t, err)
+            assert.Contains(t, err.Error(), tt.want)
+            _, err = m.GetUser(ctx, user.ID)
+            assert.NoError(t, err)
+        })
+    }
+}
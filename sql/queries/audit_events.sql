@@ -0,0 +1,27 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (id, created_at, action, actor_id, target_id, ip, user_agent, metadata)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+-- Each filter matches every event when empty or null. since is inclusive and
+-- until exclusive.
+SELECT *
+FROM audit_events
+WHERE (sqlc.arg(action)::text = '' OR action = sqlc.arg(action)::text)
+  AND (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id)::uuid)
+  AND (sqlc.narg(target_id)::uuid IS NULL OR target_id = sqlc.narg(target_id)::uuid)
+  AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since)::timestamp)
+  AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until)::timestamp)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_rows)
+OFFSET sqlc.arg(skip_rows);
@@ -20,6 +20,16 @@ FROM chirps
 WHERE user_id = $1
 ORDER BY created_at;
 
+-- name: ListChirpsPage :many
+-- author_id matches every chirp when null, and max_rows returns every chirp
+-- when null. Chirps are oldest first, or newest first when descending.
+SELECT *
+FROM chirps
+WHERE (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id)::uuid)
+ORDER BY CASE WHEN sqlc.arg(descending)::boolean THEN created_at END DESC, created_at, id
+LIMIT sqlc.narg(max_rows)
+OFFSET sqlc.arg(skip_rows);
+
 -- name: GetChirp :one
 SELECT *
 FROM Chirps
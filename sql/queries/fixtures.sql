@@ -0,0 +1,26 @@
+-- name: TruncateTables :exec
+TRUNCATE webhook_delivery_attempts, webhook_deliveries, webhooks, refresh_tokens, chirps, users, jobs, rate_limits;
+
+-- name: SeedUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, role)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: SeedChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
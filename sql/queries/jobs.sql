@@ -0,0 +1,84 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, run_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: ClaimDueJobs :many
+-- Claiming counts as an attempt and leases the job by pushing run_at into the
+-- future, so a job whose worker died is picked up again once the lease ends.
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    run_at = NOW() + INTERVAL '5 minutes',
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id
+    FROM jobs
+    WHERE status IN ('pending', 'running')
+      AND run_at <= NOW()
+    ORDER BY run_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded',
+    last_error = NULL,
+    finished_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: ScheduleJobRetry :exec
+UPDATE jobs
+SET status = 'pending',
+    run_at = $2,
+    last_error = $3,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead',
+    last_error = $2,
+    finished_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RequeueDeadJob :one
+UPDATE jobs
+SET status = 'pending',
+    attempts = 0,
+    run_at = NOW(),
+    finished_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND status = 'dead'
+RETURNING *;
+
+-- name: GetJob :one
+SELECT *
+FROM jobs
+WHERE id = $1;
+
+-- name: ListJobs :many
+SELECT *
+FROM jobs
+WHERE sqlc.arg(status)::text = ''
+   OR status = sqlc.arg(status)::text
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: CountJobsByStatus :many
+SELECT status, COUNT(*) AS count
+FROM jobs
+GROUP BY status
+ORDER BY status;
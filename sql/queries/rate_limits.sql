@@ -0,0 +1,26 @@
+-- name: TakeRateLimit :one
+-- Advances the key's tat by one emission interval, unless that would put it
+-- more than the burst window ahead of now. Returns no row when the request is
+-- over the limit.
+INSERT INTO rate_limits (key, tat)
+VALUES (sqlc.arg(key), sqlc.arg(now)::bigint + sqlc.arg(emission_interval)::bigint)
+ON CONFLICT (key) DO UPDATE
+SET tat = GREATEST(rate_limits.tat, sqlc.arg(now)::bigint) + sqlc.arg(emission_interval)::bigint
+WHERE GREATEST(rate_limits.tat, sqlc.arg(now)::bigint) + sqlc.arg(emission_interval)::bigint <= sqlc.arg(now)::bigint + sqlc.arg(window)::bigint
+RETURNING tat;
+
+-- name: GetRateLimit :one
+SELECT tat
+FROM rate_limits
+WHERE key = $1;
+
+-- name: DeleteExpiredRateLimits :execrows
+DELETE
+FROM rate_limits
+WHERE key IN (
+    SELECT key
+    FROM rate_limits
+    WHERE tat < sqlc.arg(now)::bigint
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
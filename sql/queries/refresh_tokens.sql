@@ -5,7 +5,7 @@ VALUES (
     NOW(),
     NOW(),
     $2,
-    NOW() + INTERVAL '60 days'
+    $3
 )
 RETURNING *;
 
@@ -20,3 +20,38 @@ SET updated_at = NOW(),
     revoked_at = NOW()
 WHERE token = $1
 RETURNING *;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(),
+    revoked_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE
+FROM refresh_tokens
+WHERE token IN (
+    SELECT token
+    FROM refresh_tokens
+    WHERE expires_at < NOW()
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+);
+
+-- name: DeleteRevokedRefreshTokens :execrows
+DELETE
+FROM refresh_tokens
+WHERE token IN (
+    SELECT token
+    FROM refresh_tokens
+    WHERE revoked_at < sqlc.arg(revoked_before)::timestamp
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
+
+-- name: ListUserRefreshTokens :many
+SELECT *
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,18 @@
+-- name: CreateRoleChange :one
+INSERT INTO role_changes (id, created_at, user_id, old_role, new_role, changed_by, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: ListRoleChanges :many
+SELECT *
+FROM role_changes
+WHERE user_id = $1
+ORDER BY created_at DESC;
@@ -24,3 +24,40 @@ SET email = $1,
     updated_at = NOW()
 WHERE id = $3
 RETURNING *;
+
+-- name: GetUser :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $1,
+    updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: SetUserDisabledAt :one
+UPDATE users
+SET disabled_at = $1,
+    updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: SetUserChirpyRed :one
+UPDATE users
+SET is_chirpy_red = $1,
+    updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $1,
+    updated_at = NOW()
+WHERE id = $2
+RETURNING *;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE webhook_deliveries
+ADD COLUMN trace_context TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE webhook_deliveries
+DROP COLUMN trace_context;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE INDEX refresh_tokens_expires_at_idx
+ON refresh_tokens (expires_at);
+
+CREATE INDEX refresh_tokens_revoked_at_idx
+ON refresh_tokens (revoked_at)
+WHERE revoked_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX refresh_tokens_revoked_at_idx;
+DROP INDEX refresh_tokens_expires_at_idx;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- A disabled user can't log in. Disabling a user also revokes their refresh
+-- tokens, so their sessions end when their access tokens expire.
+ALTER TABLE users
+ADD COLUMN disabled_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN disabled_at;
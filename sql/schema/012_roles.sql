@@ -0,0 +1,28 @@
+-- +goose Up
+-- A user's role decides what they may do beyond their own account; see
+-- auth.Role.
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- role_changes records every change of role. It is an audit log, so it has
+-- no foreign keys: entries outlive the users they mention. changed_by is
+-- null for changes made with chirpy admin, which acts as no user.
+CREATE TABLE role_changes (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    old_role TEXT NOT NULL,
+    new_role TEXT NOT NULL,
+    changed_by UUID,
+    reason TEXT NOT NULL
+);
+
+CREATE INDEX role_changes_user_id_idx
+ON role_changes (user_id, created_at);
+
+-- +goose Down
+DROP TABLE role_changes;
+
+ALTER TABLE users
+DROP COLUMN role;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- audit_events records privileged and security-relevant actions for
+-- compliance; see the audit package. Like role_changes it has no foreign
+-- keys, so entries outlive the users and chirps they mention, and the trigger
+-- below rejects any change to entries once they are written.
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL,
+    actor_id UUID,
+    target_id UUID,
+    ip TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    metadata JSONB NOT NULL
+);
+
+CREATE INDEX audit_events_created_at_idx
+ON audit_events (created_at);
+
+CREATE INDEX audit_events_actor_id_idx
+ON audit_events (actor_id, created_at);
+
+CREATE INDEX audit_events_target_id_idx
+ON audit_events (target_id, created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_event_change();
+
+-- +goose Down
+DROP TABLE audit_events;
+
+DROP FUNCTION reject_audit_event_change;
@@ -0,0 +1,27 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (id, created_at, action, actor_id, target_id, ip, user_agent, metadata)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(action),
+    sqlc.narg(actor_id),
+    sqlc.narg(target_id),
+    sqlc.arg(ip),
+    sqlc.arg(user_agent),
+    sqlc.arg(metadata)
+)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+-- Each filter matches every event when empty or null. since is inclusive and
+-- until exclusive.
+SELECT *
+FROM audit_events
+WHERE (sqlc.arg(action) = '' OR action = sqlc.arg(action))
+  AND (sqlc.narg(actor_id) IS NULL OR actor_id = sqlc.narg(actor_id))
+  AND (sqlc.narg(target_id) IS NULL OR target_id = sqlc.narg(target_id))
+  AND (sqlc.narg(since) IS NULL OR created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until) IS NULL OR created_at < sqlc.narg(until))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_rows)
+OFFSET sqlc.arg(skip_rows);
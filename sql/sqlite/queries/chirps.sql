@@ -0,0 +1,47 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(now),
+    sqlc.arg(body),
+    sqlc.arg(user_id)
+)
+RETURNING *;
+
+-- name: ListChirps :many
+SELECT *
+FROM chirps
+ORDER BY created_at;
+
+-- name: ListChirpsByUser :many
+SELECT *
+FROM chirps
+WHERE user_id = ?
+ORDER BY created_at;
+
+-- name: ListChirpsPage :many
+-- author_id matches every chirp when null, and max_rows returns every chirp
+-- when null. Chirps are oldest first, or newest first when descending.
+SELECT *
+FROM chirps
+WHERE (sqlc.narg(author_id) IS NULL OR user_id = sqlc.narg(author_id))
+ORDER BY CASE WHEN sqlc.arg(descending) THEN created_at END DESC, created_at, id
+LIMIT coalesce(sqlc.narg(max_rows), -1)
+OFFSET sqlc.arg(skip_rows);
+
+-- name: GetChirp :one
+SELECT *
+FROM chirps
+WHERE id = ?;
+
+-- name: DeleteChirp :exec
+DELETE
+FROM chirps
+WHERE id = ?;
+
+-- name: UpgradeUser :one
+UPDATE users
+SET is_chirpy_red = true
+WHERE id = ?
+RETURNING *;
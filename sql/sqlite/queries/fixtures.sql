@@ -0,0 +1,44 @@
+-- name: DeleteAllWebhookDeliveryAttempts :exec
+DELETE FROM webhook_delivery_attempts;
+
+-- name: DeleteAllWebhookDeliveries :exec
+DELETE FROM webhook_deliveries;
+
+-- name: DeleteAllWebhooks :exec
+DELETE FROM webhooks;
+
+-- name: DeleteAllRefreshTokens :exec
+DELETE FROM refresh_tokens;
+
+-- name: DeleteAllChirps :exec
+DELETE FROM chirps;
+
+-- name: DeleteAllJobs :exec
+DELETE FROM jobs;
+
+-- name: DeleteAllRateLimits :exec
+DELETE FROM rate_limits;
+
+-- name: SeedUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, role)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(created_at),
+    sqlc.arg(created_at),
+    sqlc.arg(email),
+    sqlc.arg(hashed_password),
+    sqlc.arg(is_chirpy_red),
+    sqlc.arg(role)
+)
+RETURNING *;
+
+-- name: SeedChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(created_at),
+    sqlc.arg(created_at),
+    sqlc.arg(body),
+    sqlc.arg(user_id)
+)
+RETURNING *;
@@ -0,0 +1,82 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, run_at)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(now),
+    sqlc.arg(kind),
+    sqlc.arg(payload),
+    sqlc.arg(run_at)
+)
+RETURNING *;
+
+-- name: ClaimDueJobs :many
+-- SQLite serialises writers, so the claim needs no row locks.
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    run_at = sqlc.arg(lease_until),
+    updated_at = sqlc.arg(now)
+WHERE id IN (
+    SELECT id
+    FROM jobs
+    WHERE status IN ('pending', 'running')
+      AND run_at <= sqlc.arg(now)
+    ORDER BY run_at
+    LIMIT sqlc.arg(limit)
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded',
+    last_error = NULL,
+    finished_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: ScheduleJobRetry :exec
+UPDATE jobs
+SET status = 'pending',
+    run_at = sqlc.arg(run_at),
+    last_error = sqlc.arg(last_error),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead',
+    last_error = sqlc.arg(last_error),
+    finished_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RequeueDeadJob :one
+UPDATE jobs
+SET status = 'pending',
+    attempts = 0,
+    run_at = sqlc.arg(now),
+    finished_at = NULL,
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+  AND status = 'dead'
+RETURNING *;
+
+-- name: GetJob :one
+SELECT *
+FROM jobs
+WHERE id = ?;
+
+-- name: ListJobs :many
+SELECT *
+FROM jobs
+WHERE sqlc.arg(status) = ''
+   OR status = sqlc.arg(status)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: CountJobsByStatus :many
+SELECT status, COUNT(*) AS count
+FROM jobs
+GROUP BY status
+ORDER BY status;
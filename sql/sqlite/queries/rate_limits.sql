@@ -0,0 +1,25 @@
+-- name: TakeRateLimit :one
+-- Advances the key's tat by one emission interval, unless that would put it
+-- more than the burst window ahead of now. Returns no row when the request is
+-- over the limit.
+INSERT INTO rate_limits (key, tat)
+VALUES (sqlc.arg(key), sqlc.arg(now) + sqlc.arg(emission_interval))
+ON CONFLICT (key) DO UPDATE
+SET tat = MAX(rate_limits.tat, sqlc.arg(now)) + sqlc.arg(emission_interval)
+WHERE MAX(rate_limits.tat, sqlc.arg(now)) + sqlc.arg(emission_interval) <= sqlc.arg(now) + sqlc.arg(window)
+RETURNING tat;
+
+-- name: GetRateLimit :one
+SELECT tat
+FROM rate_limits
+WHERE key = ?;
+
+-- name: DeleteExpiredRateLimits :execrows
+DELETE
+FROM rate_limits
+WHERE key IN (
+    SELECT key
+    FROM rate_limits
+    WHERE tat < sqlc.arg(now)
+    LIMIT sqlc.arg(batch_size)
+);
@@ -0,0 +1,55 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+VALUES (
+    sqlc.arg(token),
+    sqlc.arg(now),
+    sqlc.arg(now),
+    sqlc.arg(user_id),
+    sqlc.arg(expires_at)
+)
+RETURNING *;
+
+-- name: GetRefreshToken :one
+SELECT *
+FROM refresh_tokens
+WHERE token = ?;
+
+-- name: RevokeRefreshToken :one
+UPDATE refresh_tokens
+SET updated_at = sqlc.arg(now),
+    revoked_at = sqlc.arg(now)
+WHERE token = sqlc.arg(token)
+RETURNING *;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = sqlc.arg(now),
+    revoked_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+  AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE
+FROM refresh_tokens
+WHERE token IN (
+    SELECT token
+    FROM refresh_tokens
+    WHERE expires_at < sqlc.arg(now)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteRevokedRefreshTokens :execrows
+DELETE
+FROM refresh_tokens
+WHERE token IN (
+    SELECT token
+    FROM refresh_tokens
+    WHERE revoked_at < sqlc.arg(revoked_before)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: ListUserRefreshTokens :many
+SELECT *
+FROM refresh_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC;
@@ -0,0 +1,18 @@
+-- name: CreateRoleChange :one
+INSERT INTO role_changes (id, created_at, user_id, old_role, new_role, changed_by, reason)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(user_id),
+    sqlc.arg(old_role),
+    sqlc.arg(new_role),
+    sqlc.narg(changed_by),
+    sqlc.arg(reason)
+)
+RETURNING *;
+
+-- name: ListRoleChanges :many
+SELECT *
+FROM role_changes
+WHERE user_id = ?
+ORDER BY created_at DESC;
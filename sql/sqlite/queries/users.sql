@@ -0,0 +1,63 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(now),
+    sqlc.arg(email),
+    sqlc.arg(hashed_password)
+)
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT *
+FROM users
+WHERE email = ?;
+
+-- name: DeleteUsers :exec
+DELETE FROM users;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = sqlc.arg(email),
+    hashed_password = sqlc.arg(hashed_password),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetUser :one
+SELECT *
+FROM users
+WHERE id = ?;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = sqlc.arg(hashed_password),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: SetUserDisabledAt :one
+UPDATE users
+SET disabled_at = sqlc.narg(disabled_at),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: SetUserChirpyRed :one
+UPDATE users
+SET is_chirpy_red = sqlc.arg(is_chirpy_red),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = ?;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = sqlc.arg(role),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
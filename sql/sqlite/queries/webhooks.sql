@@ -0,0 +1,124 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(now),
+    sqlc.arg(user_id),
+    sqlc.arg(url),
+    sqlc.arg(secret),
+    sqlc.arg(events)
+)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT *
+FROM webhooks
+WHERE id = ?;
+
+-- name: ListWebhooksByUser :many
+SELECT *
+FROM webhooks
+WHERE user_id = ?
+ORDER BY created_at;
+
+-- name: ListActiveWebhooks :many
+-- events is a JSON array, so filtering by event happens in the store.
+SELECT *
+FROM webhooks
+WHERE disabled_at IS NULL
+ORDER BY created_at;
+
+-- name: DeleteWebhook :execrows
+DELETE
+FROM webhooks
+WHERE id = ?
+  AND user_id = ?;
+
+-- name: RecordWebhookSuccess :exec
+UPDATE webhooks
+SET consecutive_failures = 0,
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RecordWebhookFailure :one
+UPDATE webhooks
+SET consecutive_failures = consecutive_failures + 1,
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DisableWebhook :exec
+UPDATE webhooks
+SET disabled_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, event, payload, next_attempt_at, trace_context)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(now),
+    sqlc.arg(webhook_id),
+    sqlc.arg(event),
+    sqlc.arg(payload),
+    sqlc.arg(now),
+    sqlc.arg(trace_context)
+)
+RETURNING *;
+
+-- name: ClaimDueWebhookDeliveries :many
+-- SQLite serialises writers, so the claim needs no row locks.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until),
+    updated_at = sqlc.arg(now)
+WHERE id IN (
+    SELECT id
+    FROM webhook_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(limit)
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded',
+    attempts = attempts + 1,
+    delivered_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+    attempts = attempts + 1,
+    next_attempt_at = sqlc.arg(next_attempt_at),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE webhook_id = ?
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, created_at, delivery_id, response_code, error, duration_ms)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(now),
+    sqlc.arg(delivery_id),
+    sqlc.arg(response_code),
+    sqlc.arg(error),
+    sqlc.arg(duration_ms)
+);
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT *
+FROM webhook_delivery_attempts
+WHERE delivery_id = ?
+ORDER BY created_at;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX jobs_due_idx
+ON jobs (run_at)
+WHERE status IN ('pending', 'running');
+
+CREATE INDEX jobs_status_created_at_idx
+ON jobs (status, created_at);
+
+-- +goose Down
+DROP TABLE jobs;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- tat is the theoretical arrival time of the next request under the
+-- generic cell rate algorithm, in Unix microseconds. Keys whose tat is in
+-- the past are back to a full burst and can be deleted.
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    tat INTEGER NOT NULL
+);
+
+CREATE INDEX rate_limits_tat_idx
+ON rate_limits (tat);
+
+-- +goose Down
+DROP TABLE rate_limits;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- audit_events records privileged and security-relevant actions for
+-- compliance; see the audit package. Like role_changes it has no foreign
+-- keys, so entries outlive the users and chirps they mention, and the
+-- triggers below reject any change to entries once they are written.
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL,
+    actor_id UUID,
+    target_id UUID,
+    ip TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    metadata TEXT NOT NULL
+);
+
+CREATE INDEX audit_events_created_at_idx
+ON audit_events (created_at);
+
+CREATE INDEX audit_events_actor_id_idx
+ON audit_events (actor_id, created_at);
+
+CREATE INDEX audit_events_target_id_idx
+ON audit_events (target_id, created_at);
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_events_no_delete
+BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE audit_events;
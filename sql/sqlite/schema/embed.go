@@ -0,0 +1,8 @@
+// Package schema embeds the SQLite goose migrations. They mirror the
+// Postgres migrations in sql/schema version for version.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bamcmanus/Chirpy/internal/health"
+)
+
+// runWorker implements the worker subcommand, which runs the job and webhook
+// workers without the API. It takes the same configuration as the server and
+// serves only the probes on its address, and /metrics on the metrics address.
+func runWorker(args []string) {
+    a := newApp(args)
+
+    checker := health.New(a.cfg.Server.ReadinessTimeout)
+    checker.Add("database", health.Database(a.db))
+    checker.Add("migrations", a.migrator.Check)
+    stopWorkers := a.startWorkers(checker)
+
+    mux := http.NewServeMux()
+
+    mux.HandleFunc("GET /livez", checker.Livez)
+
+    mux.HandleFunc("GET /readyz", checker.Readyz)
+
+    a.run(a.middleware(mux, nil), checker, stopWorkers)
+}